@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/utils"
+)
+
+const (
+	metricsBytesPoolGets        = "bytes_pool_gets_total"
+	metricsBytesPoolHits        = "bytes_pool_hits_total"
+	metricsBytesPoolHitRate     = "bytes_pool_hit_rate"
+	metricsBytesPoolOutstanding = "bytes_pool_outstanding_num"
+)
+
+// bytesPoolMetrics updates the shared bytes pool metrics from the pool stats (on every metrics rotation)
+type bytesPoolMetrics struct {
+	pool    *utils.BytesPool
+	metrics *metrics.Metrics
+	last    utils.BytesPoolStats
+}
+
+func instrumentBytesPool(m *metrics.Metrics) {
+	pm := &bytesPoolMetrics{pool: utils.DefaultBytesPool(), metrics: m}
+	pm.last = pm.pool.Stats()
+
+	m.RegisterCounter(metricsBytesPoolGets, "The total number of buffers requested from the bytes pool")
+	m.RegisterCounter(metricsBytesPoolHits, "The total number of buffers reused from the bytes pool")
+	m.RegisterGauge(metricsBytesPoolHitRate, "The percentage of buffers reused from the bytes pool during the last interval")
+	m.RegisterGauge(metricsBytesPoolOutstanding, "The number of buffers taken from the bytes pool and not returned yet")
+
+	m.RegisterCollector(pm.collect)
+}
+
+func (pm *bytesPoolMetrics) collect() {
+	stats := pm.pool.Stats()
+
+	gets := stats.Gets - pm.last.Gets
+	hits := stats.Hits - pm.last.Hits
+
+	pm.metrics.Counter(metricsBytesPoolGets).Add(gets)
+	pm.metrics.Counter(metricsBytesPoolHits).Add(hits)
+	pm.metrics.Gauge(metricsBytesPoolOutstanding).Set(int(stats.Outstanding))
+
+	if gets > 0 {
+		pm.metrics.Gauge(metricsBytesPoolHitRate).Set64(hits * 100 / gets)
+	} else {
+		pm.metrics.Gauge(metricsBytesPoolHitRate).Set(0)
+	}
+
+	pm.last = stats
+}
@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -8,31 +9,103 @@ import (
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/anycable/anycable-go/config"
 	"github.com/anycable/anycable-go/metrics"
 	"github.com/anycable/anycable-go/mrb"
 	"github.com/anycable/anycable-go/node"
 	"github.com/anycable/anycable-go/pubsub"
+	"github.com/anycable/anycable-go/rpc"
 	"github.com/anycable/anycable-go/server"
+	"github.com/anycable/anycable-go/sse"
+	"github.com/anycable/anycable-go/standalone"
+	"github.com/anycable/anycable-go/tracing"
 	"github.com/anycable/anycable-go/utils"
 	"github.com/anycable/anycable-go/version"
+	"github.com/anycable/anycable-go/webhook"
 	"github.com/anycable/anycable-go/ws"
 	"github.com/apex/log"
 	"github.com/gorilla/websocket"
+	nanoid "github.com/matoous/go-nanoid"
 	"github.com/syossan27/tebata"
 )
 
+const (
+	metricsOriginRejected    = "ws_origin_rejected_total"
+	metricsRateLimited       = "ws_rate_limited_total"
+	metricsHeaderTooLarge    = "http_header_too_large_total"
+	metricsSlowStartRate     = "ws_slow_start_rate"
+	metricsSlowStartRejected = "ws_slow_start_rejected_total"
+)
+
+// Supported RPC implementations
+const (
+	rpcImplGRPC = "grpc"
+	rpcImplNone = "none"
+)
+
 type controllerFactory = func(*metrics.Metrics, *config.Config) (node.Controller, error)
 type disconnectorFactory = func(*node.Node, *config.Config) (node.Disconnector, error)
 type subscriberFactory = func(pubsub.Handler, *config.Config) (pubsub.Subscriber, error)
 type websocketHandler = func(*node.Node, *config.Config) (http.Handler, error)
+type configLoader = func() (config.Config, error)
 
 type Shutdownable interface {
 	Shutdown() error
 }
 
+// httpServersGroup shuts down HTTP servers concurrently and reports the results
+type httpServersGroup struct {
+	servers []*server.HTTPServer
+}
+
+func (g *httpServersGroup) Shutdown() error {
+	var wg sync.WaitGroup
+	var forced int32
+
+	for _, s := range g.servers {
+		wg.Add(1)
+
+		go func(s *server.HTTPServer) {
+			defer wg.Done()
+
+			if err := s.Shutdown(); err == context.DeadlineExceeded {
+				atomic.AddInt32(&forced, 1)
+			}
+		}(s)
+	}
+
+	wg.Wait()
+
+	log.WithField("context", "main").Infof(
+		"HTTP servers stopped: %d closed gracefully, %d closed forcefully",
+		len(g.servers)-int(forced), forced,
+	)
+
+	return nil
+}
+
+// Reloadable is a component which could be reloaded at runtime on SIGHUP
+type Reloadable interface {
+	Reload() error
+}
+
+type reloadableFunc func() error
+
+func (fn reloadableFunc) Reload() error {
+	return fn()
+}
+
+type shutdownableFunc func() error
+
+func (fn shutdownableFunc) Shutdown() error {
+	return fn()
+}
+
 type Runner struct {
 	name                string
 	config              *config.Config
@@ -41,8 +114,30 @@ type Runner struct {
 	subscriberFactory   subscriberFactory
 	websocketHandler    websocketHandler
 
+	sessionHooks           []SessionHook
+	sessionHookCloseCode   int
+	sessionHookCloseReason string
+	httpMiddlewares        []HTTPMiddleware
+	broadcastFilters       []node.BroadcastFilter
+	broadcastMiddlewares   []pubsub.Middleware
+	welcomeComposer        node.WelcomeComposer
+	mounts                 []*Mount
+
 	errChan       chan error
-	shutdownables []Shutdownable
+	shutdownHooks []*shutdownHook
+	shutdownMu    sync.Mutex
+	stopErr       error
+	reloadables   []Reloadable
+	httpServers   []*server.HTTPServer
+
+	// Whether to install OS signal handlers (SIGINT, SIGTERM and SIGHUP)
+	handleSignals bool
+	readiness     *readiness
+	stopOnce      sync.Once
+	stopped       chan struct{}
+
+	// Re-reads configuration on reload (only set when the config is built from CLI)
+	configLoader configLoader
 }
 
 func NewRunner(name string, config *config.Config) *Runner {
@@ -50,6 +145,8 @@ func NewRunner(name string, config *config.Config) *Runner {
 		name = "AnyCable"
 	}
 
+	var loader configLoader
+
 	if config == nil {
 		c, err := Config(os.Args[1:])
 
@@ -58,14 +155,38 @@ func NewRunner(name string, config *config.Config) *Runner {
 		}
 
 		config = &c
+		loader = reloadCLIConfig
 	}
 
 	// Set global HTTP params as early as possible to make sure all servers use them
 	server.SSL = &config.SSL
+	server.AccessLog = &config.AccessLog
 	server.Host = config.Host
 	server.MaxConn = config.MaxConn
+	server.SocketPermissions = config.SocketPermissions
+
+	if config.ShutdownTimeout > 0 {
+		server.ShutdownTimeout = time.Duration(config.ShutdownTimeout) * time.Second
+	}
+
+	server.ReadHeaderTimeout = time.Duration(config.ReadHeaderTimeout) * time.Second
+	server.HandshakeTimeout = time.Duration(config.HandshakeTimeout) * time.Second
+	server.MaxHeaderBytes = config.MaxHeaderBytes
+
+	return &Runner{
+		name:          name,
+		config:        config,
+		errChan:       make(chan error, 1),
+		configLoader:  loader,
+		handleSignals: true,
 
-	return &Runner{name: name, config: config, shutdownables: []Shutdownable{}, errChan: make(chan error)}
+		sessionHookCloseCode:   ws.CloseUnauthorized,
+		sessionHookCloseReason: defaultSessionHookCloseReason,
+	}
+}
+
+func reloadCLIConfig() (config.Config, error) {
+	return ReloadConfig(os.Args[1:])
 }
 
 func (r *Runner) ControllerFactory(fn controllerFactory) {
@@ -84,6 +205,18 @@ func (r *Runner) WebsocketHandler(fn websocketHandler) {
 	r.websocketHandler = fn
 }
 
+// DisableSignalHandlers prevents the runner from installing OS signal handlers
+// (useful when embedding into an application managing its own lifecycle)
+func (r *Runner) DisableSignalHandlers() {
+	r.handleSignals = false
+}
+
+// Errors returns a channel to receive asynchronous failures (e.g., RPC or pub/sub errors)
+// after the runner has been started
+func (r *Runner) Errors() <-chan error {
+	return r.errChan
+}
+
 func (r *Runner) Run() error {
 	if ShowVersion() {
 		fmt.Println(version.Version())
@@ -95,10 +228,61 @@ func (r *Runner) Run() error {
 		return nil
 	}
 
+	if PrintConfig() {
+		printEffectiveConfig(os.Stdout)
+		return nil
+	}
+
+	if ValidateConfig() {
+		if !printValidationReport(os.Stdout, r.config) {
+			return errors.New("Configuration validation failed")
+		}
+
+		return nil
+	}
+
+	if err := r.Start(context.Background()); err != nil {
+		return err
+	}
+
+	if r.handleSignals {
+		r.setupSignalHandlers()
+	}
+
+	// Wait for an error (or none)
+	err := <-r.errChan
+
+	if err != nil {
+		r.Stop(context.Background()) // nolint:errcheck
+	}
+
+	return err
+}
+
+// Start initializes all the components and starts servers.
+// Returns when all servers are listening (or the context is done).
+// The components started so far are stopped if any of them fails to start.
+func (r *Runner) Start(startCtx context.Context) error {
+	if err := r.start(startCtx); err != nil {
+		r.Stop(context.Background()) // nolint:errcheck
+		return err
+	}
+
+	return nil
+}
+
+func (r *Runner) start(startCtx context.Context) error {
 	config := r.config
+	startedAt := time.Now()
+
+	nodeID := resolveNodeID(config)
+	config.WS.NodeID = nodeID
+	config.Metrics.NodeID = nodeID
+
+	utils.SetStaticLogFields(log.Fields{"node_id": nodeID})
 
 	// init logging
-	err := utils.InitLogger(config.LogFormat, config.LogLevel)
+	err := utils.InitLogger(config.LogFormat, config.LogLevel, config.LogLevels)
 
 	if err != nil {
 		return fmt.Errorf("!!! Failed to initialize logger !!!\n%v", err)
@@ -110,9 +294,20 @@ func (r *Runner) Run() error {
 		ctx.Debug("🔧 🔧 🔧 Debug mode is on 🔧 🔧 🔧")
 	}
 
+	if err = validateConfig(config); err != nil {
+		return err
+	}
+
+	server.TrustedProxies, err = server.ParseCIDRs(config.TrustedProxies)
+
+	if err != nil {
+		return fmt.Errorf("!!! Failed to parse trusted proxies !!!\n%v", err)
+	}
+
 	mrubySupport := r.initMRuby()
 
 	ctx.Infof("Starting %s %s%s (pid: %d, open file limit: %s)", r.name, version.Version(), mrubySupport, os.Getpid(), utils.OpenFileLimit())
+	ctx.Infof("Node ID: %s", nodeID)
 
 	metrics, err := r.initMetrics(&config.Metrics)
 
@@ -120,7 +315,8 @@ func (r *Runner) Run() error {
 		return fmt.Errorf("!!! Failed to initialize metrics writer !!!\n%v", err)
 	}
 
-	r.shutdownables = append(r.shutdownables, metrics)
+	r.RegisterShutdownable(metrics, WithShutdownPhase(ShutdownPhaseFlushMetrics), WithShutdownName("metrics"))
+	r.reloadables = append(r.reloadables, metrics)
 
 	controller, err := r.initController(metrics, config)
 
@@ -128,13 +324,59 @@ func (r *Runner) Run() error {
 		return fmt.Errorf("!!! Failed to initialize controller !!!\n%v", err)
 	}
 
+	tracer, err := r.initTracing(metrics, config, nodeID)
+
+	if err != nil {
+		return fmt.Errorf("!!! Failed to initialize tracing !!!\n%v", err)
+	}
+
+	if tracer != nil {
+		controller = tracing.NewController(controller, tracer)
+	}
+
+	r.readiness = newReadiness(time.Duration(config.ReadyGracePeriod) * time.Second)
+	r.readiness.AddDependency("rpc", controller)
+
 	appNode := node.NewNode(controller, metrics, &config.App)
+	appNode.SetID(nodeID)
+	appNode.SetTracer(tracer)
+
+	if r.welcomeComposer != nil {
+		appNode.SetWelcomeComposer(r.welcomeComposer)
+	}
+
+	log.WithField("context", "main").Infof("RPC unavailable strategy: %s", config.App.RPCUnavailableStrategy)
+
+	if err = r.initEvents(appNode, metrics, config); err != nil {
+		return fmt.Errorf("!!! Failed to initialize session events !!!\n%v", err)
+	}
+
+	notifier, err := r.initWebhooks(appNode, controller, metrics, config, nodeID)
+
+	if err != nil {
+		return fmt.Errorf("!!! Failed to initialize webhooks !!!\n%v", err)
+	}
+
+	if err = r.initBroadcastFilters(appNode, config); err != nil {
+		return fmt.Errorf("!!! Failed to initialize broadcast filters !!!\n%v", err)
+	}
+
 	err = appNode.Start()
 
 	if err != nil {
 		return fmt.Errorf("!!! Failed to initialize application !!!\n%v", err)
 	}
 
+	r.RegisterShutdownable(appNode, WithShutdownPhase(ShutdownPhaseDrainSessions), WithShutdownName("node"))
+
+	// Go pools are created by the node
+	instrumentGoPools(metrics)
+	instrumentBytesPool(metrics)
+
+	if err = r.initMountControllers(appNode, metrics, config); err != nil {
+		return fmt.Errorf("!!! Failed to initialize mounts !!!\n%v", err)
+	}
+
 	disconnector, err := r.initDisconnector(appNode, config)
 
 	if err != nil {
@@ -144,67 +386,247 @@ func (r *Runner) Run() error {
 	go disconnector.Run() // nolint:errcheck
 	appNode.SetDisconnector(disconnector)
 
+	wsServer, err := server.ForPort(strconv.Itoa(config.Port))
+	if err != nil {
+		return fmt.Errorf("!!! Failed to initialize WebSocket server at %s:%s !!!\n%v", err, config.Host, config.Port)
+	}
+
+	// Must be configured before any server is started (e.g., HTTP broadcaster could use the same port)
+	if config.ProxyProtocol.Enabled {
+		optional, perr := server.ParseCIDRs(config.ProxyProtocol.OptionalCIDRs)
+
+		if perr != nil {
+			return fmt.Errorf("!!! Failed to configure PROXY protocol !!!\n%v", perr)
+		}
+
+		wsServer.EnableProxyProtocol(optional)
+	}
+
 	subscriber, err := r.initSubscriber(appNode, config)
 
 	if err != nil {
 		return fmt.Errorf("Couldn't configure pub/sub: %v", err)
 	}
 
-	r.shutdownables = append(r.shutdownables, subscriber)
+	r.RegisterShutdownable(subscriber, WithShutdownPhase(ShutdownPhaseStopAccepting), WithShutdownName("pub/sub"))
+	r.readiness.AddDependency("pubsub", subscriber)
+
+	if failover, ok := subscriber.(*pubsub.FailoverSubscriber); ok {
+		r.instrumentBroadcastAdapters(failover, metrics)
+	}
+
+	if notifier != nil {
+		watchBroadcastAdapters(notifier, subscriber, config)
+	}
 
 	go func() {
 		if subscribeErr := subscriber.Start(); subscribeErr != nil {
-			r.errChan <- fmt.Errorf("!!! Subscriber failed !!!\n%v", subscribeErr)
+			r.reportError(fmt.Errorf("!!! Subscriber failed !!!\n%v", subscribeErr))
 		}
 	}()
 
 	go func() {
 		if contrErr := controller.Start(); contrErr != nil {
-			r.errChan <- fmt.Errorf("!!! RPC failed !!!\n%v", contrErr)
+			r.reportError(fmt.Errorf("!!! RPC failed !!!\n%v", contrErr))
 		}
 	}()
 
-	wsServer, err := server.ForPort(strconv.Itoa(config.Port))
+	httpServers := []*server.HTTPServer{wsServer}
+
+	metricsServer := metrics.HTTPServer()
+
+	// Metrics server could be the same as the main one
+	if metricsServer != nil && metricsServer != wsServer {
+		httpServers = append(httpServers, metricsServer)
+	}
+
+	healthServer, err := r.healthServer(wsServer, metricsServer)
 	if err != nil {
-		return fmt.Errorf("!!! Failed to initialize WebSocket server at %s:%s !!!\n%v", err, config.Host, config.Port)
+		return fmt.Errorf("!!! Failed to initialize health server at %s:%d !!!\n%v", config.Host, config.HealthPort, err)
+	}
+
+	if healthServer != wsServer && healthServer != metricsServer {
+		httpServers = append(httpServers, healthServer)
+	}
+
+	r.RegisterShutdownable(&httpServersGroup{servers: httpServers[:1]}, WithShutdownPhase(ShutdownPhaseStopAccepting), WithShutdownName("WebSocket server"))
+
+	if len(httpServers) > 1 {
+		r.RegisterShutdownable(&httpServersGroup{servers: httpServers[1:]}, WithShutdownPhase(ShutdownPhaseCloseServers), WithShutdownName("HTTP servers"))
+	}
+
+	metrics.RegisterCounter(metricsOriginRejected, "The total number of WebSocket connections rejected due to the origin check")
+
+	config.WS.OnOriginRejected = func(_ string) {
+		metrics.Counter(metricsOriginRejected).Inc()
+		appNode.TrackHandshake(node.HandshakeOriginRejected, 1)
+	}
+
+	metrics.RegisterCounter(metricsRateLimited, "The total number of WebSocket connections rejected due to the rate limit")
+
+	config.WS.OnRateLimited = func(_ string) {
+		metrics.Counter(metricsRateLimited).Inc()
+		appNode.TrackHandshake(node.HandshakeRateLimited, 1)
 	}
 
-	r.shutdownables = append(r.shutdownables, wsServer)
+	config.WS.OnUpgradeFailed = func() {
+		appNode.TrackHandshake(node.HandshakeUpgradeFailed, 1)
+	}
+
+	instrumentHeaderLimit(metrics)
+	instrumentHandshakes(wsServer, appNode, metrics)
 
 	wsHandler, err := r.initWebSocketHandler(appNode, config)
 	if err != nil {
 		return fmt.Errorf("!!! Failed to initialize WebSocket handler !!!\n%v", err)
 	}
 
-	wsServer.Mux.Handle(config.Path, wsHandler)
+	var slowStart *ws.SlowStart
+
+	if config.WS.SlowStartDuration > 0 {
+		slowStart = r.initSlowStart(appNode, config, metrics)
+		wsHandler = ws.SlowStartHandler(wsHandler, slowStart, &config.WS)
+		ctx.Infof("Slow start is enabled for %ds (initial rate: %d/s, ramp: %s)", config.WS.SlowStartDuration, config.WS.SlowStartRate, config.WS.SlowStartRamp)
+	}
+
+	wsServer.Mux.Handle(config.Path, r.wrapHTTPHandler(wsHandler))
 
 	ctx.Infof("Handle WebSocket connections at %s%s", wsServer.Address(), config.Path)
 
-	wsServer.Mux.Handle(config.HealthPath, http.HandlerFunc(server.HealthHandler))
-	ctx.Infof("Handle health connections at %s%s", wsServer.Address(), config.HealthPath)
+	if config.SSE.Enabled() {
+		sseHandler := r.defaultSSEHandler(appNode, config)
+
+		// Active streams must be closed before the server shutdown (otherwise it waits for them to complete)
+		r.RegisterShutdownable(sseHandler, WithShutdownPhase(ShutdownPhaseStopAccepting), WithShutdownName("SSE streams"))
+
+		wsServer.Mux.Handle(config.SSE.Path, r.wrapHTTPHandler(sseAdmissionHandler(sseHandler, slowStart, &config.WS)))
+
+		ctx.Infof("Handle SSE connections at %s%s", wsServer.Address(), config.SSE.Path)
+	}
+
+	r.initMounts(appNode, wsServer, config)
+
+	healthServer.Mux.Handle(config.HealthPath, http.HandlerFunc(server.HealthHandler))
+	ctx.Infof("Handle health connections at %s%s", healthServer.Address(), config.HealthPath)
+
+	if config.ReadyPath != "" {
+		healthServer.Mux.Handle(config.ReadyPath, r.readiness)
+		ctx.Infof("Handle readiness checks at %s%s", healthServer.Address(), config.ReadyPath)
+	}
+
+	if config.InfoPath != "" {
+		infoHandler, ierr := r.infoHandler(metrics, startedAt)
+
+		if ierr != nil {
+			return fmt.Errorf("!!! Failed to initialize node info endpoint !!!\n%v", ierr)
+		}
+
+		healthServer.Mux.Handle(config.InfoPath, infoHandler)
+		ctx.Infof("Handle node info requests at %s%s", healthServer.Address(), config.InfoPath)
+	}
+
+	if config.DebugPath != "" {
+		healthServer.Mux.Handle(strings.TrimSuffix(config.DebugPath, "/")+"/", r.debugHandler(appNode))
+		ctx.Infof("Handle debug requests at %s%s", healthServer.Address(), config.DebugPath)
+	}
 
 	go func() {
-		if err = wsServer.StartAndAnnounce("WebSocket server"); err != nil {
+		if err := wsServer.StartAndAnnounce("WebSocket server"); err != nil {
 			if !wsServer.Stopped() {
-				r.errChan <- fmt.Errorf("WebSocket server at %s stopped: %v", wsServer.Address(), err)
+				r.reportError(fmt.Errorf("WebSocket server at %s stopped: %v", wsServer.Address(), err))
 			}
 		}
 	}()
 
+	if healthServer != wsServer {
+		go func() {
+			if err := healthServer.StartAndAnnounce("Health server"); err != nil {
+				if !healthServer.Stopped() {
+					r.reportError(fmt.Errorf("Health server at %s stopped: %v", healthServer.Address(), err))
+				}
+			}
+		}()
+	}
+
 	go func() {
 		if err := metrics.Run(); err != nil {
-			r.errChan <- fmt.Errorf("!!! Metrics module failed to start !!!\n%v", err)
+			r.reportError(fmt.Errorf("!!! Metrics module failed to start !!!\n%v", err))
 		}
 	}()
 
-	r.shutdownables = append(r.shutdownables, appNode)
-
 	r.announceGoPools()
 
-	r.setupSignalHandlers()
+	if r.configLoader != nil {
+		r.reloadables = append(r.reloadables, reloadableFunc(func() error {
+			return r.reloadSettings(appNode, disconnector)
+		}))
+	}
 
-	// Wait for an error (or none)
-	return <-r.errChan
+	if r.handleSignals {
+		r.setupReloadHandler()
+	}
+
+	r.httpServers = httpServers
+
+	if err = r.waitServersReady(startCtx); err != nil {
+		return err
+	}
+
+	notifier.Notify(webhook.EventNodeStarted, map[string]interface{}{"boot_duration_ms": time.Since(startedAt).Milliseconds()})
+
+	return nil
+}
+
+// Stop shuts down all the components (phase by phase, see RegisterShutdownable).
+// Returns ctx.Err() if shutdown hasn't completed before the context is done.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.stopOnce.Do(func() {
+		r.stopped = make(chan struct{})
+
+		// Make load balancers stop sending new connections as soon as possible
+		if r.readiness != nil {
+			r.readiness.Drain()
+		}
+
+		go func() {
+			defer close(r.stopped)
+
+			r.stopErr = r.runShutdownHooks(ctx)
+		}()
+	})
+
+	select {
+	case <-r.stopped:
+		return r.stopErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reportError passes an asynchronous failure (or nil to stop gracefully) to Run (or the Errors() channel).
+// Never blocks: only the first error is delivered, the following ones are logged.
+func (r *Runner) reportError(err error) {
+	select {
+	case r.errChan <- err:
+	default:
+		if err != nil {
+			log.WithField("context", "main").Errorf("%v", err)
+		}
+	}
+}
+
+func (r *Runner) waitServersReady(ctx context.Context) error {
+	for _, srv := range r.httpServers {
+		select {
+		case <-srv.Ready():
+		case err := <-r.errChan:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
 }
 
 func (r *Runner) initMetrics(c *metrics.Config) (*metrics.Metrics, error) {
@@ -219,12 +641,23 @@ func (r *Runner) initMetrics(c *metrics.Config) (*metrics.Metrics, error) {
 
 func (r *Runner) initController(m *metrics.Metrics, c *config.Config) (node.Controller, error) {
 	if r.controllerFactory == nil {
-		return nil, errors.New("Controller factory is not specified")
+		return r.defaultController(m, c)
 	}
 
 	return r.controllerFactory(m, c)
 }
 
+func (r *Runner) defaultController(m *metrics.Metrics, c *config.Config) (node.Controller, error) {
+	switch c.RPC.Implementation {
+	case rpcImplGRPC:
+		return rpc.NewController(m, &c.RPC), nil
+	case rpcImplNone:
+		return standalone.NewController(&c.Standalone), nil
+	}
+
+	return nil, fmt.Errorf("Unknown RPC implementation: %s", c.RPC.Implementation)
+}
+
 func (r *Runner) initDisconnector(n *node.Node, c *config.Config) (node.Disconnector, error) {
 	if r.disconnectorFactory == nil {
 		return r.defaultDisconnector(n, c)
@@ -234,11 +667,80 @@ func (r *Runner) initDisconnector(n *node.Node, c *config.Config) (node.Disconne
 }
 
 func (r *Runner) defaultDisconnector(n *node.Node, c *config.Config) (node.Disconnector, error) {
-	if c.DisconnectorDisabled {
+	// Disconnect calls are no-op in the standalone mode
+	if c.DisconnectorDisabled || (r.controllerFactory == nil && c.RPC.Implementation == rpcImplNone) {
 		return node.NewNoopDisconnector(), nil
-	} else {
-		return node.NewDisconnectQueue(n, &c.DisconnectQueue), nil
 	}
+
+	queue := node.NewDisconnectQueue(n, &c.DisconnectQueue)
+
+	if err := queue.Restore(); err != nil {
+		return nil, err
+	}
+
+	return queue, nil
+}
+
+func (r *Runner) initEvents(n *node.Node, m *metrics.Metrics, c *config.Config) error {
+	var sink node.EventsSink
+
+	switch c.App.EventsSink {
+	case "":
+		return nil
+	case node.EventsSinkLog:
+		sink = node.NewLogEventsSink()
+	case node.EventsSinkRedis:
+		publisher := pubsub.NewRedisPublisher(&c.Redis, c.App.EventsChannel)
+		r.RegisterShutdownable(publisher, WithShutdownPhase(ShutdownPhaseCloseServers), WithShutdownName("events publisher"))
+		sink = node.NewPublisherEventsSink(publisher)
+	default:
+		return fmt.Errorf("Unknown events sink: %s", c.App.EventsSink)
+	}
+
+	emitter := node.NewEventsEmitter(sink, c.App.EventsBufferSize, c.App.EventsRedact, m)
+	go emitter.Run()
+
+	n.SetEventsEmitter(emitter)
+	r.RegisterShutdownable(emitter, WithShutdownPhase(ShutdownPhaseFlushMetrics), WithShutdownName("events"))
+
+	log.WithField("context", "main").Infof("Session events are written to %s", c.App.EventsSink)
+
+	return nil
+}
+
+func (r *Runner) initTracing(m *metrics.Metrics, c *config.Config, nodeID string) (*tracing.Tracer, error) {
+	if !c.Tracing.Enabled() {
+		return nil, nil
+	}
+
+	tracer, err := tracing.NewTracer(&c.Tracing, m, nodeID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tracer.Run()
+	r.RegisterShutdownable(tracer, WithShutdownPhase(ShutdownPhaseFlushMetrics), WithShutdownName("tracing"))
+
+	return tracer, nil
+}
+
+func (r *Runner) initBroadcastFilters(n *node.Node, c *config.Config) error {
+	if pattern := c.App.AllowedBroadcastStreams; pattern != "" {
+		f, err := node.NewStreamPatternFilter(pattern)
+
+		if err != nil {
+			return err
+		}
+
+		n.AddBroadcastFilter(f)
+	}
+
+	for _, f := range r.broadcastFilters {
+		n.AddBroadcastFilter(f)
+	}
+
+	return nil
 }
 
 func (r *Runner) initSubscriber(n *node.Node, c *config.Config) (pubsub.Subscriber, error) {
@@ -246,7 +748,34 @@ func (r *Runner) initSubscriber(n *node.Node, c *config.Config) (pubsub.Subscrib
 		return nil, errors.New("Subscriber factory is not specified")
 	}
 
-	return r.subscriberFactory(n, c)
+	// Every adapter passes messages through the middlewares (the node deduplicates them later)
+	handler := pubsub.NewMiddlewareHandler(n, r.broadcastMiddlewares)
+
+	primary, err := r.subscriberFactory(handler, c)
+
+	if err != nil || c.SecondaryAdapter == "" {
+		return primary, err
+	}
+
+	// The secondary subscriber is built by the same factory with the adapter replaced
+	secondaryConfig := *c
+	secondaryConfig.BroadcastAdapter = c.SecondaryAdapter
+
+	secondary, err := r.subscriberFactory(handler, &secondaryConfig)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Messages could be delivered via both adapters
+	n.EnableBroadcastDedup()
+
+	log.WithField("context", "main").Infof("Using %s as a secondary broadcast adapter", c.SecondaryAdapter)
+
+	return pubsub.NewFailoverSubscriber(
+		&pubsub.NamedSubscriber{Name: c.BroadcastAdapter, Subscriber: primary},
+		&pubsub.NamedSubscriber{Name: c.SecondaryAdapter, Subscriber: secondary},
+	), nil
 }
 
 func (r *Runner) initWebSocketHandler(n *node.Node, c *config.Config) (http.Handler, error) {
@@ -259,16 +788,65 @@ func (r *Runner) initWebSocketHandler(n *node.Node, c *config.Config) (http.Hand
 
 func (r *Runner) defaultWebSocketHandler(n *node.Node, c *config.Config) http.Handler {
 	return ws.WebsocketHandler(c.Headers, &c.WS, func(wsc *websocket.Conn, info *ws.RequestInfo, callback func()) error {
-		wrappedConn := ws.NewConnection(wsc)
-		session := node.NewSession(n, wrappedConn, info.Url, info.Headers, info.UID)
+		wrappedConn := ws.NewConnectionWithConfig(wsc, &c.WS)
+		return r.serveSession(n, wrappedConn, info, callback)
+	})
+}
 
-		_, err := n.Authenticate(session)
+func (r *Runner) defaultSSEHandler(n *node.Node, c *config.Config) *sse.Handler {
+	return sse.NewHandler(c.Headers, &c.WS, &c.SSE, func(conn *sse.Connection, info *ws.RequestInfo, callback func()) error {
+		return r.serveSession(n, conn, info, callback)
+	})
+}
 
-		if err != nil {
-			return err
+// serveSession authenticates a new session and starts reading its messages (the same way for all transports)
+func (r *Runner) serveSession(n *node.Node, conn node.Connection, info *ws.RequestInfo, callback func()) error {
+	return r.serveMountSession(n, nil, conn, info, callback)
+}
+
+// serveMountSession serves the session for the mount (nil – the main endpoint)
+func (r *Runner) serveMountSession(n *node.Node, mount *Mount, conn node.Connection, info *ws.RequestInfo, callback func()) error {
+	session := node.NewSession(n, conn, info.Url, info.Headers, info.UID)
+	session.SetProtocolVersion(info.Protocol)
+	session.RemoteIP = info.RemoteIP
+	session.AcceptedAt = info.AcceptedAt
+
+	var clients *metrics.Gauge
+
+	if len(r.mounts) > 0 {
+		name := defaultMountName
+
+		if mount != nil {
+			name = mount.Name
 		}
 
+		n.Metrics.CounterVec(metricsMountSessions).With(name).Inc()
+		clients = n.Metrics.Gauge(mountClientsGauge(name))
+	}
+
+	if mount != nil && !mount.configureSession(session, info, r) {
+		return nil
+	}
+
+	if !r.runSessionHooks(session, info) {
+		return nil
+	}
+
+	_, err := n.Authenticate(session)
+
+	if err != nil {
+		return err
+	}
+
+	if clients == nil {
 		return session.Serve(callback)
+	}
+
+	clients.Inc()
+
+	return session.Serve(func() {
+		clients.Dec()
+		callback()
 	})
 }
 
@@ -286,6 +864,23 @@ func (r *Runner) initMRuby() string {
 	return ""
 }
 
+// healthServer returns the server to mount the health endpoint to:
+// the main one by default, or a dedicated one when health_port is set
+// (sharing the listener with the metrics server if the ports match)
+func (r *Runner) healthServer(wsServer *server.HTTPServer, metricsServer *server.HTTPServer) (*server.HTTPServer, error) {
+	port := r.config.HealthPort
+
+	if port == 0 || port == r.config.Port {
+		return wsServer, nil
+	}
+
+	if metricsServer != nil && port == r.config.Metrics.Port {
+		return metricsServer, nil
+	}
+
+	return server.ForPort(strconv.Itoa(port))
+}
+
 func (r *Runner) announceGoPools() {
 	configs := make([]string, 0)
 	pools := utils.AllPools()
@@ -297,6 +892,115 @@ func (r *Runner) announceGoPools() {
 	log.WithField("context", "main").Debugf("Go pools initialized (%s)", strings.Join(configs, ", "))
 }
 
+// instrumentHeaderLimit tracks HTTP requests rejected due to the headers size limit (shared by all servers)
+func instrumentHeaderLimit(m *metrics.Metrics) {
+	m.RegisterCounter(metricsHeaderTooLarge, "The total number of HTTP requests rejected due to the headers size limit")
+
+	last := server.HeaderLimitExceeded()
+
+	m.RegisterCollector(func() {
+		current := server.HeaderLimitExceeded()
+		m.Counter(metricsHeaderTooLarge).Add(current - last)
+		last = current
+	})
+}
+
+// instrumentHandshakes reports the connection attempts tracked by the WebSocket (and SSE) server
+// (accepted connections, failed TLS handshakes and upgrade requests to unknown paths).
+// Other servers (e.g., metrics or HTTP broadcaster at different ports) are not tracked.
+func instrumentHandshakes(srv *server.HTTPServer, n *node.Node, m *metrics.Metrics) {
+	last := srv.HandshakeStats()
+
+	m.RegisterCollector(func() {
+		current := srv.HandshakeStats()
+
+		n.TrackHandshake(node.HandshakeAccepted, current.Accepted-last.Accepted)
+		n.TrackHandshake(node.HandshakeTLSFailed, current.TLSFailed-last.TLSFailed)
+		n.TrackHandshake(node.HandshakeNotFound, current.NotFound-last.NotFound)
+
+		last = current
+	})
+}
+
+// instrumentBroadcastAdapters reports the health of every broadcast adapter via metrics and the readiness endpoint
+// (the node stays ready while at least one of the adapters is ready)
+func (r *Runner) instrumentBroadcastAdapters(s *pubsub.FailoverSubscriber, m *metrics.Metrics) {
+	for _, adapter := range s.Adapters() {
+		adapter := adapter
+		gauge := fmt.Sprintf("pubsub_%s_healthy", adapter.Name)
+
+		m.RegisterGauge(gauge, fmt.Sprintf("Whether the %s broadcast adapter is healthy (1) or not (0)", adapter.Name))
+
+		m.RegisterCollector(func() {
+			if adapter.Healthy() == nil {
+				m.Gauge(gauge).Set(1)
+			} else {
+				m.Gauge(gauge).Set(0)
+			}
+		})
+
+		r.readiness.AddOptionalDependency("pubsub:"+adapter.Name, readinessFunc(adapter.Healthy))
+	}
+}
+
+// initSlowStart creates the boot-time admission control shared by WebSocket and SSE connections
+// (readiness is not affected, so load balancers keep routing clients to the node)
+func (r *Runner) initSlowStart(n *node.Node, c *config.Config, m *metrics.Metrics) *ws.SlowStart {
+	slowStart := ws.NewSlowStart(&c.WS)
+
+	m.RegisterGauge(metricsSlowStartRate, "The current WebSocket connections admission rate during the slow start (0 – unlimited)")
+	m.RegisterCounter(metricsSlowStartRejected, "The total number of WebSocket connections rejected during the slow start")
+
+	m.RegisterCollector(func() {
+		m.Gauge(metricsSlowStartRate).Set(int(slowStart.Rate()))
+	})
+
+	c.WS.OnSlowStartRejected = func() {
+		m.Counter(metricsSlowStartRejected).Inc()
+		n.TrackHandshake(node.HandshakeSlowStart, 1)
+	}
+
+	return slowStart
+}
+
+// sseAdmissionHandler applies the same admission control as for WebSocket connections (per-IP rate limiting
+// and slow start) to the SSE stream requests, since each new stream is authenticated via RPC.
+// Commands (POST requests) are sent within the established sessions and are not limited.
+func sseAdmissionHandler(handler http.Handler, slowStart *ws.SlowStart, c *ws.Config) http.Handler {
+	admitted := ws.RateLimitHandler(handler, ws.NewConnectionsRateLimiter(c), c)
+
+	if slowStart != nil {
+		admitted = ws.SlowStartHandler(admitted, slowStart, c)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			admitted.ServeHTTP(w, r)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func (r *Runner) setupReloadHandler() {
+	reloadSig := make(chan os.Signal, 1)
+	signal.Notify(reloadSig, syscall.SIGHUP)
+
+	go func() {
+		for range reloadSig {
+			ctx := log.WithField("context", "main")
+			ctx.Info("Reloading...")
+
+			for _, reloadable := range r.reloadables {
+				if err := reloadable.Reload(); err != nil {
+					ctx.Errorf("Reload failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
 func (r *Runner) setupSignalHandlers() {
 	t := tebata.New(syscall.SIGINT, syscall.SIGTERM)
 
@@ -307,13 +1011,27 @@ func (r *Runner) setupSignalHandlers() {
 			signal.Notify(termSig, syscall.SIGINT, syscall.SIGTERM)
 			<-termSig
 			log.Warnf("Immediate termination requested. Stopped")
-			r.errChan <- nil
+			r.reportError(nil)
 		}()
 	})
 
-	for _, shutdownable := range r.shutdownables {
-		t.Reserve(shutdownable.Shutdown) // nolint:errcheck
+	t.Reserve(func() { // nolint:errcheck
+		r.Stop(context.Background()) // nolint:errcheck
+		r.reportError(nil)
+	})
+}
+
+// resolveNodeID returns the configured node identifier, the hostname or a random identifier (in that order)
+func resolveNodeID(c *config.Config) string {
+	if id := c.WS.NodeIdentifier(); id != "" {
+		return id
+	}
+
+	id, err := nanoid.Nanoid()
+
+	if err != nil {
+		return "unknown"
 	}
 
-	t.Reserve(func() { r.errChan <- nil }) // nolint:errcheck
+	return id
 }
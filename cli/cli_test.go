@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/config"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/pubsub"
+	"github.com/anycable/anycable-go/server"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/stretchr/testify/assert"
+)
+
+type testSubscriber struct {
+	stopped bool
+}
+
+func (s *testSubscriber) Start() error {
+	return nil
+}
+
+func (s *testSubscriber) Shutdown() error {
+	s.stopped = true
+	return nil
+}
+
+func TestRunnerStartStop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anycable.sock")
+
+	c := validTestConfig()
+	c.Host = server.UnixSocketPrefix + path
+	c.DisconnectorDisabled = true
+	c.Path = "/cable"
+	c.HealthPath = "/health"
+	c.ReadyPath = "/ready"
+
+	subscriber := &testSubscriber{}
+
+	runner := NewRunner("test", &c)
+	runner.DisableSignalHandlers()
+
+	runner.ControllerFactory(func(_ *metrics.Metrics, _ *config.Config) (node.Controller, error) {
+		controller := mocks.NewMockController()
+		return &controller, nil
+	})
+
+	runner.SubscriberFactory(func(_ pubsub.Handler, _ *config.Config) (pubsub.Subscriber, error) {
+		return subscriber, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.Nil(t, runner.Start(ctx))
+
+	// The server must be listening when Start returns
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", path)
+			},
+		},
+	}
+
+	res, err := client.Get("http://unix/health")
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+
+	res, err = client.Get("http://unix/ready")
+
+	if assert.Nil(t, err) {
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		res.Body.Close()
+	}
+
+	assert.Nil(t, runner.Stop(ctx))
+	assert.True(t, subscriber.stopped)
+
+	// Stop is idempotent
+	assert.Nil(t, runner.Stop(ctx))
+
+	select {
+	case err := <-runner.Errors():
+		t.Errorf("Unexpected error: %v", err)
+	default:
+	}
+}
+
+func TestRunnerStartFailure(t *testing.T) {
+	c := validTestConfig()
+	c.Host = server.UnixSocketPrefix + filepath.Join(t.TempDir(), "anycable.sock")
+	// Servers are shared by port, so we must use a different one
+	c.Port = 8092
+	c.DisconnectorDisabled = true
+
+	subscriber := &testSubscriber{}
+
+	runner := NewRunner("test", &c)
+	runner.DisableSignalHandlers()
+
+	runner.ControllerFactory(func(_ *metrics.Metrics, _ *config.Config) (node.Controller, error) {
+		controller := mocks.NewMockController()
+		return &controller, nil
+	})
+
+	runner.SubscriberFactory(func(_ pubsub.Handler, _ *config.Config) (pubsub.Subscriber, error) {
+		return subscriber, nil
+	})
+
+	runner.WebsocketHandler(func(_ *node.Node, _ *config.Config) (http.Handler, error) {
+		return nil, errors.New("handler failed")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NotNil(t, runner.Start(ctx))
+	// Components started before the failure must be stopped
+	assert.True(t, subscriber.stopped)
+}
+
+func TestRunnerReportError(t *testing.T) {
+	c := validTestConfig()
+	runner := NewRunner("test", &c)
+
+	// Never blocks, only the first error is delivered
+	runner.reportError(errors.New("first"))
+	runner.reportError(errors.New("second"))
+	runner.reportError(nil)
+
+	err := <-runner.Errors()
+	assert.Equal(t, "first", err.Error())
+
+	select {
+	case err := <-runner.Errors():
+		t.Errorf("Unexpected error: %v", err)
+	default:
+	}
+}
+
+func TestSSEAdmissionHandler(t *testing.T) {
+	c := config.New()
+	c.WS.RateLimit = 1
+	c.WS.RateLimitBurst = 1
+	c.WS.SlowStartDuration = 10
+	c.WS.SlowStartRate = 100
+
+	limited := 0
+	c.WS.OnRateLimited = func(_ string) { limited++ }
+
+	handler := sseAdmissionHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), ws.NewSlowStart(&c.WS), &c.WS)
+
+	serve := func(method string) int {
+		req := httptest.NewRequest(method, "/events", nil)
+		req.RemoteAddr = "10.0.0.1:4321"
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodGet))
+	assert.Equal(t, http.StatusTooManyRequests, serve(http.MethodGet))
+	assert.Equal(t, 1, limited)
+
+	// Commands are not limited
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost))
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost))
+}
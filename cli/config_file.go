@@ -0,0 +1,212 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/namsral/flag"
+	"gopkg.in/yaml.v3"
+)
+
+// Options which couldn't be set via config file
+var cliOnlyOptions = map[string]bool{
+	"config":          true,
+	"print-config":    true,
+	"validate-config": true,
+	"h":               true,
+	"v":               true,
+}
+
+// Env vars used as defaults for some options (they must take precedence over the config file)
+var envFallbacks = map[string]string{
+	"port":      "PORT",
+	"redis_url": "REDIS_URL",
+}
+
+// Options containing sensitive data
+var secretOptions = []string{"secret", "token", "password", "otlp_headers"}
+
+const redacted = "[REDACTED]"
+
+// Supported config file extensions (files without extension are parsed as YAML, too)
+var configFileExtensions = map[string]bool{
+	"":      true,
+	".yml":  true,
+	".yaml": true,
+}
+
+// loadConfigFile reads the YAML configuration file and sets the options which
+// haven't been provided via CLI or env (so the precedence is flags > env > file > defaults).
+// Keys are option names; nested keys are joined with underscores (e.g., `redis: { url: ... }` is the same as `redis_url`).
+// Returns the names of options set from the file.
+func loadConfigFile(fs *flag.FlagSet, path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	if ext := strings.ToLower(filepath.Ext(path)); !configFileExtensions[ext] {
+		return nil, fmt.Errorf("Unsupported config file format: %s (only YAML files are supported: .yml, .yaml)", path)
+	}
+
+	data, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read config file: %v", err)
+	}
+
+	raw := make(map[string]interface{})
+
+	if err = yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("Failed to parse config file %s: %v", path, err)
+	}
+
+	values := make(map[string]string)
+	flattenConfig("", raw, values)
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	loaded := []string{}
+
+	for _, key := range keys {
+		name := lookupOption(fs, key)
+
+		if name == "" || cliOnlyOptions[name] {
+			fmt.Fprintf(os.Stderr, "WARNING: Unknown option in %s: %s\n", path, key)
+			continue
+		}
+
+		if set[name] {
+			continue
+		}
+
+		if env, ok := envFallbacks[name]; ok && os.Getenv(env) != "" {
+			continue
+		}
+
+		if err := fs.Set(name, values[key]); err != nil {
+			return nil, fmt.Errorf("Invalid value for %s in config file %s: %v", key, path, err)
+		}
+
+		loaded = append(loaded, name)
+	}
+
+	return loaded, nil
+}
+
+func flattenConfig(prefix string, raw map[string]interface{}, values map[string]string) {
+	for key, val := range raw {
+		name := key
+
+		if prefix != "" {
+			name = prefix + "_" + key
+		}
+
+		switch v := val.(type) {
+		case nil:
+			continue
+		case map[string]interface{}:
+			flattenConfig(name, v, values)
+		case []interface{}:
+			items := make([]string, len(v))
+
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+
+			values[name] = strings.Join(items, ",")
+		default:
+			values[name] = fmt.Sprint(v)
+		}
+	}
+}
+
+// lookupOption returns the option name for the config key
+// (options could use either hyphens or underscores)
+func lookupOption(fs *flag.FlagSet, key string) string {
+	candidates := []string{
+		key,
+		strings.Replace(key, "_", "-", -1),
+		strings.Replace(key, "-", "_", -1),
+	}
+
+	for _, name := range candidates {
+		if fs.Lookup(name) != nil {
+			return name
+		}
+	}
+
+	return ""
+}
+
+// argsContain returns true if the option is present in the command line args
+func argsContain(args []string, name string) bool {
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		arg = strings.TrimLeft(arg, "-")
+
+		if i := strings.Index(arg, "="); i >= 0 {
+			arg = arg[:i]
+		}
+
+		if arg == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// printEffectiveConfig prints all options values (in the config file format)
+// along with their sources
+func printEffectiveConfig(w io.Writer) {
+	fs.VisitAll(func(f *flag.Flag) {
+		if cliOnlyOptions[f.Name] {
+			return
+		}
+
+		source, ok := sources[f.Name]
+
+		if !ok {
+			source = "default"
+		}
+
+		fmt.Fprintf(w, "%s: %q # %s\n", f.Name, redactOption(f.Name, f.Value.String()), source)
+	})
+}
+
+func redactOption(name string, value string) string {
+	if value == "" {
+		return value
+	}
+
+	for _, secret := range secretOptions {
+		if strings.Contains(name, secret) {
+			return redacted
+		}
+	}
+
+	// Hide passwords in URLs
+	if strings.Contains(value, "://") {
+		if u, err := url.Parse(value); err == nil {
+			return u.Redacted()
+		}
+	}
+
+	return value
+}
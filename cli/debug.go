@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/node"
+	"github.com/apex/log"
+)
+
+// The max number of session UIDs to include into the stream info
+const debugStreamSampleSize = 10
+
+// debugHandler returns an HTTP handler serving the sessions and streams state as JSON:
+//   - <debug_api_path>/sessions/<uid>
+//   - <debug_api_path>/streams/<name>
+//
+// The handler is protected with the debug API token.
+func (r *Runner) debugHandler(n *node.Node) http.Handler {
+	prefix := strings.TrimSuffix(r.config.DebugPath, "/") + "/"
+	redact := r.config.DebugRedact
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, prefix), "/", 2)
+
+		if len(parts) != 2 || parts[1] == "" {
+			http.NotFound(w, req)
+			return
+		}
+
+		var res interface{}
+
+		switch parts[0] {
+		case "sessions":
+			info := n.SessionInfo(parts[1])
+
+			if info == nil {
+				http.Error(w, "Session not found", http.StatusNotFound)
+				return
+			}
+
+			if redact {
+				info.Identifiers = node.RedactIdentifiers(info.Identifiers)
+			}
+
+			res = info
+		case "streams":
+			info := n.StreamInfo(parts[1], debugStreamSampleSize)
+
+			if info == nil {
+				http.Error(w, "Stream not found", http.StatusNotFound)
+				return
+			}
+
+			res = info
+		default:
+			http.NotFound(w, req)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			log.WithField("context", "main").Errorf("Failed to write debug info: %v", err)
+		}
+	})
+
+	return metrics.TokenAuthHandler(handler, r.config.DebugToken)
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugHandler(t *testing.T) {
+	c := validTestConfig()
+	c.DebugPath = "/debug"
+	c.DebugToken = "t0k3n"
+
+	appNode := node.NewNode(nil, metrics.NewMetrics(nil, 10), &c.App)
+	runner := &Runner{name: "test", config: &c}
+
+	handler := runner.debugHandler(appNode)
+
+	get := func(path string, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("Without token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, get("/debug/sessions/abc", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, get("/debug/sessions/abc", "secret").Code)
+	})
+
+	t.Run("Unknown session", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/debug/sessions/abc", "t0k3n").Code)
+	})
+
+	t.Run("Unknown stream", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/debug/streams/chat_42", "t0k3n").Code)
+	})
+
+	t.Run("Unknown resource", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/debug/channels/chat", "t0k3n").Code)
+		assert.Equal(t, http.StatusNotFound, get("/debug/sessions/", "t0k3n").Code)
+	})
+
+	t.Run("Non-GET request", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/debug/sessions/abc", nil)
+		req.Header.Set("Authorization", "Bearer t0k3n")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
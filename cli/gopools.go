@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/utils"
+)
+
+// goPoolMetrics updates Go pool metrics from the pool stats (on every metrics rotation or Prometheus scrape)
+type goPoolMetrics struct {
+	pool    *utils.GoPool
+	metrics *metrics.Metrics
+	last    utils.GoPoolStats
+
+	size      string
+	queued    string
+	processed string
+	saturated string
+	waitAvg   string
+	execAvg   string
+}
+
+func instrumentGoPools(m *metrics.Metrics) {
+	for _, pool := range utils.AllPools() {
+		prefix := fmt.Sprintf("gopool_%s_", pool.Name())
+
+		pm := &goPoolMetrics{
+			pool:      pool,
+			metrics:   m,
+			last:      pool.Stats(),
+			size:      prefix + "size",
+			queued:    prefix + "queued_num",
+			processed: prefix + "processed_total",
+			saturated: prefix + "saturated_total",
+			waitAvg:   prefix + "wait_avg_us",
+			execAvg:   prefix + "exec_avg_us",
+		}
+
+		m.RegisterGauge(pm.size, fmt.Sprintf("The max number of workers in the %s pool", pool.Name()))
+		m.RegisterGauge(pm.queued, fmt.Sprintf("The number of tasks waiting in the %s pool queue", pool.Name()))
+		m.RegisterCounter(pm.processed, fmt.Sprintf("The total number of tasks executed by the %s pool", pool.Name()))
+		m.RegisterCounter(pm.saturated, fmt.Sprintf("The total number of times the %s pool had no free workers and queue slots", pool.Name()))
+		m.RegisterGauge(pm.waitAvg, fmt.Sprintf("The average time tasks spent in the %s pool queue since the previous collection (μs)", pool.Name()))
+		m.RegisterGauge(pm.execAvg, fmt.Sprintf("The average %s pool task execution time since the previous collection (μs)", pool.Name()))
+
+		m.RegisterCollector(pm.collect)
+	}
+}
+
+func (pm *goPoolMetrics) collect() {
+	stats := pm.pool.Stats()
+
+	processed := stats.Processed - pm.last.Processed
+
+	pm.metrics.Gauge(pm.size).Set(pm.pool.Size())
+	pm.metrics.Gauge(pm.queued).Set(stats.Queued)
+	pm.metrics.Counter(pm.processed).Add(processed)
+	pm.metrics.Counter(pm.saturated).Add(stats.Saturated - pm.last.Saturated)
+
+	// Timings are measured for sampled tasks only
+	sampled := stats.Sampled - pm.last.Sampled
+
+	if sampled > 0 {
+		pm.metrics.Gauge(pm.waitAvg).Set64(uint64((stats.WaitTime - pm.last.WaitTime).Microseconds()) / sampled)
+		pm.metrics.Gauge(pm.execAvg).Set64(uint64((stats.ExecTime - pm.last.ExecTime).Microseconds()) / sampled)
+	} else {
+		pm.metrics.Gauge(pm.waitAvg).Set(0)
+		pm.metrics.Gauge(pm.execAvg).Set(0)
+	}
+
+	pm.last = stats
+}
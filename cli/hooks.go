@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"net/http"
+
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/pubsub"
+	"github.com/anycable/anycable-go/ws"
+)
+
+// SessionHook is called for every new session before authentication.
+// Returning an error closes the connection.
+type SessionHook func(*node.Session, *ws.RequestInfo) error
+
+// HTTPMiddleware wraps the WebSocket handler
+type HTTPMiddleware func(http.Handler) http.Handler
+
+const defaultSessionHookCloseReason = "unauthorized"
+
+// UseSessionHook adds a hook to the default WebSocket handler (hooks are called in the order of registration).
+// Hooks are ignored if a custom handler is provided via WebsocketHandler.
+func (r *Runner) UseSessionHook(fn SessionHook) {
+	r.sessionHooks = append(r.sessionHooks, fn)
+}
+
+// UseHTTPMiddleware adds a middleware around the WebSocket handler
+// (the first registered middleware is the outermost one)
+func (r *Runner) UseHTTPMiddleware(fn HTTPMiddleware) {
+	r.httpMiddlewares = append(r.httpMiddlewares, fn)
+}
+
+// UseBroadcastFilter adds a filter for incoming broadcasts: messages rejected by any of the filters are dropped
+// (filters are called in the order of registration after the built-in allowed streams check)
+func (r *Runner) UseBroadcastFilter(f node.BroadcastFilter) {
+	r.broadcastFilters = append(r.broadcastFilters, f)
+}
+
+// UseBroadcastMiddleware adds a middleware intercepting raw broadcast messages before they're handled by the node
+// (the first registered middleware is the outermost one). Middlewares are shared by all broadcast adapters
+// and called before deduplication, so with a secondary adapter the same message passes through them once per adapter.
+func (r *Runner) UseBroadcastMiddleware(fn pubsub.Middleware) {
+	r.broadcastMiddlewares = append(r.broadcastMiddlewares, fn)
+}
+
+// WelcomeComposer sets the function returning extra fields to add to the welcome messages
+// (merged over the static --welcome_extra fields; the fields of the welcome message itself are never overridden)
+func (r *Runner) WelcomeComposer(fn func(*node.Session) map[string]interface{}) {
+	r.welcomeComposer = fn
+}
+
+// SessionHookCloseCode sets the close code and reason used when a session hook returns an error
+// (default: 4001 "unauthorized")
+func (r *Runner) SessionHookCloseCode(code int, reason string) {
+	r.sessionHookCloseCode = code
+	r.sessionHookCloseReason = reason
+}
+
+func (r *Runner) runSessionHooks(session *node.Session, info *ws.RequestInfo) bool {
+	for _, hook := range r.sessionHooks {
+		if err := hook(session, info); err != nil {
+			session.Log.Debugf("Session rejected by hook: %v", err)
+			session.Disconnect(r.sessionHookCloseReason, r.sessionHookCloseCode)
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Runner) wrapHTTPHandler(handler http.Handler) http.Handler {
+	for i := len(r.httpMiddlewares) - 1; i >= 0; i-- {
+		handler = r.httpMiddlewares[i](handler)
+	}
+
+	return handler
+}
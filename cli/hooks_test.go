@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/config"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/pubsub"
+	"github.com/anycable/anycable-go/server"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionHooksAndMiddlewares(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anycable.sock")
+
+	c := validTestConfig()
+	c.Host = server.UnixSocketPrefix + path
+	// Servers are registered by port, use a unique one to not clash with other tests
+	c.Port = 18081
+	c.DisconnectorDisabled = true
+	c.Path = "/cable"
+	c.HealthPath = "/health"
+
+	runner := NewRunner("test", &c)
+	runner.DisableSignalHandlers()
+
+	runner.ControllerFactory(func(_ *metrics.Metrics, _ *config.Config) (node.Controller, error) {
+		controller := mocks.NewMockController()
+		return &controller, nil
+	})
+
+	runner.SubscriberFactory(func(_ pubsub.Handler, _ *config.Config) (pubsub.Subscriber, error) {
+		return &testSubscriber{}, nil
+	})
+
+	calls := []string{}
+
+	runner.UseHTTPMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, "outer")
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	runner.UseHTTPMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, "inner")
+
+			if r.Header.Get("X-Tenant") == "" {
+				http.Error(w, "Tenant is missing", http.StatusBadRequest)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	tenants := make(chan string, 1)
+
+	runner.UseSessionHook(func(s *node.Session, info *ws.RequestInfo) error {
+		tenants <- info.Url
+		return nil
+	})
+
+	runner.UseSessionHook(func(s *node.Session, info *ws.RequestInfo) error {
+		return errors.New("Tenant is disabled")
+	})
+
+	runner.SessionHookCloseCode(4003, "tenant_disabled")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.Nil(t, runner.Start(ctx))
+	defer runner.Stop(ctx) // nolint:errcheck
+
+	dialer := websocket.Dialer{
+		NetDial: func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}
+
+	_, res, err := dialer.Dial("ws://tenant.example.com/cable", nil)
+
+	assert.NotNil(t, err)
+	if assert.NotNil(t, res) {
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	}
+	assert.Equal(t, []string{"outer", "inner"}, calls)
+
+	conn, _, err := dialer.Dial("ws://tenant.example.com/cable", http.Header{"X-Tenant": {"acme"}})
+
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	defer conn.Close()
+
+	assert.Equal(t, "http://tenant.example.com/cable", <-tenants)
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second)) // nolint:errcheck
+
+	_, _, err = conn.ReadMessage()
+
+	if closeErr, ok := err.(*websocket.CloseError); assert.True(t, ok, "Expected close error, got: %v", err) {
+		assert.Equal(t, 4003, closeErr.Code)
+		assert.Equal(t, "tenant_disabled", closeErr.Text)
+	}
+}
+
+func TestUseBroadcastMiddleware(t *testing.T) {
+	c := validTestConfig()
+
+	runner := NewRunner("test", &c)
+
+	var handler pubsub.Handler
+
+	runner.SubscriberFactory(func(h pubsub.Handler, _ *config.Config) (pubsub.Subscriber, error) {
+		handler = h
+		return &testSubscriber{}, nil
+	})
+
+	appNode := node.NewNode(nil, metrics.NewMetrics(nil, 10), &c.App)
+
+	_, err := runner.initSubscriber(appNode, &c)
+	assert.Nil(t, err)
+	assert.Same(t, appNode, handler)
+
+	dropped := 0
+
+	runner.UseBroadcastMiddleware(func(next pubsub.Handler) pubsub.Handler {
+		return pubsub.HandlerFunc(func(msg []byte) {
+			dropped++
+		})
+	})
+
+	_, err = runner.initSubscriber(appNode, &c)
+	assert.Nil(t, err)
+
+	handler.(pubsub.OriginHandler).HandlePubSubFrom(pubsub.HTTPOrigin, []byte("{\"stream\":\"any\",\"data\":\"1\"}"))
+
+	assert.Equal(t, 1, dropped)
+}
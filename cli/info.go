@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mrb"
+	"github.com/anycable/anycable-go/version"
+	"github.com/apex/log"
+)
+
+// Node gauges included into the info response
+const (
+	infoClientsGauge = "clients_num"
+	infoStreamsGauge = "broadcast_streams_num"
+)
+
+// nodeInfo is the node info endpoint response.
+// NOTE: it must never contain secrets (tokens, keys, passwords, URLs with credentials)
+type nodeInfo struct {
+	Name      string          `json:"name"`
+	NodeID    string          `json:"node_id"`
+	Version   string          `json:"version"`
+	SHA       string          `json:"sha,omitempty"`
+	GoVersion string          `json:"go_version"`
+	Platform  string          `json:"platform"`
+	StartedAt int64           `json:"started_at"`
+	Uptime    int64           `json:"uptime"`
+	Features  map[string]bool `json:"features"`
+	RPC       string          `json:"rpc"`
+	Broadcast string          `json:"broadcast_adapter"`
+	Clients   uint64          `json:"clients_num"`
+	Streams   uint64          `json:"streams_num"`
+}
+
+// infoHandler returns an HTTP handler serving the node info as JSON
+// (protected the same way as the metrics endpoint)
+func (r *Runner) infoHandler(m *metrics.Metrics, startedAt time.Time) (http.Handler, error) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(r.nodeInfo(m, startedAt)); err != nil {
+			log.WithField("context", "main").Errorf("Failed to write node info: %v", err)
+		}
+	})
+
+	return metrics.AuthHandler(handler, &r.config.Metrics)
+}
+
+func (r *Runner) nodeInfo(m *metrics.Metrics, startedAt time.Time) *nodeInfo {
+	info := &nodeInfo{
+		Name:      r.name,
+		NodeID:    r.config.WS.NodeIdentifier(),
+		Version:   version.Version(),
+		SHA:       version.SHA(),
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		StartedAt: startedAt.Unix(),
+		Uptime:    int64(time.Since(startedAt).Seconds()),
+		// Features not included into this build are always disabled
+		Features: map[string]bool{
+			"mruby":   mrb.Supported(),
+			"netpoll": false,
+			"jwt":     false,
+			"apollo":  false,
+			"wspc":    false,
+		},
+		RPC:       r.config.RPC.Implementation,
+		Broadcast: r.config.BroadcastAdapter,
+	}
+
+	if r.controllerFactory != nil {
+		info.RPC = "custom"
+	}
+
+	if r.subscriberFactory != nil {
+		info.Broadcast = "custom"
+	}
+
+	if g := m.Gauge(infoClientsGauge); g != nil {
+		info.Clients = g.Value()
+	}
+
+	if g := m.Gauge(infoStreamsGauge); g != nil {
+		info.Streams = g.Value()
+	}
+
+	return info
+}
@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/anycable/anycable-go/config"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/server"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/apex/log"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	metricsMountSessions = "mount_sessions_total"
+
+	// The name of the main WebSocket endpoint (used as the metrics label)
+	defaultMountName = "default"
+)
+
+// Mount describes an additional WebSocket endpoint served by the same node:
+// the hub, metrics and broadcasting are shared with the main endpoint,
+// while the controller, WebSocket settings and session hooks could differ
+type Mount struct {
+	// Mount name (used in logs and as the metrics label)
+	Name string
+	// WebSocket endpoint path
+	Path string
+	// Creates a controller for the mount sessions (the main controller is used if nil)
+	ControllerFactory controllerFactory
+	// WebSocket settings (the main ones are used if nil)
+	WS *ws.Config
+	// Ping interval in seconds (0 – the node ping interval is used)
+	PingInterval int
+	// Session hooks called before the global ones (e.g., to identify clients without RPC)
+	SessionHooks []SessionHook
+
+	controller node.Controller
+	wsConfig   ws.Config
+}
+
+// AddMount registers an additional WebSocket endpoint (must be called before Start)
+func (r *Runner) AddMount(m *Mount) {
+	r.mounts = append(r.mounts, m)
+}
+
+// initMountControllers creates controllers for the registered mounts and registers them within the node
+// (must be called before the pending disconnects are restored, so they're performed via the mount controllers)
+func (r *Runner) initMountControllers(n *node.Node, m *metrics.Metrics, c *config.Config) error {
+	if len(r.mounts) == 0 {
+		return nil
+	}
+
+	if err := validateMounts(r.mounts, c.Path); err != nil {
+		return err
+	}
+
+	m.RegisterCounterVec(metricsMountSessions, "The total number of sessions per mount", "mount")
+	m.RegisterGauge(mountClientsGauge(defaultMountName), "The number of active clients of the main endpoint")
+
+	for _, mount := range r.mounts {
+		m.RegisterGauge(mountClientsGauge(mount.Name), fmt.Sprintf("The number of active clients of the %s mount", mount.Name))
+
+		if mount.ControllerFactory == nil {
+			continue
+		}
+
+		controller, err := mount.ControllerFactory(m, c)
+
+		if err != nil {
+			return fmt.Errorf("Failed to initialize controller for the %s mount: %v", mount.Name, err)
+		}
+
+		mount.controller = controller
+		n.RegisterController(mount.Name, controller)
+
+		name := mount.Name
+		r.readiness.AddDependency(name+" controller", controller)
+		r.RegisterShutdownable(controller, WithShutdownPhase(ShutdownPhaseStopRPC), WithShutdownName(name+" controller"))
+
+		go func() {
+			if err := controller.Start(); err != nil {
+				r.reportError(fmt.Errorf("!!! Controller for the %s mount failed !!!\n%v", name, err))
+			}
+		}()
+	}
+
+	return nil
+}
+
+// initMounts mounts the registered mounts handlers to the server
+func (r *Runner) initMounts(n *node.Node, srv *server.HTTPServer, c *config.Config) {
+	ctx := log.WithField("context", "main")
+
+	for _, mount := range r.mounts {
+		mount.wsConfig = c.WS
+
+		if mount.WS != nil {
+			mount.wsConfig = *mount.WS
+			// Keep instrumentation callbacks
+			mount.wsConfig.OnOriginRejected = c.WS.OnOriginRejected
+			mount.wsConfig.OnRateLimited = c.WS.OnRateLimited
+			mount.wsConfig.OnUpgradeFailed = c.WS.OnUpgradeFailed
+
+			if mount.wsConfig.NodeID == "" {
+				mount.wsConfig.NodeID = c.WS.NodeID
+			}
+		}
+
+		srv.Mux.Handle(mount.Path, r.wrapHTTPHandler(r.mountWebSocketHandler(n, mount, c.Headers)))
+
+		ctx.Infof("Handle WebSocket connections for the %s mount at %s%s", mount.Name, srv.Address(), mount.Path)
+	}
+}
+
+func (r *Runner) mountWebSocketHandler(n *node.Node, mount *Mount, headers []string) http.Handler {
+	return ws.WebsocketHandler(headers, &mount.wsConfig, func(wsc *websocket.Conn, info *ws.RequestInfo, callback func()) error {
+		wrappedConn := ws.NewConnectionWithConfig(wsc, &mount.wsConfig)
+		return r.serveMountSession(n, mount, wrappedConn, info, callback)
+	})
+}
+
+// configureSession applies the mount settings to the session.
+// Returns false if the session has been rejected by the mount hooks.
+func (mount *Mount) configureSession(session *node.Session, info *ws.RequestInfo, r *Runner) bool {
+	if mount.controller != nil {
+		session.UseController(mount.Name)
+	}
+
+	if mount.PingInterval > 0 {
+		session.SetPingInterval(time.Duration(mount.PingInterval) * time.Second)
+	}
+
+	for _, hook := range mount.SessionHooks {
+		if err := hook(session, info); err != nil {
+			session.Log.Debugf("Session rejected by %s mount hook: %v", mount.Name, err)
+			session.Disconnect(r.sessionHookCloseReason, r.sessionHookCloseCode)
+			return false
+		}
+	}
+
+	return true
+}
+
+// mountClientsGauge returns the name of the gauge tracking the number of the mount active clients
+func mountClientsGauge(name string) string {
+	return fmt.Sprintf("mount_%s_clients_num", name)
+}
+
+func validateMounts(mounts []*Mount, mainPath string) error {
+	names := map[string]bool{defaultMountName: true}
+	paths := map[string]bool{mainPath: true}
+
+	for _, mount := range mounts {
+		if mount.Name == "" || mount.Path == "" {
+			return errors.New("Mount name and path must be specified")
+		}
+
+		if names[mount.Name] {
+			return fmt.Errorf("Duplicate mount name: %s", mount.Name)
+		}
+
+		if paths[mount.Path] {
+			return fmt.Errorf("Duplicate mount path: %s", mount.Path)
+		}
+
+		names[mount.Name] = true
+		paths[mount.Path] = true
+	}
+
+	return nil
+}
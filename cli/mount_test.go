@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/config"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/pubsub"
+	"github.com/anycable/anycable-go/server"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+type mountTestController struct {
+	mocks.MockController
+	name          string
+	authenticated chan string
+}
+
+func (c *mountTestController) Authenticate(sid string, env *common.SessionEnv) (*common.ConnectResult, error) {
+	c.authenticated <- c.name
+	return c.MockController.Authenticate(sid, env)
+}
+
+func TestRunnerMounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anycable.sock")
+
+	c := validTestConfig()
+	c.Host = server.UnixSocketPrefix + path
+	// Servers are registered by port, use a unique one to not clash with other tests
+	c.Port = 18082
+	c.DisconnectorDisabled = true
+	c.Path = "/cable"
+	c.HealthPath = "/health"
+
+	authenticated := make(chan string, 2)
+	hooked := make(chan *ws.RequestInfo, 1)
+
+	runner := NewRunner("test", &c)
+	runner.DisableSignalHandlers()
+
+	var m *metrics.Metrics
+
+	runner.ControllerFactory(func(mm *metrics.Metrics, _ *config.Config) (node.Controller, error) {
+		m = mm
+		return &mountTestController{MockController: mocks.NewMockController(), name: "main", authenticated: authenticated}, nil
+	})
+
+	runner.SubscriberFactory(func(_ pubsub.Handler, _ *config.Config) (pubsub.Subscriber, error) {
+		return &testSubscriber{}, nil
+	})
+
+	runner.AddMount(&Mount{
+		Name: "embed",
+		Path: "/embed/cable",
+		ControllerFactory: func(_ *metrics.Metrics, _ *config.Config) (node.Controller, error) {
+			return &mountTestController{MockController: mocks.NewMockController(), name: "embed", authenticated: authenticated}, nil
+		},
+		PingInterval: 1,
+		SessionHooks: []SessionHook{func(_ *node.Session, info *ws.RequestInfo) error {
+			hooked <- info
+			return nil
+		}},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.Nil(t, runner.Start(ctx))
+	defer runner.Stop(ctx) // nolint:errcheck
+
+	dialer := websocket.Dialer{
+		NetDial: func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		},
+	}
+
+	for _, tc := range []struct{ path, controller string }{{"/embed/cable", "embed"}, {"/cable", "main"}} {
+		conn, _, err := dialer.Dial("ws://unix"+tc.path, nil)
+
+		if !assert.Nil(t, err) {
+			continue
+		}
+
+		select {
+		case name := <-authenticated:
+			assert.Equal(t, tc.controller, name)
+		case <-time.After(time.Second):
+			t.Errorf("Session at %s hasn't been authenticated", tc.path)
+		}
+
+		gauge := m.Gauge(mountClientsGauge(defaultMountName))
+
+		if tc.controller == "embed" {
+			gauge = m.Gauge(mountClientsGauge("embed"))
+		}
+
+		assert.Eventually(t, func() bool { return gauge.Value() == 1 }, time.Second, 10*time.Millisecond)
+
+		conn.Close()
+
+		assert.Eventually(t, func() bool { return gauge.Value() == 0 }, time.Second, 10*time.Millisecond)
+	}
+
+	select {
+	case info := <-hooked:
+		assert.Contains(t, info.Url, "/embed/cable")
+	default:
+		t.Error("Mount session hook hasn't been called")
+	}
+}
+
+func TestValidateMounts(t *testing.T) {
+	assert.Nil(t, validateMounts([]*Mount{{Name: "embed", Path: "/embed/cable"}}, "/cable"))
+
+	assert.NotNil(t, validateMounts([]*Mount{{Name: "embed"}}, "/cable"))
+	assert.NotNil(t, validateMounts([]*Mount{{Name: "default", Path: "/embed/cable"}}, "/cable"))
+	assert.NotNil(t, validateMounts([]*Mount{{Name: "embed", Path: "/cable"}}, "/cable"))
+	assert.NotNil(t, validateMounts([]*Mount{{Name: "a", Path: "/a"}, {Name: "a", Path: "/b"}}, "/cable"))
+}
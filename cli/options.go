@@ -2,31 +2,61 @@ package cli
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/anycable/anycable-go/config"
+	"github.com/anycable/anycable-go/ws"
 	"github.com/namsral/flag"
 )
 
+// cliOptions contains values of options which are not stored in config.Config directly
+type cliOptions struct {
+	headers    string
+	debugMode  bool
+	configPath string
+	// Deprecated max_message_size value (-1 if not set)
+	maxMessageSize int64
+}
+
 var (
-	// Config represents the CLI config loaded from options and ENV
+	// Config represents the CLI config loaded from options, ENV and config file
 	defaults    config.Config
-	headers     string
+	opts        cliOptions
 	showVersion bool
 	showHelp    bool
-	debugMode   bool
+	printConfig bool
+	validate    bool
 	fs          *flag.FlagSet
+	// Where the option values came from (flag, env or file)
+	sources map[string]string
 )
 
 func init() {
+	// Disable the built-in key-value config files support (we load YAML files ourselves)
+	flag.DefaultConfigFlagname = ""
+
 	// Configure namespaced flagSet with errorHandling set to ExitOnError (=1)
 	fs = flag.NewFlagSetWithEnvPrefix(os.Args[0], "ANYCABLE", 1)
 	fs.Usage = PrintHelp
 
 	defaults = config.New()
 
+	bindFlags(fs, &defaults, &opts)
+
+	// CLI vars
+	fs.BoolVar(&showHelp, "h", false, "")
+	fs.BoolVar(&showVersion, "v", false, "")
+	fs.BoolVar(&printConfig, "print-config", false, "")
+	fs.BoolVar(&validate, "validate-config", false, "")
+}
+
+// bindFlags defines config flags writing values to the provided config
+func bindFlags(fs *flag.FlagSet, defaults *config.Config, opts *cliOptions) {
+	fs.StringVar(&opts.configPath, "config", "", "")
+
 	// Fetch
 	portDefault := 8080
 	port := os.Getenv("PORT")
@@ -47,13 +77,36 @@ func init() {
 	fs.StringVar(&defaults.Host, "host", "localhost", "")
 	fs.IntVar(&defaults.Port, "port", portDefault, "")
 	fs.IntVar(&defaults.MaxConn, "max-conn", 0, "")
+	fs.StringVar(&defaults.SocketPermissions, "socket_permissions", "", "")
+	fs.IntVar(&defaults.ShutdownTimeout, "shutdown_timeout", 30, "")
+	fs.IntVar(&defaults.ReadHeaderTimeout, "server_read_header_timeout", 10, "")
+	fs.IntVar(&defaults.HandshakeTimeout, "server_handshake_timeout", 30, "")
+	fs.IntVar(&defaults.MaxHeaderBytes, "server_max_header_bytes", 65536, "")
+	fs.StringVar(&defaults.TrustedProxies, "trusted_proxies", "", "")
+	fs.BoolVar(&defaults.ProxyProtocol.Enabled, "proxy_protocol", false, "")
+	fs.StringVar(&defaults.ProxyProtocol.OptionalCIDRs, "proxy_protocol_optional_cidrs", "", "")
 	fs.StringVar(&defaults.Path, "path", "/cable", "")
 	fs.StringVar(&defaults.HealthPath, "health-path", "/health", "")
+	fs.IntVar(&defaults.HealthPort, "health_port", 0, "")
+	fs.StringVar(&defaults.InfoPath, "info_path", "", "")
+	fs.StringVar(&defaults.DebugPath, "debug_api_path", "", "")
+	fs.StringVar(&defaults.DebugToken, "debug_api_token", "", "")
+	fs.BoolVar(&defaults.DebugRedact, "debug_api_redact", false, "")
+	fs.StringVar(&defaults.ReadyPath, "ready_path", "/ready", "")
+	fs.IntVar(&defaults.ReadyGracePeriod, "ready_grace_period", 0, "")
 
 	fs.StringVar(&defaults.SSL.CertPath, "ssl_cert", "", "")
 	fs.StringVar(&defaults.SSL.KeyPath, "ssl_key", "", "")
+	fs.StringVar(&defaults.SSL.ClientCAPath, "ssl_client_ca_path", "", "")
+	fs.StringVar(&defaults.SSL.VerifyClient, "ssl_verify_client", "off", "")
+	fs.StringVar(&defaults.SSL.ExtraCerts, "ssl_extra_certs", "", "")
 
 	fs.StringVar(&defaults.BroadcastAdapter, "broadcast_adapter", "redis", "")
+	fs.StringVar(&defaults.SecondaryAdapter, "secondary_broadcast_adapter", "", "")
+	fs.IntVar(&defaults.App.BroadcastDedupSize, "broadcast_dedup_size", 10000, "")
+	fs.IntVar(&defaults.App.BroadcastDedupTTL, "broadcast_dedup_ttl", 60, "")
+	fs.StringVar(&defaults.App.AllowedBroadcastStreams, "allowed_broadcast_streams", "", "")
+	fs.StringVar(&defaults.App.StreamsNamespace, "streams_namespace", "", "")
 
 	fs.StringVar(&defaults.Redis.URL, "redis_url", redisDefault, "")
 	fs.StringVar(&defaults.Redis.Channel, "redis_channel", "__anycable__", "")
@@ -65,43 +118,126 @@ func init() {
 	fs.StringVar(&defaults.HTTPPubSub.Path, "http_broadcast_path", "/_broadcast", "")
 	fs.StringVar(&defaults.HTTPPubSub.Secret, "http_broadcast_secret", "", "")
 
+	fs.StringVar(&defaults.RPC.Implementation, "rpc_impl", "grpc", "")
+	fs.StringVar(&defaults.Standalone.Channels, "standalone_channels", "", "")
 	fs.StringVar(&defaults.RPC.Host, "rpc_host", "localhost:50051", "")
 	fs.IntVar(&defaults.RPC.Concurrency, "rpc_concurrency", 28, "")
 	fs.BoolVar(&defaults.RPC.EnableTLS, "rpc_enable_tls", false, "")
 	fs.IntVar(&defaults.RPC.MaxRecvSize, "rpc_max_call_recv_size", 0, "")
 	fs.IntVar(&defaults.RPC.MaxSendSize, "rpc_max_call_send_size", 0, "")
-	fs.StringVar(&headers, "headers", "cookie", "")
+	fs.StringVar(&defaults.App.RPCUnavailableStrategy, "rpc_unavailable_strategy", "reject", "")
+	fs.IntVar(&defaults.App.RPCUnavailableRetryAfter, "rpc_unavailable_retry_after", 5, "")
+	fs.IntVar(&defaults.App.RPCUnavailableQueueTimeout, "rpc_unavailable_queue_timeout", 5, "")
+	fs.StringVar(&defaults.App.AnonymousIdentifiers, "anonymous_identifiers", "", "")
+	fs.StringVar(&opts.headers, "headers", "cookie", "")
+	fs.StringVar(&defaults.WS.ProxyCookies, "proxy_cookies", "", "")
+	fs.StringVar(&defaults.WS.EnvMeta, "env_meta", ws.DefaultEnvMeta, "")
+	fs.StringVar(&defaults.WS.NodeID, "node_id", "", "")
 
 	fs.IntVar(&defaults.WS.ReadBufferSize, "read_buffer_size", 1024, "")
 	fs.IntVar(&defaults.WS.WriteBufferSize, "write_buffer_size", 1024, "")
-	fs.Int64Var(&defaults.WS.MaxMessageSize, "max_message_size", 65536, "")
+	fs.Int64Var(&defaults.WS.MaxMessageSize, "ws_max_message_size", 65536, "")
+	fs.Int64Var(&opts.maxMessageSize, "max_message_size", -1, "")
 	fs.BoolVar(&defaults.WS.EnableCompression, "enable_ws_compression", false, "")
+	fs.IntVar(&defaults.WS.CompressionLevel, "ws_compression_level", 1, "")
+	fs.IntVar(&defaults.WS.CompressionMinSize, "ws_compression_min_size", 0, "")
 	fs.StringVar(&defaults.WS.AllowedOrigins, "allowed_origins", "", "")
+	fs.BoolVar(&defaults.WS.AllowMissingOrigin, "allow_missing_origin", false, "")
+	fs.IntVar(&defaults.WS.RateLimit, "ws_rate_limit", 0, "")
+	fs.IntVar(&defaults.WS.RateLimitInterval, "ws_rate_limit_interval", 1, "")
+	fs.IntVar(&defaults.WS.RateLimitBurst, "ws_rate_limit_burst", 0, "")
+	fs.StringVar(&defaults.WS.RateLimitExempt, "ws_rate_limit_exempt", "", "")
+	fs.IntVar(&defaults.WS.RateLimitCacheSize, "ws_rate_limit_cache_size", 10000, "")
+	fs.IntVar(&defaults.WS.SlowStartDuration, "slow_start_duration", 0, "")
+	fs.IntVar(&defaults.WS.SlowStartRate, "slow_start_rate", 100, "")
+	fs.StringVar(&defaults.WS.SlowStartRamp, "slow_start_ramp", "linear", "")
+
+	fs.StringVar(&defaults.SSE.Path, "sse_path", "", "")
+	fs.IntVar(&defaults.SSE.IdleTimeout, "sse_idle_timeout", 30, "")
+	fs.IntVar(&defaults.SSE.BufferSize, "sse_buffer_size", 100, "")
 
 	fs.IntVar(&defaults.DisconnectQueue.Rate, "disconnect_rate", 100, "")
+	fs.IntVar(&defaults.DisconnectQueue.Workers, "disconnect_workers", 1, "")
+	fs.IntVar(&defaults.DisconnectQueue.Capacity, "disconnect_queue_capacity", 4096, "")
+	fs.IntVar(&defaults.DisconnectQueue.BatchSize, "disconnect_batch_size", 1, "")
+	fs.IntVar(&defaults.DisconnectQueue.BatchWindow, "disconnect_batch_window", 0, "")
 	fs.IntVar(&defaults.DisconnectQueue.ShutdownTimeout, "disconnect_timeout", 5, "")
+	fs.StringVar(&defaults.DisconnectQueue.StoragePath, "disconnect_storage_path", "", "")
+	fs.BoolVar(&defaults.DisconnectQueue.StorageCredentials, "disconnect_storage_credentials", false, "")
 	fs.BoolVar(&defaults.DisconnectorDisabled, "disable_disconnect", false, "")
+	fs.StringVar(&defaults.App.DisconnectMode, "disconnect_mode", "always", "")
 
 	fs.StringVar(&defaults.LogLevel, "log_level", "info", "")
+	fs.StringVar(&defaults.LogLevels, "log_levels", "", "")
 	fs.StringVar(&defaults.LogFormat, "log_format", "text", "")
-	fs.BoolVar(&debugMode, "debug", false, "")
+	fs.BoolVar(&opts.debugMode, "debug", false, "")
+	fs.BoolVar(&defaults.AccessLog.Enabled, "access_log", false, "")
+	fs.StringVar(&defaults.AccessLog.ExcludePaths, "access_log_exclude", "", "")
 
 	fs.BoolVar(&defaults.Metrics.Log, "metrics_log", false, "")
 	fs.IntVar(&defaults.Metrics.RotateInterval, "metrics_rotate_interval", 0, "")
 	fs.IntVar(&defaults.Metrics.LogInterval, "metrics_log_interval", -1, "")
 	fs.StringVar(&defaults.Metrics.LogFormatter, "metrics_log_formatter", "", "")
+	fs.StringVar(&defaults.Metrics.LogFilter, "metrics_log_filter", "", "")
+	fs.StringVar(&defaults.Metrics.LogFields, "metrics_log_fields", "", "")
 	fs.StringVar(&defaults.Metrics.HTTP, "metrics_http", "", "")
 	fs.StringVar(&defaults.Metrics.Host, "metrics_host", "", "")
 	fs.IntVar(&defaults.Metrics.Port, "metrics_port", 0, "")
+	fs.StringVar(&defaults.Metrics.SSL.CertPath, "metrics_ssl_cert", "", "")
+	fs.StringVar(&defaults.Metrics.SSL.KeyPath, "metrics_ssl_key", "", "")
+	fs.StringVar(&defaults.Metrics.AuthToken, "metrics_auth_token", "", "")
+	fs.StringVar(&defaults.Metrics.AllowedCIDRs, "metrics_allowed_cidrs", "", "")
+	fs.BoolVar(&defaults.App.MetricsPerChannel, "metrics_per_channel", false, "")
+	fs.BoolVar(&defaults.Metrics.GoRuntime, "metrics_go_runtime", false, "")
+	fs.StringVar(&defaults.Metrics.OTLPEndpoint, "metrics_otlp_endpoint", "", "")
+	fs.StringVar(&defaults.Metrics.OTLPProtocol, "metrics_otlp_protocol", "http", "")
+	fs.StringVar(&defaults.Metrics.OTLPHeaders, "metrics_otlp_headers", "", "")
+	fs.IntVar(&defaults.Metrics.OTLPInterval, "metrics_otlp_interval", 0, "")
+
+	fs.StringVar(&defaults.Tracing.OTLPEndpoint, "tracing_otlp_endpoint", "", "")
+	fs.StringVar(&defaults.Tracing.OTLPHeaders, "tracing_otlp_headers", "", "")
+	fs.Float64Var(&defaults.Tracing.SampleRate, "tracing_sample_rate", 1, "")
+	fs.IntVar(&defaults.Tracing.BroadcastThreshold, "tracing_broadcast_threshold", 100, "")
+	fs.IntVar(&defaults.Tracing.ExportInterval, "tracing_export_interval", 5, "")
+
+	fs.StringVar(&defaults.Webhook.URL, "webhook_url", "", "")
+	fs.StringVar(&defaults.Webhook.Secret, "webhook_secret", "", "")
+	fs.IntVar(&defaults.Webhook.QueueSize, "webhook_queue_size", 100, "")
+	fs.IntVar(&defaults.Webhook.MaxRetries, "webhook_max_retries", 3, "")
 
 	fs.IntVar(&defaults.App.PingInterval, "ping_interval", 3, "")
 	fs.StringVar(&defaults.App.PingTimestampPrecision, "ping_timestamp_precision", "s", "")
+	fs.IntVar(&defaults.App.PongTimeout, "pong_timeout", 0, "")
+	fs.IntVar(&defaults.App.MaxConnectionsPerIdentifier, "max_connections_per_identifier", 0, "")
+	fs.StringVar(&defaults.App.ConnectionsLimitMode, "connections_limit_mode", "reject", "")
+	fs.IntVar(&defaults.App.MaxSessions, "max_sessions", 0, "")
+	fs.IntVar(&defaults.App.SessionsSoftLimit, "sessions_soft_limit", 0, "")
+	fs.IntVar(&defaults.App.CommandsRateLimitSubscribe, "commands_rate_limit_subscribe", 0, "")
+	fs.IntVar(&defaults.App.CommandsRateLimitSubscribeBurst, "commands_rate_limit_subscribe_burst", 0, "")
+	fs.IntVar(&defaults.App.CommandsRateLimitPerform, "commands_rate_limit_perform", 0, "")
+	fs.IntVar(&defaults.App.CommandsRateLimitPerformBurst, "commands_rate_limit_perform_burst", 0, "")
+	fs.StringVar(&defaults.App.CommandsRateLimitChannels, "commands_rate_limit_channels", "", "")
+	fs.IntVar(&defaults.App.CommandsRateLimitMaxViolations, "commands_rate_limit_max_violations", 10, "")
+	fs.StringVar(&defaults.App.BinaryBroadcasts, "binary_broadcasts", "drop", "")
+	fs.IntVar(&defaults.App.HistoryLimit, "history_limit", 0, "")
+	fs.IntVar(&defaults.App.HistoryTTL, "history_ttl", 0, "")
+	fs.IntVar(&defaults.App.HistoryMaxStreams, "history_max_streams", 10000, "")
+	fs.IntVar(&defaults.App.ReliableBufferSize, "reliable_buffer_size", 100, "")
+	fs.IntVar(&defaults.App.ReliableAckTimeout, "reliable_ack_timeout", 5, "")
+	fs.IntVar(&defaults.App.ReliableMaxRetries, "reliable_max_retries", 3, "")
+	fs.IntVar(&defaults.App.SessionMaxLifetime, "session_max_lifetime", 0, "")
+	fs.IntVar(&defaults.App.SessionLifetimeJitter, "session_lifetime_jitter", 10, "")
+	fs.IntVar(&defaults.App.ExpiryWarning, "expiry_warning", 0, "")
+	fs.StringVar(&defaults.App.WelcomeExtra, "welcome_extra", "", "")
+	fs.StringVar(&defaults.App.DisconnectExtra, "disconnect_extra", "", "")
+	fs.IntVar(&defaults.App.SessionStoreMaxSize, "session_store_max_size", 4096, "")
+	fs.StringVar(&defaults.App.SessionStoreRPCKeys, "session_store_rpc_keys", "", "")
+	fs.StringVar(&defaults.App.EventsSink, "events_sink", "", "")
+	fs.StringVar(&defaults.App.EventsChannel, "events_channel", "__anycable_events__", "")
+	fs.IntVar(&defaults.App.EventsBufferSize, "events_buffer_size", 1024, "")
+	fs.BoolVar(&defaults.App.EventsRedact, "events_redact", false, "")
 	fs.IntVar(&defaults.App.StatsRefreshInterval, "stats_refresh_interval", 5, "")
 	fs.IntVar(&defaults.App.HubGopoolSize, "hub_gopool_size", 16, "")
-
-	// CLI vars
-	fs.BoolVar(&showHelp, "h", false, "")
-	fs.BoolVar(&showVersion, "v", false, "")
 }
 
 // Config returns CLI configuration
@@ -110,10 +246,63 @@ func Config(args []string) (config.Config, error) {
 		return config.Config{}, err
 	}
 
-	prepareComplexDefaults()
+	sources = make(map[string]string)
+
+	fs.Visit(func(f *flag.Flag) {
+		if argsContain(args, f.Name) {
+			sources[f.Name] = "flag"
+		} else {
+			sources[f.Name] = "env"
+		}
+	})
+
+	loaded, err := loadConfigFile(fs, opts.configPath)
+
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	for _, name := range loaded {
+		sources[name] = "file"
+	}
+
+	prepareComplexDefaults(&defaults, opts.headers, opts.debugMode)
+	resolveDeprecatedOptions(fs, &defaults, &opts)
 	return defaults, nil
 }
 
+// ReloadConfig reads configuration from the provided args, ENV and config file once again
+// without changing the initial one (used to update settings at runtime)
+func ReloadConfig(args []string) (config.Config, error) {
+	c := config.New()
+
+	var (
+		o       cliOptions
+		ignored bool
+	)
+
+	rfs := flag.NewFlagSetWithEnvPrefix(os.Args[0], "ANYCABLE", flag.ContinueOnError)
+	rfs.SetOutput(ioutil.Discard)
+
+	bindFlags(rfs, &c, &o)
+	rfs.BoolVar(&ignored, "h", false, "")
+	rfs.BoolVar(&ignored, "v", false, "")
+	rfs.BoolVar(&ignored, "print-config", false, "")
+	rfs.BoolVar(&ignored, "validate-config", false, "")
+
+	if err := rfs.Parse(args); err != nil {
+		return config.Config{}, err
+	}
+
+	if _, err := loadConfigFile(rfs, o.configPath); err != nil {
+		return config.Config{}, err
+	}
+
+	prepareComplexDefaults(&c, o.headers, o.debugMode)
+	resolveDeprecatedOptions(rfs, &c, &o)
+	return c, nil
+}
+
 // ShowVersion returns true if -v flag was provided
 func ShowVersion() bool {
 	return showVersion
@@ -126,7 +315,17 @@ func ShowHelp() bool {
 
 // DebugMode returns true if -debug flag is provided
 func DebugMode() bool {
-	return debugMode
+	return opts.debugMode
+}
+
+// PrintConfig returns true if --print-config flag is provided
+func PrintConfig() bool {
+	return printConfig
+}
+
+// ValidateConfig returns true if --validate-config flag is provided
+func ValidateConfig() bool {
+	return validate
 }
 
 const usage = `AnyCable-Go, The WebSocket server for https://anycable.io
@@ -135,16 +334,40 @@ USAGE
   anycable-go [options]
 
 OPTIONS
-  --host                                 Server host, default: localhost, env: ANYCABLE_HOST
+  --config                               Path to the YAML configuration file, default: "", env: ANYCABLE_CONFIG
+  --host                                 Server host (or Unix socket path, e.g., unix:///tmp/anycable.sock), default: localhost, env: ANYCABLE_HOST
   --port                                 Server port, default: 8080, env: ANYCABLE_PORT, PORT
   --max-conn                             Limit simultaneous server connections (0 – without limit), default: 0, env: ANYCABLE_MAX_CONN
+  --socket_permissions                   Unix socket file permissions (octal, e.g., 0660), default: "" (system default), env: ANYCABLE_SOCKET_PERMISSIONS
+  --shutdown_timeout                     The number of seconds to wait for active HTTP requests to complete during shutdown, default: 30, env: ANYCABLE_SHUTDOWN_TIMEOUT
+  --server_read_header_timeout           The max time to read HTTP request headers (in seconds, 0 – no limit), default: 10, env: ANYCABLE_SERVER_READ_HEADER_TIMEOUT
+  --server_handshake_timeout             The max time to read HTTP requests and complete WebSocket handshakes (in seconds, 0 – no limit), default: 30, env: ANYCABLE_SERVER_HANDSHAKE_TIMEOUT
+  --server_max_header_bytes              The max size of HTTP request headers (in bytes, 0 – Go default), default: 65536, env: ANYCABLE_SERVER_MAX_HEADER_BYTES
+  --trusted_proxies                      Comma-separated list of proxies CIDRs to trust the X-Forwarded-For header from, default: "", env: ANYCABLE_TRUSTED_PROXIES
+  --proxy_protocol                       Accept PROXY protocol (v1 and v2) headers on the main server port, default: false, env: ANYCABLE_PROXY_PROTOCOL
+  --proxy_protocol_optional_cidrs        Comma-separated list of CIDRs allowed to connect without the PROXY protocol header, default: "", env: ANYCABLE_PROXY_PROTOCOL_OPTIONAL_CIDRS
   --path                                 WebSocket endpoint path, default: /cable, env: ANYCABLE_PATH
   --health-path                          HTTP health endpoint path, default: /health, env: ANYCABLE_HEALTH_PATH
+  --health_port                          Serve the health endpoint on a separate port (0 – use the main port), default: 0, env: ANYCABLE_HEALTH_PORT
+  --info_path                            HTTP node info endpoint path (served along with the health endpoint), default: "" (disabled), env: ANYCABLE_INFO_PATH
+  --debug_api_path                       HTTP debug endpoints path prefix (served along with the health endpoint), default: "" (disabled), env: ANYCABLE_DEBUG_API_PATH
+  --debug_api_token                      Bearer token to access the debug endpoints (required if enabled), default: "", env: ANYCABLE_DEBUG_API_TOKEN
+  --debug_api_redact                     Redact connection identifiers values in the debug endpoints responses, default: false, env: ANYCABLE_DEBUG_API_REDACT
+  --ready_path                           HTTP readiness endpoint path (served along with the health endpoint), default: /ready, env: ANYCABLE_READY_PATH
+  --ready_grace_period                   The number of seconds after start to report the node as not ready, default: 0, env: ANYCABLE_READY_GRACE_PERIOD
 
   --ssl_cert                             SSL certificate path, env: ANYCABLE_SSL_CERT
   --ssl_key                              SSL private key path, env: ANYCABLE_SSL_KEY
+  --ssl_client_ca_path                   CA certificates path to verify client certificates, env: ANYCABLE_SSL_CLIENT_CA_PATH
+  --ssl_verify_client                    Client certificates verification mode (off, optional, require), default: off, env: ANYCABLE_SSL_VERIFY_CLIENT
+  --ssl_extra_certs                      Additional SSL certificates selected by SNI (comma-separated cert_path:key_path pairs), env: ANYCABLE_SSL_EXTRA_CERTS
 
   --broadcast_adapter                    Broadcasting adapter to use (redis or http), default: redis, env: ANYCABLE_BROADCAST_ADAPTER
+  --secondary_broadcast_adapter          Secondary broadcasting adapter to run along with the primary one (redis or http), default: "" (disabled), env: ANYCABLE_SECONDARY_BROADCAST_ADAPTER
+  --broadcast_dedup_size                 The max number of recent broadcast IDs to keep for deduplication (with the secondary adapter), default: 10000, env: ANYCABLE_BROADCAST_DEDUP_SIZE
+  --broadcast_dedup_ttl                  For how long to keep broadcast IDs for deduplication (seconds), default: 60, env: ANYCABLE_BROADCAST_DEDUP_TTL
+  --allowed_broadcast_streams            Regular expression the broadcast stream names must match (others are dropped), default: "" (all allowed), env: ANYCABLE_ALLOWED_BROADCAST_STREAMS
+  --streams_namespace                    Namespace to isolate streams of multiple apps sharing the same broadcast adapter (broadcasts to other namespaces are dropped), default: "" (disabled), env: ANYCABLE_STREAMS_NAMESPACE
 
   --redis_url                            Redis url, default: redis://localhost:6379/5, env: ANYCABLE_REDIS_URL, REDIS_URL
   --redis_channel                        Redis channel for broadcasts, default: __anycable__, env: ANYCABLE_REDIS_CHANNEL
@@ -156,40 +379,129 @@ OPTIONS
   --http_broadcast_path                  HTTP pub/sub endpoint path, default: /_broadcast, env: ANYCABLE_HTTP_BROADCAST_PATH
   --http_broadcast_secret                HTTP pub/sub authorization secret, default: "" (disabled), env: ANYCABLE_HTTP_BROADCAST_SECRET
 
+  --rpc_impl                             RPC implementation (grpc or none), default: grpc, env: ANYCABLE_RPC_IMPL
+  --standalone_channels                  Comma-separated list of public channels (or patterns) allowed to subscribe to when rpc_impl=none, default: "", env: ANYCABLE_STANDALONE_CHANNELS
   --rpc_host                             RPC service address, default: localhost:50051, env: ANYCABLE_RPC_HOST
   --rpc_concurrency                      Max number of concurrent RPC request; should be slightly less than the RPC server concurrency, default: 28, env: ANYCABLE_RPC_CONCURRENCY
   --rpc_enable_tls                       Enable client-side TLS with the RPC server, default: false, env: ANYCABLE_RPC_ENABLE_TLS
   --rpc_max_call_recv_size               Override default MaxCallRecvMsgSize for RPC client (bytes), default: none, env: ANYCABLE_RPC_MAX_CALL_RECV_SIZE
   --rpc_max_call_send_size               Override default MaxCallSendMsgSize for RPC client (bytes), default: none, env: ANYCABLE_RPC_MAX_CALL_SEND_SIZE
+  --rpc_unavailable_strategy             What to do with new connections when RPC is unavailable (reject, allow_anonymous or queue), default: reject, env: ANYCABLE_RPC_UNAVAILABLE_STRATEGY
+  --rpc_unavailable_retry_after          Reconnection delay (in seconds) suggested to clients rejected due to unavailable RPC, default: 5, env: ANYCABLE_RPC_UNAVAILABLE_RETRY_AFTER
+  --rpc_unavailable_queue_timeout        For how long to hold new connections waiting for RPC (in seconds, queue strategy), default: 5, env: ANYCABLE_RPC_UNAVAILABLE_QUEUE_TIMEOUT
+  --anonymous_identifiers                Identifiers of the connections accepted without authentication (allow_anonymous strategy), default: "", env: ANYCABLE_ANONYMOUS_IDENTIFIERS
   --headers                              List of headers to proxy to RPC, default: cookie, env: ANYCABLE_HEADERS
+  --proxy_cookies                        Comma-separated list of cookies to proxy to RPC (all cookies are proxied if empty), default: "", env: ANYCABLE_PROXY_COOKIES
+  --env_meta                             Comma-separated list of connection metadata entries to pass to RPC (remote_addr, tls, protocol, node_id), default: "remote_addr,tls,protocol,node_id", env: ANYCABLE_ENV_META
+  --node_id                              Node identifier used in logs, metrics, welcome messages and RPC (hostname is used if empty), default: "", env: ANYCABLE_NODE_ID
 
   --disconnect_rate                      Max number of Disconnect calls per second, default: 100, env: ANYCABLE_DISCONNECT_RATE
+  --disconnect_workers                   The number of concurrent Disconnect calls, default: 1, env: ANYCABLE_DISCONNECT_WORKERS
+  --disconnect_queue_capacity            The max number of pending Disconnect calls (newer calls are dropped), default: 4096, env: ANYCABLE_DISCONNECT_QUEUE_CAPACITY
+  --disconnect_batch_size                The max number of sessions to disconnect in a single call, default: 1, env: ANYCABLE_DISCONNECT_BATCH_SIZE
+  --disconnect_batch_window              How long to wait for a batch to fill (in milliseconds), default: 0, env: ANYCABLE_DISCONNECT_BATCH_WINDOW
   --disconnect_timeout                   Graceful shutdown timeouts (in seconds), default: 5, env: ANYCABLE_DISCONNECT_TIMEOUT
+  --disconnect_storage_path              Path to the file to persist pending Disconnect calls between restarts, default: "" (disabled), env: ANYCABLE_DISCONNECT_STORAGE_PATH
+  --disconnect_storage_credentials       Persist Cookie and Authorization headers along with pending Disconnect calls, default: false, env: ANYCABLE_DISCONNECT_STORAGE_CREDENTIALS
   --disable_disconnect                   Disable calling Disconnect callback, default: false, env: ANYCABLE_DISABLE_DISCONNECT
+  --disconnect_mode                      When to call Disconnect callback by default (always, never, only_if_subscribed), default: always, env: ANYCABLE_DISCONNECT_MODE
 
   --log_level                            Set logging level (debug/info/warn/error/fatal), default: info, env: ANYCABLE_LOG_LEVEL
+  --log_levels                           Comma-separated list of per-context logging levels (e.g., rpc=debug,ws=warn), default: "", env: ANYCABLE_LOG_LEVELS
   --log_format                           Set logging format (text, json), default: text, env: ANYCABLE_LOG_FORMAT
+  --access_log                           Log HTTP requests, default: false, env: ANYCABLE_ACCESS_LOG
+  --access_log_exclude                   Comma-separated list of paths to skip in the access log (e.g., /health), default: "", env: ANYCABLE_ACCESS_LOG_EXCLUDE
   --debug                                Enable debug mode (more verbose logging), default: false, env: ANYCABLE_DEBUG
 
   --metrics_log                          Enable metrics logging (with info level), default: false, env: ANYCABLE_METRICS_LOG
   --metrics_rotate_interval              Specify how often flush metrics to writers (logs, statsd) (in seconds), default: 15, env: ANYCABLE_METRICS_ROTATE_INTERVAL
   --metrics_log_interval                 DEPRECATED. Specify how often flush metrics logs (in seconds), default: 15, env: ANYCABLE_METRICS_LOG_INTERVAL
   --metrics_log_formatter                Specify the path to custom Ruby formatter script (only supported on MacOS and Linux), default: "" (none), env: ANYCABLE_METRICS_LOG_FORMATTER
+  --metrics_log_filter                   Comma-separated list of metrics names (or glob patterns) to log, default: "" (all), env: ANYCABLE_METRICS_LOG_FILTER
+  --metrics_log_fields                   Static fields to add to metrics logs (format: 'key1=value1,key2=value2'), default: "", env: ANYCABLE_METRICS_LOG_FIELDS
   --metrics_http                         Enable HTTP metrics endpoint at the specified path, default: "" (disabled), env: ANYCABLE_METRICS_HTTP
   --metrics_host                         Server host for metrics endpoint, default: the same as for main server, env: ANYCABLE_METRICS_HOST
   --metrics_port                         Server port for metrics endpoint, default: the same as for main server, env: ANYCABLE_METRICS_PORT
+  --metrics_ssl_cert                     SSL certificate path for metrics endpoint (requires a dedicated port), default: "" (the same as for main server), env: ANYCABLE_METRICS_SSL_CERT
+  --metrics_ssl_key                      SSL private key path for metrics endpoint, default: "" (the same as for main server), env: ANYCABLE_METRICS_SSL_KEY
+  --metrics_auth_token                   Require the specified bearer token to access HTTP metrics endpoint, default: "" (disabled), env: ANYCABLE_METRICS_AUTH_TOKEN
+  --metrics_allowed_cidrs                Comma-separated list of CIDRs allowed to access HTTP metrics endpoint, default: "" (all), env: ANYCABLE_METRICS_ALLOWED_CIDRS
+  --metrics_per_channel                  Enable per-channel commands metrics, default: false, env: ANYCABLE_METRICS_PER_CHANNEL
+  --metrics_go_runtime                   Enable Go runtime metrics (memory, GC, goroutines), default: false, env: ANYCABLE_METRICS_GO_RUNTIME
+  --metrics_otlp_endpoint                Export metrics to the OpenTelemetry collector at the specified URL, default: "" (disabled), env: ANYCABLE_METRICS_OTLP_ENDPOINT
+  --metrics_otlp_protocol                OTLP protocol (only http is supported), default: http, env: ANYCABLE_METRICS_OTLP_PROTOCOL
+  --metrics_otlp_headers                 Headers to send with OTLP requests (format: 'key1=value1,key2=value2'), default: "", env: ANYCABLE_METRICS_OTLP_HEADERS
+  --metrics_otlp_interval                How often to export metrics via OTLP (in seconds), default: the same as metrics_rotate_interval, env: ANYCABLE_METRICS_OTLP_INTERVAL
+
+  --tracing_otlp_endpoint                Export traces to the OpenTelemetry collector at the specified URL (OTLP/HTTP), default: "" (disabled), env: ANYCABLE_TRACING_OTLP_ENDPOINT
+  --tracing_otlp_headers                 Headers to send with traces export requests (format: 'key1=value1,key2=value2'), default: "", env: ANYCABLE_TRACING_OTLP_HEADERS
+  --tracing_sample_rate                  The ratio of traces to sample (from 0 to 1; incoming traceparent sampling decision is respected), default: 1, env: ANYCABLE_TRACING_SAMPLE_RATE
+  --tracing_broadcast_threshold          The min number of stream subscribers to trace broadcasts fan-out (0 – disabled), default: 100, env: ANYCABLE_TRACING_BROADCAST_THRESHOLD
+  --tracing_export_interval              How often to export spans (in seconds), default: 5, env: ANYCABLE_TRACING_EXPORT_INTERVAL
+
+  --webhook_url                          Post node lifecycle events (started, draining, RPC lost, etc.) to the specified URL, default: "" (disabled), env: ANYCABLE_WEBHOOK_URL
+  --webhook_secret                       A secret to sign webhook payloads with (HMAC-SHA256), default: "" (not signed), env: ANYCABLE_WEBHOOK_SECRET
+  --webhook_queue_size                   The max number of webhook events waiting for delivery (the rest are dropped), default: 100, env: ANYCABLE_WEBHOOK_QUEUE_SIZE
+  --webhook_max_retries                  The max number of webhook delivery retries, default: 3, env: ANYCABLE_WEBHOOK_MAX_RETRIES
 
   --read_buffer_size                     WebSocket connection read buffer size, default: 1024, env: ANYCABLE_READ_BUFFER_SIZE
   --write_buffer_size                    WebSocket connection write buffer size, default: 1024, env: ANYCABLE_WRITE_BUFFER_SIZE
-  --max_message_size                     Maximum size of a message in bytes, default: 65536, env: ANYCABLE_MAX_MESSAGE_SIZE
+  --ws_max_message_size                  Maximum size of an incoming message in bytes (connection is closed with 1009 code if exceeded), default: 65536, env: ANYCABLE_WS_MAX_MESSAGE_SIZE
+  --max_message_size                     DEPRECATED. Use ws_max_message_size instead, env: ANYCABLE_MAX_MESSAGE_SIZE
   --enable_ws_compression                Enable experimental WebSocket per message compression, default: false, env: ANYCABLE_ENABLE_WS_COMPRESSION
+  --ws_compression_level                 WebSocket compression level (1 – best speed, 9 – best compression), default: 1, env: ANYCABLE_WS_COMPRESSION_LEVEL
+  --ws_compression_min_size              Do not compress messages smaller than the specified size (in bytes), default: 0, env: ANYCABLE_WS_COMPRESSION_MIN_SIZE
   --hub_gopool_size                      The size of the goroutines pool to broadcast messages, default: 16, env: ANYCABLE_HUB_GOPOOL_SIZE
   --allowed_origins                      Accept requests only from specified origins, e.g., "www.example.com,*example.io". No check is performed if empty, default: "", env: ANYCABLE_ALLOWED_ORIGINS
+  --allow_missing_origin                 Accept requests without the Origin header when allowed_origins is set, default: false, env: ANYCABLE_ALLOW_MISSING_ORIGIN
+  --ws_rate_limit                        The max number of WebSocket connections per IP per interval (0 – no limit), default: 0, env: ANYCABLE_WS_RATE_LIMIT
+  --ws_rate_limit_interval               Rate limit interval in seconds, default: 1, env: ANYCABLE_WS_RATE_LIMIT_INTERVAL
+  --ws_rate_limit_burst                  The max number of WebSocket connections per IP in a burst, default: 0 (same as ws_rate_limit), env: ANYCABLE_WS_RATE_LIMIT_BURST
+  --ws_rate_limit_exempt                 Comma-separated list of CIDRs not affected by the rate limit, default: "", env: ANYCABLE_WS_RATE_LIMIT_EXEMPT
+  --ws_rate_limit_cache_size             The max number of tracked IPs, default: 10000, env: ANYCABLE_WS_RATE_LIMIT_CACHE_SIZE
+  --slow_start_duration                  For how long to limit the WebSocket connections admission rate after start (seconds), default: 0 (disabled), env: ANYCABLE_SLOW_START_DURATION
+  --slow_start_rate                      The initial number of connections accepted per second during the slow start, default: 100, env: ANYCABLE_SLOW_START_RATE
+  --slow_start_ramp                      How to ramp up the admission rate during the slow start (linear, exponential), default: linear, env: ANYCABLE_SLOW_START_RAMP
+
+  --sse_path                             SSE fallback transport endpoint path, default: "" (disabled), env: ANYCABLE_SSE_PATH
+  --sse_idle_timeout                     Close SSE sessions without active streams after the specified number of seconds, default: 30, env: ANYCABLE_SSE_IDLE_TIMEOUT
+  --sse_buffer_size                      The max number of messages to keep per SSE session to resume streams, default: 100, env: ANYCABLE_SSE_BUFFER_SIZE
 
   --ping_interval                        Action Cable ping interval (in seconds), default: 3, env: ANYCABLE_PING_INTERVAL
   --ping_timestamp_precision             Precision for timestamps in ping messages (s, ms, ns), default: s, env: ANYCABLE_PING_TIMESTAMP_PRECISION
+  --pong_timeout                         Close the session after the specified number of consecutive pings left without response (0 – disabled), default: 0, env: ANYCABLE_PONG_TIMEOUT
+  --max_connections_per_identifier       The max number of connections with the same identifiers (0 – no limit), default: 0, env: ANYCABLE_MAX_CONNECTIONS_PER_IDENTIFIER
+  --connections_limit_mode               What to do when max_connections_per_identifier is exceeded (reject, kick_oldest), default: reject, env: ANYCABLE_CONNECTIONS_LIMIT_MODE
+  --max_sessions                         The max number of concurrent sessions (0 – no limit), default: 0, env: ANYCABLE_MAX_SESSIONS
+  --sessions_soft_limit                  Log a warning when the number of sessions reaches this value (0 – disabled), default: 0, env: ANYCABLE_SESSIONS_SOFT_LIMIT
+  --commands_rate_limit_subscribe        The max number of subscribe/unsubscribe commands per second per session (0 – no limit), default: 0, env: ANYCABLE_COMMANDS_RATE_LIMIT_SUBSCRIBE
+  --commands_rate_limit_subscribe_burst  The max number of subscribe/unsubscribe commands per session in a burst, default: 0 (same as the rate), env: ANYCABLE_COMMANDS_RATE_LIMIT_SUBSCRIBE_BURST
+  --commands_rate_limit_perform          The max number of perform commands per second per session (0 – no limit), default: 0, env: ANYCABLE_COMMANDS_RATE_LIMIT_PERFORM
+  --commands_rate_limit_perform_burst    The max number of perform commands per session in a burst, default: 0 (same as the rate), env: ANYCABLE_COMMANDS_RATE_LIMIT_PERFORM_BURST
+  --commands_rate_limit_channels         Per-channel perform limits (format: 'Channel=rate[:burst],...'), default: "", env: ANYCABLE_COMMANDS_RATE_LIMIT_CHANNELS
+  --commands_rate_limit_max_violations   Close the session after the specified number of consecutive rate-limited commands (0 – never), default: 10, env: ANYCABLE_COMMANDS_RATE_LIMIT_MAX_VIOLATIONS
+  --binary_broadcasts                    What to do with binary broadcasts for JSON clients (drop, base64), default: drop, env: ANYCABLE_BINARY_BROADCASTS
+  --history_limit                        The max number of messages to keep in the history per stream (0 – disabled), default: 0, env: ANYCABLE_HISTORY_LIMIT
+  --history_ttl                          For how long to keep messages in the history (in seconds, 0 – no limit), default: 0, env: ANYCABLE_HISTORY_TTL
+  --history_max_streams                  The max number of streams with history, default: 10000, env: ANYCABLE_HISTORY_MAX_STREAMS
+  --reliable_buffer_size                 The max number of unacknowledged messages per session (0 – disable reliable delivery), default: 100, env: ANYCABLE_RELIABLE_BUFFER_SIZE
+  --reliable_ack_timeout                 For how long to wait for a message acknowledgement before re-sending it (in seconds), default: 5, env: ANYCABLE_RELIABLE_ACK_TIMEOUT
+  --reliable_max_retries                 The max number of re-sending attempts before closing the session, default: 3, env: ANYCABLE_RELIABLE_MAX_RETRIES
+  --session_max_lifetime                 Ask clients to reconnect after the specified number of seconds (0 – no limit), default: 0, env: ANYCABLE_SESSION_MAX_LIFETIME
+  --session_lifetime_jitter              The max percentage of the session lifetime to subtract randomly, default: 10, env: ANYCABLE_SESSION_LIFETIME_JITTER
+  --expiry_warning                       Warn clients the specified number of seconds before their credentials expire (0 – disabled), default: 0, env: ANYCABLE_EXPIRY_WARNING
+  --welcome_extra                        Extra fields to add to welcome messages (JSON object), default: "", env: ANYCABLE_WELCOME_EXTRA
+  --disconnect_extra                     Extra fields to add to disconnect messages per reason (JSON object), default: "", env: ANYCABLE_DISCONNECT_EXTRA
+  --session_store_max_size               The max size of the session store in bytes (0 – no limit), default: 4096, env: ANYCABLE_SESSION_STORE_MAX_SIZE
+  --session_store_rpc_keys               Comma-separated list of the session store keys to pass to RPC (as x-anycable-store-<key> headers), default: "", env: ANYCABLE_SESSION_STORE_RPC_KEYS
+  --events_sink                          Where to write session lifecycle events for auditing (log or redis), default: "" (disabled), env: ANYCABLE_EVENTS_SINK
+  --events_channel                       Redis channel to publish session events to, default: __anycable_events__, env: ANYCABLE_EVENTS_CHANNEL
+  --events_buffer_size                   The max number of session events waiting to be written (others are dropped), default: 1024, env: ANYCABLE_EVENTS_BUFFER_SIZE
+  --events_redact                        Redact connection identifiers values in session events, default: false, env: ANYCABLE_EVENTS_REDACT
   --stats_refresh_interval               How often to refresh the server stats (in seconds), default: 5, env: ANYCABLE_STATS_REFRESH_INTERVAL
 
+  --print-config           Print the effective configuration (with secrets redacted) and exit
+  --validate-config        Validate the configuration (without binding ports or connecting to services) and exit
   -h                       This help screen
   -v                       Show version
 
@@ -200,7 +512,7 @@ func PrintHelp() {
 	fmt.Print(usage)
 }
 
-func prepareComplexDefaults() {
+func prepareComplexDefaults(defaults *config.Config, headers string, debugMode bool) {
 	defaults.Headers = parseHeaders(headers)
 
 	if debugMode {
@@ -223,6 +535,31 @@ Use metrics_rotate_interval instead.`)
 	}
 }
 
+// resolveDeprecatedOptions applies the values of the deprecated options bound to the separate variables
+// (the new options take precedence if set explicitly)
+func resolveDeprecatedOptions(fs *flag.FlagSet, defaults *config.Config, opts *cliOptions) {
+	if opts.maxMessageSize >= 0 {
+		fmt.Println(`DEPRECATION WARNING: max_message_size option is deprecated
+and will be deleted in the next major release of anycable-go.
+Use ws_max_message_size instead.`)
+
+		if !isFlagSet(fs, "ws_max_message_size") {
+			defaults.WS.MaxMessageSize = opts.maxMessageSize
+		}
+	}
+}
+
+// isFlagSet returns true if the option has been provided via flag, env or config file
+func isFlagSet(fs *flag.FlagSet, name string) (set bool) {
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return
+}
+
 // parseHeaders returns a headers list with the values from
 // a comma-separated string list
 func parseHeaders(str string) []string {
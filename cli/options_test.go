@@ -12,3 +12,35 @@ func TestParseHeadersArg(t *testing.T) {
 	headers := parseHeaders("cookie,X-API-TOKEN,Origin")
 	assert.Equal(t, expected, headers)
 }
+
+func TestReloadConfig(t *testing.T) {
+	c, err := ReloadConfig([]string{"--hub_gopool_size", "32", "--headers", "cookie,X-Api-Token"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 32, c.App.HubGopoolSize)
+	assert.Equal(t, []string{"cookie", "x-api-token"}, c.Headers)
+
+	// Doesn't change the initial configuration
+	assert.Equal(t, 16, defaults.App.HubGopoolSize)
+
+	_, err = ReloadConfig([]string{"--hub_gopool_size", "many"})
+	assert.NotNil(t, err)
+}
+
+func TestDeprecatedMaxMessageSize(t *testing.T) {
+	c, err := ReloadConfig([]string{"--max_message_size", "1024"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1024), c.WS.MaxMessageSize)
+
+	// The new option takes precedence regardless of the order
+	c, err = ReloadConfig([]string{"--ws_max_message_size", "2048", "--max_message_size", "1024"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2048), c.WS.MaxMessageSize)
+
+	c, err = ReloadConfig([]string{"--max_message_size", "1024", "--ws_max_message_size", "65536"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(65536), c.WS.MaxMessageSize)
+}
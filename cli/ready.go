@@ -0,0 +1,157 @@
+package cli
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// ReadinessProbe could be implemented by components (controllers, subscribers)
+// which are not ready to serve right after start (e.g., waiting for a connection to be established).
+// Components not implementing this interface are considered ready.
+type ReadinessProbe interface {
+	Ready() error
+}
+
+const readinessOK = "ok"
+
+// readinessFunc is an adapter to use ordinary functions as readiness probes
+type readinessFunc func() error
+
+func (fn readinessFunc) Ready() error {
+	return fn()
+}
+
+type readinessDependency struct {
+	name  string
+	probe ReadinessProbe
+	// Optional dependencies are reported but do not affect the readiness
+	optional bool
+}
+
+// readiness tracks the node readiness: all the dependencies must pass their probes
+// (only the first successful probe matters), the startup grace period must expire,
+// and the node must not be shutting down
+type readiness struct {
+	draining    int32
+	startedAt   time.Time
+	gracePeriod time.Duration
+
+	mu           sync.Mutex
+	dependencies []readinessDependency
+	passed       map[string]bool
+}
+
+type readinessStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func newReadiness(gracePeriod time.Duration) *readiness {
+	return &readiness{
+		startedAt:   time.Now(),
+		gracePeriod: gracePeriod,
+		passed:      make(map[string]bool),
+	}
+}
+
+// AddDependency registers a component to check (if it implements ReadinessProbe)
+func (rd *readiness) AddDependency(name string, component interface{}) {
+	probe, ok := component.(ReadinessProbe)
+
+	if !ok {
+		return
+	}
+
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	rd.dependencies = append(rd.dependencies, readinessDependency{name: name, probe: probe})
+}
+
+// AddOptionalDependency registers a component which state is reported (and checked on every request)
+// but doesn't affect the node readiness (e.g., one of the redundant broadcast adapters)
+func (rd *readiness) AddOptionalDependency(name string, probe ReadinessProbe) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	rd.dependencies = append(rd.dependencies, readinessDependency{name: name, probe: probe, optional: true})
+}
+
+// Drain marks the node as not ready (must be called as soon as shutdown begins)
+func (rd *readiness) Drain() {
+	atomic.StoreInt32(&rd.draining, 1)
+}
+
+func (rd *readiness) Status() (bool, *readinessStatus) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+
+	ready := true
+	checks := make(map[string]string)
+
+	if atomic.LoadInt32(&rd.draining) == 1 {
+		return false, &readinessStatus{Status: "draining", Checks: checks}
+	}
+
+	if time.Since(rd.startedAt) < rd.gracePeriod {
+		ready = false
+		checks["startup"] = "Startup grace period hasn't expired yet"
+	} else {
+		checks["startup"] = readinessOK
+	}
+
+	for _, dep := range rd.dependencies {
+		if dep.optional {
+			if err := dep.probe.Ready(); err != nil {
+				checks[dep.name] = err.Error()
+			} else {
+				checks[dep.name] = readinessOK
+			}
+
+			continue
+		}
+
+		if rd.passed[dep.name] {
+			checks[dep.name] = readinessOK
+			continue
+		}
+
+		if err := dep.probe.Ready(); err != nil {
+			ready = false
+			checks[dep.name] = err.Error()
+			continue
+		}
+
+		rd.passed[dep.name] = true
+		checks[dep.name] = readinessOK
+	}
+
+	status := &readinessStatus{Status: "ready", Checks: checks}
+
+	if !ready {
+		status.Status = "not_ready"
+	}
+
+	return ready, status
+}
+
+func (rd *readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ready, status := rd.Status()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if ready {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.WithField("context", "main").Errorf("Failed to write readiness status: %v", err)
+	}
+}
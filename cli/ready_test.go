@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testProbe struct {
+	err error
+}
+
+func (p *testProbe) Ready() error {
+	return p.err
+}
+
+func readinessRequest(t *testing.T, rd *readiness) (int, *readinessStatus) {
+	w := httptest.NewRecorder()
+	rd.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+
+	var status readinessStatus
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &status))
+
+	return w.Code, &status
+}
+
+func TestReadiness(t *testing.T) {
+	rpc := &testProbe{err: errors.New("Not connected")}
+
+	rd := newReadiness(0)
+	rd.AddDependency("rpc", rpc)
+	// Components without probes are always ready
+	rd.AddDependency("pubsub", &testSubscriber{})
+
+	code, status := readinessRequest(t, rd)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not_ready", status.Status)
+	assert.Equal(t, map[string]string{"startup": "ok", "rpc": "Not connected"}, status.Checks)
+
+	rpc.err = nil
+
+	code, status = readinessRequest(t, rd)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", status.Status)
+
+	// Only the first successful probe matters
+	rpc.err = errors.New("Connection lost")
+
+	code, _ = readinessRequest(t, rd)
+	assert.Equal(t, http.StatusOK, code)
+
+	rd.Drain()
+
+	code, status = readinessRequest(t, rd)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "draining", status.Status)
+}
+
+func TestReadinessGracePeriod(t *testing.T) {
+	rd := newReadiness(time.Minute)
+
+	code, status := readinessRequest(t, rd)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not_ready", status.Status)
+	assert.NotEqual(t, "ok", status.Checks["startup"])
+
+	rd.startedAt = time.Now().Add(-2 * time.Minute)
+
+	code, _ = readinessRequest(t, rd)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestReadinessOptionalDependencies(t *testing.T) {
+	secondary := &testProbe{err: errors.New("Not subscribed")}
+
+	rd := newReadiness(0)
+	rd.AddOptionalDependency("pubsub:redis", secondary)
+
+	code, status := readinessRequest(t, rd)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Not subscribed", status.Checks["pubsub:redis"])
+
+	// Optional dependencies are checked every time
+	secondary.err = nil
+	_, status = readinessRequest(t, rd)
+	assert.Equal(t, "ok", status.Checks["pubsub:redis"])
+
+	secondary.err = errors.New("Connection lost")
+	code, status = readinessRequest(t, rd)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Connection lost", status.Checks["pubsub:redis"])
+}
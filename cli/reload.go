@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/utils"
+	"github.com/apex/log"
+)
+
+// Settings which could be updated at runtime (config struct paths)
+var reloadableSettings = map[string]bool{
+	"LogLevel":             true,
+	"LogFormat":            true,
+	"LogLevels":            true,
+	"App.PingInterval":     true,
+	"App.HubGopoolSize":    true,
+	"DisconnectQueue.Rate": true,
+}
+
+// rateSetter is implemented by disconnectors supporting rate updates
+type rateSetter interface {
+	SetRate(rate int)
+}
+
+// reloadSettings re-reads the configuration and applies the settings which could be changed at runtime.
+// The running configuration is left untouched if the new one is invalid.
+func (r *Runner) reloadSettings(appNode *node.Node, disconnector node.Disconnector) error {
+	c, err := r.configLoader()
+
+	if err != nil {
+		return fmt.Errorf("Failed to read configuration: %v", err)
+	}
+
+	if _, err = log.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("Unknown log level: %s", c.LogLevel)
+	}
+
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("Unknown log format: %s", c.LogFormat)
+	}
+
+	if _, err = utils.ParseLogLevels(c.LogLevels); err != nil {
+		return err
+	}
+
+	if c.App.PingInterval <= 0 {
+		return fmt.Errorf("Ping interval must be positive, got: %d", c.App.PingInterval)
+	}
+
+	if c.DisconnectQueue.Rate <= 0 {
+		return fmt.Errorf("Disconnect rate must be positive, got: %d", c.DisconnectQueue.Rate)
+	}
+
+	current := r.config
+
+	// The node identifier is resolved at startup
+	if c.WS.NodeID == "" {
+		c.WS.NodeID = current.WS.NodeID
+	}
+
+	c.Metrics.NodeID = current.Metrics.NodeID
+
+	diff := configDiff(reflect.ValueOf(*current), reflect.ValueOf(c), "")
+
+	if len(diff) == 0 {
+		log.WithField("context", "main").Info("No configuration changes")
+		return nil
+	}
+
+	if c.LogLevel != current.LogLevel || c.LogFormat != current.LogFormat || c.LogLevels != current.LogLevels {
+		if err = utils.InitLogger(c.LogFormat, c.LogLevel, c.LogLevels); err != nil {
+			return err
+		}
+
+		current.LogLevel = c.LogLevel
+		current.LogFormat = c.LogFormat
+		current.LogLevels = c.LogLevels
+	}
+
+	// Reloadable settings which couldn't be applied (e.g., not supported by the used components)
+	unsupported := map[string]bool{}
+
+	if c.App.PingInterval != current.App.PingInterval {
+		appNode.SetPingInterval(c.App.PingInterval)
+		current.App.PingInterval = c.App.PingInterval
+	}
+
+	if c.DisconnectQueue.Rate != current.DisconnectQueue.Rate {
+		if setter, ok := disconnector.(rateSetter); ok {
+			setter.SetRate(c.DisconnectQueue.Rate)
+			current.DisconnectQueue.Rate = c.DisconnectQueue.Rate
+		} else {
+			unsupported["DisconnectQueue.Rate"] = true
+		}
+	}
+
+	if c.App.HubGopoolSize != current.App.HubGopoolSize && c.App.HubGopoolSize > 0 {
+		for _, pool := range utils.AllPools() {
+			if pool.Name() == "broadcast" {
+				pool.Resize(c.App.HubGopoolSize)
+			}
+		}
+
+		current.App.HubGopoolSize = c.App.HubGopoolSize
+		r.announceGoPools()
+	}
+
+	ctx := log.WithField("context", "main")
+
+	applied := []string{}
+	notApplied := []string{}
+	ignored := []string{}
+
+	for _, path := range diff {
+		if unsupported[path] {
+			notApplied = append(notApplied, path)
+		} else if reloadableSettings[path] {
+			applied = append(applied, path)
+		} else {
+			ignored = append(ignored, path)
+		}
+	}
+
+	if len(applied) > 0 {
+		ctx.Infof("Settings updated: %s", strings.Join(applied, ", "))
+	}
+
+	if len(notApplied) > 0 {
+		ctx.Warnf("Settings changes not applied (not supported by the running components): %s", strings.Join(notApplied, ", "))
+	}
+
+	if len(ignored) > 0 {
+		ctx.Warnf("Settings changes ignored (restart required): %s", strings.Join(ignored, ", "))
+	}
+
+	return nil
+}
+
+// configDiff returns the paths of the struct fields with different values
+// (functions, e.g., runtime hooks, are skipped)
+func configDiff(a reflect.Value, b reflect.Value, prefix string) []string {
+	diff := []string{}
+
+	for i := 0; i < a.NumField(); i++ {
+		field := a.Type().Field(i)
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		path := prefix + field.Name
+		av, bv := a.Field(i), b.Field(i)
+
+		switch av.Kind() {
+		case reflect.Func:
+			continue
+		case reflect.Struct:
+			diff = append(diff, configDiff(av, bv, path+".")...)
+		default:
+			if !reflect.DeepEqual(av.Interface(), bv.Interface()) {
+				diff = append(diff, path)
+			}
+		}
+	}
+
+	return diff
+}
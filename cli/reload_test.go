@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/config"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/node"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigDiff(t *testing.T) {
+	a := config.New()
+	b := config.New()
+
+	b.Port = 9090
+	b.Redis.URL = "redis://example.com"
+	b.WS.OnRateLimited = func(_ string) {}
+
+	diff := configDiff(reflect.ValueOf(a), reflect.ValueOf(b), "")
+
+	assert.Equal(t, []string{"Redis.URL", "Port"}, diff)
+}
+
+func TestReloadSettings(t *testing.T) {
+	current := config.New()
+	current.LogLevel = "info"
+	current.LogFormat = "text"
+
+	appNode := node.NewNode(nil, metrics.NewMetrics(nil, 10), &current.App)
+	disconnector := node.NewDisconnectQueue(appNode, &current.DisconnectQueue)
+
+	reloaded := config.New()
+	reloaded.LogLevel = "info"
+	reloaded.LogFormat = "text"
+
+	runner := &Runner{
+		config:       &current,
+		configLoader: func() (config.Config, error) { return reloaded, nil },
+	}
+
+	t.Run("With invalid settings", func(t *testing.T) {
+		reloaded.LogLevel = "verbose"
+		reloaded.App.PingInterval = 10
+		defer func() { reloaded.LogLevel = "info" }()
+
+		assert.NotNil(t, runner.reloadSettings(appNode, disconnector))
+
+		assert.Equal(t, "info", current.LogLevel)
+		assert.Equal(t, 3, current.App.PingInterval)
+		assert.Equal(t, 3*time.Second, appNode.PingInterval())
+	})
+
+	t.Run("With runtime settings", func(t *testing.T) {
+		reloaded.App.PingInterval = 10
+		reloaded.DisconnectQueue.Rate = 50
+		reloaded.Port = 9090
+
+		assert.Nil(t, runner.reloadSettings(appNode, disconnector))
+
+		assert.Equal(t, 10, current.App.PingInterval)
+		assert.Equal(t, 10*time.Second, appNode.PingInterval())
+		assert.Equal(t, 50, current.DisconnectQueue.Rate)
+		// Requires restart
+		assert.Equal(t, 0, current.Port)
+	})
+
+	t.Run("With disconnector not supporting rate updates", func(t *testing.T) {
+		reloaded.DisconnectQueue.Rate = 100
+		defer func() { reloaded.DisconnectQueue.Rate = 50 }()
+
+		assert.Nil(t, runner.reloadSettings(appNode, node.NewNoopDisconnector()))
+		assert.Equal(t, 50, current.DisconnectQueue.Rate)
+	})
+
+	t.Run("With invalid log levels", func(t *testing.T) {
+		reloaded.LogLevels = "rpc=verbose"
+		defer func() { reloaded.LogLevels = "" }()
+
+		assert.NotNil(t, runner.reloadSettings(appNode, disconnector))
+		assert.Equal(t, "", current.LogLevels)
+	})
+
+	t.Run("With log levels", func(t *testing.T) {
+		reloaded.LogLevels = "rpc=debug"
+
+		assert.Nil(t, runner.reloadSettings(appNode, disconnector))
+		assert.Equal(t, "rpc=debug", current.LogLevels)
+	})
+}
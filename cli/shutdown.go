@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// ShutdownPhase defines the order of shutdown hooks execution
+type ShutdownPhase int
+
+const (
+	// Stop accepting new connections and broadcasts
+	ShutdownPhaseStopAccepting ShutdownPhase = iota
+	// Disconnect active sessions
+	ShutdownPhaseDrainSessions
+	// Close connections to external services (RPC, etc.)
+	ShutdownPhaseStopRPC
+	// Write the final metrics
+	ShutdownPhaseFlushMetrics
+	// Stop auxiliary servers (metrics, health)
+	ShutdownPhaseCloseServers
+)
+
+func (p ShutdownPhase) String() string {
+	switch p {
+	case ShutdownPhaseStopAccepting:
+		return "stop-accepting"
+	case ShutdownPhaseDrainSessions:
+		return "drain-sessions"
+	case ShutdownPhaseStopRPC:
+		return "stop-rpc"
+	case ShutdownPhaseFlushMetrics:
+		return "flush-metrics"
+	case ShutdownPhaseCloseServers:
+		return "close-servers"
+	}
+
+	return fmt.Sprintf("phase-%d", int(p))
+}
+
+type shutdownHook struct {
+	shutdownable Shutdownable
+	name         string
+	phase        ShutdownPhase
+	// Max time to wait for the hook (0 – no limit besides the Stop context)
+	timeout time.Duration
+}
+
+// ShutdownOption configures a shutdown hook
+type ShutdownOption func(*shutdownHook)
+
+// WithShutdownPhase sets the hook phase (default: ShutdownPhaseDrainSessions)
+func WithShutdownPhase(phase ShutdownPhase) ShutdownOption {
+	return func(h *shutdownHook) {
+		h.phase = phase
+	}
+}
+
+// WithShutdownTimeout sets the max time to wait for the hook to complete
+func WithShutdownTimeout(timeout time.Duration) ShutdownOption {
+	return func(h *shutdownHook) {
+		h.timeout = timeout
+	}
+}
+
+// WithShutdownName sets the hook name used in logs
+func WithShutdownName(name string) ShutdownOption {
+	return func(h *shutdownHook) {
+		h.name = name
+	}
+}
+
+// RegisterShutdownable adds a component to be shut down on Stop.
+// Hooks are executed in phase order (and in registration order within a phase).
+func (r *Runner) RegisterShutdownable(s Shutdownable, opts ...ShutdownOption) {
+	hook := &shutdownHook{
+		shutdownable: s,
+		name:         fmt.Sprintf("%T", s),
+		phase:        ShutdownPhaseDrainSessions,
+	}
+
+	for _, opt := range opts {
+		opt(hook)
+	}
+
+	r.shutdownMu.Lock()
+	defer r.shutdownMu.Unlock()
+
+	r.shutdownHooks = append(r.shutdownHooks, hook)
+}
+
+// runShutdownHooks executes hooks one by one giving each of them the rest of the context time
+// (or its own timeout if it's less). Hooks are skipped when the context is done.
+func (r *Runner) runShutdownHooks(ctx context.Context) error {
+	r.shutdownMu.Lock()
+	hooks := make([]*shutdownHook, len(r.shutdownHooks))
+	copy(hooks, r.shutdownHooks)
+	r.shutdownMu.Unlock()
+
+	sort.SliceStable(hooks, func(i, j int) bool { return hooks[i].phase < hooks[j].phase })
+
+	logger := log.WithField("context", "main")
+
+	for i, hook := range hooks {
+		if ctx.Err() != nil {
+			logger.Warnf("Shutdown timed out, %d hooks skipped", len(hooks)-i)
+			return ctx.Err()
+		}
+
+		hookCtx := ctx
+		cancel := func() {}
+
+		if hook.timeout > 0 {
+			hookCtx, cancel = context.WithTimeout(ctx, hook.timeout)
+		}
+
+		start := time.Now()
+		done := make(chan error, 1)
+
+		go func(s Shutdownable) {
+			done <- s.Shutdown()
+		}(hook.shutdownable)
+
+		select {
+		case err := <-done:
+			if err != nil {
+				logger.Errorf("Shutdown of %s (%s) failed in %v: %v", hook.name, hook.phase, time.Since(start), err)
+			} else {
+				logger.Infof("Shutdown of %s (%s) completed in %v", hook.name, hook.phase, time.Since(start))
+			}
+		case <-hookCtx.Done():
+			logger.Warnf("Shutdown of %s (%s) hasn't completed in %v, proceeding", hook.name, hook.phase, time.Since(start))
+		}
+
+		cancel()
+	}
+
+	return nil
+}
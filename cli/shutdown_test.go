@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testShutdownable struct {
+	name  string
+	delay time.Duration
+	log   chan string
+}
+
+func (s *testShutdownable) Shutdown() error {
+	time.Sleep(s.delay)
+	s.log <- s.name
+	return nil
+}
+
+func TestShutdownHooksOrder(t *testing.T) {
+	log := make(chan string, 10)
+	runner := &Runner{}
+
+	runner.RegisterShutdownable(&testShutdownable{name: "metrics", log: log}, WithShutdownPhase(ShutdownPhaseFlushMetrics))
+	runner.RegisterShutdownable(&testShutdownable{name: "node", log: log})
+	runner.RegisterShutdownable(&testShutdownable{name: "servers", log: log}, WithShutdownPhase(ShutdownPhaseCloseServers))
+	runner.RegisterShutdownable(&testShutdownable{name: "ws", log: log}, WithShutdownPhase(ShutdownPhaseStopAccepting))
+	runner.RegisterShutdownable(&testShutdownable{name: "broker", log: log}, WithShutdownPhase(ShutdownPhaseStopAccepting))
+
+	assert.Nil(t, runner.Stop(context.Background()))
+
+	close(log)
+
+	order := []string{}
+	for name := range log {
+		order = append(order, name)
+	}
+
+	assert.Equal(t, []string{"ws", "broker", "node", "metrics", "servers"}, order)
+}
+
+func TestShutdownHooksTimeouts(t *testing.T) {
+	log := make(chan string, 10)
+	runner := &Runner{}
+
+	runner.RegisterShutdownable(&testShutdownable{name: "slow", delay: time.Second, log: log}, WithShutdownTimeout(50*time.Millisecond))
+	runner.RegisterShutdownable(&testShutdownable{name: "metrics", log: log}, WithShutdownPhase(ShutdownPhaseFlushMetrics))
+
+	assert.Nil(t, runner.Stop(context.Background()))
+	assert.Equal(t, "metrics", <-log)
+}
+
+func TestShutdownHooksDeadline(t *testing.T) {
+	log := make(chan string, 10)
+	runner := &Runner{}
+
+	runner.RegisterShutdownable(&testShutdownable{name: "slow", delay: time.Second, log: log})
+	runner.RegisterShutdownable(&testShutdownable{name: "metrics", log: log}, WithShutdownPhase(ShutdownPhaseFlushMetrics))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, runner.Stop(ctx))
+
+	// Wait for the slow hook to complete to make sure the next one has been skipped
+	assert.Equal(t, "slow", <-log)
+
+	select {
+	case name := <-log:
+		t.Errorf("Hook must be skipped: %s", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestShutdownPhaseString(t *testing.T) {
+	assert.Equal(t, "drain-sessions", ShutdownPhaseDrainSessions.String())
+	assert.Equal(t, "phase-42", ShutdownPhase(42).String())
+}
@@ -0,0 +1,466 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/config"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/server"
+	"github.com/anycable/anycable-go/standalone"
+	"github.com/anycable/anycable-go/utils"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/apex/log"
+)
+
+// configCheck is a single configuration validation
+type configCheck struct {
+	// Check name (used in the validation report)
+	name string
+	// Error message prefix
+	message string
+	check   func(c *config.Config) error
+}
+
+// Validations performed before starting the server (and by --validate-config).
+// Checks must not bind ports or connect to external services.
+var configChecks = []configCheck{
+	{name: "logging", message: "Failed to initialize logger", check: checkLogging},
+	{name: "trusted proxies", message: "Failed to parse trusted proxies", check: checkTrustedProxies},
+	{name: "PROXY protocol", message: "Failed to configure PROXY protocol", check: checkProxyProtocol},
+	{name: "SSL", message: "Failed to configure SSL", check: checkSSL},
+	{name: "WebSocket", message: "Invalid WebSocket settings", check: checkWebSocket},
+	{name: "SSE", message: "Invalid SSE settings", check: checkSSE},
+	{name: "connections limit", message: "Invalid connections limit settings", check: checkConnectionsLimit},
+	{name: "commands rate limit", message: "Invalid commands rate limit settings", check: checkCommandsRateLimit},
+	{name: "disconnect", message: "Invalid disconnect settings", check: checkDisconnect},
+	{name: "session lifetime", message: "Invalid session lifetime settings", check: checkSessionLifetime},
+	{name: "history", message: "Invalid history settings", check: checkHistory},
+	{name: "RPC", message: "Invalid RPC settings", check: checkRPC},
+	{name: "broadcasting", message: "Invalid broadcasting settings", check: checkBroadcasting},
+	{name: "metrics", message: "Invalid metrics settings", check: checkMetrics},
+	{name: "tracing", message: "Invalid tracing settings", check: checkTracing},
+	{name: "webhook", message: "Invalid webhook settings", check: checkWebhook},
+	{name: "debug API", message: "Invalid debug API settings", check: checkDebugAPI},
+	{name: "events", message: "Invalid session events settings", check: checkEvents},
+	{name: "messages", message: "Invalid messages settings", check: checkMessages},
+}
+
+// validateConfig runs all the checks and returns the first error
+func validateConfig(c *config.Config) error {
+	for _, ch := range configChecks {
+		if err := ch.check(c); err != nil {
+			return fmt.Errorf("!!! %s !!!\n%v", ch.message, err)
+		}
+	}
+
+	return nil
+}
+
+// printValidationReport runs all the checks and prints the results.
+// Returns false if any check failed.
+func printValidationReport(w io.Writer, c *config.Config) bool {
+	valid := true
+
+	for _, ch := range configChecks {
+		if err := ch.check(c); err != nil {
+			valid = false
+			fmt.Fprintf(w, "FAIL  %s: %v\n", ch.name, err)
+		} else {
+			fmt.Fprintf(w, "OK    %s\n", ch.name)
+		}
+	}
+
+	if valid {
+		fmt.Fprintln(w, "\nConfiguration is valid")
+	} else {
+		fmt.Fprintln(w, "\nConfiguration is invalid")
+	}
+
+	return valid
+}
+
+func checkLogging(c *config.Config) error {
+	if _, err := log.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("Unknown log level: %s", c.LogLevel)
+	}
+
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		return fmt.Errorf("Unknown log format: %s", c.LogFormat)
+	}
+
+	if _, err := utils.ParseLogLevels(c.LogLevels); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func checkTrustedProxies(c *config.Config) error {
+	_, err := server.ParseCIDRs(c.TrustedProxies)
+	return err
+}
+
+func checkProxyProtocol(c *config.Config) error {
+	if !c.ProxyProtocol.Enabled {
+		return nil
+	}
+
+	_, err := server.ParseCIDRs(c.ProxyProtocol.OptionalCIDRs)
+	return err
+}
+
+func checkSSL(c *config.Config) error {
+	if c.SSL.CertPath == "" && c.SSL.KeyPath == "" {
+		return nil
+	}
+
+	if !c.SSL.Available() {
+		return errors.New("Both ssl_cert and ssl_key must be specified")
+	}
+
+	return c.SSL.Validate()
+}
+
+func checkWebSocket(c *config.Config) error {
+	if level := c.WS.CompressionLevel; c.WS.EnableCompression && (level < 1 || level > 9) {
+		return fmt.Errorf("Compression level must be between 1 and 9, got: %d", level)
+	}
+
+	if c.WS.RateLimit < 0 {
+		return fmt.Errorf("Rate limit must be non-negative: %d", c.WS.RateLimit)
+	}
+
+	if c.WS.RateLimit > 0 {
+		if c.WS.RateLimitInterval < 1 {
+			return fmt.Errorf("Rate limit interval must be positive: %d", c.WS.RateLimitInterval)
+		}
+
+		if c.WS.RateLimitBurst < 0 {
+			return fmt.Errorf("Rate limit burst must be non-negative: %d", c.WS.RateLimitBurst)
+		}
+
+		// Evicting buckets right away would reset the limits for every connection
+		if c.WS.RateLimitCacheSize < 1 {
+			return fmt.Errorf("Rate limit cache size must be positive: %d", c.WS.RateLimitCacheSize)
+		}
+	}
+
+	if _, err := server.ParseCIDRs(c.WS.RateLimitExempt); err != nil {
+		return fmt.Errorf("Failed to parse rate limit exempt CIDRs: %v", err)
+	}
+
+	if _, err := ws.ParseEnvMeta(c.WS.EnvMeta); err != nil {
+		return err
+	}
+
+	if c.WS.SlowStartDuration < 0 {
+		return fmt.Errorf("Slow start duration must be non-negative: %d", c.WS.SlowStartDuration)
+	}
+
+	if c.WS.SlowStartDuration > 0 {
+		if c.WS.SlowStartRate < 1 {
+			return fmt.Errorf("Slow start rate must be positive: %d", c.WS.SlowStartRate)
+		}
+
+		if ramp := c.WS.SlowStartRamp; ramp != ws.SlowStartLinear && ramp != ws.SlowStartExponential {
+			return fmt.Errorf("Unknown slow start ramp: %s", ramp)
+		}
+	}
+
+	return nil
+}
+
+func checkSSE(c *config.Config) error {
+	if !c.SSE.Enabled() {
+		return nil
+	}
+
+	if c.SSE.Path == c.Path {
+		return fmt.Errorf("SSE path must be different from the WebSocket path: %s", c.SSE.Path)
+	}
+
+	if c.SSE.IdleTimeout <= 0 {
+		return fmt.Errorf("SSE idle timeout must be positive: %d", c.SSE.IdleTimeout)
+	}
+
+	if c.SSE.BufferSize <= 0 {
+		return fmt.Errorf("SSE buffer size must be positive: %d", c.SSE.BufferSize)
+	}
+
+	return nil
+}
+
+func checkConnectionsLimit(c *config.Config) error {
+	if mode := c.App.ConnectionsLimitMode; mode != node.ConnectionsLimitReject && mode != node.ConnectionsLimitKickOldest {
+		return fmt.Errorf("Unknown connections limit mode: %s", mode)
+	}
+
+	if c.App.MaxSessions < 0 {
+		return fmt.Errorf("Max sessions must be non-negative: %d", c.App.MaxSessions)
+	}
+
+	if c.App.SessionsSoftLimit < 0 {
+		return fmt.Errorf("Sessions soft limit must be non-negative: %d", c.App.SessionsSoftLimit)
+	}
+
+	if c.App.MaxSessions > 0 && c.App.SessionsSoftLimit > c.App.MaxSessions {
+		return fmt.Errorf("Sessions soft limit (%d) must not exceed max sessions (%d)", c.App.SessionsSoftLimit, c.App.MaxSessions)
+	}
+
+	return nil
+}
+
+func checkCommandsRateLimit(c *config.Config) error {
+	values := []struct {
+		name  string
+		value int
+	}{
+		{"commands_rate_limit_subscribe", c.App.CommandsRateLimitSubscribe},
+		{"commands_rate_limit_subscribe_burst", c.App.CommandsRateLimitSubscribeBurst},
+		{"commands_rate_limit_perform", c.App.CommandsRateLimitPerform},
+		{"commands_rate_limit_perform_burst", c.App.CommandsRateLimitPerformBurst},
+		{"commands_rate_limit_max_violations", c.App.CommandsRateLimitMaxViolations},
+	}
+
+	for _, v := range values {
+		if v.value < 0 {
+			return fmt.Errorf("%s must be non-negative: %d", v.name, v.value)
+		}
+	}
+
+	if _, err := node.ParseCommandsRateLimitChannels(c.App.CommandsRateLimitChannels); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func checkDisconnect(c *config.Config) error {
+	if mode := c.App.DisconnectMode; !common.ValidDisconnectMode(mode) {
+		return fmt.Errorf("Unknown disconnect mode: %s", mode)
+	}
+
+	return nil
+}
+
+func checkSessionLifetime(c *config.Config) error {
+	if c.App.SessionMaxLifetime < 0 {
+		return fmt.Errorf("Session max lifetime must be non-negative: %d", c.App.SessionMaxLifetime)
+	}
+
+	if jitter := c.App.SessionLifetimeJitter; jitter < 0 || jitter > 100 {
+		return fmt.Errorf("Session lifetime jitter must be between 0 and 100: %d", jitter)
+	}
+
+	if c.App.ExpiryWarning < 0 {
+		return fmt.Errorf("Expiry warning must be non-negative: %d", c.App.ExpiryWarning)
+	}
+
+	if c.App.SessionStoreMaxSize < 0 {
+		return fmt.Errorf("Session store max size must be non-negative: %d", c.App.SessionStoreMaxSize)
+	}
+
+	return nil
+}
+
+func checkHistory(c *config.Config) error {
+	if c.App.HistoryLimit < 0 {
+		return fmt.Errorf("History limit must be non-negative: %d", c.App.HistoryLimit)
+	}
+
+	if c.App.HistoryTTL < 0 {
+		return fmt.Errorf("History TTL must be non-negative: %d", c.App.HistoryTTL)
+	}
+
+	// History is enabled by the limit only, TTL alone would keep an unbounded number of messages
+	if c.App.HistoryLimit == 0 {
+		if c.App.HistoryTTL > 0 {
+			return fmt.Errorf("History TTL requires history limit to be set: history_ttl=%d, history_limit=0", c.App.HistoryTTL)
+		}
+
+		return nil
+	}
+
+	if c.App.HistoryMaxStreams <= 0 {
+		return fmt.Errorf("History max streams must be positive: %d", c.App.HistoryMaxStreams)
+	}
+
+	return nil
+}
+
+func checkRPC(c *config.Config) error {
+	switch c.RPC.Implementation {
+	case rpcImplGRPC:
+		if c.RPC.Host == "" || c.RPC.Host == "unix://" {
+			return errors.New("RPC host must be specified")
+		}
+	case rpcImplNone:
+		if _, err := standalone.ParseChannels(c.Standalone.Channels); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown RPC implementation: %s", c.RPC.Implementation)
+	}
+
+	switch c.App.RPCUnavailableStrategy {
+	case node.RPCUnavailableReject, node.RPCUnavailableAllowAnonymous:
+	case node.RPCUnavailableQueue:
+		if c.App.RPCUnavailableQueueTimeout <= 0 {
+			return errors.New("RPC unavailable queue timeout must be positive")
+		}
+	default:
+		return fmt.Errorf("Unknown RPC unavailable strategy: %s", c.App.RPCUnavailableStrategy)
+	}
+
+	return nil
+}
+
+func checkBroadcasting(c *config.Config) error {
+	if mode := c.App.BinaryBroadcasts; mode != node.BinaryBroadcastsDrop && mode != node.BinaryBroadcastsBase64 {
+		return fmt.Errorf("Unknown binary broadcasts mode: %s", mode)
+	}
+
+	if pattern := c.App.AllowedBroadcastStreams; pattern != "" {
+		if _, err := node.NewStreamPatternFilter(pattern); err != nil {
+			return fmt.Errorf("Invalid allowed broadcast streams pattern: %v", err)
+		}
+	}
+
+	if ns := c.App.StreamsNamespace; strings.Contains(ns, node.StreamsNamespaceSeparator) || strings.TrimSpace(ns) != ns {
+		return fmt.Errorf("Streams namespace must not contain %q and leading or trailing spaces: %q", node.StreamsNamespaceSeparator, ns)
+	}
+
+	if err := checkBroadcastAdapter(c.BroadcastAdapter, c); err != nil {
+		return err
+	}
+
+	if c.SecondaryAdapter == "" {
+		return nil
+	}
+
+	if c.SecondaryAdapter == c.BroadcastAdapter {
+		return fmt.Errorf("Secondary broadcast adapter must differ from the primary one: %s", c.BroadcastAdapter)
+	}
+
+	if c.App.BroadcastDedupSize <= 0 || c.App.BroadcastDedupTTL <= 0 {
+		return errors.New("Broadcast deduplication size and TTL must be positive")
+	}
+
+	return checkBroadcastAdapter(c.SecondaryAdapter, c)
+}
+
+func checkBroadcastAdapter(adapter string, c *config.Config) error {
+	switch adapter {
+	case "redis":
+		return c.Redis.Validate()
+	case "http":
+		return nil
+	}
+
+	return fmt.Errorf("Unknown broadcast adapter: %s", adapter)
+}
+
+func checkMetrics(c *config.Config) error {
+	m := &c.Metrics
+
+	if m.SSL.CertPath != "" || m.SSL.KeyPath != "" {
+		if !m.SSL.Available() {
+			return errors.New("Both metrics_ssl_cert and metrics_ssl_key must be specified to serve metrics over TLS")
+		}
+
+		if err := m.SSL.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// A dedicated metrics server can't use the main server port
+	dedicated := m.SSL.Available() || (m.Host != "" && m.Host != c.Host)
+
+	if m.HTTPEnabled() && dedicated && m.Port == c.Port {
+		return fmt.Errorf("Metrics server with custom host or TLS settings must use a separate port (metrics_port), got: %d", m.Port)
+	}
+
+	if _, err := server.ParseCIDRs(m.AllowedCIDRs); err != nil {
+		return fmt.Errorf("Failed to parse metrics allowed CIDRs: %v", err)
+	}
+
+	if m.OTLPEnabled() {
+		if err := m.ValidateOTLP(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkTracing(c *config.Config) error {
+	if !c.Tracing.Enabled() {
+		return nil
+	}
+
+	return c.Tracing.Validate()
+}
+
+func checkWebhook(c *config.Config) error {
+	if !c.Webhook.Enabled() {
+		return nil
+	}
+
+	return c.Webhook.Validate()
+}
+
+func checkDebugAPI(c *config.Config) error {
+	if c.DebugPath == "" {
+		return nil
+	}
+
+	if c.DebugToken == "" {
+		return errors.New("Debug API token (debug_api_token) must be specified to enable the debug endpoints")
+	}
+
+	if c.DebugPath == c.HealthPath || c.DebugPath == c.InfoPath || c.DebugPath == c.ReadyPath {
+		return fmt.Errorf("Debug API path must not conflict with other health server endpoints: %s", c.DebugPath)
+	}
+
+	return nil
+}
+
+func checkEvents(c *config.Config) error {
+	switch c.App.EventsSink {
+	case "":
+		return nil
+	case node.EventsSinkLog:
+	case node.EventsSinkRedis:
+		if c.App.EventsChannel == "" {
+			return errors.New("Events channel must be specified for the redis sink")
+		}
+
+		if err := c.Redis.Validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown events sink: %s", c.App.EventsSink)
+	}
+
+	if c.App.EventsBufferSize <= 0 {
+		return errors.New("Events buffer size must be positive")
+	}
+
+	return nil
+}
+
+func checkMessages(c *config.Config) error {
+	if _, err := node.ParseWelcomeExtra(c.App.WelcomeExtra); err != nil {
+		return err
+	}
+
+	if _, err := node.ParseDisconnectExtra(c.App.DisconnectExtra); err != nil {
+		return err
+	}
+
+	return nil
+}
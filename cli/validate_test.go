@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/anycable/anycable-go/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func validTestConfig() config.Config {
+	c := config.New()
+	c.LogLevel = "info"
+	c.LogFormat = "text"
+	c.Port = 8080
+	c.BroadcastAdapter = "redis"
+	c.Redis.URL = "redis://localhost:6379/5"
+	c.RPC.Host = "localhost:50051"
+	c.Metrics.Port = 8080
+	return c
+}
+
+func TestValidateConfig(t *testing.T) {
+	c := validTestConfig()
+	assert.Nil(t, validateConfig(&c))
+
+	var out bytes.Buffer
+	assert.True(t, printValidationReport(&out, &c))
+	assert.Contains(t, out.String(), "Configuration is valid")
+}
+
+func TestValidateConfigErrors(t *testing.T) {
+	tests := map[string]func(c *config.Config){
+		"Failed to initialize logger":     func(c *config.Config) { c.LogFormat = "xml" },
+		"Failed to parse trusted proxies": func(c *config.Config) { c.TrustedProxies = "10.0.0.0/33" },
+		"Failed to configure SSL": func(c *config.Config) {
+			c.SSL.CertPath = filepath.Join(t.TempDir(), "missing.crt")
+			c.SSL.KeyPath = filepath.Join(t.TempDir(), "missing.key")
+		},
+		"Invalid WebSocket settings": func(c *config.Config) {
+			c.WS.EnableCompression = true
+			c.WS.CompressionLevel = 10
+		},
+		"Invalid SSE settings": func(c *config.Config) {
+			c.SSE.Path = "/sse"
+			c.SSE.BufferSize = 0
+		},
+		"Invalid connections limit settings":   func(c *config.Config) { c.App.ConnectionsLimitMode = "kick_all" },
+		"Invalid commands rate limit settings": func(c *config.Config) { c.App.CommandsRateLimitChannels = "CursorChannel" },
+		"Invalid disconnect settings":          func(c *config.Config) { c.App.DisconnectMode = "sometimes" },
+		"Invalid session lifetime settings":    func(c *config.Config) { c.App.SessionLifetimeJitter = 120 },
+		"Invalid history settings":             func(c *config.Config) { c.App.HistoryTTL = 60 },
+		"Invalid RPC settings":                 func(c *config.Config) { c.RPC.Implementation = "http" },
+		"Invalid broadcasting settings":        func(c *config.Config) { c.Redis.URL = "localhost:6379" },
+		"Invalid metrics settings": func(c *config.Config) {
+			c.Metrics.HTTP = "/metrics"
+			c.Metrics.Host = "0.0.0.0"
+		},
+		"Invalid tracing settings": func(c *config.Config) {
+			c.Tracing.OTLPEndpoint = "http://localhost:4318"
+			c.Tracing.SampleRate = 1.5
+		},
+		"Invalid webhook settings":        func(c *config.Config) { c.Webhook.URL = "localhost:8080" },
+		"Invalid debug API settings":      func(c *config.Config) { c.DebugPath = "/debug" },
+		"Invalid session events settings": func(c *config.Config) { c.App.EventsSink = "kafka" },
+		"Invalid messages settings":       func(c *config.Config) { c.App.WelcomeExtra = "features=history" },
+	}
+
+	for message, mutate := range tests {
+		c := validTestConfig()
+		mutate(&c)
+
+		err := validateConfig(&c)
+
+		if assert.NotNil(t, err, message) {
+			assert.Contains(t, err.Error(), message)
+		}
+
+		var out bytes.Buffer
+		assert.False(t, printValidationReport(&out, &c), message)
+		assert.Contains(t, out.String(), "FAIL")
+	}
+}
+
+func TestValidateMetricsOTLP(t *testing.T) {
+	c := validTestConfig()
+	c.Metrics.OTLPEndpoint = "http://localhost:4318"
+
+	assert.Nil(t, validateConfig(&c))
+
+	c.Metrics.OTLPProtocol = "grpc"
+
+	err := validateConfig(&c)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Unsupported OTLP protocol: grpc")
+
+	c.Metrics.OTLPProtocol = "http"
+	c.Metrics.OTLPHeaders = "authorization"
+
+	assert.NotNil(t, validateConfig(&c))
+}
+
+func TestValidateSecondaryAdapter(t *testing.T) {
+	c := validTestConfig()
+	c.SecondaryAdapter = "http"
+	assert.Nil(t, validateConfig(&c))
+
+	c.SecondaryAdapter = "redis"
+	assert.Contains(t, validateConfig(&c).Error(), "must differ from the primary one")
+
+	c.SecondaryAdapter = "nats"
+	assert.Contains(t, validateConfig(&c).Error(), "Unknown broadcast adapter: nats")
+
+	c.SecondaryAdapter = "http"
+	c.App.BroadcastDedupSize = 0
+	assert.Contains(t, validateConfig(&c).Error(), "must be positive")
+}
+
+func TestValidateRPCUnavailableStrategy(t *testing.T) {
+	c := validTestConfig()
+	c.App.RPCUnavailableStrategy = "allow_anonymous"
+	assert.Nil(t, validateConfig(&c))
+
+	c.App.RPCUnavailableStrategy = "retry"
+	assert.Contains(t, validateConfig(&c).Error(), "Unknown RPC unavailable strategy: retry")
+
+	c.App.RPCUnavailableStrategy = "queue"
+	c.App.RPCUnavailableQueueTimeout = 0
+	assert.Contains(t, validateConfig(&c).Error(), "queue timeout must be positive")
+}
+
+func TestValidateMaxSessions(t *testing.T) {
+	c := validTestConfig()
+	c.App.MaxSessions = 100
+	c.App.SessionsSoftLimit = 80
+	assert.Nil(t, validateConfig(&c))
+
+	c.App.SessionsSoftLimit = 120
+	assert.Contains(t, validateConfig(&c).Error(), "Sessions soft limit (120) must not exceed max sessions (100)")
+
+	c.App.MaxSessions = -1
+	assert.Contains(t, validateConfig(&c).Error(), "Max sessions must be non-negative: -1")
+}
+
+func TestValidateStreamsNamespace(t *testing.T) {
+	c := validTestConfig()
+	c.App.StreamsNamespace = "staging"
+	assert.Nil(t, validateConfig(&c))
+
+	c.App.StreamsNamespace = "staging:eu"
+	assert.Contains(t, validateConfig(&c).Error(), "Streams namespace must not contain \":\"")
+
+	c.App.StreamsNamespace = "staging "
+	assert.Contains(t, validateConfig(&c).Error(), "Streams namespace must not contain")
+}
+
+func TestValidateHistory(t *testing.T) {
+	c := validTestConfig()
+	c.App.HistoryLimit = 100
+	c.App.HistoryTTL = 300
+	assert.Nil(t, validateConfig(&c))
+
+	c.App.HistoryMaxStreams = 0
+	assert.Contains(t, validateConfig(&c).Error(), "History max streams must be positive: 0")
+
+	c.App.HistoryMaxStreams = 10
+	c.App.HistoryLimit = 0
+	assert.Contains(t, validateConfig(&c).Error(), "History TTL requires history limit to be set")
+
+	c.App.HistoryLimit = -1
+	assert.Contains(t, validateConfig(&c).Error(), "History limit must be non-negative: -1")
+}
+
+func TestValidateWebSocketRateLimit(t *testing.T) {
+	c := validTestConfig()
+	c.WS.RateLimit = 10
+	assert.Nil(t, validateConfig(&c))
+
+	c.WS.RateLimitCacheSize = 0
+	assert.Contains(t, validateConfig(&c).Error(), "Rate limit cache size must be positive: 0")
+
+	c.WS.RateLimitCacheSize = 100
+	c.WS.RateLimitInterval = 0
+	assert.Contains(t, validateConfig(&c).Error(), "Rate limit interval must be positive: 0")
+
+	c.WS.RateLimit = -1
+	assert.Contains(t, validateConfig(&c).Error(), "Rate limit must be non-negative: -1")
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"sync"
+	"time"
+
+	"github.com/anycable/anycable-go/config"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/pubsub"
+	"github.com/anycable/anycable-go/webhook"
+	"github.com/apex/log"
+)
+
+// drainNotifier reports the shutdown progress via webhooks
+type drainNotifier struct {
+	notifier *webhook.Notifier
+	node     *node.Node
+
+	mu        sync.Mutex
+	startedAt time.Time
+	sessions  int
+}
+
+// shutdownInitiated must be called before any other shutdown hook
+func (d *drainNotifier) shutdownInitiated() error {
+	// Components going down during shutdown are expected, no need to report them
+	d.notifier.StopWatching()
+
+	sessions := d.node.Size()
+
+	d.mu.Lock()
+	d.startedAt = time.Now()
+	d.sessions = sessions
+	d.mu.Unlock()
+
+	d.notifier.Notify(webhook.EventShutdownInitiated, map[string]interface{}{"sessions": sessions})
+
+	return nil
+}
+
+// drainCompleted must be called right after the sessions have been drained
+func (d *drainNotifier) drainCompleted() error {
+	d.mu.Lock()
+	duration := time.Since(d.startedAt)
+	sessions := d.sessions
+	d.mu.Unlock()
+
+	d.notifier.Notify(webhook.EventDrainCompleted, map[string]interface{}{
+		"sessions_drained": sessions,
+		"duration_ms":      duration.Milliseconds(),
+	})
+
+	return nil
+}
+
+// initWebhooks creates the lifecycle events notifier (nil if webhooks are disabled).
+// Shutdown hooks are executed in registration order within a phase, so it must be called
+// before any other component is registered for the stop-accepting and stop-rpc phases.
+func (r *Runner) initWebhooks(n *node.Node, controller node.Controller, m *metrics.Metrics, c *config.Config, nodeID string) (*webhook.Notifier, error) {
+	if !c.Webhook.Enabled() {
+		return nil, nil
+	}
+
+	notifier, err := webhook.NewNotifier(&c.Webhook, m, nodeID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	go notifier.Run()
+
+	drain := &drainNotifier{notifier: notifier, node: n}
+
+	r.RegisterShutdownable(shutdownableFunc(drain.shutdownInitiated), WithShutdownPhase(ShutdownPhaseStopAccepting), WithShutdownName("webhooks: shutdown initiated"))
+	r.RegisterShutdownable(shutdownableFunc(drain.drainCompleted), WithShutdownPhase(ShutdownPhaseStopRPC), WithShutdownName("webhooks: drain completed"))
+	// Deliver events from all the previous phases
+	r.RegisterShutdownable(notifier, WithShutdownPhase(ShutdownPhaseCloseServers), WithShutdownName("webhooks"))
+
+	if probe, ok := controller.(ReadinessProbe); ok {
+		notifier.Watch(probe.Ready, webhook.EventRPCLost, webhook.EventRPCRestored, nil)
+	}
+
+	log.WithField("context", "main").Infof("Lifecycle events are posted to %s", redactOption("webhook_url", c.Webhook.URL))
+
+	return notifier, nil
+}
+
+// watchBroadcastAdapters reports broadcast adapters connection state changes via webhooks
+func watchBroadcastAdapters(notifier *webhook.Notifier, s pubsub.Subscriber, c *config.Config) {
+	if failover, ok := s.(*pubsub.FailoverSubscriber); ok {
+		for _, adapter := range failover.Adapters() {
+			data := map[string]interface{}{"adapter": adapter.Name}
+			notifier.Watch(adapter.Healthy, webhook.EventSubscriberLost, webhook.EventSubscriberReconnected, data)
+		}
+
+		return
+	}
+
+	if probe, ok := s.(ReadinessProbe); ok {
+		data := map[string]interface{}{"adapter": c.BroadcastAdapter}
+		notifier.Watch(probe.Ready, webhook.EventSubscriberLost, webhook.EventSubscriberReconnected, data)
+	}
+}
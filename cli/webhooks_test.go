@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/config"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/anycable/anycable-go/node"
+	"github.com/anycable/anycable-go/pubsub"
+	"github.com/anycable/anycable-go/server"
+	"github.com/anycable/anycable-go/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunnerWebhooks(t *testing.T) {
+	var mu sync.Mutex
+	var events []*webhook.Event
+
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhook.Event
+
+		if err := json.NewDecoder(r.Body).Decode(&event); err == nil {
+			mu.Lock()
+			events = append(events, &event)
+			mu.Unlock()
+		}
+	}))
+	defer endpoint.Close()
+
+	c := validTestConfig()
+	c.Host = server.UnixSocketPrefix + filepath.Join(t.TempDir(), "anycable.sock")
+	// Servers are shared by port, so we must use a different one
+	c.Port = 8091
+	c.Metrics.Port = 8091
+	c.DisconnectorDisabled = true
+	c.Path = "/cable"
+	c.HealthPath = "/health"
+	c.WS.NodeID = "test-node"
+	c.Webhook.URL = endpoint.URL
+
+	runner := NewRunner("test", &c)
+	runner.DisableSignalHandlers()
+
+	runner.ControllerFactory(func(_ *metrics.Metrics, _ *config.Config) (node.Controller, error) {
+		controller := mocks.NewMockController()
+		return &controller, nil
+	})
+
+	runner.SubscriberFactory(func(_ pubsub.Handler, _ *config.Config) (pubsub.Subscriber, error) {
+		return &testSubscriber{}, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.Nil(t, runner.Start(ctx))
+	assert.Nil(t, runner.Stop(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	types := []string{}
+
+	for _, event := range events {
+		types = append(types, event.Type)
+		assert.Equal(t, "test-node", event.NodeID)
+	}
+
+	assert.Equal(t, []string{webhook.EventNodeStarted, webhook.EventShutdownInitiated, webhook.EventDrainCompleted}, types)
+
+	if len(events) == 3 {
+		assert.Equal(t, float64(0), events[2].Data["sessions_drained"])
+	}
+}
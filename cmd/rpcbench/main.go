@@ -57,7 +57,7 @@ func main() {
 		logLevel = "debug"
 	}
 
-	err := utils.InitLogger("text", logLevel)
+	err := utils.InitLogger("text", logLevel, "")
 
 	if err != nil {
 		log.Errorf("!!! Failed to initialize logger !!!\n%v", err)
@@ -71,7 +71,7 @@ func main() {
 		logLevel = "debug"
 	}
 
-	err := utils.InitLogger("text", logLevel)
+	err := utils.InitLogger("text", logLevel, "")
 
 	if err != nil {
 		log.Errorf("!!! Failed to initialize logger !!!\n%v", err)
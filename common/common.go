@@ -3,9 +3,13 @@ package common
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 )
 
+// ErrRPCUnavailable is returned (wrapped) by controllers when the RPC server couldn't be reached
+var ErrRPCUnavailable = errors.New("RPC is unavailable")
+
 // Command result status
 const (
 	SUCCESS = iota
@@ -23,8 +27,60 @@ const (
 	RejectedType   = "reject_subscription"
 	// Not suppurted by Action Cable currently
 	UnsubscribedType = "unsubscribed"
+	// Stream stopped by the application (the channel subscription is kept)
+	StreamStoppedType = "stream_stopped"
+	// History replay results
+	HistoryConfirmedType = "confirm_history"
+	HistoryRejectedType  = "reject_history"
+	// Credentials refresh results
+	RefreshConfirmedType = "confirm_refresh"
+	// Server warnings (e.g., credentials are about to expire)
+	WarningType = "warning"
+	// Commands rejected by the server without processing (e.g., due to rate limiting)
+	CommandRejectedType = "reject_command"
 )
 
+// Subscription rejection reasons
+const (
+	RejectionInvalidSignature = "invalid_signature"
+	RejectionRPCRejected      = "rpc_rejected"
+	RejectionLimitExceeded    = "limit_exceeded"
+	RejectionUnknownChannel   = "unknown_channel"
+	RejectionRPCError         = "rpc_error"
+	RejectionRateLimited      = "rate_limited"
+)
+
+// Disconnect modes (whether to call Disconnect RPC when the session is closed)
+const (
+	DisconnectModeAlways           = "always"
+	DisconnectModeNever            = "never"
+	DisconnectModeOnlyIfSubscribed = "only_if_subscribed"
+)
+
+// DisconnectModeStateKey is a reserved connection state key used by RPC servers
+// to set the session disconnect mode in Connect responses
+const DisconnectModeStateKey = "__disconnect_mode__"
+
+// ExpiresAtStateKey is a reserved connection state key used by RPC servers
+// to pass the session credentials expiration time (Unix seconds) in Connect responses
+const ExpiresAtStateKey = "__expires_at__"
+
+// Headers added to the Connect request env when the session credentials are refreshed
+const (
+	RefreshHeader      = "x-anycable-refresh"
+	RefreshTokenHeader = "x-anycable-refresh-token"
+)
+
+// ValidDisconnectMode returns true if the mode is supported
+func ValidDisconnectMode(mode string) bool {
+	switch mode {
+	case DisconnectModeAlways, DisconnectModeNever, DisconnectModeOnlyIfSubscribed:
+		return true
+	}
+
+	return false
+}
+
 // SessionEnv represents the underlying HTTP connection data:
 // URL and request headers
 type SessionEnv struct {
@@ -32,6 +88,8 @@ type SessionEnv struct {
 	Headers         *map[string]string
 	ConnectionState *map[string]string
 	ChannelStates   *map[string]map[string]string
+	// Session-scoped key-value storage (available to controllers)
+	Store *SessionStore
 }
 
 // NewSessionEnv builds a new SessionEnv
@@ -43,6 +101,7 @@ func NewSessionEnv(url string, headers *map[string]string) *SessionEnv {
 		Headers:         headers,
 		ConnectionState: &state,
 		ChannelStates:   &channels,
+		Store:           NewSessionStore(0, nil),
 	}
 }
 
@@ -107,6 +166,14 @@ func (st *SessionEnv) SetHeader(key string, val string) {
 	(*st.Headers)[key] = val
 }
 
+// DisconnectRequest contains information required to perform a Disconnect call
+type DisconnectRequest struct {
+	UID           string
+	Env           *SessionEnv
+	Identifiers   string
+	Subscriptions []string
+}
+
 // CallResult contains shared RPC result fields
 type CallResult struct {
 	Transmissions []string
@@ -123,6 +190,10 @@ type ConnectResult struct {
 	CState        map[string]string
 	IState        map[string]string
 	Status        int
+	// Disconnect mode requested by the application (empty – use the default one)
+	DisconnectMode string
+	// Credentials expiration time (Unix seconds, 0 – unknown)
+	ExpiresAt int64
 }
 
 // ToCallResult returns the corresponding CallResult
@@ -151,6 +222,24 @@ type CommandResult struct {
 	CState         map[string]string
 	IState         map[string]string
 	Status         int
+	// Why the subscription has been rejected (see Rejection* constants)
+	RejectionReason string
+}
+
+// NewRejectedSubscriptionResult returns a failed command result with the reject_subscription transmission
+// and the specified rejection reason (could be used by custom controllers)
+func NewRejectedSubscriptionResult(identifier string, reason string) *CommandResult {
+	// Marshaling strings never fails
+	reply, _ := json.Marshal(struct {
+		Identifier string `json:"identifier"`
+		Type       string `json:"type"`
+	}{identifier, RejectedType})
+
+	return &CommandResult{
+		Status:          FAILURE,
+		Transmissions:   []string{string(reply)},
+		RejectionReason: reason,
+	}
 }
 
 // ToCallResult returns the corresponding CallResult
@@ -170,12 +259,40 @@ type Message struct {
 	Command    string      `json:"command"`
 	Identifier string      `json:"identifier"`
 	Data       interface{} `json:"data"`
+	// History to replay (for "history" and "subscribe" commands)
+	History *HistoryRequest `json:"history,omitempty"`
+	// Whether to enable at-least-once delivery for the subscription (for "subscribe" command)
+	Reliable bool `json:"reliable,omitempty"`
+	// Acknowledged message ID (for "ack" command)
+	ID uint64 `json:"id,omitempty"`
+	// New credentials (for "refresh" command)
+	Token string `json:"token,omitempty"`
+}
+
+// HistoryPosition is the last received stream message position
+type HistoryPosition struct {
+	Epoch  string `json:"epoch"`
+	Offset uint64 `json:"offset"`
+}
+
+// HistoryRequest describes the stream messages to replay:
+// messages after the specified positions (per stream) or, for other streams, messages since the specified time (Unix seconds)
+type HistoryRequest struct {
+	Since   int64                      `json:"since,omitempty"`
+	Streams map[string]HistoryPosition `json:"streams,omitempty"`
 }
 
 // StreamMessage represents a pub/sub message to be sent to stream
 type StreamMessage struct {
 	Stream string `json:"stream"`
 	Data   string `json:"data"`
+	// Pre-serialized binary payload (base64-encoded in JSON)
+	Binary []byte `json:"binary,omitempty"`
+	// Optional unique broadcast ID (used to deduplicate messages received via multiple adapters)
+	BroadcastID string `json:"broadcast_id,omitempty"`
+	// Stream history position (set by the node when history is enabled)
+	Offset uint64 `json:"-"`
+	Epoch  string `json:"-"`
 }
 
 // RemoteCommandMessage represents a pub/sub message with a remote command (e.g., disconnect)
@@ -190,6 +307,17 @@ type RemoteDisconnectMessage struct {
 	Reconnect  bool   `json:"reconnect"`
 }
 
+// StopStreamMessage contains information required to unsubscribe all the sessions from a stream
+type StopStreamMessage struct {
+	Stream string `json:"stream"`
+	// Optional payload to deliver to the stream subscribers before stopping the stream
+	Data string `json:"data,omitempty"`
+	// Whether to notify the stream subscribers with the "unsubscribed" message
+	Notify bool `json:"notify,omitempty"`
+	// Optional unique broadcast ID (used to deduplicate messages received via multiple adapters)
+	BroadcastID string `json:"broadcast_id,omitempty"`
+}
+
 // PingMessage represents a server ping
 type PingMessage struct {
 	Type    string      `json:"type"`
@@ -205,17 +333,79 @@ type DisconnectMessage struct {
 	Type      string `json:"type"`
 	Reason    string `json:"reason"`
 	Reconnect bool   `json:"reconnect"`
+	// Suggested reconnection delay in seconds (if any)
+	RetryAfter int `json:"retry_after,omitempty"`
+	// Custom fields to add to the message (standard fields can't be overridden)
+	Extra map[string]interface{} `json:"-"`
 }
 
 func (d *DisconnectMessage) GetType() string {
 	return DisconnectType
 }
 
+// MarshalJSON merges the extra fields into the message
+func (d *DisconnectMessage) MarshalJSON() ([]byte, error) {
+	type plain DisconnectMessage
+
+	b, err := json.Marshal((*plain)(d))
+
+	if err != nil || len(d.Extra) == 0 {
+		return b, err
+	}
+
+	var base map[string]interface{}
+
+	if err = json.Unmarshal(b, &base); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]interface{}, len(d.Extra)+len(base))
+
+	for k, v := range d.Extra {
+		fields[k] = v
+	}
+
+	for k, v := range base {
+		fields[k] = v
+	}
+
+	return json.Marshal(fields)
+}
+
+// WarningMessage represents a server warning (e.g., the session credentials are about to expire)
+type WarningMessage struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	// Credentials expiration time (Unix seconds)
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+}
+
+func (w *WarningMessage) GetType() string {
+	return WarningType
+}
+
+// CommandRejectedMessage is sent to clients when the command is rejected without processing
+type CommandRejectedMessage struct {
+	Type       string `json:"type"`
+	Command    string `json:"command"`
+	Identifier string `json:"identifier,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+func (r *CommandRejectedMessage) GetType() string {
+	return CommandRejectedType
+}
+
 // Reply represents an outgoing client message
 type Reply struct {
 	Type       string      `json:"type,omitempty"`
 	Identifier string      `json:"identifier"`
 	Message    interface{} `json:"message"`
+	StreamID   string      `json:"stream_id,omitempty"`
+	Offset     uint64      `json:"offset,omitempty"`
+	Epoch      string      `json:"epoch,omitempty"`
+	// Message ID to acknowledge (for subscriptions with reliable delivery)
+	ID uint64 `json:"id,omitempty"`
 }
 
 func (r *Reply) GetType() string {
@@ -224,18 +414,34 @@ func (r *Reply) GetType() string {
 
 // PubSubMessageFromJSON takes raw JSON byte array and return the corresponding struct
 func PubSubMessageFromJSON(raw []byte) (interface{}, error) {
-	smsg := StreamMessage{}
+	rmsg := RemoteCommandMessage{}
+
+	if err := json.Unmarshal(raw, &rmsg); err != nil {
+		return nil, err
+	}
+
+	if rmsg.Command == "" {
+		smsg := StreamMessage{}
 
-	if err := json.Unmarshal(raw, &smsg); err == nil {
-		if smsg.Stream != "" {
-			return smsg, nil
+		if err := json.Unmarshal(raw, &smsg); err == nil {
+			if smsg.Stream != "" {
+				return smsg, nil
+			}
 		}
 	}
 
-	rmsg := RemoteCommandMessage{}
+	if rmsg.Command == "stop_stream" {
+		smsg := StopStreamMessage{}
 
-	if err := json.Unmarshal(raw, &rmsg); err != nil {
-		return nil, err
+		if err := json.Unmarshal(raw, &smsg); err != nil {
+			return nil, err
+		}
+
+		if smsg.Stream == "" {
+			return nil, fmt.Errorf("Stream is missing: %s", raw)
+		}
+
+		return smsg, nil
 	}
 
 	if rmsg.Command == "disconnect" {
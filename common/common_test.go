@@ -68,6 +68,25 @@ func TestPubSubMessageFromJSON(t *testing.T) {
 		assert.Equal(t, false, casted.Reconnect)
 	})
 
+	t.Run("Stop stream message", func(t *testing.T) {
+		msg := []byte("{\"command\":\"stop_stream\",\"stream\":\"room_42\",\"data\":\"{\\\"closed\\\":true}\",\"notify\":true}")
+
+		result, err := PubSubMessageFromJSON(msg)
+		assert.Nil(t, err)
+
+		casted := result.(StopStreamMessage)
+		assert.Equal(t, "room_42", casted.Stream)
+		assert.Equal(t, "{\"closed\":true}", casted.Data)
+		assert.True(t, casted.Notify)
+	})
+
+	t.Run("Stop stream message without stream", func(t *testing.T) {
+		msg := []byte("{\"command\":\"stop_stream\"}")
+
+		_, err := PubSubMessageFromJSON(msg)
+		assert.NotNil(t, err)
+	})
+
 	t.Run("Broadcast message", func(t *testing.T) {
 		msg := []byte("{\"stream\":\"bread-test\",\"data\":\"test\"}")
 
@@ -78,4 +97,23 @@ func TestPubSubMessageFromJSON(t *testing.T) {
 		assert.Equal(t, "bread-test", casted.Stream)
 		assert.Equal(t, "test", casted.Data)
 	})
+
+	t.Run("Binary broadcast message", func(t *testing.T) {
+		msg := []byte("{\"stream\":\"bread-test\",\"binary\":\"CgVoZWxsbw==\"}")
+
+		result, err := PubSubMessageFromJSON(msg)
+		assert.Nil(t, err)
+
+		casted := result.(StreamMessage)
+		assert.Equal(t, "bread-test", casted.Stream)
+		assert.Equal(t, []byte("\n\x05hello"), casted.Binary)
+	})
+}
+
+func TestNewRejectedSubscriptionResult(t *testing.T) {
+	res := NewRejectedSubscriptionResult("{\"channel\":\"ChatChannel\"}", RejectionLimitExceeded)
+
+	assert.Equal(t, FAILURE, res.Status)
+	assert.Equal(t, RejectionLimitExceeded, res.RejectionReason)
+	assert.Equal(t, []string{"{\"identifier\":\"{\\\"channel\\\":\\\"ChatChannel\\\"}\",\"type\":\"reject_subscription\"}"}, res.Transmissions)
 }
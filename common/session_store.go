@@ -0,0 +1,183 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// SessionStoreHeaderPrefix is a prefix of headers used to pass the session store entries to RPC
+const SessionStoreHeaderPrefix = "x-anycable-store-"
+
+// ErrSessionStoreLimit is returned when the session store size limit is exceeded
+var ErrSessionStoreLimit = errors.New("Session store size limit exceeded")
+
+// ErrSessionStoreInvalidValue is returned when the value of the key passed to RPC is not a valid UTF-8 string
+// (RPC headers are strings, so they can't contain arbitrary bytes)
+var ErrSessionStoreInvalidValue = errors.New("Session store value passed to RPC must be a valid UTF-8 string")
+
+// SessionStore is a concurrency-safe key-value storage scoped to a session.
+// It could be used by controllers to keep arbitrary data between commands.
+// Only the entries marked as persistent are kept when the session is restored (e.g., to perform a Disconnect call).
+type SessionStore struct {
+	// The max total size of keys and values in bytes (0 – no limit)
+	maxSize int
+	// Keys to pass to RPC along with the session env
+	rpcKeys []string
+
+	mu         sync.RWMutex
+	size       int
+	values     map[string][]byte
+	persistent map[string]bool
+}
+
+// NewSessionStore creates a new store with the specified size limit and the keys to pass to RPC
+func NewSessionStore(maxSize int, rpcKeys []string) *SessionStore {
+	return &SessionStore{
+		maxSize:    maxSize,
+		rpcKeys:    rpcKeys,
+		values:     make(map[string][]byte),
+		persistent: make(map[string]bool),
+	}
+}
+
+// ParseSessionStoreKeys parses a comma-separated list of the session store keys
+func ParseSessionStoreKeys(list string) []string {
+	keys := []string{}
+
+	for _, key := range strings.Split(list, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Get returns the value for the key
+func (st *SessionStore) Get(key string) ([]byte, bool) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	val, ok := st.values[key]
+
+	return val, ok
+}
+
+// Set stores the value for the key.
+// Returns an error if the size limit would be exceeded or the value of the key passed to RPC is not a valid UTF-8 string
+// (the previous value is kept in this case).
+func (st *SessionStore) Set(key string, value []byte) error {
+	return st.set(key, value, false)
+}
+
+// SetPersistent stores the value for the key and marks it as persistent
+func (st *SessionStore) SetPersistent(key string, value []byte) error {
+	return st.set(key, value, true)
+}
+
+// Delete removes the key from the store
+func (st *SessionStore) Delete(key string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if val, ok := st.values[key]; ok {
+		st.size -= len(key) + len(val)
+		delete(st.values, key)
+		delete(st.persistent, key)
+	}
+}
+
+// Clear removes all the entries
+func (st *SessionStore) Clear() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.size = 0
+	st.values = make(map[string][]byte)
+	st.persistent = make(map[string]bool)
+}
+
+// Size returns the total size of keys and values in bytes
+func (st *SessionStore) Size() int {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	return st.size
+}
+
+// PersistentEntries returns a copy of the entries marked as persistent
+func (st *SessionStore) PersistentEntries() map[string][]byte {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	res := make(map[string][]byte, len(st.persistent))
+
+	for key := range st.persistent {
+		res[key] = st.values[key]
+	}
+
+	return res
+}
+
+// RPCHeaders returns the configured entries to pass to RPC as headers
+func (st *SessionStore) RPCHeaders() map[string]string {
+	if len(st.rpcKeys) == 0 {
+		return nil
+	}
+
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+
+	res := make(map[string]string)
+
+	for _, key := range st.rpcKeys {
+		if val, ok := st.values[key]; ok {
+			res[SessionStoreHeaderPrefix+key] = string(val)
+		}
+	}
+
+	return res
+}
+
+func (st *SessionStore) set(key string, value []byte, persistent bool) error {
+	if st.isRPCKey(key) && !utf8.Valid(value) {
+		return fmt.Errorf("%w: %s", ErrSessionStoreInvalidValue, key)
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	size := st.size + len(key) + len(value)
+
+	if prev, ok := st.values[key]; ok {
+		size -= len(key) + len(prev)
+	}
+
+	if st.maxSize > 0 && size > st.maxSize {
+		return fmt.Errorf("%w: %d bytes (max: %d)", ErrSessionStoreLimit, size, st.maxSize)
+	}
+
+	st.size = size
+	st.values[key] = value
+
+	if persistent {
+		st.persistent[key] = true
+	} else {
+		delete(st.persistent, key)
+	}
+
+	return nil
+}
+
+func (st *SessionStore) isRPCKey(key string) bool {
+	for _, rpcKey := range st.rpcKeys {
+		if rpcKey == key {
+			return true
+		}
+	}
+
+	return false
+}
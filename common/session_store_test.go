@@ -0,0 +1,80 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionStore(t *testing.T) {
+	store := NewSessionStore(16, []string{"locale"})
+
+	t.Run("Set and get", func(t *testing.T) {
+		assert.Nil(t, store.Set("locale", []byte("en")))
+
+		val, ok := store.Get("locale")
+		assert.True(t, ok)
+		assert.Equal(t, "en", string(val))
+		assert.Equal(t, 8, store.Size())
+
+		_, ok = store.Get("unknown")
+		assert.False(t, ok)
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		assert.Nil(t, store.Set("locale", []byte("fr")))
+		assert.Equal(t, 8, store.Size())
+	})
+
+	t.Run("Size limit", func(t *testing.T) {
+		err := store.Set("token", []byte("secret"))
+
+		assert.True(t, errors.Is(err, ErrSessionStoreLimit))
+		assert.Equal(t, 8, store.Size())
+
+		_, ok := store.Get("token")
+		assert.False(t, ok)
+	})
+
+	t.Run("Invalid RPC value", func(t *testing.T) {
+		err := store.Set("locale", []byte{0xff, 0xfe})
+
+		assert.True(t, errors.Is(err, ErrSessionStoreInvalidValue))
+
+		val, _ := store.Get("locale")
+		assert.Equal(t, "fr", string(val))
+
+		// Values not passed to RPC could contain arbitrary bytes
+		assert.Nil(t, store.Set("b", []byte{0xff}))
+		store.Delete("b")
+	})
+
+	t.Run("Persistent entries", func(t *testing.T) {
+		assert.Nil(t, store.SetPersistent("id", []byte("42")))
+		assert.Equal(t, map[string][]byte{"id": []byte("42")}, store.PersistentEntries())
+
+		assert.Nil(t, store.Set("id", []byte("43")))
+		assert.Empty(t, store.PersistentEntries())
+	})
+
+	t.Run("RPC headers", func(t *testing.T) {
+		assert.Equal(t, map[string]string{"x-anycable-store-locale": "fr"}, store.RPCHeaders())
+	})
+
+	t.Run("Delete and clear", func(t *testing.T) {
+		store.Delete("id")
+		assert.Equal(t, 8, store.Size())
+
+		store.Clear()
+		assert.Equal(t, 0, store.Size())
+
+		_, ok := store.Get("locale")
+		assert.False(t, ok)
+	})
+}
+
+func TestParseSessionStoreKeys(t *testing.T) {
+	assert.Equal(t, []string{"locale", "tenant"}, ParseSessionStoreKeys(" locale, ,tenant"))
+	assert.Empty(t, ParseSessionStoreKeys(""))
+}
@@ -6,6 +6,10 @@ import (
 	"github.com/anycable/anycable-go/pubsub"
 	"github.com/anycable/anycable-go/rpc"
 	"github.com/anycable/anycable-go/server"
+	"github.com/anycable/anycable-go/sse"
+	"github.com/anycable/anycable-go/standalone"
+	"github.com/anycable/anycable-go/tracing"
+	"github.com/anycable/anycable-go/webhook"
 	"github.com/anycable/anycable-go/ws"
 )
 
@@ -13,33 +17,60 @@ import (
 type Config struct {
 	App                  node.Config
 	RPC                  rpc.Config
+	Standalone           standalone.Config
 	Redis                pubsub.RedisConfig
 	HTTPPubSub           pubsub.HTTPConfig
 	Host                 string
 	Port                 int
 	MaxConn              int
+	SocketPermissions    string
+	ShutdownTimeout      int
+	ReadHeaderTimeout    int
+	HandshakeTimeout     int
+	MaxHeaderBytes       int
+	TrustedProxies       string
+	ProxyProtocol        server.ProxyProtocolConfig
 	BroadcastAdapter     string
+	SecondaryAdapter     string
 	Path                 string
 	HealthPath           string
+	HealthPort           int
+	InfoPath             string
+	DebugPath            string
+	DebugToken           string
+	DebugRedact          bool
+	ReadyPath            string
+	ReadyGracePeriod     int
 	Headers              []string
 	SSL                  server.SSLConfig
+	AccessLog            server.AccessLogConfig
 	WS                   ws.Config
+	SSE                  sse.Config
 	MaxMessageSize       int64
 	DisconnectorDisabled bool
 	DisconnectQueue      node.DisconnectQueueConfig
 	LogLevel             string
+	LogLevels            string
 	LogFormat            string
 	Metrics              metrics.Config
+	Tracing              tracing.Config
+	Webhook              webhook.Config
 }
 
 // New returns a new empty config
 func New() Config {
-	config := Config{}
+	config := Config{ReadHeaderTimeout: 10, HandshakeTimeout: 30, MaxHeaderBytes: 64 * 1024}
 	config.App = node.NewConfig()
 	config.SSL = server.NewSSLConfig()
+	config.AccessLog = server.NewAccessLogConfig()
+	config.ProxyProtocol = server.NewProxyProtocolConfig()
 	config.WS = ws.NewConfig()
+	config.SSE = sse.NewConfig()
 	config.Metrics = metrics.NewConfig()
+	config.Tracing = tracing.NewConfig()
+	config.Webhook = webhook.NewConfig()
 	config.RPC = rpc.NewConfig()
+	config.Standalone = standalone.NewConfig()
 	config.Redis = pubsub.NewRedisConfig()
 	config.HTTPPubSub = pubsub.NewHTTPConfig()
 	config.DisconnectQueue = node.NewDisconnectQueueConfig()
@@ -20,4 +20,11 @@ type Encoder interface {
 	Decode(payload []byte) (*common.Message, error)
 }
 
+// PooledEncoder is implemented by encoders capable of encoding transmissions into pooled buffers
+// (see ws.NewPooledFrame). Other messages could be shared between sessions (see node.EncodingCache), thus, they're never pooled.
+type PooledEncoder interface {
+	EncodeTransmissionPooled(msg string) (*ws.SentFrame, error)
+}
+
 var _ Encoder = (*JSON)(nil)
+var _ PooledEncoder = (*JSON)(nil)
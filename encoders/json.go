@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 
 	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/utils"
 	"github.com/anycable/anycable-go/ws"
 )
 
@@ -28,6 +29,13 @@ func (JSON) EncodeTransmission(msg string) (*ws.SentFrame, error) {
 	return &ws.SentFrame{FrameType: ws.TextFrame, Payload: []byte(msg)}, nil
 }
 
+func (JSON) EncodeTransmissionPooled(msg string) (*ws.SentFrame, error) {
+	b := utils.GetBytes(len(msg))
+	copy(b, msg)
+
+	return ws.NewPooledFrame(ws.TextFrame, b), nil
+}
+
 func (JSON) Decode(raw []byte) (*common.Message, error) {
 	msg := &common.Message{}
 
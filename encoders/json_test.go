@@ -31,6 +31,19 @@ func TestJSONEncoder(t *testing.T) {
 		assert.Equal(t, expected, actual.Payload)
 	})
 
+	t.Run(".EncodeTransmissionPooled", func(t *testing.T) {
+		msg := "{\"type\":\"test\",\"identifier\":\"test_channel\",\"message\":\"hello\"}"
+		expected := []byte(msg)
+
+		actual, err := coder.EncodeTransmissionPooled(msg)
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual.Payload)
+
+		actual.Release()
+		assert.Nil(t, actual.Payload)
+	})
+
 	t.Run(".Decode", func(t *testing.T) {
 		msg := []byte("{\"command\":\"test\",\"identifier\":\"test_channel\",\"data\":\"hello\"}")
 
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/anycable/anycable-go/server"
+)
+
+// authHandler protects metrics handler with a bearer token and/or IP allowlist
+type authHandler struct {
+	handler http.Handler
+	token   string
+	nets    []*net.IPNet
+}
+
+// newAuthHandler wraps the handler with authentication checks.
+// Returns the handler itself if no auth configured
+func newAuthHandler(handler http.Handler, token string, cidrs string) (http.Handler, error) {
+	nets, err := server.ParseCIDRs(cidrs)
+
+	if err != nil {
+		return nil, fmt.Errorf("Invalid metrics allowed CIDRs: %v", err)
+	}
+
+	if token == "" && len(nets) == 0 {
+		return handler, nil
+	}
+
+	return &authHandler{handler: handler, token: token, nets: nets}, nil
+}
+
+// AuthHandler wraps the handler with the same authentication checks as the metrics endpoint
+// (could be used to protect other internal endpoints)
+func AuthHandler(handler http.Handler, config *Config) (http.Handler, error) {
+	return newAuthHandler(handler, config.AuthToken, config.AllowedCIDRs)
+}
+
+// TokenAuthHandler wraps the handler with a bearer token check (the handler is returned as is if the token is empty)
+func TokenAuthHandler(handler http.Handler, token string) http.Handler {
+	// No CIDRs, so no errors
+	h, _ := newAuthHandler(handler, token, "")
+	return h
+}
+
+func (h *authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(h.nets) > 0 && !h.allowedIP(r.RemoteAddr) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if h.token != "" && !h.validToken(r.Header.Get("Authorization")) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	h.handler.ServeHTTP(w, r)
+}
+
+func (h *authHandler) allowedIP(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+
+	if err != nil {
+		host = addr
+	}
+
+	ip := net.ParseIP(host)
+
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range h.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *authHandler) validToken(header string) bool {
+	const prefix = "Bearer "
+
+	if !strings.HasPrefix(header, prefix) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, prefix)), []byte(h.token)) == 1
+}
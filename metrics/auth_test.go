@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	request := func(h http.Handler, addr string, auth string) int {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		req.RemoteAddr = addr
+
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		return rr.Code
+	}
+
+	t.Run("Without auth", func(t *testing.T) {
+		h, err := newAuthHandler(ok, "", "")
+		assert.Nil(t, err)
+
+		assert.Equal(t, http.StatusOK, request(h, "1.2.3.4:1234", ""))
+	})
+
+	t.Run("With token", func(t *testing.T) {
+		h, err := newAuthHandler(ok, "secret", "")
+		assert.Nil(t, err)
+
+		assert.Equal(t, http.StatusOK, request(h, "1.2.3.4:1234", "Bearer secret"))
+		assert.Equal(t, http.StatusUnauthorized, request(h, "1.2.3.4:1234", "Bearer wrong"))
+		assert.Equal(t, http.StatusUnauthorized, request(h, "1.2.3.4:1234", ""))
+	})
+
+	t.Run("With CIDRs", func(t *testing.T) {
+		h, err := newAuthHandler(ok, "", "10.0.0.0/8, 127.0.0.1, ::1")
+		assert.Nil(t, err)
+
+		assert.Equal(t, http.StatusOK, request(h, "10.1.2.3:1234", ""))
+		assert.Equal(t, http.StatusOK, request(h, "127.0.0.1:1234", ""))
+		assert.Equal(t, http.StatusOK, request(h, "[::1]:1234", ""))
+		assert.Equal(t, http.StatusForbidden, request(h, "1.2.3.4:1234", ""))
+	})
+
+	t.Run("With token and CIDRs", func(t *testing.T) {
+		h, err := newAuthHandler(ok, "secret", "10.0.0.0/8")
+		assert.Nil(t, err)
+
+		assert.Equal(t, http.StatusOK, request(h, "10.1.2.3:1234", "Bearer secret"))
+		assert.Equal(t, http.StatusUnauthorized, request(h, "10.1.2.3:1234", ""))
+		assert.Equal(t, http.StatusForbidden, request(h, "1.2.3.4:1234", "Bearer secret"))
+	})
+
+	t.Run("With invalid CIDR", func(t *testing.T) {
+		_, err := newAuthHandler(ok, "", "10.0.0.0/64")
+		assert.NotNil(t, err)
+	})
+}
@@ -1,19 +1,38 @@
 package metrics
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/anycable/anycable-go/server"
+)
+
 // Config contains metrics configuration
 type Config struct {
 	Log            bool
 	LogInterval    int // Deprecated
 	RotateInterval int
 	LogFormatter   string
+	LogFilter      string
+	LogFields      string
 	HTTP           string
 	Host           string
 	Port           int
+	OTLPEndpoint   string
+	OTLPProtocol   string
+	OTLPHeaders    string
+	OTLPInterval   int
+	GoRuntime      bool
+	AuthToken      string
+	AllowedCIDRs   string
+	SSL            server.SSLConfig
+	// Node identifier added to metrics (set by the runner from --node_id)
+	NodeID string
 }
 
 // NewConfig creates an empty Config struct
 func NewConfig() Config {
-	return Config{}
+	return Config{OTLPProtocol: "http"}
 }
 
 // LogEnabled returns true iff any log option is specified
@@ -26,7 +45,64 @@ func (c *Config) HTTPEnabled() bool {
 	return c.HTTP != ""
 }
 
+// OTLPEnabled returns true iff OTLPEndpoint is not empty
+func (c *Config) OTLPEnabled() bool {
+	return c.OTLPEndpoint != ""
+}
+
+// ValidateOTLP checks the OTLP exporter settings.
+// Only the HTTP transport is implemented, so the gRPC one is rejected explicitly.
+func (c *Config) ValidateOTLP() error {
+	if c.OTLPProtocol != "" && c.OTLPProtocol != "http" {
+		return fmt.Errorf("Unsupported OTLP protocol: %s. Only http is supported", c.OTLPProtocol)
+	}
+
+	if c.OTLPInterval < 0 {
+		return fmt.Errorf("OTLP export interval must be non-negative, got: %d", c.OTLPInterval)
+	}
+
+	if _, err := parseKeyValuePairs(c.OTLPHeaders); err != nil {
+		return fmt.Errorf("Invalid OTLP headers: %v", err)
+	}
+
+	return nil
+}
+
 // LogFormatterEnabled returns true iff LogFormatter is not empty
 func (c *Config) LogFormatterEnabled() bool {
 	return c.LogFormatter != ""
 }
+
+// LogFilterPatterns returns the list of metrics names (or glob patterns) to include into logs
+func (c *Config) LogFilterPatterns() []string {
+	patterns := []string{}
+
+	for _, pattern := range strings.Split(c.LogFilter, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+
+	return patterns
+}
+
+// parseKeyValuePairs parses a string in the "key1=value1,key2=value2" format
+func parseKeyValuePairs(raw string) (map[string]string, error) {
+	pairs := make(map[string]string)
+
+	if raw == "" {
+		return pairs, nil
+	}
+
+	for _, pair := range strings.Split(raw, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("Invalid key-value pair: %s", pair)
+		}
+
+		pairs[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return pairs, nil
+}
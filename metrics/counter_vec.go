@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// CounterVec is a set of counters sharing the same name and distinguished by a label value
+type CounterVec struct {
+	name     string
+	desc     string
+	label    string
+	mu       sync.RWMutex
+	counters map[string]*Counter
+}
+
+// NewCounterVec creates new CounterVec
+func NewCounterVec(name string, desc string, label string) *CounterVec {
+	return &CounterVec{name: name, desc: desc, label: label, counters: make(map[string]*Counter)}
+}
+
+// Name returns counter vector name
+func (v *CounterVec) Name() string {
+	return v.name
+}
+
+// Desc returns counter vector description
+func (v *CounterVec) Desc() string {
+	return v.desc
+}
+
+// Label returns the label name
+func (v *CounterVec) Label() string {
+	return v.label
+}
+
+// With returns the counter for the label value (creating it if necessary)
+func (v *CounterVec) With(value string) *Counter {
+	v.mu.RLock()
+	c, ok := v.counters[value]
+	v.mu.RUnlock()
+
+	if ok {
+		return c
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if c, ok = v.counters[value]; !ok {
+		c = NewCounter(v.name, v.desc)
+		v.counters[value] = c
+	}
+
+	return c
+}
+
+// Each applies function f to each label value and counter (sorted by label values)
+func (v *CounterVec) Each(f func(value string, c *Counter)) {
+	v.mu.RLock()
+	values := make([]string, 0, len(v.counters))
+
+	for value := range v.counters {
+		values = append(values, value)
+	}
+	v.mu.RUnlock()
+
+	sort.Strings(values)
+
+	for _, value := range values {
+		f(value, v.With(value))
+	}
+}
+
+// Top returns up to n label values with the highest interval values (in descending order)
+func (v *CounterVec) Top(n int) []string {
+	values := []string{}
+	intervals := make(map[string]uint64)
+
+	v.Each(func(value string, c *Counter) {
+		if iv := c.IntervalValue(); iv > 0 {
+			values = append(values, value)
+			intervals[value] = iv
+		}
+	})
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return intervals[values[i]] > intervals[values[j]]
+	})
+
+	if len(values) > n {
+		values = values[:n]
+	}
+
+	return values
+}
+
+// UpdateDelta updates the delta values of all counters
+func (v *CounterVec) UpdateDelta() {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, c := range v.counters {
+		c.UpdateDelta()
+	}
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounterVec(t *testing.T) {
+	v := NewCounterVec("test_total", "", "channel")
+
+	v.With("a").Add(3)
+	v.With("b").Inc()
+	v.With("c").Add(2)
+
+	assert.Equal(t, uint64(3), v.With("a").Value())
+
+	values := []string{}
+	v.Each(func(value string, c *Counter) {
+		values = append(values, value)
+	})
+
+	assert.Equal(t, []string{"a", "b", "c"}, values)
+	assert.Equal(t, []string{"a", "c"}, v.Top(2))
+
+	v.UpdateDelta()
+	v.With("b").Add(5)
+	v.UpdateDelta()
+
+	assert.Equal(t, []string{"b"}, v.Top(2))
+}
@@ -3,6 +3,10 @@
 package metrics
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/anycable/anycable-go/mrb"
 	"github.com/apex/log"
 	"github.com/mitchellh/go-mruby"
@@ -13,6 +17,11 @@ type RubyPrinter struct {
 	path      string
 	mrbModule *mruby.MrbValue
 	engine    *mrb.Engine
+
+	// Protects engine and module, which could be swapped on reload
+	mu       sync.Mutex
+	loadedAt time.Time
+	loadErr  error
 }
 
 // NewCustomPrinter generates log formatter from the provided (as path)
@@ -23,26 +32,86 @@ func NewCustomPrinter(path string) (*RubyPrinter, error) {
 
 // Run initializes the Ruby VM
 func (p *RubyPrinter) Run(interval int) error {
-	p.engine = mrb.DefaultEngine()
+	engine, mod, err := p.load()
 
-	if err := p.engine.LoadFile(p.path); err != nil {
+	if err != nil {
 		return err
 	}
 
-	mod := p.engine.VM.Module("MetricsFormatter")
-
-	p.mrbModule = mod.MrbValue(p.engine.VM)
+	p.mu.Lock()
+	p.engine = engine
+	p.mrbModule = mod
+	p.loadedAt = time.Now()
+	p.mu.Unlock()
 
 	log.WithField("context", "metrics").Infof("Log metrics every %ds using a custom Ruby formatter from %s", interval, p.path)
 
 	return nil
 }
 
+// Reload re-compiles the script and swaps it with the current one.
+// The current script is kept active if the new one fails to compile (the error is returned to be logged by the caller).
+func (p *RubyPrinter) Reload() error {
+	engine, mod, err := p.load()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.loadErr = err
+
+	if err != nil {
+		return fmt.Errorf("Failed to reload custom Ruby formatter from %s, keep using the previous version: %v", p.path, err)
+	}
+
+	old := p.engine
+
+	p.engine = engine
+	p.mrbModule = mod
+	p.loadedAt = time.Now()
+
+	if old != nil {
+		old.VM.Close()
+	}
+
+	log.WithField("context", "metrics").Infof("Reloaded custom Ruby formatter from %s", p.path)
+
+	return nil
+}
+
+func (p *RubyPrinter) load() (*mrb.Engine, *mruby.MrbValue, error) {
+	engine := mrb.NewEngine()
+
+	if err := engine.LoadFile(p.path); err != nil {
+		engine.VM.Close()
+		return nil, nil, err
+	}
+
+	mod := engine.VM.Module("MetricsFormatter")
+
+	return engine, mod.MrbValue(engine.VM), nil
+}
+
 func (p *RubyPrinter) Stop() {
 }
 
 // Write prints formatted snapshot to the log
 func (p *RubyPrinter) Write(m *Metrics) error {
+	p.mu.Lock()
+	loadedAt, loadErr := p.loadedAt, p.loadErr
+	p.mu.Unlock()
+
+	if g := m.Gauge(metricsFormatterLoadedAt); g != nil {
+		g.Set64(uint64(loadedAt.Unix()))
+	}
+
+	if g := m.Gauge(metricsFormatterLoadFailed); g != nil {
+		if loadErr != nil {
+			g.Set(1)
+		} else {
+			g.Set(0)
+		}
+	}
+
 	snapshot := m.IntervalSnapshot()
 	p.Print(snapshot)
 	return nil
@@ -50,6 +119,9 @@ func (p *RubyPrinter) Write(m *Metrics) error {
 
 // Print calls Ruby script to format the output and prints it to the log
 func (printer *RubyPrinter) Print(snapshot map[string]uint64) {
+	printer.mu.Lock()
+	defer printer.mu.Unlock()
+
 	rhash, _ := printer.engine.VM.LoadString("{}")
 
 	hash := rhash.Hash()
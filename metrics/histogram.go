@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// DefaultBuckets are the default histogram buckets (the same as Prometheus client uses).
+// Suitable for measuring network requests latency in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram samples observations and counts them in configurable buckets
+type Histogram struct {
+	name    string
+	desc    string
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+
+	lastIntervalCount uint64
+	lastIntervalSum   float64
+	intervalCount     uint64
+	intervalSum       float64
+}
+
+// NewHistogram creates new Histogram with the specified upper bounds of buckets.
+// Uses DefaultBuckets if no buckets provided.
+func NewHistogram(name string, desc string, buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	return &Histogram{name: name, desc: desc, buckets: sorted, counts: make([]uint64, len(sorted))}
+}
+
+// Name returns histogram name
+func (h *Histogram) Name() string {
+	return h.name
+}
+
+// Desc returns histogram description
+func (h *Histogram) Desc() string {
+	return h.desc
+}
+
+// Buckets returns buckets upper bounds
+func (h *Histogram) Buckets() []float64 {
+	return h.buckets
+}
+
+// Observe adds a single observation to the histogram
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if i < len(h.buckets) {
+		h.counts[i]++
+	}
+
+	h.count++
+	h.sum += v
+}
+
+// Snapshot returns cumulative buckets counts (corresponding to Buckets()),
+// the total number of observations and their sum
+func (h *Histogram) Snapshot() (buckets []uint64, count uint64, sum float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	buckets = make([]uint64, len(h.counts))
+
+	var acc uint64
+
+	for i, c := range h.counts {
+		acc += c
+		buckets[i] = acc
+	}
+
+	return buckets, h.count, h.sum
+}
+
+// Count returns the total number of observations
+func (h *Histogram) Count() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.count
+}
+
+// Sum returns the sum of all observations
+func (h *Histogram) Sum() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.sum
+}
+
+// IntervalValue returns the number and the sum of observations for the last interval
+func (h *Histogram) IntervalValue() (uint64, float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.lastIntervalCount == 0 {
+		return h.count, h.sum
+	}
+
+	return h.intervalCount, h.intervalSum
+}
+
+// UpdateDelta updates the last interval values
+func (h *Histogram) UpdateDelta() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.intervalCount = h.count - h.lastIntervalCount
+	h.intervalSum = h.sum - h.lastIntervalSum
+	h.lastIntervalCount = h.count
+	h.lastIntervalSum = h.sum
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test", "", []float64{1, 0.1, 0.5})
+
+	assert.Equal(t, []float64{0.1, 0.5, 1}, h.Buckets())
+
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(0.3)
+	h.Observe(2)
+
+	buckets, count, sum := h.Snapshot()
+
+	assert.Equal(t, []uint64{2, 3, 3}, buckets)
+	assert.Equal(t, uint64(4), count)
+	assert.InDelta(t, 2.45, sum, 0.0001)
+
+	h.UpdateDelta()
+	h.Observe(0.5)
+
+	count, sum = h.IntervalValue()
+	assert.Equal(t, uint64(4), count)
+	assert.InDelta(t, 2.45, sum, 0.0001)
+
+	h.UpdateDelta()
+
+	count, sum = h.IntervalValue()
+	assert.Equal(t, uint64(1), count)
+	assert.InDelta(t, 0.5, sum, 0.0001)
+}
+
+func TestHistogramDefaultBuckets(t *testing.T) {
+	h := NewHistogram("test", "", nil)
+
+	assert.Equal(t, DefaultBuckets, h.Buckets())
+}
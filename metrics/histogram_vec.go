@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// HistogramVec is a set of histograms sharing the same name and buckets and distinguished by a label value
+type HistogramVec struct {
+	name       string
+	desc       string
+	label      string
+	buckets    []float64
+	mu         sync.RWMutex
+	histograms map[string]*Histogram
+}
+
+// NewHistogramVec creates new HistogramVec (DefaultBuckets are used if buckets are nil)
+func NewHistogramVec(name string, desc string, label string, buckets []float64) *HistogramVec {
+	return &HistogramVec{name: name, desc: desc, label: label, buckets: buckets, histograms: make(map[string]*Histogram)}
+}
+
+// Name returns histogram vector name
+func (v *HistogramVec) Name() string {
+	return v.name
+}
+
+// Desc returns histogram vector description
+func (v *HistogramVec) Desc() string {
+	return v.desc
+}
+
+// Label returns the label name
+func (v *HistogramVec) Label() string {
+	return v.label
+}
+
+// With returns the histogram for the label value (creating it if necessary)
+func (v *HistogramVec) With(value string) *Histogram {
+	v.mu.RLock()
+	h, ok := v.histograms[value]
+	v.mu.RUnlock()
+
+	if ok {
+		return h
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if h, ok = v.histograms[value]; !ok {
+		h = NewHistogram(v.name, v.desc, v.buckets)
+		v.histograms[value] = h
+	}
+
+	return h
+}
+
+// Each applies function f to each label value and histogram (sorted by label values)
+func (v *HistogramVec) Each(f func(value string, h *Histogram)) {
+	v.mu.RLock()
+	values := make([]string, 0, len(v.histograms))
+
+	for value := range v.histograms {
+		values = append(values, value)
+	}
+	v.mu.RUnlock()
+
+	sort.Strings(values)
+
+	for _, value := range values {
+		f(value, v.With(value))
+	}
+}
+
+// UpdateDelta updates the interval values of all histograms
+func (v *HistogramVec) UpdateDelta() {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, h := range v.histograms {
+		h.UpdateDelta()
+	}
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramVec(t *testing.T) {
+	v := NewHistogramVec("test_seconds", "", "method", []float64{0.1, 1})
+
+	v.With("connect").Observe(0.05)
+	v.With("connect").Observe(0.5)
+	v.With("command").Observe(2)
+
+	assert.Equal(t, uint64(2), v.With("connect").Count())
+	assert.Equal(t, []float64{0.1, 1}, v.With("command").Buckets())
+
+	values := []string{}
+	v.Each(func(value string, h *Histogram) {
+		values = append(values, value)
+	})
+
+	assert.Equal(t, []string{"command", "connect"}, values)
+
+	v.UpdateDelta()
+
+	count, sum := v.With("command").IntervalValue()
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, 2.0, sum)
+}
@@ -1,9 +1,12 @@
 package metrics
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"path"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,6 +16,9 @@ import (
 
 const DefaultRotateInterval = 15
 
+// SnapshotTopN is the max number of labeled values per counter vector included into interval snapshots
+const SnapshotTopN = 5
+
 // IntervalHandler describe a periodical metrics writer interface
 type IntervalWriter interface {
 	Run(interval int) error
@@ -20,6 +26,11 @@ type IntervalWriter interface {
 	Write(m *Metrics) error
 }
 
+// ReloadableWriter describes a writer which could be reloaded at runtime (e.g., on SIGHUP)
+type ReloadableWriter interface {
+	Reload() error
+}
+
 // Metrics stores some useful stats about node
 type Metrics struct {
 	mu             sync.RWMutex
@@ -29,8 +40,17 @@ type Metrics struct {
 	rotateInterval time.Duration
 	counters       map[string]*Counter
 	gauges         map[string]*Gauge
+	histograms     map[string]*Histogram
+	counterVecs    map[string]*CounterVec
+	histogramVecs  map[string]*HistogramVec
+	timings        map[string]*Timing
+	goRuntime      bool
+	nodeID         string
+	collectors     []func()
 	shutdownCh     chan struct{}
 	log            *log.Entry
+	// Collectors are called from both the rotation loop and the Prometheus handler
+	collectMu sync.Mutex
 }
 
 // FromConfig creates a new metrics instance from the prodived configuration
@@ -49,36 +69,107 @@ func FromConfig(config *Config) (*Metrics, error) {
 				return nil, err
 			}
 		} else {
-			metricsPrinter = NewBasePrinter()
+			fields, err := parseKeyValuePairs(config.LogFields)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid metrics log fields: %v", err)
+			}
+
+			filter := config.LogFilterPatterns()
+
+			for _, pattern := range filter {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return nil, fmt.Errorf("Invalid metrics log filter pattern: %s", pattern)
+				}
+			}
+
+			if _, ok := fields["node_id"]; !ok && config.NodeID != "" {
+				fields["node_id"] = config.NodeID
+			}
+
+			metricsPrinter = NewBasePrinter(filter, fields)
 		}
 
 		writers = append(writers, metricsPrinter)
 	}
 
 	instance := NewMetrics(writers, config.RotateInterval)
+	instance.nodeID = config.NodeID
+
+	if config.LogFormatterEnabled() {
+		instance.RegisterGauge(metricsFormatterLoadedAt, "The last time the custom metrics formatter was loaded (Unix timestamp)")
+		instance.RegisterGauge(metricsFormatterLoadFailed, "Whether the last custom metrics formatter reload failed (1) or not (0)")
+	}
+
+	if config.GoRuntime {
+		instance.EnableGoRuntime()
+	}
+
+	if config.OTLPEnabled() {
+		otlpWriter, err := NewOTLPWriter(instance, config)
+
+		if err != nil {
+			return nil, err
+		}
+
+		instance.RegisterWriter(otlpWriter)
+	}
 
 	if config.HTTPEnabled() {
-		if config.Host != "" && config.Host != server.Host {
-			srv, err := server.NewServer(config.Host, strconv.Itoa(config.Port), server.SSL, 0)
-			if err != nil {
-				return nil, err
-			}
-			instance.server = srv
-		} else {
-			srv, err := server.ForPort(strconv.Itoa(config.Port))
-			if err != nil {
-				return nil, err
-			}
-			instance.server = srv
+		srv, err := newHTTPServer(config)
+
+		if err != nil {
+			return nil, err
+		}
+
+		instance.server = srv
+
+		handler, err := newAuthHandler(http.HandlerFunc(instance.PrometheusHandler), config.AuthToken, config.AllowedCIDRs)
+
+		if err != nil {
+			return nil, err
 		}
 
 		instance.httpPath = config.HTTP
-		instance.server.Mux.Handle(instance.httpPath, http.HandlerFunc(instance.PrometheusHandler))
+		instance.server.Mux.Handle(instance.httpPath, handler)
 	}
 
 	return instance, nil
 }
 
+// newHTTPServer returns a server to mount metrics endpoint to:
+// a dedicated one if a custom host or TLS configuration is specified; otherwise, the server for the port
+// (which could be shared with the main server)
+func newHTTPServer(config *Config) (*server.HTTPServer, error) {
+	port := strconv.Itoa(config.Port)
+
+	if config.SSL.CertPath != "" || config.SSL.KeyPath != "" {
+		if !config.SSL.Available() {
+			return nil, errors.New("Both metrics_ssl_cert and metrics_ssl_key must be specified to serve metrics over TLS")
+		}
+
+		host := config.Host
+
+		if host == "" {
+			host = server.Host
+		}
+
+		srv, err := server.NewServer(host, port, &config.SSL, 0)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to configure TLS for metrics server (cert: %s, key: %s): %v", config.SSL.CertPath, config.SSL.KeyPath, err)
+		}
+
+		return srv, nil
+	}
+
+	if config.Host != "" && config.Host != server.Host {
+		return server.NewServer(config.Host, port, server.SSL, 0)
+	}
+
+	return server.ForPort(port)
+}
+
 // NewMetrics build new metrics struct
 func NewMetrics(writers []IntervalWriter, rotateIntervalSeconds int) *Metrics {
 	rotateInterval := time.Duration(rotateIntervalSeconds) * time.Second
@@ -88,6 +179,10 @@ func NewMetrics(writers []IntervalWriter, rotateIntervalSeconds int) *Metrics {
 		rotateInterval: rotateInterval,
 		counters:       make(map[string]*Counter),
 		gauges:         make(map[string]*Gauge),
+		histograms:     make(map[string]*Histogram),
+		counterVecs:    make(map[string]*CounterVec),
+		histogramVecs:  make(map[string]*HistogramVec),
+		timings:        make(map[string]*Timing),
 		shutdownCh:     make(chan struct{}),
 		log:            log.WithField("context", "metrics"),
 	}
@@ -99,19 +194,28 @@ func (m *Metrics) RegisterWriter(w IntervalWriter) {
 
 // Run periodically updates counters delta (and logs metrics if necessary)
 func (m *Metrics) Run() error {
+	serverErr := make(chan error, 1)
+
 	if m.server != nil {
 		m.log.Infof("Serve metrics at %s%s", m.server.Address(), m.httpPath)
 
-		if err := m.server.StartAndAnnounce("Metrics server"); err != nil {
-			if !m.server.Stopped() {
-				return fmt.Errorf("Metrics HTTP server at %s stopped: %v", m.server.Address(), err)
+		go func() {
+			if err := m.server.StartAndAnnounce("Metrics server"); err != nil {
+				if !m.server.Stopped() {
+					serverErr <- fmt.Errorf("Metrics HTTP server at %s stopped: %v", m.server.Address(), err)
+				}
 			}
-		}
+		}()
 	}
 
-	if len(m.writers) == 0 {
+	if len(m.writers) == 0 && !m.goRuntime {
 		m.log.Debug("No metrics writers. Disable metrics rotation")
-		return nil
+
+		if m.server == nil {
+			return nil
+		}
+
+		return <-serverErr
 	}
 
 	if m.rotateInterval == 0 {
@@ -128,8 +232,17 @@ func (m *Metrics) Run() error {
 		select {
 		case <-m.shutdownCh:
 			return nil
+		case err := <-serverErr:
+			return err
 		case <-time.After(m.rotateInterval):
 			m.log.Debugf("Rotate metrics (interval %v)", m.rotateInterval)
+
+			if m.goRuntime {
+				m.collectRuntime()
+			}
+
+			m.collect()
+
 			m.rotate()
 
 			for _, writer := range m.writers {
@@ -141,21 +254,43 @@ func (m *Metrics) Run() error {
 	}
 }
 
+// HTTPServer returns the server serving metrics endpoint (if any).
+// NOTE: the server could be shared with other components, so it's not stopped on metrics shutdown
+func (m *Metrics) HTTPServer() *server.HTTPServer {
+	return m.server
+}
+
+// Reload reloads writers supporting it
+func (m *Metrics) Reload() error {
+	errs := []string{}
+
+	for _, writer := range m.writers {
+		if reloadable, ok := writer.(ReloadableWriter); ok {
+			if err := reloadable.Reload(); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
 // Shutdown stops metrics updates
 func (m *Metrics) Shutdown() (err error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.shutdownCh == nil {
+	select {
+	case <-m.shutdownCh:
 		return
+	default:
 	}
 
 	close(m.shutdownCh)
-	m.shutdownCh = nil
-
-	if m.server != nil {
-		m.server.Shutdown() //nolint:errcheck
-	}
 
 	for _, writer := range m.writers {
 		writer.Stop()
@@ -164,6 +299,31 @@ func (m *Metrics) Shutdown() (err error) {
 	return
 }
 
+// RegisterCollector adds a function which is called on every metrics rotation and Prometheus scrape
+// (could be used to update gauges from external stats).
+// Collectors don't enable rotation by themselves (there is no need to collect stats nobody reads).
+// Collectors are never called concurrently.
+func (m *Metrics) RegisterCollector(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.collectors = append(m.collectors, fn)
+}
+
+// collect calls the registered collectors
+func (m *Metrics) collect() {
+	m.mu.RLock()
+	collectors := m.collectors
+	m.mu.RUnlock()
+
+	m.collectMu.Lock()
+	defer m.collectMu.Unlock()
+
+	for _, collect := range collectors {
+		collect()
+	}
+}
+
 // RegisterCounter adds new counter to the registry
 func (m *Metrics) RegisterCounter(name string, desc string) {
 	m.mu.Lock()
@@ -180,6 +340,38 @@ func (m *Metrics) RegisterGauge(name string, desc string) {
 	m.gauges[name] = NewGauge(name, desc)
 }
 
+// RegisterHistogram adds new histogram to the registry (DefaultBuckets are used if buckets are nil)
+func (m *Metrics) RegisterHistogram(name string, desc string, buckets []float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.histograms[name] = NewHistogram(name, desc, buckets)
+}
+
+// RegisterCounterVec adds new labeled counters set to the registry
+func (m *Metrics) RegisterCounterVec(name string, desc string, label string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.counterVecs[name] = NewCounterVec(name, desc, label)
+}
+
+// RegisterHistogramVec adds new labeled histograms set to the registry (DefaultBuckets are used if buckets are nil)
+func (m *Metrics) RegisterHistogramVec(name string, desc string, label string, buckets []float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.histogramVecs[name] = NewHistogramVec(name, desc, label, buckets)
+}
+
+// RegisterTiming adds new interval-aggregated timing to the registry
+func (m *Metrics) RegisterTiming(name string, desc string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.timings[name] = NewTiming(name, desc)
+}
+
 // Counter returns counter by name
 func (m *Metrics) Counter(name string) *Counter {
 	return m.counters[name]
@@ -210,7 +402,70 @@ func (m *Metrics) EachGauge(f func(g *Gauge)) {
 	}
 }
 
-// IntervalSnapshot returns recorded interval metrics snapshot
+// Histogram returns histogram by name
+func (m *Metrics) Histogram(name string) *Histogram {
+	return m.histograms[name]
+}
+
+// EachHistogram applies function f(*Histogram) to each histogram in a set
+func (m *Metrics) EachHistogram(f func(h *Histogram)) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, histogram := range m.histograms {
+		f(histogram)
+	}
+}
+
+// CounterVec returns counter vector by name
+func (m *Metrics) CounterVec(name string) *CounterVec {
+	return m.counterVecs[name]
+}
+
+// EachCounterVec applies function f(*CounterVec) to each counter vector in a set
+func (m *Metrics) EachCounterVec(f func(v *CounterVec)) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, vec := range m.counterVecs {
+		f(vec)
+	}
+}
+
+// HistogramVec returns histogram vector by name
+func (m *Metrics) HistogramVec(name string) *HistogramVec {
+	return m.histogramVecs[name]
+}
+
+// EachHistogramVec applies function f(*HistogramVec) to each histogram vector in a set
+func (m *Metrics) EachHistogramVec(f func(v *HistogramVec)) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, vec := range m.histogramVecs {
+		f(vec)
+	}
+}
+
+// Timing returns timing by name
+func (m *Metrics) Timing(name string) *Timing {
+	return m.timings[name]
+}
+
+// EachTiming applies function f(*Timing) to each timing in a set
+func (m *Metrics) EachTiming(f func(t *Timing)) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, timing := range m.timings {
+		f(timing)
+	}
+}
+
+// IntervalSnapshot returns recorded interval metrics snapshot.
+// Counter vectors are represented by top SnapshotTopN values (<name>:<label value>).
+// Histograms are represented by the number of observations (<name>_count), see IntervalHistogramSums for the sums.
+// Histogram vectors are represented by the number of observations per label value (<name>_count:<label value>).
 func (m *Metrics) IntervalSnapshot() map[string]uint64 {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -225,6 +480,70 @@ func (m *Metrics) IntervalSnapshot() map[string]uint64 {
 		snapshot[name] = g.Value()
 	}
 
+	for name, h := range m.histograms {
+		count, _ := h.IntervalValue()
+		snapshot[name+"_count"] = count
+	}
+
+	for name, v := range m.counterVecs {
+		for _, value := range v.Top(SnapshotTopN) {
+			snapshot[name+":"+value] = v.With(value).IntervalValue()
+		}
+	}
+
+	for name, v := range m.histogramVecs {
+		v.Each(func(value string, h *Histogram) {
+			count, _ := h.IntervalValue()
+			snapshot[name+"_count:"+value] = count
+		})
+	}
+
+	return snapshot
+}
+
+// IntervalHistogramSums returns the sums of histograms observations for the last interval (<name>_sum).
+// Sums are kept as is (e.g., durations in seconds), since rounding them would lose the values.
+func (m *Metrics) IntervalHistogramSums() map[string]float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot := make(map[string]float64)
+
+	for name, h := range m.histograms {
+		_, sum := h.IntervalValue()
+		snapshot[name+"_sum"] = sum
+	}
+
+	for name, v := range m.histogramVecs {
+		v.Each(func(value string, h *Histogram) {
+			_, sum := h.IntervalValue()
+			snapshot[name+"_sum:"+value] = sum
+		})
+	}
+
+	return snapshot
+}
+
+// IntervalTimings returns timings quantiles for the last interval (<name>_p50, <name>_p95, etc.).
+// Timings without observations during the interval are skipped.
+func (m *Metrics) IntervalTimings() map[string]time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot := make(map[string]time.Duration)
+
+	for name, t := range m.timings {
+		quantiles, count := t.IntervalValue()
+
+		if count == 0 {
+			continue
+		}
+
+		for i, q := range TimingQuantiles {
+			snapshot[name+"_"+quantileSuffix(q)] = quantiles[i]
+		}
+	}
+
 	return snapshot
 }
 
@@ -235,4 +554,20 @@ func (m *Metrics) rotate() {
 	for _, c := range m.counters {
 		c.UpdateDelta()
 	}
+
+	for _, h := range m.histograms {
+		h.UpdateDelta()
+	}
+
+	for _, v := range m.counterVecs {
+		v.UpdateDelta()
+	}
+
+	for _, v := range m.histogramVecs {
+		v.UpdateDelta()
+	}
+
+	for _, t := range m.timings {
+		t.UpdateDelta()
+	}
 }
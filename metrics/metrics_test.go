@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -31,6 +32,39 @@ func TestMetricsSnapshot(t *testing.T) {
 	assert.Equal(t, uint64(123), m.IntervalSnapshot()["test_gauge"])
 }
 
+func TestMetricsSnapshotHistogram(t *testing.T) {
+	m := NewMetrics(nil, 10)
+
+	m.RegisterHistogram("test_duration", "", []float64{1, 10})
+
+	m.Histogram("test_duration").Observe(2.4)
+	m.Histogram("test_duration").Observe(4.8)
+
+	m.rotate()
+
+	assert.Equal(t, uint64(2), m.IntervalSnapshot()["test_duration_count"])
+	assert.NotContains(t, m.IntervalSnapshot(), "test_duration_sum")
+	assert.InDelta(t, 7.2, m.IntervalHistogramSums()["test_duration_sum"], 1e-9)
+}
+
+func TestMetricsSnapshotCounterVec(t *testing.T) {
+	m := NewMetrics(nil, 10)
+
+	m.RegisterCounterVec("test_total", "", "channel")
+
+	for i := 0; i < 10; i++ {
+		m.CounterVec("test_total").With(fmt.Sprintf("channel_%d", i)).Add(uint64(i + 1))
+	}
+
+	m.rotate()
+
+	snapshot := m.IntervalSnapshot()
+
+	assert.Equal(t, uint64(10), snapshot["test_total:channel_9"])
+	assert.Equal(t, uint64(6), snapshot["test_total:channel_5"])
+	assert.NotContains(t, snapshot, "test_total:channel_4")
+}
+
 func TestMetrics_EachGauge(t *testing.T) {
 	m := NewMetrics(nil, 10)
 
@@ -70,3 +104,38 @@ func TestMetrics_EachCounter(t *testing.T) {
 		}
 	})
 }
+
+func TestMetrics_GoRuntime(t *testing.T) {
+	m := NewMetrics(nil, 10)
+	m.EnableGoRuntime()
+
+	assert.NotZero(t, m.Gauge("go_goroutines").Value())
+	assert.NotZero(t, m.Gauge("go_threads").Value())
+	assert.NotZero(t, m.Gauge("go_heap_alloc_bytes").Value())
+	assert.NotZero(t, m.Gauge("go_sys_bytes").Value())
+
+	assert.Contains(t, m.IntervalSnapshot(), "go_gc_count")
+}
+
+func TestFromConfig_SSL(t *testing.T) {
+	t.Run("With incomplete SSL config", func(t *testing.T) {
+		config := NewConfig()
+		config.HTTP = "/metrics"
+		config.Port = 8089
+		config.SSL.CertPath = "/path/to/cert.pem"
+
+		_, err := FromConfig(&config)
+		assert.Contains(t, err.Error(), "metrics_ssl_key")
+	})
+
+	t.Run("With missing certificate", func(t *testing.T) {
+		config := NewConfig()
+		config.HTTP = "/metrics"
+		config.Port = 8089
+		config.SSL.CertPath = "/path/to/cert.pem"
+		config.SSL.KeyPath = "/path/to/key.pem"
+
+		_, err := FromConfig(&config)
+		assert.Contains(t, err.Error(), "Failed to configure TLS for metrics server")
+	})
+}
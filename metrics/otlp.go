@@ -0,0 +1,414 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anycable/anycable-go/version"
+	"github.com/apex/log"
+)
+
+const (
+	otlpServiceName       = "anycable-go"
+	otlpCumulative        = 2
+	otlpRequestTimeout    = 10 * time.Second
+	otlpRetryInitialDelay = time.Second
+	otlpMetricsPath       = "/v1/metrics"
+)
+
+// OTLPWriter periodically exports metrics to an OpenTelemetry collector
+// using OTLP/HTTP protocol with JSON encoding.
+//
+// Metrics are exported with the cumulative temporality on the writer's own schedule,
+// so a slow or unavailable collector never blocks the metrics collection loop.
+type OTLPWriter struct {
+	metrics  *Metrics
+	endpoint string
+	headers  map[string]string
+	interval time.Duration
+	instance string
+	client   *http.Client
+
+	startedAt time.Time
+	shutdown  chan struct{}
+	closeOnce sync.Once
+	log       *log.Entry
+}
+
+// NewOTLPWriter creates a new OTLP writer for the metrics instance
+func NewOTLPWriter(m *Metrics, config *Config) (*OTLPWriter, error) {
+	if err := config.ValidateOTLP(); err != nil {
+		return nil, err
+	}
+
+	headers, err := parseKeyValuePairs(config.OTLPHeaders)
+
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := strings.TrimSuffix(config.OTLPEndpoint, "/")
+
+	if !strings.HasSuffix(endpoint, otlpMetricsPath) {
+		endpoint += otlpMetricsPath
+	}
+
+	instance := config.NodeID
+
+	if instance == "" {
+		if instance, err = os.Hostname(); err != nil {
+			instance = "unknown"
+		}
+	}
+
+	return &OTLPWriter{
+		metrics:   m,
+		endpoint:  endpoint,
+		headers:   headers,
+		interval:  time.Duration(config.OTLPInterval) * time.Second,
+		instance:  instance,
+		client:    &http.Client{Timeout: otlpRequestTimeout},
+		startedAt: time.Now(),
+		shutdown:  make(chan struct{}),
+		log:       log.WithField("context", "metrics"),
+	}, nil
+}
+
+// Run starts the exporting loop (using the rotation interval if no export interval specified)
+func (w *OTLPWriter) Run(interval int) error {
+	if w.interval == 0 {
+		w.interval = time.Duration(interval) * time.Second
+	}
+
+	w.log.Infof("Export metrics to OTLP endpoint %s every %v", w.endpoint, w.interval)
+
+	go w.loop()
+
+	return nil
+}
+
+// Stop stops the exporting loop
+func (w *OTLPWriter) Stop() {
+	w.closeOnce.Do(func() {
+		close(w.shutdown)
+	})
+}
+
+// Write does nothing, since metrics are exported on the writer's own schedule
+func (w *OTLPWriter) Write(m *Metrics) error {
+	return nil
+}
+
+func (w *OTLPWriter) loop() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.shutdown:
+			return
+		case <-ticker.C:
+			w.export(time.Now().Add(w.interval))
+		}
+	}
+}
+
+// export sends the current metrics and retries with exponential backoff until
+// the deadline (the next export time), since the next export supersedes the current one
+func (w *OTLPWriter) export(deadline time.Time) {
+	payload, err := json.Marshal(w.buildRequest(time.Now()))
+
+	if err != nil {
+		w.log.Errorf("Failed to encode OTLP metrics: %v", err)
+		return
+	}
+
+	delay := otlpRetryInitialDelay
+
+	for {
+		err := w.send(payload)
+
+		if err == nil {
+			return
+		}
+
+		var permanent *otlpPermanentError
+
+		if errors.As(err, &permanent) || time.Now().Add(delay).After(deadline) {
+			w.log.Warnf("Failed to export metrics to OTLP endpoint: %v", err)
+			return
+		}
+
+		w.log.Debugf("Failed to export metrics to OTLP endpoint, retrying in %v: %v", delay, err)
+
+		select {
+		case <-w.shutdown:
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+	}
+}
+
+type otlpPermanentError struct {
+	status int
+}
+
+func (e *otlpPermanentError) Error() string {
+	return fmt.Sprintf("OTLP endpoint responded with %d", e.status)
+}
+
+func (w *OTLPWriter) send(payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.endpoint, bytes.NewReader(payload))
+
+	if err != nil {
+		return &otlpPermanentError{}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := w.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body) // nolint:errcheck
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+
+	// See https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/protocol/otlp.md#retryable-response-codes
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return fmt.Errorf("OTLP endpoint responded with %d", res.StatusCode)
+	default:
+		return &otlpPermanentError{status: res.StatusCode}
+	}
+}
+
+// OTLP JSON encoding structs (see opentelemetry-proto/opentelemetry/proto/metrics/v1).
+// Note that 64-bit integers are encoded as strings.
+type otlpRequest struct {
+	ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource     otlpResource       `json:"resource"`
+	ScopeMetrics []otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpScopeMetrics struct {
+	Scope   otlpScope    `json:"scope"`
+	Metrics []otlpMetric `json:"metrics"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpMetric struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Sum         *otlpSum       `json:"sum,omitempty"`
+	Gauge       *otlpGauge     `json:"gauge,omitempty"`
+	Histogram   *otlpHistogram `json:"histogram,omitempty"`
+}
+
+type otlpSum struct {
+	DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	AggregationTemporality int                   `json:"aggregationTemporality"`
+	IsMonotonic            bool                  `json:"isMonotonic"`
+}
+
+type otlpGauge struct {
+	DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+}
+
+type otlpNumberDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsInt             string          `json:"asInt"`
+}
+
+type otlpHistogram struct {
+	DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                      `json:"aggregationTemporality"`
+}
+
+type otlpHistogramDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	Count             string          `json:"count"`
+	Sum               float64         `json:"sum"`
+	BucketCounts      []string        `json:"bucketCounts"`
+	ExplicitBounds    []float64       `json:"explicitBounds"`
+}
+
+func (w *OTLPWriter) buildRequest(now time.Time) *otlpRequest {
+	start := otlpTime(w.startedAt)
+	ts := otlpTime(now)
+
+	metrics := []otlpMetric{}
+
+	w.metrics.EachCounter(func(counter *Counter) {
+		metrics = append(metrics, otlpMetric{
+			Name:        prometheusNamespace + "_" + counter.Name(),
+			Description: counter.Desc(),
+			Sum: &otlpSum{
+				DataPoints: []otlpNumberDataPoint{
+					{StartTimeUnixNano: start, TimeUnixNano: ts, AsInt: strconv.FormatUint(counter.Value(), 10)},
+				},
+				AggregationTemporality: otlpCumulative,
+				IsMonotonic:            true,
+			},
+		})
+	})
+
+	w.metrics.EachCounterVec(func(vec *CounterVec) {
+		points := []otlpNumberDataPoint{}
+
+		vec.Each(func(value string, counter *Counter) {
+			points = append(points, otlpNumberDataPoint{
+				Attributes:        []otlpAttribute{{Key: vec.Label(), Value: otlpAttributeValue{StringValue: value}}},
+				StartTimeUnixNano: start,
+				TimeUnixNano:      ts,
+				AsInt:             strconv.FormatUint(counter.Value(), 10),
+			})
+		})
+
+		metrics = append(metrics, otlpMetric{
+			Name:        prometheusNamespace + "_" + vec.Name(),
+			Description: vec.Desc(),
+			Sum: &otlpSum{
+				DataPoints:             points,
+				AggregationTemporality: otlpCumulative,
+				IsMonotonic:            true,
+			},
+		})
+	})
+
+	w.metrics.EachGauge(func(gauge *Gauge) {
+		metrics = append(metrics, otlpMetric{
+			Name:        prometheusNamespace + "_" + gauge.Name(),
+			Description: gauge.Desc(),
+			Gauge: &otlpGauge{
+				DataPoints: []otlpNumberDataPoint{
+					{TimeUnixNano: ts, AsInt: strconv.FormatUint(gauge.Value(), 10)},
+				},
+			},
+		})
+	})
+
+	w.metrics.EachHistogram(func(histogram *Histogram) {
+		metrics = append(metrics, otlpMetric{
+			Name:        prometheusNamespace + "_" + histogram.Name(),
+			Description: histogram.Desc(),
+			Histogram: &otlpHistogram{
+				DataPoints:             []otlpHistogramDataPoint{otlpHistogramPoint(histogram, nil, start, ts)},
+				AggregationTemporality: otlpCumulative,
+			},
+		})
+	})
+
+	w.metrics.EachHistogramVec(func(vec *HistogramVec) {
+		points := []otlpHistogramDataPoint{}
+
+		vec.Each(func(value string, histogram *Histogram) {
+			attributes := []otlpAttribute{{Key: vec.Label(), Value: otlpAttributeValue{StringValue: value}}}
+			points = append(points, otlpHistogramPoint(histogram, attributes, start, ts))
+		})
+
+		metrics = append(metrics, otlpMetric{
+			Name:        prometheusNamespace + "_" + vec.Name(),
+			Description: vec.Desc(),
+			Histogram: &otlpHistogram{
+				DataPoints:             points,
+				AggregationTemporality: otlpCumulative,
+			},
+		})
+	})
+
+	return &otlpRequest{
+		ResourceMetrics: []otlpResourceMetrics{
+			{
+				Resource: otlpResource{
+					Attributes: []otlpAttribute{
+						{Key: "service.name", Value: otlpAttributeValue{StringValue: otlpServiceName}},
+						{Key: "service.instance.id", Value: otlpAttributeValue{StringValue: w.instance}},
+						{Key: "service.version", Value: otlpAttributeValue{StringValue: version.Version()}},
+					},
+				},
+				ScopeMetrics: []otlpScopeMetrics{
+					{
+						Scope:   otlpScope{Name: otlpServiceName, Version: version.Version()},
+						Metrics: metrics,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func otlpHistogramPoint(histogram *Histogram, attributes []otlpAttribute, start string, ts string) otlpHistogramDataPoint {
+	buckets, count, sum := histogram.Snapshot()
+
+	// OTLP expects per-bucket (not cumulative) counts including the +Inf bucket
+	counts := make([]string, len(buckets)+1)
+
+	var prev uint64
+
+	for i, c := range buckets {
+		counts[i] = strconv.FormatUint(c-prev, 10)
+		prev = c
+	}
+
+	counts[len(buckets)] = strconv.FormatUint(count-prev, 10)
+
+	return otlpHistogramDataPoint{
+		Attributes:        attributes,
+		StartTimeUnixNano: start,
+		TimeUnixNano:      ts,
+		Count:             strconv.FormatUint(count, 10),
+		Sum:               sum,
+		BucketCounts:      counts,
+		ExplicitBounds:    histogram.Buckets(),
+	}
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOTLPWriter(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	m := NewMetrics(nil, 10)
+	m.RegisterCounter("test_total", "Total number of smth")
+	m.RegisterGauge("tests", "Number of active smth")
+	m.RegisterHistogram("test_seconds", "Duration of smth", []float64{0.1, 1})
+	m.RegisterHistogramVec("test_call_seconds", "Duration of calls", "method", []float64{0.1, 1})
+
+	m.Counter("test_total").Add(3)
+	m.Gauge("tests").Set(5)
+	m.Histogram("test_seconds").Observe(0.5)
+	m.Histogram("test_seconds").Observe(5)
+	m.HistogramVec("test_call_seconds").With("connect").Observe(0.05)
+
+	config := NewConfig()
+	config.OTLPEndpoint = srv.URL
+	config.OTLPHeaders = "Authorization=Bearer secret"
+
+	w, err := NewOTLPWriter(m, &config)
+	assert.Nil(t, err)
+
+	w.export(time.Now().Add(time.Second))
+
+	req := <-received
+	assert.Equal(t, "/v1/metrics", req.URL.Path)
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+
+	var payload otlpRequest
+	assert.Nil(t, json.Unmarshal(<-bodies, &payload))
+
+	resource := payload.ResourceMetrics[0]
+	assert.Equal(t, "service.name", resource.Resource.Attributes[0].Key)
+	assert.Equal(t, "anycable-go", resource.Resource.Attributes[0].Value.StringValue)
+	assert.Equal(t, "service.instance.id", resource.Resource.Attributes[1].Key)
+
+	metrics := make(map[string]otlpMetric)
+
+	for _, metric := range resource.ScopeMetrics[0].Metrics {
+		metrics[metric.Name] = metric
+	}
+
+	counter := metrics["anycable_go_test_total"]
+	assert.Equal(t, "3", counter.Sum.DataPoints[0].AsInt)
+	assert.True(t, counter.Sum.IsMonotonic)
+
+	gauge := metrics["anycable_go_tests"]
+	assert.Equal(t, "5", gauge.Gauge.DataPoints[0].AsInt)
+
+	histogram := metrics["anycable_go_test_seconds"]
+	assert.Equal(t, "2", histogram.Histogram.DataPoints[0].Count)
+	assert.Equal(t, []string{"0", "1", "1"}, histogram.Histogram.DataPoints[0].BucketCounts)
+	assert.Equal(t, []float64{0.1, 1}, histogram.Histogram.DataPoints[0].ExplicitBounds)
+
+	vec := metrics["anycable_go_test_call_seconds"]
+	assert.Equal(t, "method", vec.Histogram.DataPoints[0].Attributes[0].Key)
+	assert.Equal(t, "connect", vec.Histogram.DataPoints[0].Attributes[0].Value.StringValue)
+	assert.Equal(t, []string{"1", "0", "0"}, vec.Histogram.DataPoints[0].BucketCounts)
+}
+
+func TestOTLPWriterRetries(t *testing.T) {
+	var attempts int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	config := NewConfig()
+	config.OTLPEndpoint = srv.URL + "/v1/metrics"
+
+	w, err := NewOTLPWriter(NewMetrics(nil, 10), &config)
+	assert.Nil(t, err)
+
+	w.export(time.Now().Add(5 * time.Second))
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+
+	atomic.StoreInt32(&attempts, 10)
+
+	// Doesn't retry if the deadline is close
+	w.export(time.Now())
+	assert.Equal(t, int32(11), atomic.LoadInt32(&attempts))
+}
+
+func TestOTLPWriterConfig(t *testing.T) {
+	config := NewConfig()
+	config.OTLPEndpoint = "http://localhost:4318"
+
+	config.OTLPProtocol = "grpc"
+	_, err := NewOTLPWriter(NewMetrics(nil, 10), &config)
+	assert.NotNil(t, err)
+
+	config.OTLPProtocol = "http"
+	config.OTLPHeaders = "invalid"
+	_, err = NewOTLPWriter(NewMetrics(nil, 10), &config)
+	assert.NotNil(t, err)
+}
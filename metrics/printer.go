@@ -1,6 +1,17 @@
 package metrics
 
-import "github.com/apex/log"
+import (
+	"path"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+const (
+	metricsFormatterLoadedAt   = "metrics_formatter_loaded_at"
+	metricsFormatterLoadFailed = "metrics_formatter_load_failed"
+)
 
 // Printer describes metrics logging interface
 type Printer interface {
@@ -9,11 +20,17 @@ type Printer interface {
 
 // BasePrinter simply logs stats as structured log
 type BasePrinter struct {
+	// Metrics names or glob patterns to include (all metrics are included if empty)
+	filter []string
+	// Static fields to add to every log entry
+	fields map[string]string
+	// Used to check the filter against the actual metrics only once
+	checkFilter sync.Once
 }
 
 // NewBasePrinter returns new base printer struct
-func NewBasePrinter() *BasePrinter {
-	return &BasePrinter{}
+func NewBasePrinter(filter []string, fields map[string]string) *BasePrinter {
+	return &BasePrinter{filter: filter, fields: fields}
 }
 
 // Run prints a message to the log with metrics logging details
@@ -28,13 +45,45 @@ func (p *BasePrinter) Stop() {
 // Write prints formatted snapshot to the log
 func (p *BasePrinter) Write(m *Metrics) error {
 	snapshot := m.IntervalSnapshot()
-	p.Print(snapshot)
+
+	if len(p.filter) > 0 {
+		p.checkFilter.Do(func() { p.warnUnmatchedPatterns(snapshot) })
+
+		snapshot = p.filterSnapshot(snapshot)
+	}
+
+	sums := m.IntervalHistogramSums()
+	timings := m.IntervalTimings()
+
+	if len(p.filter) > 0 {
+		for name := range sums {
+			if !p.matches(name) {
+				delete(sums, name)
+			}
+		}
+
+		for name := range timings {
+			if !p.matches(name) {
+				delete(timings, name)
+			}
+		}
+	}
+
+	p.print(snapshot, sums, timings)
 	return nil
 }
 
 // Print logs stats data using global logger with info level
-func (*BasePrinter) Print(snapshot map[string]uint64) {
-	fields := make(log.Fields, len(snapshot)+1)
+func (p *BasePrinter) Print(snapshot map[string]uint64) {
+	p.print(snapshot, nil, nil)
+}
+
+func (p *BasePrinter) print(snapshot map[string]uint64, sums map[string]float64, timings map[string]time.Duration) {
+	fields := make(log.Fields, len(snapshot)+len(sums)+len(timings)+len(p.fields)+1)
+
+	for k, v := range p.fields {
+		fields[k] = v
+	}
 
 	fields["context"] = "metrics"
 
@@ -42,5 +91,61 @@ func (*BasePrinter) Print(snapshot map[string]uint64) {
 		fields[k] = v
 	}
 
+	for k, v := range sums {
+		fields[k] = v
+	}
+
+	for k, v := range timings {
+		fields[k] = formatTiming(v)
+	}
+
 	log.WithFields(fields).Info("")
 }
+
+func (p *BasePrinter) filterSnapshot(snapshot map[string]uint64) map[string]uint64 {
+	filtered := make(map[string]uint64)
+
+	for name, value := range snapshot {
+		if p.matches(name) {
+			filtered[name] = value
+		}
+	}
+
+	return filtered
+}
+
+func (p *BasePrinter) matches(name string) bool {
+	for _, pattern := range p.filter {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// formatTiming rounds the duration to make logs more readable (e.g., "12ms" or "350µs")
+func formatTiming(d time.Duration) string {
+	if d >= time.Millisecond {
+		return d.Round(time.Millisecond).String()
+	}
+
+	return d.Round(time.Microsecond).String()
+}
+
+func (p *BasePrinter) warnUnmatchedPatterns(snapshot map[string]uint64) {
+	for _, pattern := range p.filter {
+		matched := false
+
+		for name := range snapshot {
+			if ok, _ := path.Match(pattern, name); ok {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			log.WithField("context", "metrics").Warnf("Metrics log filter pattern doesn't match any metric: %s", pattern)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBasePrinterFilter(t *testing.T) {
+	p := NewBasePrinter([]string{"clients_num", "rpc_*"}, nil)
+
+	snapshot := map[string]uint64{
+		"clients_num":       1,
+		"clients_uniq_num":  1,
+		"rpc_call_total":    10,
+		"rpc_error_total":   2,
+		"broadcast_msg_num": 5,
+	}
+
+	assert.Equal(t,
+		map[string]uint64{"clients_num": 1, "rpc_call_total": 10, "rpc_error_total": 2},
+		p.filterSnapshot(snapshot),
+	)
+}
+
+func TestFromConfig_LogOptions(t *testing.T) {
+	config := NewConfig()
+	config.Log = true
+	config.LogFilter = "clients_num, rpc_*"
+	config.LogFields = "region=eu"
+
+	m, err := FromConfig(&config)
+	assert.Nil(t, err)
+
+	printer := m.writers[0].(*BasePrinter)
+	assert.Equal(t, []string{"clients_num", "rpc_*"}, printer.filter)
+	assert.Equal(t, map[string]string{"region": "eu"}, printer.fields)
+
+	config.LogFilter = "rpc_["
+	_, err = FromConfig(&config)
+	assert.NotNil(t, err)
+
+	config.LogFilter = ""
+	config.LogFields = "region"
+	_, err = FromConfig(&config)
+	assert.NotNil(t, err)
+}
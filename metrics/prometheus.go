@@ -11,10 +11,22 @@ const (
 	prometheusNamespace = "anycable_go"
 )
 
+var prometheusLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
 // Prometheus returns metrics info in Prometheus format
 func (m *Metrics) Prometheus() string {
 	var buf strings.Builder
 
+	m.collect()
+
+	if m.nodeID != "" {
+		name := prometheusNamespace + `_node_info`
+
+		buf.WriteString("\n# HELP " + name + " The node information (the value is always 1)\n")
+		buf.WriteString("# TYPE " + name + " gauge\n")
+		buf.WriteString(name + `{node_id="` + prometheusLabelEscaper.Replace(m.nodeID) + `"} 1` + "\n")
+	}
+
 	m.EachCounter(func(counter *Counter) {
 		name := prometheusNamespace + `_` + counter.Name()
 
@@ -25,6 +37,19 @@ func (m *Metrics) Prometheus() string {
 		buf.WriteString(name + " " + strconv.FormatUint(counter.Value(), 10) + "\n")
 	})
 
+	m.EachCounterVec(func(vec *CounterVec) {
+		name := prometheusNamespace + `_` + vec.Name()
+
+		buf.WriteString(
+			"\n# HELP " + name + " " + vec.Desc() + "\n",
+		)
+		buf.WriteString("# TYPE " + name + " counter\n")
+
+		vec.Each(func(value string, counter *Counter) {
+			buf.WriteString(name + `{` + vec.Label() + `="` + prometheusLabelEscaper.Replace(value) + `"} ` + strconv.FormatUint(counter.Value(), 10) + "\n")
+		})
+	})
+
 	m.EachGauge(func(gauge *Gauge) {
 		name := prometheusNamespace + `_` + gauge.Name()
 
@@ -35,6 +60,65 @@ func (m *Metrics) Prometheus() string {
 		buf.WriteString(name + " " + strconv.FormatUint(gauge.Value(), 10) + "\n")
 	})
 
+	m.EachHistogram(func(histogram *Histogram) {
+		name := prometheusNamespace + `_` + histogram.Name()
+
+		buf.WriteString(
+			"\n# HELP " + name + " " + histogram.Desc() + "\n",
+		)
+		buf.WriteString("# TYPE " + name + " histogram\n")
+
+		buckets, count, sum := histogram.Snapshot()
+
+		for i, bound := range histogram.Buckets() {
+			buf.WriteString(name + `_bucket{le="` + strconv.FormatFloat(bound, 'g', -1, 64) + `"} ` + strconv.FormatUint(buckets[i], 10) + "\n")
+		}
+
+		buf.WriteString(name + `_bucket{le="+Inf"} ` + strconv.FormatUint(count, 10) + "\n")
+		buf.WriteString(name + "_sum " + strconv.FormatFloat(sum, 'g', -1, 64) + "\n")
+		buf.WriteString(name + "_count " + strconv.FormatUint(count, 10) + "\n")
+	})
+
+	m.EachHistogramVec(func(vec *HistogramVec) {
+		name := prometheusNamespace + `_` + vec.Name()
+
+		buf.WriteString(
+			"\n# HELP " + name + " " + vec.Desc() + "\n",
+		)
+		buf.WriteString("# TYPE " + name + " histogram\n")
+
+		vec.Each(func(value string, histogram *Histogram) {
+			label := vec.Label() + `="` + prometheusLabelEscaper.Replace(value) + `"`
+			buckets, count, sum := histogram.Snapshot()
+
+			for i, bound := range histogram.Buckets() {
+				buf.WriteString(name + `_bucket{` + label + `,le="` + strconv.FormatFloat(bound, 'g', -1, 64) + `"} ` + strconv.FormatUint(buckets[i], 10) + "\n")
+			}
+
+			buf.WriteString(name + `_bucket{` + label + `,le="+Inf"} ` + strconv.FormatUint(count, 10) + "\n")
+			buf.WriteString(name + `_sum{` + label + `} ` + strconv.FormatFloat(sum, 'g', -1, 64) + "\n")
+			buf.WriteString(name + `_count{` + label + `} ` + strconv.FormatUint(count, 10) + "\n")
+		})
+	})
+
+	m.EachTiming(func(timing *Timing) {
+		name := prometheusNamespace + `_` + timing.Name()
+
+		buf.WriteString(
+			"\n# HELP " + name + " " + timing.Desc() + "\n",
+		)
+		buf.WriteString("# TYPE " + name + " summary\n")
+
+		quantiles, _ := timing.IntervalValue()
+
+		for i, q := range TimingQuantiles {
+			buf.WriteString(name + `{quantile="` + strconv.FormatFloat(q, 'g', -1, 64) + `"} ` + strconv.FormatFloat(quantiles[i].Seconds(), 'g', -1, 64) + "\n")
+		}
+
+		buf.WriteString(name + "_sum " + strconv.FormatFloat(timing.Sum().Seconds(), 'g', -1, 64) + "\n")
+		buf.WriteString(name + "_count " + strconv.FormatUint(timing.Count(), 10) + "\n")
+	})
+
 	return buf.String()
 }
 
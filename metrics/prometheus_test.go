@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -54,6 +55,111 @@ anycable_go_any_tests 0
 	)
 }
 
+func TestPrometheusCollectors(t *testing.T) {
+	m := NewMetrics(nil, 10)
+
+	m.RegisterGauge("pool_size", "The size of the pool")
+
+	size := 0
+
+	m.RegisterCollector(func() {
+		size++
+		m.Gauge("pool_size").Set(size)
+	})
+
+	assert.Contains(t, m.Prometheus(), "anycable_go_pool_size 1\n")
+	assert.Contains(t, m.Prometheus(), "anycable_go_pool_size 2\n")
+}
+
+func TestPrometheusHistogram(t *testing.T) {
+	m := NewMetrics(nil, 10)
+
+	m.RegisterHistogram("test_seconds", "Duration of smth", []float64{0.1, 1})
+
+	m.Histogram("test_seconds").Observe(0.05)
+	m.Histogram("test_seconds").Observe(0.5)
+	m.Histogram("test_seconds").Observe(5)
+
+	assert.Contains(t, m.Prometheus(),
+		`
+# HELP anycable_go_test_seconds Duration of smth
+# TYPE anycable_go_test_seconds histogram
+anycable_go_test_seconds_bucket{le="0.1"} 1
+anycable_go_test_seconds_bucket{le="1"} 2
+anycable_go_test_seconds_bucket{le="+Inf"} 3
+anycable_go_test_seconds_sum 5.55
+anycable_go_test_seconds_count 3
+`,
+	)
+}
+
+func TestPrometheusHistogramVec(t *testing.T) {
+	m := NewMetrics(nil, 10)
+
+	m.RegisterHistogramVec("test_seconds", "Duration of smth", "method", []float64{0.1, 1})
+
+	m.HistogramVec("test_seconds").With("connect").Observe(0.05)
+	m.HistogramVec("test_seconds").With("command").Observe(5)
+
+	assert.Contains(t, m.Prometheus(),
+		`
+# HELP anycable_go_test_seconds Duration of smth
+# TYPE anycable_go_test_seconds histogram
+anycable_go_test_seconds_bucket{method="command",le="0.1"} 0
+anycable_go_test_seconds_bucket{method="command",le="1"} 0
+anycable_go_test_seconds_bucket{method="command",le="+Inf"} 1
+anycable_go_test_seconds_sum{method="command"} 5
+anycable_go_test_seconds_count{method="command"} 1
+anycable_go_test_seconds_bucket{method="connect",le="0.1"} 1
+anycable_go_test_seconds_bucket{method="connect",le="1"} 1
+anycable_go_test_seconds_bucket{method="connect",le="+Inf"} 1
+anycable_go_test_seconds_sum{method="connect"} 0.05
+anycable_go_test_seconds_count{method="connect"} 1
+`,
+	)
+}
+
+func TestPrometheusTiming(t *testing.T) {
+	m := NewMetrics(nil, 10)
+
+	m.RegisterTiming("test_call", "Duration of calls")
+
+	m.Timing("test_call").Observe(100 * time.Millisecond)
+	m.Timing("test_call").Observe(300 * time.Millisecond)
+
+	m.rotate()
+
+	assert.Contains(t, m.Prometheus(),
+		`
+# HELP anycable_go_test_call Duration of calls
+# TYPE anycable_go_test_call summary
+anycable_go_test_call{quantile="0.5"} 0.1
+anycable_go_test_call{quantile="0.95"} 0.3
+anycable_go_test_call{quantile="0.99"} 0.3
+anycable_go_test_call_sum 0.4
+anycable_go_test_call_count 2
+`,
+	)
+}
+
+func TestPrometheusCounterVec(t *testing.T) {
+	m := NewMetrics(nil, 10)
+
+	m.RegisterCounterVec("commands_total", "Total number of commands", "channel")
+
+	m.CounterVec("commands_total").With("ChatChannel").Add(2)
+	m.CounterVec("commands_total").With(`Weird"Channel`).Inc()
+
+	assert.Contains(t, m.Prometheus(),
+		`
+# HELP anycable_go_commands_total Total number of commands
+# TYPE anycable_go_commands_total counter
+anycable_go_commands_total{channel="ChatChannel"} 2
+anycable_go_commands_total{channel="Weird\"Channel"} 1
+`,
+	)
+}
+
 func TestPrometheusHandler(t *testing.T) {
 	m := NewMetrics(nil, 10)
 
@@ -79,3 +185,19 @@ func TestPrometheusHandler(t *testing.T) {
 	assert.Contains(t, body, "anycable_go_test_total 3")
 	assert.Contains(t, body, "anycable_go_any_total 0")
 }
+
+func TestPrometheusNodeInfo(t *testing.T) {
+	m := NewMetrics(nil, 10)
+
+	assert.NotContains(t, m.Prometheus(), "anycable_go_node_info")
+
+	m.nodeID = "node-1"
+
+	assert.Contains(t, m.Prometheus(),
+		`
+# HELP anycable_go_node_info The node information (the value is always 1)
+# TYPE anycable_go_node_info gauge
+anycable_go_node_info{node_id="node-1"} 1
+`,
+	)
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"runtime"
+	"runtime/pprof"
+)
+
+const (
+	metricsGoGoroutines   = "go_goroutines"
+	metricsGoThreads      = "go_threads"
+	metricsGoHeapAlloc    = "go_heap_alloc_bytes"
+	metricsGoHeapInuse    = "go_heap_inuse_bytes"
+	metricsGoSys          = "go_sys_bytes"
+	metricsGoGCPauseTotal = "go_gc_pause_total_ns"
+	metricsGoGCCount      = "go_gc_count"
+)
+
+// EnableGoRuntime registers Go runtime gauges (updated on every metrics rotation)
+func (m *Metrics) EnableGoRuntime() {
+	m.RegisterGauge(metricsGoGoroutines, "The number of goroutines")
+	m.RegisterGauge(metricsGoThreads, "The number of OS threads created")
+	m.RegisterGauge(metricsGoHeapAlloc, "The number of bytes of allocated heap objects")
+	m.RegisterGauge(metricsGoHeapInuse, "The number of bytes in in-use heap spans")
+	m.RegisterGauge(metricsGoSys, "The total bytes of memory obtained from the OS")
+	m.RegisterGauge(metricsGoGCPauseTotal, "The cumulative time spent in GC stop-the-world pauses (ns)")
+	m.RegisterGauge(metricsGoGCCount, "The number of completed GC cycles")
+
+	m.goRuntime = true
+
+	m.collectRuntime()
+}
+
+func (m *Metrics) collectRuntime() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	m.Gauge(metricsGoGoroutines).Set(runtime.NumGoroutine())
+	m.Gauge(metricsGoThreads).Set(pprof.Lookup("threadcreate").Count())
+	m.Gauge(metricsGoHeapAlloc).Set64(stats.HeapAlloc)
+	m.Gauge(metricsGoHeapInuse).Set64(stats.HeapInuse)
+	m.Gauge(metricsGoSys).Set64(stats.Sys)
+	m.Gauge(metricsGoGCPauseTotal).Set64(stats.PauseTotalNs)
+	m.Gauge(metricsGoGCCount).Set64(uint64(stats.NumGC))
+}
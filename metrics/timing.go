@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// The number of independent reservoirs to reduce lock contention
+	timingShards = 8
+	// The max number of samples kept per shard during the interval
+	timingShardSize = 256
+)
+
+// TimingQuantiles are the quantiles calculated for timings
+var TimingQuantiles = []float64{0.5, 0.95, 0.99}
+
+// timingShard is a reservoir of the interval samples (Algorithm R)
+type timingShard struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	seen    uint64
+	rnd     uint64
+	// Avoid false sharing between shards
+	_ [64]byte
+}
+
+func (s *timingShard) add(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seen++
+
+	if len(s.samples) < timingShardSize {
+		s.samples = append(s.samples, d)
+		return
+	}
+
+	// xorshift is enough to pick a random sample to replace
+	s.rnd ^= s.rnd << 13
+	s.rnd ^= s.rnd >> 7
+	s.rnd ^= s.rnd << 17
+
+	if j := s.rnd % s.seen; j < timingShardSize {
+		s.samples[j] = d
+	}
+}
+
+// reset returns the samples and the number of observations and starts a new interval
+func (s *timingShard) reset() ([]time.Duration, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples, seen := s.samples, s.seen
+	s.samples = make([]time.Duration, 0, timingShardSize)
+	s.seen = 0
+
+	return samples, seen
+}
+
+// Timing aggregates durations (e.g., latencies) within the metrics interval
+// and calculates quantiles (TimingQuantiles) on rotation.
+// Observations are sampled into a few independent reservoirs, so recording is cheap
+// and the memory usage doesn't depend on the number of observations.
+type Timing struct {
+	// Must be the first fields to be 64-bit aligned
+	count uint64
+	sum   int64
+	next  uint32
+
+	name   string
+	desc   string
+	shards [timingShards]timingShard
+
+	mu        sync.RWMutex
+	quantiles []time.Duration
+	interval  uint64
+}
+
+// NewTiming creates a new Timing
+func NewTiming(name string, desc string) *Timing {
+	t := &Timing{name: name, desc: desc, quantiles: make([]time.Duration, len(TimingQuantiles))}
+
+	for i := range t.shards {
+		t.shards[i].rnd = uint64(i)*0x9E3779B97F4A7C15 + 1
+	}
+
+	return t
+}
+
+// Name returns timing name
+func (t *Timing) Name() string {
+	return t.name
+}
+
+// Desc returns timing description
+func (t *Timing) Desc() string {
+	return t.desc
+}
+
+// Observe records a single duration
+func (t *Timing) Observe(d time.Duration) {
+	atomic.AddUint64(&t.count, 1)
+	atomic.AddInt64(&t.sum, int64(d))
+
+	shard := atomic.AddUint32(&t.next, 1) % timingShards
+	t.shards[shard].add(d)
+}
+
+// Count returns the total number of observations
+func (t *Timing) Count() uint64 {
+	return atomic.LoadUint64(&t.count)
+}
+
+// Sum returns the sum of all observations
+func (t *Timing) Sum() time.Duration {
+	return time.Duration(atomic.LoadInt64(&t.sum))
+}
+
+// IntervalValue returns the quantiles (corresponding to TimingQuantiles) and the number of observations
+// for the last interval
+func (t *Timing) IntervalValue() ([]time.Duration, uint64) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	quantiles := make([]time.Duration, len(t.quantiles))
+	copy(quantiles, t.quantiles)
+
+	return quantiles, t.interval
+}
+
+// UpdateDelta calculates the quantiles for the last interval and resets the samples
+func (t *Timing) UpdateDelta() {
+	samples := []time.Duration{}
+	var seen uint64
+
+	for i := range t.shards {
+		shardSamples, shardSeen := t.shards[i].reset()
+
+		samples = append(samples, shardSamples...)
+		seen += shardSeen
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	quantiles := make([]time.Duration, len(TimingQuantiles))
+
+	if len(samples) > 0 {
+		for i, q := range TimingQuantiles {
+			idx := int(math.Ceil(q*float64(len(samples)))) - 1
+
+			if idx < 0 {
+				idx = 0
+			}
+
+			quantiles[i] = samples[idx]
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.quantiles = quantiles
+	t.interval = seen
+}
+
+// quantileSuffix returns the name suffix for the quantile (e.g., "p95")
+func quantileSuffix(q float64) string {
+	return "p" + strconv.FormatFloat(q*100, 'f', -1, 64)
+}
@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTiming(t *testing.T) {
+	timing := NewTiming("test", "")
+
+	for i := 1; i <= 100; i++ {
+		timing.Observe(time.Duration(i) * time.Millisecond)
+	}
+
+	assert.Equal(t, uint64(100), timing.Count())
+	assert.Equal(t, 5050*time.Millisecond, timing.Sum())
+
+	timing.UpdateDelta()
+
+	quantiles, count := timing.IntervalValue()
+	assert.Equal(t, uint64(100), count)
+	assert.Equal(t, []time.Duration{50 * time.Millisecond, 95 * time.Millisecond, 99 * time.Millisecond}, quantiles)
+
+	// Samples are reset every interval
+	timing.Observe(time.Second)
+	timing.UpdateDelta()
+
+	quantiles, count = timing.IntervalValue()
+	assert.Equal(t, uint64(1), count)
+	assert.Equal(t, []time.Duration{time.Second, time.Second, time.Second}, quantiles)
+
+	timing.UpdateDelta()
+
+	quantiles, count = timing.IntervalValue()
+	assert.Equal(t, uint64(0), count)
+	assert.Equal(t, []time.Duration{0, 0, 0}, quantiles)
+}
+
+func TestTimingSampling(t *testing.T) {
+	timing := NewTiming("test", "")
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := 0; i < 10000; i++ {
+				timing.Observe(time.Duration(i%100+1) * time.Millisecond)
+			}
+		}()
+	}
+
+	wg.Wait()
+	timing.UpdateDelta()
+
+	quantiles, count := timing.IntervalValue()
+
+	assert.Equal(t, uint64(40000), count)
+	assert.InDelta(t, 50, quantiles[0].Milliseconds(), 10)
+	assert.InDelta(t, 95, quantiles[1].Milliseconds(), 5)
+	assert.InDelta(t, 99, quantiles[2].Milliseconds(), 3)
+
+	for i := range timing.shards {
+		assert.LessOrEqual(t, len(timing.shards[i].samples), timingShardSize)
+	}
+}
+
+func TestMetricsIntervalTimings(t *testing.T) {
+	m := NewMetrics(nil, 10)
+
+	m.RegisterTiming("rpc_call", "")
+	m.RegisterTiming("idle", "")
+
+	m.Timing("rpc_call").Observe(12 * time.Millisecond)
+
+	m.rotate()
+
+	assert.Equal(t,
+		map[string]time.Duration{
+			"rpc_call_p50": 12 * time.Millisecond,
+			"rpc_call_p95": 12 * time.Millisecond,
+			"rpc_call_p99": 12 * time.Millisecond,
+		},
+		m.IntervalTimings(),
+	)
+}
+
+func TestFormatTiming(t *testing.T) {
+	assert.Equal(t, "12ms", formatTiming(12345678*time.Nanosecond))
+	assert.Equal(t, "350µs", formatTiming(350123*time.Nanosecond))
+	assert.Equal(t, "1.5s", formatTiming(1500*time.Millisecond))
+}
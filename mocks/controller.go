@@ -23,6 +23,7 @@ func (c *MockController) Start() error {
 // Authenticate emulates authentication process:
 // - if path is equal to "failure" then authentication failed
 // - otherwise returns value of headers['id'] as identifier
+// (and headers['x-disconnect-mode'] as disconnect mode)
 func (c *MockController) Authenticate(sid string, env *common.SessionEnv) (*common.ConnectResult, error) {
 	if env.URL == "/failure" {
 		return &common.ConnectResult{Status: common.FAILURE, Transmissions: []string{"unauthorized"}}, nil
@@ -38,6 +39,8 @@ func (c *MockController) Authenticate(sid string, env *common.SessionEnv) (*comm
 		res.CState = map[string]string{"_s_": (*env.Headers)["x-session-test"]}
 	}
 
+	res.DisconnectMode = (*env.Headers)["x-disconnect-mode"]
+
 	return &res, nil
 }
 
@@ -0,0 +1,68 @@
+package node
+
+import (
+	"sync"
+	"time"
+)
+
+type dedupEntry struct {
+	seenAt time.Time
+	// Position in the ring (to avoid evicting the entry re-registered after expiration)
+	pos int
+}
+
+// BroadcastDeduplicator keeps the IDs of the recently received broadcasts
+// to drop the same messages delivered via multiple adapters.
+// The memory is bounded: the oldest IDs are evicted when the ring is full.
+type BroadcastDeduplicator struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	ring    []string
+	pos     int
+	entries map[string]dedupEntry
+
+	now func() time.Time
+}
+
+// NewBroadcastDeduplicator creates a deduplicator keeping up to size IDs for the specified period of time
+func NewBroadcastDeduplicator(size int, ttl time.Duration) *BroadcastDeduplicator {
+	return &BroadcastDeduplicator{
+		ttl:     ttl,
+		ring:    make([]string, size),
+		entries: make(map[string]dedupEntry, size),
+		now:     time.Now,
+	}
+}
+
+// Seen returns true if the ID has been registered recently; otherwise, the ID is registered
+func (d *BroadcastDeduplicator) Seen(id string) bool {
+	now := d.now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if entry, ok := d.entries[id]; ok && now.Sub(entry.seenAt) < d.ttl {
+		return true
+	}
+
+	if evicted := d.ring[d.pos]; evicted != "" {
+		if entry, ok := d.entries[evicted]; ok && entry.pos == d.pos {
+			delete(d.entries, evicted)
+		}
+	}
+
+	d.ring[d.pos] = id
+	d.entries[id] = dedupEntry{seenAt: now, pos: d.pos}
+	d.pos = (d.pos + 1) % len(d.ring)
+
+	return false
+}
+
+// Size returns the number of tracked IDs
+func (d *BroadcastDeduplicator) Size() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return len(d.entries)
+}
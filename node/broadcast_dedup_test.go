@@ -0,0 +1,37 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroadcastDeduplicator(t *testing.T) {
+	now := time.Now()
+
+	d := NewBroadcastDeduplicator(3, time.Minute)
+	d.now = func() time.Time { return now }
+
+	assert.False(t, d.Seen("a"))
+	assert.True(t, d.Seen("a"))
+	assert.False(t, d.Seen("b"))
+
+	t.Run("Expires IDs after TTL", func(t *testing.T) {
+		now = now.Add(2 * time.Minute)
+
+		assert.False(t, d.Seen("a"))
+		assert.True(t, d.Seen("a"))
+	})
+
+	t.Run("Evicts the oldest IDs when full", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			assert.False(t, d.Seen(fmt.Sprintf("id-%d", i)))
+		}
+
+		assert.Equal(t, 3, d.Size())
+		assert.False(t, d.Seen("id-0"))
+		assert.True(t, d.Seen("id-4"))
+	})
+}
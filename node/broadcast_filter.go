@@ -0,0 +1,45 @@
+package node
+
+import (
+	"regexp"
+
+	"github.com/anycable/anycable-go/common"
+)
+
+// BroadcastFilter decides whether a broadcast should be delivered to the stream subscribers.
+// Origin is the name of the broadcasting adapter the message has been received from
+// (e.g., "redis" or "http"; empty if unknown).
+type BroadcastFilter interface {
+	AllowBroadcast(origin string, msg *common.StreamMessage) bool
+}
+
+// BroadcastFilterFunc is an adapter to use ordinary functions as broadcast filters
+type BroadcastFilterFunc func(origin string, msg *common.StreamMessage) bool
+
+// AllowBroadcast calls fn(origin, msg)
+func (fn BroadcastFilterFunc) AllowBroadcast(origin string, msg *common.StreamMessage) bool {
+	return fn(origin, msg)
+}
+
+// StreamPatternFilter allows broadcasts only to the streams matching the regular expression
+type StreamPatternFilter struct {
+	pattern *regexp.Regexp
+}
+
+var _ BroadcastFilter = (*StreamPatternFilter)(nil)
+
+// NewStreamPatternFilter compiles the pattern and returns a new filter
+func NewStreamPatternFilter(pattern string) (*StreamPatternFilter, error) {
+	re, err := regexp.Compile(pattern)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamPatternFilter{pattern: re}, nil
+}
+
+// AllowBroadcast returns true if the stream name matches the pattern
+func (f *StreamPatternFilter) AllowBroadcast(origin string, msg *common.StreamMessage) bool {
+	return f.pattern.MatchString(msg.Stream)
+}
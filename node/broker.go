@@ -0,0 +1,241 @@
+package node
+
+import (
+	"container/list"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/anycable/anycable-go/metrics"
+)
+
+const (
+	metricsHistoryStreams  = "history_streams_num"
+	metricsHistoryMessages = "history_messages_num"
+	metricsHistoryEvicted  = "history_evicted_streams_total"
+	metricsHistoryReplayed = "history_replayed_msg_total"
+	metricsHistoryRejected = "history_rejected_total"
+)
+
+// historyEntry is a stream message stored in the history
+type historyEntry struct {
+	offset    uint64
+	timestamp int64
+	data      string
+}
+
+// streamHistory is a ring buffer of the latest stream messages
+type streamHistory struct {
+	stream  string
+	entries []historyEntry
+	// Position of the oldest entry
+	head int
+	size int
+	// Offset of the latest message (offsets start from 1)
+	lastOffset uint64
+	// LRU list element
+	elem *list.Element
+}
+
+func (sh *streamHistory) add(entry historyEntry) {
+	if sh.size < len(sh.entries) {
+		sh.entries[(sh.head+sh.size)%len(sh.entries)] = entry
+		sh.size++
+		return
+	}
+
+	sh.entries[sh.head] = entry
+	sh.head = (sh.head + 1) % len(sh.entries)
+}
+
+func (sh *streamHistory) at(i int) *historyEntry {
+	return &sh.entries[(sh.head+i)%len(sh.entries)]
+}
+
+// expire removes entries older than the deadline and returns the number of removed entries
+func (sh *streamHistory) expire(deadline int64) int {
+	removed := 0
+
+	for sh.size > 0 && sh.at(0).timestamp < deadline {
+		sh.entries[sh.head] = historyEntry{}
+		sh.head = (sh.head + 1) % len(sh.entries)
+		sh.size--
+		removed++
+	}
+
+	return removed
+}
+
+// Broker keeps the recent messages for streams in memory to replay them to clients
+// (e.g., after reconnecting).
+// Every stream message gets an offset (starting from 1); offsets are only valid within the same epoch
+// (which is changed on restart).
+// The total number of tracked streams is limited: the least recently broadcasted streams are evicted first.
+type Broker struct {
+	epoch      string
+	limit      int
+	ttl        time.Duration
+	maxStreams int
+	metrics    *metrics.Metrics
+
+	mu       sync.Mutex
+	streams  map[string]*streamHistory
+	lru      *list.List
+	messages int
+}
+
+// NewBroker builds a new broker from the node config
+func NewBroker(c *Config, m *metrics.Metrics) *Broker {
+	m.RegisterGauge(metricsHistoryStreams, "The number of streams with the history stored in memory")
+	m.RegisterGauge(metricsHistoryMessages, "The number of messages stored in the streams history")
+	m.RegisterCounter(metricsHistoryEvicted, "The total number of streams evicted from the history due to the streams limit")
+	m.RegisterCounter(metricsHistoryReplayed, "The total number of messages replayed from the history")
+	m.RegisterCounter(metricsHistoryRejected, "The total number of history requests which couldn't be satisfied")
+
+	return &Broker{
+		epoch:      strconv.FormatInt(time.Now().UnixNano(), 36),
+		limit:      c.HistoryLimit,
+		ttl:        time.Duration(c.HistoryTTL) * time.Second,
+		maxStreams: c.HistoryMaxStreams,
+		metrics:    m,
+		streams:    make(map[string]*streamHistory),
+		lru:        list.New(),
+	}
+}
+
+// Epoch returns the current history epoch
+func (b *Broker) Epoch() string {
+	return b.epoch
+}
+
+// Add stores the message in the stream history and returns its offset
+func (b *Broker) Add(stream string, data string) uint64 {
+	now := time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sh, ok := b.streams[stream]
+
+	if ok {
+		b.lru.MoveToFront(sh.elem)
+	} else {
+		if b.maxStreams > 0 && len(b.streams) >= b.maxStreams {
+			b.evictOldest()
+		}
+
+		sh = &streamHistory{stream: stream, entries: make([]historyEntry, b.limit)}
+		sh.elem = b.lru.PushFront(sh)
+		b.streams[stream] = sh
+	}
+
+	b.expire(sh, now)
+
+	if sh.size == len(sh.entries) {
+		b.messages--
+	}
+
+	sh.lastOffset++
+	sh.add(historyEntry{offset: sh.lastOffset, timestamp: now.Unix(), data: data})
+	b.messages++
+
+	b.updateMetrics()
+
+	return sh.lastOffset
+}
+
+// HistoryFrom returns the stream messages with offsets greater than the specified one.
+// Returns an error if some messages after the offset are no longer available
+// (or the offset belongs to another epoch).
+func (b *Broker) HistoryFrom(stream string, epoch string, offset uint64) ([]historyEntry, error) {
+	if epoch != b.epoch {
+		return nil, errors.New("Unknown epoch")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sh, ok := b.streams[stream]
+
+	if !ok {
+		return nil, errors.New("Stream history is not available")
+	}
+
+	b.expire(sh, time.Now())
+
+	if offset > sh.lastOffset {
+		return nil, errors.New("Unknown offset")
+	}
+
+	if offset == sh.lastOffset {
+		return nil, nil
+	}
+
+	// The next message must be in the buffer
+	if sh.size == 0 || sh.at(0).offset > offset+1 {
+		return nil, errors.New("Requested messages are no longer available")
+	}
+
+	return b.collect(sh, func(e *historyEntry) bool { return e.offset > offset }), nil
+}
+
+// HistorySince returns the stream messages broadcasted at or after the specified time (Unix seconds)
+func (b *Broker) HistorySince(stream string, since int64) []historyEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sh, ok := b.streams[stream]
+
+	if !ok {
+		return nil
+	}
+
+	b.expire(sh, time.Now())
+
+	return b.collect(sh, func(e *historyEntry) bool { return e.timestamp >= since })
+}
+
+func (b *Broker) collect(sh *streamHistory, filter func(*historyEntry) bool) []historyEntry {
+	entries := []historyEntry{}
+
+	for i := 0; i < sh.size; i++ {
+		if e := sh.at(i); filter(e) {
+			entries = append(entries, *e)
+		}
+	}
+
+	return entries
+}
+
+func (b *Broker) expire(sh *streamHistory, now time.Time) {
+	if b.ttl <= 0 {
+		return
+	}
+
+	removed := sh.expire(now.Add(-b.ttl).Unix())
+
+	if removed > 0 {
+		b.messages -= removed
+		b.updateMetrics()
+	}
+}
+
+func (b *Broker) evictOldest() {
+	elem := b.lru.Back()
+
+	if elem == nil {
+		return
+	}
+
+	sh := b.lru.Remove(elem).(*streamHistory)
+	delete(b.streams, sh.stream)
+	b.messages -= sh.size
+
+	b.metrics.Counter(metricsHistoryEvicted).Inc()
+}
+
+func (b *Broker) updateMetrics() {
+	b.metrics.Gauge(metricsHistoryStreams).Set(len(b.streams))
+	b.metrics.Gauge(metricsHistoryMessages).Set(b.messages)
+}
@@ -0,0 +1,115 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBroker(limit int, maxStreams int) *Broker {
+	config := NewConfig()
+	config.HistoryLimit = limit
+	config.HistoryMaxStreams = maxStreams
+
+	return NewBroker(&config, metrics.NewMetrics(nil, 10))
+}
+
+func entriesData(entries []historyEntry) []string {
+	data := []string{}
+
+	for _, e := range entries {
+		data = append(data, e.data)
+	}
+
+	return data
+}
+
+func TestBrokerHistoryFrom(t *testing.T) {
+	broker := newTestBroker(3, 10)
+
+	for i, data := range []string{"a", "b", "c", "d"} {
+		assert.Equal(t, uint64(i+1), broker.Add("test", data))
+	}
+
+	entries, err := broker.HistoryFrom("test", broker.Epoch(), 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"c", "d"}, entriesData(entries))
+	assert.Equal(t, uint64(3), entries[0].offset)
+
+	// The oldest available message has offset 2
+	entries, err = broker.HistoryFrom("test", broker.Epoch(), 1)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"b", "c", "d"}, entriesData(entries))
+
+	entries, err = broker.HistoryFrom("test", broker.Epoch(), 4)
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+
+	_, err = broker.HistoryFrom("test", broker.Epoch(), 0)
+	assert.NotNil(t, err)
+
+	_, err = broker.HistoryFrom("test", broker.Epoch(), 5)
+	assert.NotNil(t, err)
+
+	_, err = broker.HistoryFrom("test", "another", 3)
+	assert.NotNil(t, err)
+
+	_, err = broker.HistoryFrom("unknown", broker.Epoch(), 1)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, uint64(3), broker.metrics.Gauge(metricsHistoryMessages).Value())
+}
+
+func TestBrokerHistorySince(t *testing.T) {
+	broker := newTestBroker(3, 10)
+
+	broker.Add("test", "a")
+	broker.Add("test", "b")
+
+	// Make the first message older
+	broker.streams["test"].at(0).timestamp -= 60
+
+	entries := broker.HistorySince("test", time.Now().Add(-10*time.Second).Unix())
+	assert.Equal(t, []string{"b"}, entriesData(entries))
+
+	assert.Empty(t, broker.HistorySince("unknown", 0))
+}
+
+func TestBrokerTTL(t *testing.T) {
+	broker := newTestBroker(3, 10)
+	broker.ttl = 10 * time.Second
+
+	broker.Add("test", "a")
+	broker.Add("test", "b")
+
+	broker.streams["test"].at(0).timestamp -= 60
+
+	entries := broker.HistorySince("test", 0)
+	assert.Equal(t, []string{"b"}, entriesData(entries))
+
+	// Expired messages are no longer available
+	_, err := broker.HistoryFrom("test", broker.Epoch(), 0)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, uint64(1), broker.metrics.Gauge(metricsHistoryMessages).Value())
+}
+
+func TestBrokerStreamsLimit(t *testing.T) {
+	broker := newTestBroker(2, 2)
+
+	broker.Add("a", "1")
+	broker.Add("b", "1")
+	broker.Add("a", "2")
+	// "b" is the least recently used one
+	broker.Add("c", "1")
+
+	assert.Contains(t, broker.streams, "a")
+	assert.Contains(t, broker.streams, "c")
+	assert.NotContains(t, broker.streams, "b")
+
+	assert.Equal(t, uint64(2), broker.metrics.Gauge(metricsHistoryStreams).Value())
+	assert.Equal(t, uint64(3), broker.metrics.Gauge(metricsHistoryMessages).Value())
+	assert.Equal(t, uint64(1), broker.metrics.Counter(metricsHistoryEvicted).Value())
+}
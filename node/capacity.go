@@ -0,0 +1,100 @@
+package node
+
+import (
+	"sync/atomic"
+
+	"github.com/anycable/anycable-go/common"
+)
+
+const (
+	metricsSessionsRejectedCapacity = "sessions_rejected_capacity"
+	metricsSessionsReserved         = "sessions_reserved_num"
+)
+
+// capacityTracked returns true if the number of sessions must be tracked (max_sessions or sessions_soft_limit is set)
+func (n *Node) capacityTracked() bool {
+	return n.config.MaxSessions > 0 || n.config.SessionsSoftLimit > 0
+}
+
+// reserveSessionSlot takes a slot for the new session (before authentication).
+// Returns false if the max number of sessions has been reached.
+func (n *Node) reserveSessionSlot(s *Session) bool {
+	if !n.capacityTracked() {
+		return true
+	}
+
+	max := int64(n.config.MaxSessions)
+
+	for {
+		current := atomic.LoadInt64(&n.sessionsNum)
+
+		if max > 0 && current >= max {
+			return false
+		}
+
+		if atomic.CompareAndSwapInt64(&n.sessionsNum, current, current+1) {
+			break
+		}
+	}
+
+	s.mu.Lock()
+	s.slotReserved = true
+	s.mu.Unlock()
+
+	n.checkSessionsSoftLimit()
+
+	return true
+}
+
+// releaseSessionSlot frees the slot taken by the session (could be called multiple times)
+func (n *Node) releaseSessionSlot(s *Session) {
+	s.mu.Lock()
+	reserved := s.slotReserved
+	s.slotReserved = false
+	s.mu.Unlock()
+
+	if !reserved {
+		return
+	}
+
+	current := atomic.AddInt64(&n.sessionsNum, -1)
+
+	if soft := int64(n.config.SessionsSoftLimit); soft > 0 && current < soft {
+		atomic.StoreInt32(&n.softLimitWarned, 0)
+	}
+}
+
+// checkSessionsSoftLimit logs a warning when the number of sessions crosses the soft limit
+// (once per crossing)
+func (n *Node) checkSessionsSoftLimit() {
+	soft := int64(n.config.SessionsSoftLimit)
+
+	if soft <= 0 {
+		return
+	}
+
+	current := atomic.LoadInt64(&n.sessionsNum)
+
+	if current < soft || !atomic.CompareAndSwapInt32(&n.softLimitWarned, 0, 1) {
+		return
+	}
+
+	if n.config.MaxSessions > 0 {
+		n.log.Warnf("The number of sessions (%d) has reached the soft limit of %d (max sessions: %d)", current, soft, n.config.MaxSessions)
+	} else {
+		n.log.Warnf("The number of sessions (%d) has reached the soft limit of %d", current, soft)
+	}
+}
+
+// rejectServerFull closes the session which couldn't be accepted due to the max sessions limit
+func (n *Node) rejectServerFull(s *Session) *common.ConnectResult {
+	n.Metrics.Counter(metricsSessionsRejectedCapacity).Inc()
+	n.TrackHandshake(HandshakeServerFull, 1)
+
+	s.Log.Debugf("Session rejected: max sessions limit (%d) is reached", n.config.MaxSessions)
+
+	s.Send(newDisconnectMessage(serverFullReason, true))
+	s.Disconnect(serverFullReason, closeCode(serverFullReason))
+
+	return &common.ConnectResult{Status: common.ERROR}
+}
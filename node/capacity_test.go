@@ -0,0 +1,156 @@
+package node
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCapacityNode(max int, soft int) *Node {
+	controller := mocks.NewMockController()
+	config := NewConfig()
+	config.MaxSessions = max
+	config.SessionsSoftLimit = soft
+
+	node := NewNode(&controller, metrics.NewMetrics(nil, 10), &config)
+	dconfig := NewDisconnectQueueConfig()
+	node.SetDisconnector(NewDisconnectQueue(node, &dconfig))
+
+	return node
+}
+
+func newCapacitySession(node *Node, id string, url string) *Session {
+	session := NewMockSessionWithEnv(id, node, url, &map[string]string{"id": id})
+	session.closed = false
+
+	return session
+}
+
+func TestMaxSessions(t *testing.T) {
+	t.Run("Rejects sessions over the limit", func(t *testing.T) {
+		node := newCapacityNode(2, 0)
+
+		for i := 0; i < 2; i++ {
+			res, err := node.Authenticate(newCapacitySession(node, fmt.Sprintf("%d", i), "/cable"))
+			assert.Nil(t, err)
+			assert.Equal(t, common.SUCCESS, res.Status)
+		}
+
+		session := newCapacitySession(node, "3", "/cable")
+
+		res, err := node.Authenticate(session)
+		assert.Nil(t, err)
+		assert.Equal(t, common.ERROR, res.Status)
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"type\":\"disconnect\",\"reason\":\"server_full\",\"reconnect\":true}", string(msg))
+
+		assert.False(t, session.Connected)
+		assert.Equal(t, ws.CloseTryAgainLater, session.closeCode)
+		assert.Equal(t, 2, node.hub.Size())
+		assert.Equal(t, uint64(1), node.Metrics.Counter(metricsSessionsRejectedCapacity).Value())
+		assert.Equal(t, uint64(1), node.Metrics.CounterVec(metricsHandshakes).With(HandshakeServerFull).Value())
+	})
+
+	t.Run("Doesn't count sessions failed to authenticate", func(t *testing.T) {
+		node := newCapacityNode(1, 0)
+
+		res, err := node.Authenticate(newCapacitySession(node, "1", "/failure"))
+		assert.Nil(t, err)
+		assert.Equal(t, common.FAILURE, res.Status)
+
+		_, err = node.Authenticate(newCapacitySession(node, "2", "/error"))
+		assert.NotNil(t, err)
+
+		assert.Equal(t, int64(0), atomic.LoadInt64(&node.sessionsNum))
+
+		res, err = node.Authenticate(newCapacitySession(node, "3", "/cable"))
+		assert.Nil(t, err)
+		assert.Equal(t, common.SUCCESS, res.Status)
+	})
+
+	t.Run("Releases slots when sessions are closed", func(t *testing.T) {
+		node := newCapacityNode(1, 0)
+
+		session := newCapacitySession(node, "1", "/cable")
+
+		res, _ := node.Authenticate(session)
+		assert.Equal(t, common.SUCCESS, res.Status)
+
+		res, _ = node.Authenticate(newCapacitySession(node, "2", "/cable"))
+		assert.Equal(t, common.ERROR, res.Status)
+
+		session.Disconnect("test", ws.CloseNormalClosure)
+		// Slots are released only once
+		node.releaseSessionSlot(session)
+
+		assert.Equal(t, int64(0), atomic.LoadInt64(&node.sessionsNum))
+
+		res, _ = node.Authenticate(newCapacitySession(node, "3", "/cable"))
+		assert.Equal(t, common.SUCCESS, res.Status)
+	})
+
+	t.Run("Concurrent sessions", func(t *testing.T) {
+		node := newCapacityNode(10, 0)
+
+		var wg sync.WaitGroup
+		var accepted int64
+
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				res, _ := node.Authenticate(newCapacitySession(node, fmt.Sprintf("%d", i), "/cable"))
+
+				if res.Status == common.SUCCESS {
+					atomic.AddInt64(&accepted, 1)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+
+		assert.Equal(t, int64(10), accepted)
+		assert.Equal(t, int64(10), atomic.LoadInt64(&node.sessionsNum))
+		assert.Equal(t, uint64(40), node.Metrics.Counter(metricsSessionsRejectedCapacity).Value())
+	})
+
+	t.Run("Without limits", func(t *testing.T) {
+		node := newCapacityNode(0, 0)
+
+		session := newCapacitySession(node, "1", "/cable")
+
+		res, _ := node.Authenticate(session)
+		assert.Equal(t, common.SUCCESS, res.Status)
+		assert.False(t, session.slotReserved)
+		assert.Equal(t, int64(0), atomic.LoadInt64(&node.sessionsNum))
+	})
+}
+
+func TestSessionsSoftLimit(t *testing.T) {
+	node := newCapacityNode(0, 2)
+
+	first := newCapacitySession(node, "1", "/cable")
+	node.Authenticate(first) // nolint:errcheck
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&node.softLimitWarned))
+
+	second := newCapacitySession(node, "2", "/cable")
+	node.Authenticate(second) // nolint:errcheck
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&node.softLimitWarned))
+
+	second.Disconnect("test", ws.CloseNormalClosure)
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&node.softLimitWarned))
+}
@@ -0,0 +1,207 @@
+package node
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/apex/log"
+)
+
+const (
+	metricsCommandsRateLimited = "commands_rate_limited"
+)
+
+// CommandsRateLimit describes a token bucket: the number of commands per second and the burst size
+type CommandsRateLimit struct {
+	Rate  int
+	Burst int
+}
+
+// Enabled returns true if the limit is set
+func (l CommandsRateLimit) Enabled() bool {
+	return l.Rate > 0
+}
+
+// ParseCommandsRateLimitChannels parses per-channel perform limits (format: "Channel=rate[:burst],...")
+func ParseCommandsRateLimitChannels(raw string) (map[string]CommandsRateLimit, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	limits := make(map[string]CommandsRateLimit)
+
+	for _, pair := range strings.Split(raw, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("Invalid channel limit (must be in the Channel=rate[:burst] format): %s", pair)
+		}
+
+		values := strings.SplitN(parts[1], ":", 2)
+
+		rate, err := strconv.Atoi(values[0])
+
+		if err != nil || rate < 0 {
+			return nil, fmt.Errorf("Invalid channel rate for %s: %s", parts[0], values[0])
+		}
+
+		limit := CommandsRateLimit{Rate: rate}
+
+		if len(values) == 2 {
+			burst, err := strconv.Atoi(values[1])
+
+			if err != nil || burst < 0 {
+				return nil, fmt.Errorf("Invalid channel burst for %s: %s", parts[0], values[1])
+			}
+
+			limit.Burst = burst
+		}
+
+		limits[parts[0]] = limit
+	}
+
+	return limits, nil
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket (the bucket is full initially)
+func (b *tokenBucket) allow(limit CommandsRateLimit, now time.Time) bool {
+	burst := float64(limit.Burst)
+
+	if limit.Burst < limit.Rate {
+		burst = float64(limit.Rate)
+	}
+
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * float64(limit.Rate)
+
+		if b.tokens > burst {
+			b.tokens = burst
+		}
+	}
+
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+
+	return true
+}
+
+// commandsLimiter tracks the session commands rate
+type commandsLimiter struct {
+	mu        sync.Mutex
+	subscribe tokenBucket
+	perform   tokenBucket
+	// Per-channel perform buckets (for channels with custom limits)
+	channels map[string]*tokenBucket
+	// The number of consecutive rejected commands
+	violations int
+}
+
+// commandsRateLimited returns true if any commands rate limit is configured
+func (n *Node) commandsRateLimited() bool {
+	return n.subscribeLimit.Enabled() || n.performLimit.Enabled() || len(n.channelLimits) > 0
+}
+
+// allowCommand checks the session commands rate limit and rejects the command if it's exceeded.
+// Sessions exceeding the limit too many times in a row are closed.
+func (n *Node) allowCommand(s *Session, msg *common.Message) bool {
+	if !n.commandsRateLimited() {
+		return true
+	}
+
+	var limit CommandsRateLimit
+	var bucket func(l *commandsLimiter) *tokenBucket
+
+	switch msg.Command {
+	case "subscribe", "unsubscribe":
+		limit = n.subscribeLimit
+		bucket = func(l *commandsLimiter) *tokenBucket { return &l.subscribe }
+	case "message":
+		channel := channelFromIdentifier(msg.Identifier)
+
+		if channelLimit, ok := n.channelLimits[channel]; ok {
+			limit = channelLimit
+			bucket = func(l *commandsLimiter) *tokenBucket {
+				if _, ok := l.channels[channel]; !ok {
+					l.channels[channel] = &tokenBucket{}
+				}
+
+				return l.channels[channel]
+			}
+		} else {
+			limit = n.performLimit
+			bucket = func(l *commandsLimiter) *tokenBucket { return &l.perform }
+		}
+	default:
+		return true
+	}
+
+	if !limit.Enabled() {
+		return true
+	}
+
+	s.mu.Lock()
+	if s.commandsLimiter == nil {
+		s.commandsLimiter = &commandsLimiter{channels: make(map[string]*tokenBucket)}
+	}
+	limiter := s.commandsLimiter
+	s.mu.Unlock()
+
+	limiter.mu.Lock()
+	allowed := bucket(limiter).allow(limit, time.Now())
+
+	if allowed {
+		limiter.violations = 0
+	} else {
+		limiter.violations++
+	}
+
+	violations := limiter.violations
+	limiter.mu.Unlock()
+
+	if allowed {
+		return true
+	}
+
+	n.Metrics.Counter(metricsCommandsRateLimited).Inc()
+
+	s.Log.WithFields(log.Fields{"command": msg.Command, "identifier": msg.Identifier, "violations": violations}).Debugf("Command rate limit exceeded")
+
+	if max := n.config.CommandsRateLimitMaxViolations; max > 0 && violations >= max {
+		s.Log.Warnf("Session closed: commands rate limit exceeded %d times in a row", violations)
+
+		s.Send(newDisconnectMessage(commandsRateLimitedReason, false))
+		s.Disconnect(commandsRateLimitedReason, closeCode(commandsRateLimitedReason))
+
+		return false
+	}
+
+	if msg.Command == "subscribe" {
+		n.trackRejection(s, msg.Identifier, common.RejectionRateLimited)
+		transmit(s, common.NewRejectedSubscriptionResult(msg.Identifier, common.RejectionRateLimited).Transmissions)
+	} else {
+		s.Send(&common.CommandRejectedMessage{
+			Type:       common.CommandRejectedType,
+			Command:    msg.Command,
+			Identifier: msg.Identifier,
+			Reason:     common.RejectionRateLimited,
+		})
+	}
+
+	return false
+}
@@ -0,0 +1,136 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCommandsRateLimitChannels(t *testing.T) {
+	limits, err := ParseCommandsRateLimitChannels("CursorChannel=100:200, ChatChannel=5")
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]CommandsRateLimit{
+		"CursorChannel": {Rate: 100, Burst: 200},
+		"ChatChannel":   {Rate: 5},
+	}, limits)
+
+	limits, err = ParseCommandsRateLimitChannels("")
+
+	assert.Nil(t, err)
+	assert.Nil(t, limits)
+
+	for _, invalid := range []string{"CursorChannel", "=10", "CursorChannel=fast", "CursorChannel=10:-1"} {
+		_, err = ParseCommandsRateLimitChannels(invalid)
+		assert.NotNil(t, err, invalid)
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	limit := CommandsRateLimit{Rate: 2, Burst: 3}
+	bucket := &tokenBucket{}
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, bucket.allow(limit, now))
+	}
+
+	assert.False(t, bucket.allow(limit, now))
+	assert.False(t, bucket.allow(limit, now.Add(400*time.Millisecond)))
+	assert.True(t, bucket.allow(limit, now.Add(600*time.Millisecond)))
+
+	// The burst is never less than the rate
+	bucket = &tokenBucket{}
+
+	for i := 0; i < 2; i++ {
+		assert.True(t, bucket.allow(CommandsRateLimit{Rate: 2}, now))
+	}
+
+	assert.False(t, bucket.allow(CommandsRateLimit{Rate: 2}, now))
+}
+
+func TestCommandsRateLimit(t *testing.T) {
+	controller := mocks.NewMockController()
+	config := NewConfig()
+	config.CommandsRateLimitSubscribe = 1
+	config.CommandsRateLimitPerform = 2
+	config.CommandsRateLimitChannels = "CursorChannel=10"
+	config.CommandsRateLimitMaxViolations = 3
+
+	node := NewNode(&controller, metrics.NewMetrics(nil, 10), &config)
+	dconfig := NewDisconnectQueueConfig()
+	node.SetDisconnector(NewDisconnectQueue(node, &dconfig))
+
+	session := NewMockSession("14", node)
+	session.closed = false
+
+	chat := `{"channel":"ChatChannel"}`
+	cursor := `{"channel":"CursorChannel"}`
+
+	session.subscriptions[chat] = true
+	session.subscriptions[cursor] = true
+
+	perform := func(identifier string) {
+		err := node.HandleCommand(session, &common.Message{Command: "message", Identifier: identifier, Data: "move"})
+		assert.Nil(t, err)
+	}
+
+	read := func() string {
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		return string(msg)
+	}
+
+	t.Run("Rejects perform commands over the limit", func(t *testing.T) {
+		perform(chat)
+		perform(chat)
+
+		assert.Equal(t, "move", read())
+		assert.Equal(t, "move", read())
+
+		perform(chat)
+
+		assert.Equal(t, `{"type":"reject_command","command":"message","identifier":"{\"channel\":\"ChatChannel\"}","reason":"rate_limited"}`, read())
+		assert.Equal(t, uint64(1), node.Metrics.Counter(metricsCommandsRateLimited).Value())
+	})
+
+	t.Run("Uses per-channel limits", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			perform(cursor)
+			assert.Equal(t, "move", read())
+		}
+
+		assert.Equal(t, uint64(1), node.Metrics.Counter(metricsCommandsRateLimited).Value())
+	})
+
+	t.Run("Rejects subscriptions over the limit", func(t *testing.T) {
+		identifier := `{"channel":"PresenceChannel"}`
+
+		err := node.HandleCommand(session, &common.Message{Command: "subscribe", Identifier: `{"channel":"NotificationsChannel"}`})
+		assert.Nil(t, err)
+		read()
+
+		err = node.HandleCommand(session, &common.Message{Command: "subscribe", Identifier: identifier})
+		assert.Nil(t, err)
+
+		assert.Equal(t, `{"identifier":"{\"channel\":\"PresenceChannel\"}","type":"reject_subscription"}`, read())
+		assert.Equal(t, uint64(1), node.Metrics.CounterVec(metricsSubscriptionsRejected).With(common.RejectionRateLimited).Value())
+		assert.Equal(t, uint64(2), node.Metrics.Counter(metricsCommandsRateLimited).Value())
+	})
+
+	t.Run("Closes the session after consecutive violations", func(t *testing.T) {
+		perform(chat)
+		assert.Contains(t, read(), "reject_command")
+
+		perform(chat)
+
+		assert.Equal(t, `{"type":"disconnect","reason":"rate_limited","reconnect":false}`, read())
+		assert.Equal(t, ws.CloseTooManyRequests, session.closeCode)
+		assert.Equal(t, uint64(4), node.Metrics.Counter(metricsCommandsRateLimited).Value())
+	})
+}
@@ -1,5 +1,29 @@
 package node
 
+import "github.com/anycable/anycable-go/common"
+
+// Connections per identifier limit modes
+const (
+	ConnectionsLimitReject     = "reject"
+	ConnectionsLimitKickOldest = "kick_oldest"
+)
+
+// Binary broadcasts delivery modes for JSON clients
+const (
+	BinaryBroadcastsDrop   = "drop"
+	BinaryBroadcastsBase64 = "base64"
+)
+
+// Strategies to handle new sessions when RPC is unavailable at authentication time
+const (
+	// Close the connection asking the client to retry later
+	RPCUnavailableReject = "reject"
+	// Accept the session with the anonymous identifiers and authenticate it on the next command
+	RPCUnavailableAllowAnonymous = "allow_anonymous"
+	// Wait for the RPC connection to be established before authenticating the session
+	RPCUnavailableQueue = "queue"
+)
+
 // Config contains general application/node settings
 type Config struct {
 	// How often server should send Action Cable ping messages (seconds)
@@ -10,9 +34,90 @@ type Config struct {
 	HubGopoolSize int
 	// How should ping message timestamp be formatted? ('s' => seconds, 'ms' => milli seconds, 'ns' => nano seconds)
 	PingTimestampPrecision string
+	// The number of consecutive pings without any response from a client
+	// after which the session is considered stale and closed (0 – disabled)
+	PongTimeout int
+	// Whether to collect per-channel commands metrics
+	MetricsPerChannel bool
+	// The max number of sessions with the same connection identifiers (0 – no limit)
+	MaxConnectionsPerIdentifier int
+	// The max number of concurrent sessions (0 – no limit); new sessions are rejected before authentication when reached
+	MaxSessions int
+	// Log a warning when the number of sessions reaches this value (0 – disabled)
+	SessionsSoftLimit int
+	// What to do when the limit is exceeded: reject the new connection ("reject") or close the oldest one ("kick_oldest")
+	ConnectionsLimitMode string
+	// What to do with binary broadcasts for clients using JSON: skip them ("drop") or send base64-encoded payloads as strings ("base64")
+	BinaryBroadcasts string
+	// The max number of messages to keep in the history per stream (0 – history is disabled)
+	HistoryLimit int
+	// For how long to keep messages in the history (seconds, 0 – no limit)
+	HistoryTTL int
+	// The max number of streams with history (the least recently broadcasted streams are evicted first)
+	HistoryMaxStreams int
+	// The max number of unacknowledged messages per session for subscriptions with reliable delivery (0 – reliable delivery is disabled)
+	ReliableBufferSize int
+	// For how long to wait for a message acknowledgement before re-sending it (seconds)
+	ReliableAckTimeout int
+	// The max number of re-sending attempts before closing the session
+	ReliableMaxRetries int
+	// When to call Disconnect RPC for closed sessions by default: "always", "never" or "only_if_subscribed"
+	// (could be overridden per session by the Connect response)
+	DisconnectMode string
+	// The max session lifetime (seconds, 0 – no limit); expired sessions are asked to reconnect
+	SessionMaxLifetime int
+	// The max percentage of the lifetime to subtract randomly from every session deadline
+	// (to avoid reconnection storms)
+	SessionLifetimeJitter int
+	// Regular expression the broadcast stream names must match (empty – all streams are allowed)
+	AllowedBroadcastStreams string
+	// Namespace to prefix stream names with (empty – streams are not namespaced)
+	StreamsNamespace string
+	// The max size of the session store in bytes (0 – no limit)
+	SessionStoreMaxSize int
+	// Comma-separated list of the session store keys to pass to RPC
+	SessionStoreRPCKeys string
+	// The max number of recent broadcast IDs to keep for deduplication (used when multiple broadcast adapters are enabled)
+	BroadcastDedupSize int
+	// For how long to keep broadcast IDs for deduplication (seconds)
+	BroadcastDedupTTL int
+	// Where to write session lifecycle events: "log" or "redis" (empty – events are disabled)
+	EventsSink string
+	// Redis channel to publish events to (for the "redis" sink)
+	EventsChannel string
+	// The max number of events waiting to be written (new events are dropped when the buffer is full)
+	EventsBufferSize int
+	// Whether to redact connection identifiers values in events
+	EventsRedact bool
+	// What to do with new sessions when RPC is unavailable: "reject", "allow_anonymous" or "queue"
+	RPCUnavailableStrategy string
+	// Reconnection delay (seconds) suggested to clients rejected due to unavailable RPC
+	RPCUnavailableRetryAfter int
+	// For how long to hold a new session waiting for RPC to become available (seconds, "queue" strategy)
+	RPCUnavailableQueueTimeout int
+	// Identifiers of the sessions accepted without authentication ("allow_anonymous" strategy)
+	AnonymousIdentifiers string
+	// Warn clients the specified number of seconds before the session credentials expire (0 – disabled)
+	ExpiryWarning int
+	// Extra fields to add to welcome messages (JSON object)
+	WelcomeExtra string
+	// Extra fields to add to disconnect messages per reason (JSON object, e.g., {"server_full":{"retry_in":30}})
+	DisconnectExtra string
+	// The max number of subscribe/unsubscribe commands per second per session (0 – no limit)
+	CommandsRateLimitSubscribe int
+	// The max number of subscribe/unsubscribe commands per session in a burst (defaults to the rate)
+	CommandsRateLimitSubscribeBurst int
+	// The max number of perform commands per second per session (0 – no limit)
+	CommandsRateLimitPerform int
+	// The max number of perform commands per session in a burst (defaults to the rate)
+	CommandsRateLimitPerformBurst int
+	// Per-channel perform limits overriding the default one (format: "Channel=rate[:burst],...")
+	CommandsRateLimitChannels string
+	// The number of consecutive rate-limited commands after which the session is closed (0 – never close)
+	CommandsRateLimitMaxViolations int
 }
 
 // NewConfig builds a new config
 func NewConfig() Config {
-	return Config{PingInterval: 3, StatsRefreshInterval: 5, HubGopoolSize: 16, PingTimestampPrecision: "s"}
+	return Config{PingInterval: 3, StatsRefreshInterval: 5, HubGopoolSize: 16, PingTimestampPrecision: "s", ConnectionsLimitMode: ConnectionsLimitReject, BinaryBroadcasts: BinaryBroadcastsDrop, HistoryMaxStreams: 10000, ReliableBufferSize: 100, ReliableAckTimeout: 5, ReliableMaxRetries: 3, DisconnectMode: common.DisconnectModeAlways, SessionLifetimeJitter: 10, SessionStoreMaxSize: 4096, BroadcastDedupSize: 10000, BroadcastDedupTTL: 60, EventsChannel: "__anycable_events__", EventsBufferSize: 1024, RPCUnavailableStrategy: RPCUnavailableReject, RPCUnavailableRetryAfter: 5, RPCUnavailableQueueTimeout: 5, CommandsRateLimitMaxViolations: 10}
 }
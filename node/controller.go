@@ -1,6 +1,10 @@
 package node
 
-import "github.com/anycable/anycable-go/common"
+import (
+	"context"
+
+	"github.com/anycable/anycable-go/common"
+)
 
 // Controller is an interface describing business-logic handler (e.g. RPC)
 type Controller interface {
@@ -12,3 +16,18 @@ type Controller interface {
 	Perform(sid string, env *common.SessionEnv, id string, channel string, data string) (*common.CommandResult, error)
 	Disconnect(sid string, env *common.SessionEnv, id string, subscriptions []string) error
 }
+
+// CancelableController is implemented by controllers which could abort
+// in-flight commands when the context is cancelled (e.g., when the session is closed).
+// NOTE: cancelling a call must not affect other calls.
+type CancelableController interface {
+	SubscribeContext(ctx context.Context, sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error)
+	PerformContext(ctx context.Context, sid string, env *common.SessionEnv, id string, channel string, data string) (*common.CommandResult, error)
+}
+
+// BatchDisconnectController is implemented by controllers which could
+// perform multiple Disconnect calls at once.
+// It returns the list of errors corresponding to the requests.
+type BatchDisconnectController interface {
+	DisconnectBatch(requests []*common.DisconnectRequest) []error
+}
@@ -0,0 +1,97 @@
+package node
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Replacement for identifiers values when redaction is enabled
+const redactedValue = "[REDACTED]"
+
+// SessionInfo is a snapshot of the session state (used for debugging)
+type SessionInfo struct {
+	UID             string `json:"uid"`
+	NodeID          string `json:"node_id,omitempty"`
+	Identifiers     string `json:"identifiers"`
+	Connected       bool   `json:"connected"`
+	Encoder         string `json:"encoder"`
+	ProtocolVersion string `json:"protocol_version"`
+	CreatedAt       int64  `json:"created_at"`
+	// Channel identifiers to streams
+	Subscriptions   map[string][]string `json:"subscriptions"`
+	PendingMessages int                 `json:"pending_messages"`
+}
+
+// StreamInfo is a snapshot of the stream subscribers (used for debugging)
+type StreamInfo struct {
+	Name        string `json:"name"`
+	Subscribers int    `json:"subscribers"`
+	// UIDs of some of the subscribed sessions
+	Sample []string `json:"sample"`
+}
+
+// SessionInfo returns the state of the registered session with the specified uid (or nil if not found).
+// Hub locks are only held while copying the session streams.
+func (n *Node) SessionInfo(uid string) *SessionInfo {
+	s := n.hub.findSession(uid)
+
+	if s == nil {
+		return nil
+	}
+
+	info := &SessionInfo{
+		UID:             s.UID,
+		NodeID:          n.id,
+		Identifiers:     s.Identifiers,
+		Encoder:         s.encoder.ID(),
+		ProtocolVersion: s.ProtocolVersion,
+		CreatedAt:       s.CreatedAt.Unix(),
+		Subscriptions:   n.hub.sessionStreamsSnapshot(uid),
+		PendingMessages: s.pendingMessages(),
+	}
+
+	s.mu.Lock()
+	info.Connected = s.Connected
+	s.mu.Unlock()
+
+	return info
+}
+
+// StreamInfo returns the number of the stream subscribers and up to sampleSize of their UIDs
+// (or nil if the stream is unknown)
+func (n *Node) StreamInfo(name string, sampleSize int) *StreamInfo {
+	count, sample, ok := n.hub.streamSnapshot(name, sampleSize)
+
+	if !ok {
+		return nil
+	}
+
+	sort.Strings(sample)
+
+	return &StreamInfo{Name: name, Subscribers: count, Sample: sample}
+}
+
+// RedactIdentifiers replaces the identifiers values (keeping the keys if identifiers is a JSON object)
+func RedactIdentifiers(identifiers string) string {
+	if identifiers == "" {
+		return ""
+	}
+
+	var fields map[string]interface{}
+
+	if err := json.Unmarshal([]byte(identifiers), &fields); err != nil {
+		return redactedValue
+	}
+
+	for key := range fields {
+		fields[key] = redactedValue
+	}
+
+	redacted, err := json.Marshal(fields)
+
+	if err != nil {
+		return redactedValue
+	}
+
+	return string(redacted)
+}
@@ -0,0 +1,61 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugInfo(t *testing.T) {
+	node := NewMockNode()
+
+	session := NewMockSession("14", &node)
+	session.Identifiers = `{"user_id":"42"}`
+	session.Connected = true
+	session2 := NewMockSession("15", &node)
+
+	node.hub.addSession(session)
+	node.hub.subscribeSession("14", "chat_42", "chat")
+	node.hub.subscribeSession("14", "presence", "chat")
+
+	node.hub.addSession(session2)
+	node.hub.subscribeSession("15", "chat_42", "chat")
+
+	t.Run("Session info", func(t *testing.T) {
+		info := node.SessionInfo("14")
+
+		assert.NotNil(t, info)
+		assert.Equal(t, "14", info.UID)
+		assert.Equal(t, `{"user_id":"42"}`, info.Identifiers)
+		assert.True(t, info.Connected)
+		assert.Equal(t, "json", info.Encoder)
+		assert.ElementsMatch(t, []string{"chat_42", "presence"}, info.Subscriptions["chat"])
+		assert.Equal(t, 0, info.PendingMessages)
+	})
+
+	t.Run("Unknown session info", func(t *testing.T) {
+		assert.Nil(t, node.SessionInfo("16"))
+	})
+
+	t.Run("Stream info", func(t *testing.T) {
+		info := node.StreamInfo("chat_42", 10)
+
+		assert.NotNil(t, info)
+		assert.Equal(t, 2, info.Subscribers)
+		assert.Equal(t, []string{"14", "15"}, info.Sample)
+
+		info = node.StreamInfo("chat_42", 1)
+		assert.Equal(t, 2, info.Subscribers)
+		assert.Len(t, info.Sample, 1)
+	})
+
+	t.Run("Unknown stream info", func(t *testing.T) {
+		assert.Nil(t, node.StreamInfo("unknown", 10))
+	})
+}
+
+func TestRedactIdentifiers(t *testing.T) {
+	assert.Equal(t, "", RedactIdentifiers(""))
+	assert.Equal(t, `{"current_user":"[REDACTED]","token":"[REDACTED]"}`, RedactIdentifiers(`{"current_user":"gid://app/User/42","token":{"id":1}}`))
+	assert.Equal(t, "[REDACTED]", RedactIdentifiers("user-42"))
+}
@@ -8,17 +8,40 @@ import (
 	"github.com/apex/log"
 )
 
+const (
+	metricsDisconnectEnqueued  = "disconnect_queue_enqueued_total"
+	metricsDisconnectProcessed = "disconnect_queue_processed_total"
+	metricsDisconnectDropped   = "disconnect_queue_dropped_total"
+	metricsDisconnectFailed    = "disconnect_queue_failed_total"
+	metricsDisconnectLatency   = "disconnect_queue_latency_seconds"
+)
+
+// disconnectLatencyBuckets cover rate-limited calls waiting in the queue for minutes
+var disconnectLatencyBuckets = []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300}
+
 // DisconnectQueueConfig contains DisconnectQueue configuration
 type DisconnectQueueConfig struct {
 	// Limit the number of Disconnect RPC calls per second
 	Rate int
+	// The number of concurrent Disconnect RPC calls
+	Workers int
 	// How much time wait to call all enqueued calls at exit (in seconds)
 	ShutdownTimeout int
+	// Path to the file to persist pending calls between restarts (disabled if empty)
+	StoragePath string
+	// Whether to persist credentials headers (cookie, authorization); they are dropped by default
+	StorageCredentials bool
+	// The max number of pending calls (new calls are dropped when the queue is full)
+	Capacity int
+	// The max number of sessions to disconnect in a single call
+	BatchSize int
+	// How long to wait for more sessions to fill a batch (in milliseconds)
+	BatchWindow int
 }
 
 // NewDisconnectQueueConfig builds a new config
 func NewDisconnectQueueConfig() DisconnectQueueConfig {
-	return DisconnectQueueConfig{ShutdownTimeout: 5, Rate: 100}
+	return DisconnectQueueConfig{ShutdownTimeout: 5, Rate: 100, Workers: 1, BatchSize: 1, Capacity: 4096}
 }
 
 // DisconnectQueue is a rate-limited executor
@@ -26,10 +49,18 @@ type DisconnectQueue struct {
 	node *Node
 	// Throttling rate
 	rate time.Duration
+	// Throttling ticker (initialized on Run)
+	throttle *time.Ticker
+	// The number of workers performing calls
+	workers int
 	// Graceful shutdown timeout
 	timeout time.Duration
+	// The max number of sessions per call
+	batchSize int
+	// Time to wait for a batch to fill
+	batchWindow time.Duration
 	// Call RPC Disconnect for connections
-	disconnect chan *Session
+	disconnect chan *disconnectTask
 	// Logger with context
 	log *log.Entry
 	// Control channel to shutdown the executer
@@ -38,44 +69,220 @@ type DisconnectQueue struct {
 	isStopped bool
 	// Mutex to work with stopped status concurrently
 	mu sync.Mutex
+	// Path to the persistent storage
+	storagePath string
+	// Whether to keep credentials headers in the persistent storage
+	storageCredentials bool
+	// Persistent storage for pending calls
+	storage *DisconnectStorage
+}
+
+// disconnectTask is a queue item
+type disconnectTask struct {
+	session    *Session
+	enqueuedAt time.Time
+	// Persistent storage entry ID (0 if not persisted)
+	storageID uint64
 }
 
 // NewDisconnectQueue builds new queue with a specified rate (max calls per second)
 func NewDisconnectQueue(node *Node, config *DisconnectQueueConfig) *DisconnectQueue {
-	rateDuration := time.Millisecond * time.Duration(1000/config.Rate)
+	rateDuration := time.Second / time.Duration(config.Rate)
 	timeout := time.Duration(config.ShutdownTimeout) * time.Second
 
+	workers := config.Workers
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	batchSize := config.BatchSize
+
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	capacity := config.Capacity
+
+	if capacity < 1 {
+		capacity = 1
+	}
+
 	ctx := log.WithField("context", "disconnector")
 
-	ctx.Debugf("Calls rate: %v", rateDuration)
+	ctx.Debugf("Calls rate: %v, workers: %d, batch size: %d", rateDuration, workers, batchSize)
+
+	node.Metrics.RegisterCounter(metricsDisconnectEnqueued, "The total number of enqueued Disconnect calls")
+	node.Metrics.RegisterCounter(metricsDisconnectProcessed, "The total number of processed Disconnect calls")
+	node.Metrics.RegisterCounter(metricsDisconnectDropped, "The total number of Disconnect calls dropped due to the queue overflow or shutdown")
+	node.Metrics.RegisterCounter(metricsDisconnectFailed, "The total number of failed Disconnect calls")
+	node.Metrics.RegisterHistogram(metricsDisconnectLatency, "The time between enqueuing and completing of Disconnect calls", disconnectLatencyBuckets)
 
 	return &DisconnectQueue{
-		node:       node,
-		disconnect: make(chan *Session, 4096),
-		rate:       rateDuration,
-		timeout:    timeout,
-		log:        ctx,
-		shutdown:   make(chan struct{}, 1),
+		node:               node,
+		disconnect:         make(chan *disconnectTask, capacity),
+		rate:               rateDuration,
+		workers:            workers,
+		timeout:            timeout,
+		batchSize:          batchSize,
+		batchWindow:        time.Duration(config.BatchWindow) * time.Millisecond,
+		log:                ctx,
+		shutdown:           make(chan struct{}),
+		storagePath:        config.StoragePath,
+		storageCredentials: config.StorageCredentials,
+	}
+}
+
+// Restore opens the persistent storage (if configured) and enqueues
+// the calls left from the previous run
+func (d *DisconnectQueue) Restore() error {
+	if d.storagePath == "" {
+		return nil
+	}
+
+	storage, err := OpenDisconnectStorage(d.storagePath)
+
+	if err != nil {
+		return fmt.Errorf("Failed to open disconnect storage at %s: %v", d.storagePath, err)
 	}
+
+	d.storage = storage
+
+	ids, entries := storage.Pending()
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	tasks := make([]*disconnectTask, len(entries))
+	now := time.Now()
+
+	for i, entry := range entries {
+		tasks[i] = &disconnectTask{session: newRestoredSession(d.node, entry), enqueuedAt: now, storageID: ids[i]}
+	}
+
+	d.node.Metrics.Counter(metricsDisconnectEnqueued).Add(uint64(len(tasks)))
+
+	// The backlog could be larger than the queue capacity,
+	// so we do not block until the queue is running.
+	// Tasks not enqueued before shutdown are kept in the storage till the next run
+	go func() {
+		for _, task := range tasks {
+			select {
+			case d.disconnect <- task:
+			case <-d.shutdown:
+				return
+			}
+		}
+	}()
+
+	return nil
 }
 
-// Run starts queue
+// Run starts queue workers and waits for them to finish
 func (d *DisconnectQueue) Run() error {
+	d.mu.Lock()
 	throttle := time.NewTicker(d.rate)
+	d.throttle = throttle
+	d.mu.Unlock()
+
 	defer throttle.Stop()
 
+	var wg sync.WaitGroup
+
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			d.runWorker(throttle.C)
+		}()
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// SetRate updates the max number of calls per second
+func (d *DisconnectQueue) SetRate(rate int) {
+	if rate < 1 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.rate = time.Second / time.Duration(rate)
+
+	if d.throttle != nil {
+		d.throttle.Reset(d.rate)
+	}
+
+	d.log.Debugf("Calls rate: %v", d.rate)
+}
+
+func (d *DisconnectQueue) runWorker(throttle <-chan time.Time) {
 	for {
 		select {
-		case session := <-d.disconnect:
-			<-throttle.C
-			d.node.DisconnectNow(session) //nolint:errcheck
+		case task := <-d.disconnect:
+			batch := d.collectBatch(task)
+
+			select {
+			case <-throttle:
+			case <-d.shutdown:
+				// No need to wait during shutdown, we're in a hurry
+			}
+
+			d.disconnectBatch(batch)
+		case <-d.shutdown:
+			return
+		}
+	}
+}
+
+// collectBatch fetches more tasks from the queue until the batch is full
+// or the batch window is over
+func (d *DisconnectQueue) collectBatch(task *disconnectTask) []*disconnectTask {
+	batch := []*disconnectTask{task}
+
+	if d.batchSize == 1 {
+		return batch
+	}
+
+	var window <-chan time.Time
+
+	if d.batchWindow > 0 {
+		timer := time.NewTimer(d.batchWindow)
+		defer timer.Stop()
+		window = timer.C
+	}
+
+	for len(batch) < d.batchSize {
+		if window == nil {
+			select {
+			case t := <-d.disconnect:
+				batch = append(batch, t)
+			default:
+				return batch
+			}
+			continue
+		}
+
+		select {
+		case t := <-d.disconnect:
+			batch = append(batch, t)
+		case <-window:
+			return batch
 		case <-d.shutdown:
-			return nil
+			return batch
 		}
 	}
+
+	return batch
 }
 
-// Shutdown stops throttling and makes requests one by one
+// Shutdown stops throttling and makes requests concurrently (using the specified number of workers)
 func (d *DisconnectQueue) Shutdown() error {
 	d.mu.Lock()
 	if d.isStopped {
@@ -84,9 +291,11 @@ func (d *DisconnectQueue) Shutdown() error {
 	}
 
 	d.isStopped = true
-	d.shutdown <- struct{}{}
+	close(d.shutdown)
 	d.mu.Unlock()
 
+	defer d.closeStorage()
+
 	left := len(d.disconnect)
 
 	if left == 0 {
@@ -95,23 +304,49 @@ func (d *DisconnectQueue) Shutdown() error {
 
 	d.log.Infof("Invoking remaining disconnects for %s: %d", d.timeout, left)
 
-	for {
-		select {
-		case session := <-d.disconnect:
-			err := d.node.DisconnectNow(session)
+	var wg sync.WaitGroup
 
-			left--
+	done := make(chan struct{})
+	cancel := make(chan struct{})
 
-			if err != nil {
-				return err
-			}
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
 
-			if left == 0 {
-				return nil
+			for {
+				select {
+				case <-cancel:
+					return
+				default:
+				}
+
+				select {
+				case task := <-d.disconnect:
+					d.disconnectBatch(d.drainBatch(task))
+				default:
+					return
+				}
 			}
-		case <-time.After(d.timeout):
-			return fmt.Errorf("Had no time to invoke Disconnect calls: %d", len(d.disconnect))
-		}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(d.timeout):
+		close(cancel)
+
+		dropped := len(d.disconnect)
+		d.node.Metrics.Counter(metricsDisconnectDropped).Add(uint64(dropped))
+
+		return fmt.Errorf("Had no time to invoke Disconnect calls: %d", dropped)
 	}
 }
 
@@ -125,7 +360,24 @@ func (d *DisconnectQueue) Enqueue(s *Session) error {
 		return nil
 	}
 
-	d.disconnect <- s
+	task := &disconnectTask{session: s, enqueuedAt: time.Now()}
+
+	if d.storage != nil {
+		task.storageID = d.persist(s)
+	}
+
+	select {
+	case d.disconnect <- task:
+		d.node.Metrics.Counter(metricsDisconnectEnqueued).Inc()
+	default:
+		d.node.Metrics.Counter(metricsDisconnectDropped).Inc()
+
+		if d.storage != nil {
+			d.release(task)
+		}
+
+		return fmt.Errorf("Disconnect queue is full, dropping call for %s", s.UID)
+	}
 
 	return nil
 }
@@ -134,3 +386,87 @@ func (d *DisconnectQueue) Enqueue(s *Session) error {
 func (d *DisconnectQueue) Size() int {
 	return len(d.disconnect)
 }
+
+// drainBatch fetches already enqueued tasks without waiting
+func (d *DisconnectQueue) drainBatch(task *disconnectTask) []*disconnectTask {
+	batch := []*disconnectTask{task}
+
+	for len(batch) < d.batchSize {
+		select {
+		case t := <-d.disconnect:
+			batch = append(batch, t)
+		default:
+			return batch
+		}
+	}
+
+	return batch
+}
+
+func (d *DisconnectQueue) disconnectBatch(tasks []*disconnectTask) {
+	sessions := make([]*Session, len(tasks))
+
+	for i, task := range tasks {
+		sessions[i] = task.session
+	}
+
+	errs := d.node.DisconnectNowBatch(sessions)
+
+	failed := 0
+
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+
+	latency := d.node.Metrics.Histogram(metricsDisconnectLatency)
+
+	for _, task := range tasks {
+		latency.Observe(time.Since(task.enqueuedAt).Seconds())
+	}
+
+	d.node.Metrics.Counter(metricsDisconnectProcessed).Add(uint64(len(tasks)))
+	d.node.Metrics.Counter(metricsDisconnectFailed).Add(uint64(failed))
+
+	if d.storage != nil {
+		for _, task := range tasks {
+			d.release(task)
+		}
+	}
+}
+
+func (d *DisconnectQueue) persist(s *Session) uint64 {
+	id, err := d.storage.Add(newDisconnectEntry(s, d.storageCredentials))
+
+	if err != nil {
+		d.log.WithField("sid", s.UID).Warnf("Failed to persist disconnect call: %v", err)
+		return 0
+	}
+
+	return id
+}
+
+func (d *DisconnectQueue) release(task *disconnectTask) {
+	if task.storageID == 0 {
+		return
+	}
+
+	if err := d.storage.Done(task.storageID); err != nil {
+		d.log.WithField("sid", task.session.UID).Warnf("Failed to update disconnect storage: %v", err)
+	}
+}
+
+func (d *DisconnectQueue) closeStorage() {
+	if d.storage == nil {
+		return
+	}
+
+	if left := d.storage.Size(); left > 0 {
+		d.log.Infof("Pending disconnects persisted to %s: %d", d.storagePath, left)
+	}
+
+	if err := d.storage.Close(); err != nil {
+		d.log.Warnf("Failed to close disconnect storage: %v", err)
+	}
+}
@@ -1,9 +1,15 @@
 package node
 
 import (
+	"fmt"
 	"runtime"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mocks"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -26,6 +32,102 @@ func TestDisconnectQueue_Run(t *testing.T) {
 			runtime.Gosched()
 		}
 	})
+
+	t.Run("Disconnects sessions using multiple workers", func(t *testing.T) {
+		node := NewMockNode()
+		config := NewDisconnectQueueConfig()
+		config.Rate = 1000
+		config.Workers = 4
+		q := NewDisconnectQueue(&node, &config)
+
+		for i := 1; i <= 20; i++ {
+			assert.Nil(t, q.Enqueue(NewMockSession(fmt.Sprintf("%d", i), q.node)))
+		}
+
+		done := make(chan struct{})
+
+		go func() {
+			q.Run() // nolint:errcheck
+			close(done)
+		}()
+
+		for node.Metrics.Counter(metricsDisconnectProcessed).Value() < 20 {
+			runtime.Gosched()
+		}
+
+		assert.Equal(t, 0, q.Size())
+
+		assert.Nil(t, q.Shutdown())
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("Run hasn't returned after shutdown")
+		}
+	})
+}
+
+type batchController struct {
+	mocks.MockController
+
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (c *batchController) DisconnectBatch(requests []*common.DisconnectRequest) []error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	uids := make([]string, len(requests))
+
+	for i, req := range requests {
+		uids[i] = req.UID
+	}
+
+	c.batches = append(c.batches, uids)
+
+	return make([]error, len(requests))
+}
+
+// slowController takes a second to perform Disconnect calls
+type slowController struct {
+	mocks.MockController
+}
+
+func (c *slowController) Disconnect(sid string, env *common.SessionEnv, id string, subscriptions []string) error {
+	time.Sleep(time.Second)
+	return nil
+}
+
+func TestDisconnectQueue_Batch(t *testing.T) {
+	controller := &batchController{MockController: mocks.NewMockController()}
+	nconfig := NewConfig()
+	node := NewNode(controller, metrics.NewMetrics(nil, 10), &nconfig)
+
+	config := NewDisconnectQueueConfig()
+	config.Rate = 1000
+	config.BatchSize = 3
+	config.BatchWindow = 100
+	q := NewDisconnectQueue(node, &config)
+
+	for i := 1; i <= 5; i++ {
+		assert.Nil(t, q.Enqueue(NewMockSession(fmt.Sprintf("%d", i), node)))
+	}
+
+	go q.Run()         // nolint:errcheck
+	defer q.Shutdown() // nolint:errcheck
+
+	for node.Metrics.Counter(metricsDisconnectProcessed).Value() < 5 {
+		runtime.Gosched()
+	}
+
+	// Latency is tracked for every call in a batch
+	assert.Equal(t, uint64(5), node.Metrics.Histogram(metricsDisconnectLatency).Count())
+
+	controller.mu.Lock()
+	defer controller.mu.Unlock()
+
+	assert.Equal(t, [][]string{{"1", "2", "3"}, {"4", "5"}}, controller.batches)
 }
 
 func TestDisconnectQueue_Shutdown(t *testing.T) {
@@ -41,7 +143,17 @@ func TestDisconnectQueue_Shutdown(t *testing.T) {
 	})
 
 	t.Run("Stops after timeout", func(t *testing.T) {
-		t.Skip("TODO: We need a trully node mock to control disconnect operation")
+		nconfig := NewConfig()
+		node := NewNode(&slowController{MockController: mocks.NewMockController()}, metrics.NewMetrics(nil, 10), &nconfig)
+		config := NewDisconnectQueueConfig()
+		q := NewDisconnectQueue(node, &config)
+		q.timeout = 100 * time.Millisecond
+
+		assert.Nil(t, q.Enqueue(NewMockSession("1", q.node)))
+		assert.Nil(t, q.Enqueue(NewMockSession("2", q.node)))
+
+		assert.NotNil(t, q.Shutdown())
+		assert.Equal(t, uint64(1), q.node.Metrics.Counter(metricsDisconnectDropped).Value())
 	})
 
 	t.Run("Allows multiple entering", func(t *testing.T) {
@@ -61,6 +173,20 @@ func TestDisconnectQueue_Enqueue(t *testing.T) {
 		assert.Equal(t, 1, q.Size())
 	})
 
+	t.Run("When the queue is full", func(t *testing.T) {
+		node := NewMockNode()
+		config := NewDisconnectQueueConfig()
+		config.Capacity = 1
+		q := NewDisconnectQueue(&node, &config)
+
+		assert.Nil(t, q.Enqueue(NewMockSession("1", q.node)))
+		assert.NotNil(t, q.Enqueue(NewMockSession("2", q.node)))
+
+		assert.Equal(t, 1, q.Size())
+		assert.Equal(t, uint64(1), node.Metrics.Counter(metricsDisconnectEnqueued).Value())
+		assert.Equal(t, uint64(1), node.Metrics.Counter(metricsDisconnectDropped).Value())
+	})
+
 	t.Run("After shutdown", func(t *testing.T) {
 		q := newQueue()
 		q.Shutdown() // nolint:errcheck
@@ -0,0 +1,55 @@
+package node
+
+import "github.com/anycable/anycable-go/ws"
+
+// Disconnect reasons.
+// The same values are used in disconnect messages and as WebSocket close frames reasons
+const (
+	// serverRestartReason is the disconnect reason on shutdown
+	serverRestartReason    = "server_restart"
+	remoteDisconnectReason = "remote"
+	noPongReason           = "no_pong"
+	unauthorizedReason     = "unauthorized"
+	serverErrorReason      = "server_error"
+	messageTooBigReason    = "message_too_big"
+	// tooManyConnectionsReason is the disconnect reason when the connections per identifier limit is exceeded
+	tooManyConnectionsReason = "too_many_connections"
+	// deliveryFailedReason is the disconnect reason when reliable messages haven't been acknowledged
+	deliveryFailedReason = "delivery_failed"
+	// sessionExpiredReason is the disconnect reason when the session max lifetime is exceeded
+	sessionExpiredReason = "session_expired"
+	// rpcUnavailableReason is the disconnect reason when RPC is unavailable at authentication time
+	rpcUnavailableReason = "rpc_unavailable"
+	// tokenExpiredReason is the disconnect reason when the session credentials couldn't be refreshed
+	tokenExpiredReason = "token_expired"
+	// serverFullReason is the disconnect reason when the max sessions limit is reached
+	serverFullReason = "server_full"
+	// commandsRateLimitedReason is the disconnect reason when the session keeps exceeding the commands rate limit
+	commandsRateLimitedReason = "rate_limited"
+)
+
+// closeCodes maps disconnect reasons to WebSocket close codes
+var closeCodes = map[string]int{
+	serverRestartReason:       ws.CloseGoingAway,
+	remoteDisconnectReason:    ws.CloseNormalClosure,
+	noPongReason:              ws.ClosePongTimeout,
+	unauthorizedReason:        ws.CloseUnauthorized,
+	serverErrorReason:         ws.CloseInternalServerErr,
+	messageTooBigReason:       ws.CloseMessageTooBig,
+	tooManyConnectionsReason:  ws.CloseTooManyRequests,
+	deliveryFailedReason:      ws.CloseDeliveryFailed,
+	sessionExpiredReason:      ws.CloseGoingAway,
+	rpcUnavailableReason:      ws.CloseTryAgainLater,
+	tokenExpiredReason:        ws.CloseTokenExpired,
+	serverFullReason:          ws.CloseTryAgainLater,
+	commandsRateLimitedReason: ws.CloseTooManyRequests,
+}
+
+// closeCode returns a WebSocket close code for the disconnect reason
+func closeCode(reason string) int {
+	if code, ok := closeCodes[reason]; ok {
+		return code
+	}
+
+	return ws.CloseNormalClosure
+}
@@ -0,0 +1,124 @@
+package node
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/anycable/anycable-go/ws"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisconnectCloseCodes(t *testing.T) {
+	node := newRefreshNode()
+	node.config.MaxConnectionsPerIdentifier = 1
+	node.config.PongTimeout = 1
+
+	config := ws.NewConfig()
+
+	srv := httptest.NewServer(ws.WebsocketHandler([]string{"id"}, &config, func(wsc *websocket.Conn, info *ws.RequestInfo, callback func()) error {
+		u, _ := url.Parse(info.Url)
+		session := NewSession(node, ws.NewConnection(wsc), u.Path, info.Headers, info.UID)
+		session.SetProtocolVersion(info.Protocol)
+
+		if _, err := node.Authenticate(session); err != nil {
+			return err
+		}
+
+		// Send pings right away (without waiting for the ping interval) to exceed the pong timeout
+		if u.Path == "/no_pong" {
+			for i := 0; i <= node.config.PongTimeout; i++ {
+				session.sendPing()
+			}
+		}
+
+		return session.Serve(callback)
+	}))
+	defer srv.Close()
+
+	dial := func(path string, id string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+srv.URL[len("http"):]+path, http.Header{"Id": {id}})
+		assert.Nil(t, err)
+		return conn
+	}
+
+	readCloseError := func(conn *websocket.Conn) *websocket.CloseError {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				closeErr, _ := err.(*websocket.CloseError)
+				return closeErr
+			}
+		}
+	}
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		conn := dial("/failure", "user")
+		defer conn.Close()
+
+		err := readCloseError(conn)
+		assert.NotNil(t, err)
+		assert.Equal(t, 4001, err.Code)
+		assert.Equal(t, "unauthorized", err.Text)
+	})
+
+	t.Run("Server error", func(t *testing.T) {
+		conn := dial("/error", "user")
+		defer conn.Close()
+
+		err := readCloseError(conn)
+		assert.NotNil(t, err)
+		assert.Equal(t, websocket.CloseInternalServerErr, err.Code)
+		assert.Equal(t, "server_error", err.Text)
+	})
+
+	t.Run("Too many connections", func(t *testing.T) {
+		first := dial("/cable", "limited")
+		defer first.Close()
+
+		_, msg, err := first.ReadMessage()
+		assert.Nil(t, err)
+		assert.Equal(t, "welcome", string(msg))
+
+		second := dial("/cable", "limited")
+		defer second.Close()
+
+		closeErr := readCloseError(second)
+		assert.NotNil(t, closeErr)
+		assert.Equal(t, 4029, closeErr.Code)
+		assert.Equal(t, "too_many_connections", closeErr.Text)
+	})
+
+	t.Run("No pong", func(t *testing.T) {
+		conn := dial("/no_pong", "stale")
+		defer conn.Close()
+
+		// Do not respond to protocol-level pings
+		conn.SetPingHandler(func(string) error { return nil })
+
+		err := readCloseError(conn)
+		assert.NotNil(t, err)
+		assert.Equal(t, 4009, err.Code)
+		assert.Equal(t, "no_pong", err.Text)
+	})
+
+	t.Run("Token expired", func(t *testing.T) {
+		dialer := websocket.Dialer{Subprotocols: []string{ws.ActionCableV11JSON}}
+		conn, _, err := dialer.Dial("ws"+srv.URL[len("http"):]+"/cable", http.Header{"Id": {"refreshed"}})
+		assert.Nil(t, err)
+		defer conn.Close()
+
+		_, msg, err := conn.ReadMessage()
+		assert.Nil(t, err)
+		assert.Equal(t, "welcome", string(msg))
+
+		err = conn.WriteMessage(websocket.TextMessage, []byte("{\"command\":\"refresh\",\"token\":\"expired\"}"))
+		assert.Nil(t, err)
+
+		closeErr := readCloseError(conn)
+		assert.NotNil(t, closeErr)
+		assert.Equal(t, 4002, closeErr.Code)
+		assert.Equal(t, "token_expired", closeErr.Text)
+	})
+}
@@ -0,0 +1,408 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/apex/log"
+)
+
+const (
+	// The number of processed entries after which the log is compacted
+	storageCompactThreshold = 1024
+
+	storageOpAdd  = "add"
+	storageOpDone = "done"
+)
+
+// Headers containing client credentials (not persisted unless explicitly enabled)
+var storageCredentialsHeaders = []string{"cookie", "authorization"}
+
+// disconnectEntry contains the session information required to perform a Disconnect call
+type disconnectEntry struct {
+	UID             string                       `json:"sid"`
+	Identifiers     string                       `json:"ids"`
+	URL             string                       `json:"url"`
+	Headers         map[string]string            `json:"headers,omitempty"`
+	ConnectionState map[string]string            `json:"cstate,omitempty"`
+	ChannelStates   map[string]map[string]string `json:"istate,omitempty"`
+	Subscriptions   []string                     `json:"subscriptions,omitempty"`
+	// Only persistent session store entries are kept
+	Store map[string][]byte `json:"store,omitempty"`
+	// Custom controller name (see Node.RegisterController)
+	Controller string `json:"controller,omitempty"`
+}
+
+type storageRecord struct {
+	Op    string           `json:"op"`
+	ID    uint64           `json:"id"`
+	Entry *disconnectEntry `json:"entry,omitempty"`
+}
+
+// DisconnectStorage is a file-backed write-ahead log of pending Disconnect calls.
+//
+// Every record is written as a single line prefixed with its CRC32 checksum,
+// so corrupted records (e.g., a partially written tail after a crash) are detected and skipped.
+type DisconnectStorage struct {
+	path      string
+	file      *os.File
+	writer    *bufio.Writer
+	pending   map[uint64]*disconnectEntry
+	nextID    uint64
+	processed int
+	mu        sync.Mutex
+	log       *log.Entry
+}
+
+// OpenDisconnectStorage opens (or creates) the log at the specified path and
+// loads pending entries
+func OpenDisconnectStorage(path string) (*DisconnectStorage, error) {
+	st := &DisconnectStorage{
+		path:    path,
+		pending: make(map[uint64]*disconnectEntry),
+		nextID:  1,
+		log:     log.WithField("context", "disconnector"),
+	}
+
+	if err := st.load(); err != nil {
+		return nil, err
+	}
+
+	// Compact right away to get rid of processed and corrupted records
+	if err := st.compact(); err != nil {
+		return nil, err
+	}
+
+	return st, nil
+}
+
+// Pending returns the list of pending entries ordered by the time they were added
+func (st *DisconnectStorage) Pending() (ids []uint64, entries []*disconnectEntry) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	for id := range st.pending {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		entries = append(entries, st.pending[id])
+	}
+
+	return
+}
+
+// Add appends a new entry to the log and returns its ID
+func (st *DisconnectStorage) Add(entry *disconnectEntry) (uint64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.file == nil {
+		return 0, fmt.Errorf("Storage is closed")
+	}
+
+	id := st.nextID
+	st.nextID++
+
+	if err := st.write(&storageRecord{Op: storageOpAdd, ID: id, Entry: entry}); err != nil {
+		return 0, err
+	}
+
+	st.pending[id] = entry
+
+	return id, nil
+}
+
+// Done marks the entry as processed
+func (st *DisconnectStorage) Done(id uint64) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.file == nil {
+		return nil
+	}
+
+	if _, ok := st.pending[id]; !ok {
+		return nil
+	}
+
+	delete(st.pending, id)
+	st.processed++
+
+	if len(st.pending) == 0 || st.processed >= storageCompactThreshold {
+		return st.compact()
+	}
+
+	return st.write(&storageRecord{Op: storageOpDone, ID: id})
+}
+
+// Size returns the number of pending entries
+func (st *DisconnectStorage) Size() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return len(st.pending)
+}
+
+// Close flushes and closes the underlying file
+func (st *DisconnectStorage) Close() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.file == nil {
+		return nil
+	}
+
+	err := st.writer.Flush()
+
+	if cerr := st.file.Close(); err == nil {
+		err = cerr
+	}
+
+	st.file = nil
+	st.writer = nil
+
+	return err
+}
+
+func (st *DisconnectStorage) load() error {
+	data, err := os.ReadFile(st.path)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	skipped := 0
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		record, err := decodeStorageRecord(line)
+
+		if err != nil {
+			skipped++
+			continue
+		}
+
+		switch record.Op {
+		case storageOpAdd:
+			if record.Entry != nil {
+				st.pending[record.ID] = record.Entry
+			}
+		case storageOpDone:
+			delete(st.pending, record.ID)
+		}
+
+		if record.ID >= st.nextID {
+			st.nextID = record.ID + 1
+		}
+	}
+
+	if skipped > 0 {
+		st.log.Warnf("Skipped corrupted disconnect log records: %d", skipped)
+	}
+
+	if len(st.pending) > 0 {
+		st.log.Infof("Restored pending disconnects from %s: %d", st.path, len(st.pending))
+	}
+
+	return nil
+}
+
+// compact rewrites the log to contain only pending entries.
+// Must be called within the lock.
+func (st *DisconnectStorage) compact() error {
+	if st.file != nil {
+		st.writer.Flush() // nolint:errcheck
+		st.file.Close()
+		st.file = nil
+	}
+
+	tmpPath := st.path + ".tmp"
+
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return err
+	}
+
+	st.file = tmp
+	st.writer = bufio.NewWriter(tmp)
+
+	ids := make([]uint64, 0, len(st.pending))
+
+	for id := range st.pending {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		if err := st.write(&storageRecord{Op: storageOpAdd, ID: id, Entry: st.pending[id]}); err != nil {
+			return err
+		}
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, st.path); err != nil {
+		return err
+	}
+
+	st.processed = 0
+
+	return nil
+}
+
+// write appends the record to the log.
+// Must be called within the lock.
+func (st *DisconnectStorage) write(record *storageRecord) error {
+	payload, err := json.Marshal(record)
+
+	if err != nil {
+		return err
+	}
+
+	checksum := strconv.FormatUint(uint64(crc32.ChecksumIEEE(payload)), 16)
+
+	st.writer.WriteString(checksum) // nolint:errcheck
+	st.writer.WriteByte(' ')        // nolint:errcheck
+	st.writer.Write(payload)        // nolint:errcheck
+	st.writer.WriteByte('\n')       // nolint:errcheck
+
+	return st.writer.Flush()
+}
+
+func decodeStorageRecord(line []byte) (*storageRecord, error) {
+	parts := bytes.SplitN(line, []byte(" "), 2)
+
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Malformed record: %s", line)
+	}
+
+	checksum, err := strconv.ParseUint(string(parts[0]), 16, 32)
+
+	if err != nil || uint32(checksum) != crc32.ChecksumIEEE(parts[1]) {
+		return nil, fmt.Errorf("Checksum mismatch: %s", line)
+	}
+
+	record := &storageRecord{}
+
+	if err := json.Unmarshal(parts[1], record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// newDisconnectEntry builds the entry to persist (credentials headers are only kept if withCredentials is true)
+func newDisconnectEntry(s *Session, withCredentials bool) *disconnectEntry {
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	entry := &disconnectEntry{
+		UID:           s.UID,
+		Identifiers:   s.Identifiers,
+		URL:           s.env.URL,
+		Subscriptions: subscriptionsList(s.subscriptions),
+		Controller:    s.controllerName,
+	}
+
+	if s.env.Headers != nil {
+		entry.Headers = make(map[string]string, len(*s.env.Headers))
+
+		for k, v := range *s.env.Headers {
+			entry.Headers[k] = v
+		}
+
+		if !withCredentials {
+			for _, k := range storageCredentialsHeaders {
+				delete(entry.Headers, k)
+			}
+		}
+	}
+
+	if s.env.ConnectionState != nil {
+		entry.ConnectionState = *s.env.ConnectionState
+	}
+
+	if s.env.ChannelStates != nil {
+		entry.ChannelStates = *s.env.ChannelStates
+	}
+
+	if s.env.Store != nil {
+		if store := s.env.Store.PersistentEntries(); len(store) > 0 {
+			entry.Store = store
+		}
+	}
+
+	return entry
+}
+
+// newRestoredSession builds a closed session containing only the data required
+// to perform a Disconnect call
+func newRestoredSession(n *Node, entry *disconnectEntry) *Session {
+	headers := entry.Headers
+
+	if headers == nil {
+		headers = make(map[string]string)
+	}
+
+	env := common.NewSessionEnv(entry.URL, &headers)
+
+	if entry.ConnectionState != nil {
+		env.MergeConnectionState(&entry.ConnectionState)
+	}
+
+	for id, state := range entry.ChannelStates {
+		state := state
+		env.MergeChannelState(id, &state)
+	}
+
+	env.Store = common.NewSessionStore(0, n.sessionStoreRPCKeys)
+
+	for key, val := range entry.Store {
+		// No size limit, so no errors
+		env.Store.SetPersistent(key, val) // nolint:errcheck
+	}
+
+	subscriptions := make(map[string]bool)
+
+	for _, id := range entry.Subscriptions {
+		subscriptions[id] = true
+	}
+
+	session := &Session{
+		node:          n,
+		env:           env,
+		subscriptions: subscriptions,
+		closed:        true,
+		UID:           entry.UID,
+		Identifiers:   entry.Identifiers,
+		Log:           n.log.WithField("sid", entry.UID),
+	}
+
+	if entry.Controller != "" && !session.UseController(entry.Controller) {
+		session.Log.Warnf("Unknown controller %s, the default one is used to perform Disconnect call", entry.Controller)
+	}
+
+	return session
+}
@@ -0,0 +1,149 @@
+package node
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisconnectStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disconnect.log")
+
+	st, err := OpenDisconnectStorage(path)
+	assert.NoError(t, err)
+
+	id1, err := st.Add(&disconnectEntry{UID: "1", Identifiers: "user_1", URL: "/cable"})
+	assert.NoError(t, err)
+
+	id2, err := st.Add(&disconnectEntry{UID: "2", Identifiers: "user_2", URL: "/cable", Subscriptions: []string{"chat"}})
+	assert.NoError(t, err)
+
+	_, err = st.Add(&disconnectEntry{UID: "3", Identifiers: "user_3", URL: "/cable"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, st.Done(id1))
+	assert.NoError(t, st.Close())
+
+	t.Run("Restores pending entries", func(t *testing.T) {
+		restored, err := OpenDisconnectStorage(path)
+		assert.NoError(t, err)
+		defer restored.Close()
+
+		ids, entries := restored.Pending()
+
+		assert.Len(t, entries, 2)
+		assert.Equal(t, id2, ids[0])
+		assert.Equal(t, "user_2", entries[0].Identifiers)
+		assert.Equal(t, []string{"chat"}, entries[0].Subscriptions)
+		assert.Equal(t, "user_3", entries[1].Identifiers)
+	})
+
+	t.Run("Skips corrupted records", func(t *testing.T) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+		assert.NoError(t, err)
+
+		_, err = f.WriteString("deadbeef {\"op\":\"add\",\"id\":42,\"ent")
+		assert.NoError(t, err)
+		assert.NoError(t, f.Close())
+
+		restored, err := OpenDisconnectStorage(path)
+		assert.NoError(t, err)
+
+		assert.Equal(t, 2, restored.Size())
+
+		id, err := restored.Add(&disconnectEntry{UID: "4", Identifiers: "user_4"})
+		assert.NoError(t, err)
+		assert.Greater(t, id, id2)
+
+		assert.NoError(t, restored.Close())
+	})
+}
+
+func TestDisconnectQueue_Restore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disconnect.log")
+
+	node := NewMockNode()
+	config := NewDisconnectQueueConfig()
+	config.Rate = 1000
+	config.StoragePath = path
+
+	q := NewDisconnectQueue(&node, &config)
+	assert.NoError(t, q.Restore())
+
+	session := NewMockSession("1", &node)
+	assert.Nil(t, session.Store().SetPersistent("locale", []byte("en")))
+	assert.Nil(t, session.Store().Set("token", []byte("secret")))
+
+	assert.Nil(t, q.Enqueue(session))
+	assert.Nil(t, q.Enqueue(NewMockSession("2", &node)))
+
+	// Emulate crash
+	assert.NoError(t, q.storage.Close())
+
+	q2 := NewDisconnectQueue(&node, &config)
+	assert.NoError(t, q2.Restore())
+
+	for q2.Size() < 2 {
+		runtime.Gosched()
+	}
+
+	task := <-q2.disconnect
+	assert.Equal(t, "1", task.session.UID)
+	assert.Equal(t, "/cable-test", task.session.env.URL)
+
+	locale, _ := task.session.Store().Get("locale")
+	assert.Equal(t, "en", string(locale))
+
+	_, ok := task.session.Store().Get("token")
+	assert.False(t, ok)
+
+	q2.disconnectBatch([]*disconnectTask{task})
+
+	assert.Equal(t, 1, q2.storage.Size())
+
+	assert.Nil(t, q2.Shutdown())
+
+	q3 := NewDisconnectQueue(&node, &config)
+	assert.NoError(t, q3.Restore())
+	defer q3.Shutdown() // nolint:errcheck
+
+	assert.Equal(t, 0, q3.storage.Size())
+}
+
+func TestDisconnectEntryCredentials(t *testing.T) {
+	node := NewMockNode()
+	session := NewMockSession("1", &node)
+	headers := map[string]string{"cookie": "token=secret", "authorization": "Bearer secret", "x-api-version": "2"}
+	session.env.Headers = &headers
+
+	entry := newDisconnectEntry(session, false)
+	assert.Equal(t, map[string]string{"x-api-version": "2"}, entry.Headers)
+	// Session headers are not modified
+	assert.Equal(t, "token=secret", headers["cookie"])
+
+	entry = newDisconnectEntry(session, true)
+	assert.Equal(t, headers, entry.Headers)
+}
+
+func TestRestoredSessionController(t *testing.T) {
+	node := NewMockNode()
+	controller := mocks.NewMockController()
+	node.RegisterController("embed", &controller)
+
+	session := NewMockSession("1", &node)
+	assert.True(t, session.UseController("embed"))
+	assert.False(t, NewMockSession("2", &node).UseController("unknown"))
+
+	restored := newRestoredSession(&node, newDisconnectEntry(session, false))
+	assert.Same(t, &controller, node.controllerFor(restored))
+
+	entry := newDisconnectEntry(session, false)
+	entry.Controller = "removed"
+
+	restored = newRestoredSession(&node, entry)
+	assert.Equal(t, node.controller, node.controllerFor(restored))
+}
@@ -0,0 +1,213 @@
+package node
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/apex/log"
+)
+
+const (
+	metricsEventsEmitted = "events_emitted_total"
+	metricsEventsDropped = "events_dropped_total"
+	metricsEventsFailed  = "events_failed_total"
+
+	// How long to wait for the pending events to be written on shutdown
+	eventsShutdownTimeout = 2 * time.Second
+)
+
+// Session lifecycle event types
+const (
+	EventSessionOpened        = "session_opened"
+	EventSessionAuthenticated = "session_authenticated"
+	EventSessionClosed        = "session_closed"
+)
+
+// Events sink types
+const (
+	EventsSinkLog   = "log"
+	EventsSinkRedis = "redis"
+)
+
+// SessionEvent represents a session lifecycle event (used for auditing)
+type SessionEvent struct {
+	Type string `json:"type"`
+	// Event time (Unix milliseconds)
+	Timestamp   int64  `json:"ts"`
+	SessionID   string `json:"sid"`
+	Identifiers string `json:"identifiers,omitempty"`
+	RemoteIP    string `json:"remote_ip,omitempty"`
+	// Identifier of the node the session is connected to
+	NodeID string `json:"node_id,omitempty"`
+	// Close reason and code (closed events only)
+	Reason string `json:"reason,omitempty"`
+	Code   int    `json:"code,omitempty"`
+	// Session duration in milliseconds (closed events only)
+	Duration int64 `json:"duration_ms,omitempty"`
+}
+
+// EventsSink writes session events somewhere (called from a single goroutine)
+type EventsSink interface {
+	Write(event *SessionEvent) error
+}
+
+// Publisher is an interface for publishing raw messages (e.g., to a Redis channel)
+type Publisher interface {
+	Publish(payload []byte) error
+}
+
+// LogEventsSink writes events as structured log entries (with context=events)
+type LogEventsSink struct {
+	log *log.Entry
+}
+
+// NewLogEventsSink builds a new LogEventsSink
+func NewLogEventsSink() *LogEventsSink {
+	return &LogEventsSink{log: log.WithField("context", "events")}
+}
+
+// Write logs the event
+func (s *LogEventsSink) Write(event *SessionEvent) error {
+	fields := log.Fields{"sid": event.SessionID, "ts": event.Timestamp}
+
+	if event.Identifiers != "" {
+		fields["identifiers"] = event.Identifiers
+	}
+
+	if event.RemoteIP != "" {
+		fields["remote_ip"] = event.RemoteIP
+	}
+
+	if event.Type == EventSessionClosed {
+		fields["reason"] = event.Reason
+		fields["code"] = event.Code
+		fields["duration_ms"] = event.Duration
+	}
+
+	s.log.WithFields(fields).Info(event.Type)
+
+	return nil
+}
+
+// PublisherEventsSink publishes JSON-encoded events via the publisher
+type PublisherEventsSink struct {
+	publisher Publisher
+}
+
+// NewPublisherEventsSink builds a new PublisherEventsSink
+func NewPublisherEventsSink(publisher Publisher) *PublisherEventsSink {
+	return &PublisherEventsSink{publisher: publisher}
+}
+
+// Write publishes the event
+func (s *PublisherEventsSink) Write(event *SessionEvent) error {
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	return s.publisher.Publish(payload)
+}
+
+// EventsEmitter passes session events to the sink asynchronously.
+// The buffer is bounded: events are dropped when the sink can't keep up,
+// so emitting never blocks sessions.
+type EventsEmitter struct {
+	sink    EventsSink
+	redact  bool
+	events  chan *SessionEvent
+	metrics *metrics.Metrics
+	log     *log.Entry
+
+	mu      sync.RWMutex
+	stopped bool
+	done    chan struct{}
+}
+
+// NewEventsEmitter builds a new emitter with the specified buffer size
+func NewEventsEmitter(sink EventsSink, bufferSize int, redact bool, m *metrics.Metrics) *EventsEmitter {
+	m.RegisterCounter(metricsEventsEmitted, "The total number of session events written to the sink")
+	m.RegisterCounter(metricsEventsDropped, "The total number of session events dropped due to the full buffer")
+	m.RegisterCounter(metricsEventsFailed, "The total number of session events failed to be written to the sink")
+
+	return &EventsEmitter{
+		sink:    sink,
+		redact:  redact,
+		events:  make(chan *SessionEvent, bufferSize),
+		metrics: m,
+		log:     log.WithField("context", "events"),
+		done:    make(chan struct{}),
+	}
+}
+
+// Run writes events to the sink until the emitter is shut down
+func (e *EventsEmitter) Run() {
+	defer close(e.done)
+
+	for event := range e.events {
+		// Redact here to keep the emitting sessions' work minimal
+		if e.redact {
+			event.Identifiers = RedactIdentifiers(event.Identifiers)
+		}
+
+		if err := e.sink.Write(event); err != nil {
+			e.metrics.Counter(metricsEventsFailed).Inc()
+			e.log.Debugf("Failed to write %s event: %v", event.Type, err)
+			continue
+		}
+
+		e.metrics.Counter(metricsEventsEmitted).Inc()
+	}
+}
+
+// Emit enqueues the event without blocking (the event is dropped if the buffer is full)
+func (e *EventsEmitter) Emit(event *SessionEvent) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.stopped {
+		return
+	}
+
+	select {
+	case e.events <- event:
+	default:
+		e.metrics.Counter(metricsEventsDropped).Inc()
+	}
+}
+
+// Shutdown stops accepting new events and waits for the pending ones to be written
+func (e *EventsEmitter) Shutdown() error {
+	e.mu.Lock()
+
+	if e.stopped {
+		e.mu.Unlock()
+		return nil
+	}
+
+	e.stopped = true
+	close(e.events)
+	e.mu.Unlock()
+
+	select {
+	case <-e.done:
+	case <-time.After(eventsShutdownTimeout):
+		e.log.Warnf("Pending events haven't been written in %s", eventsShutdownTimeout)
+	}
+
+	return nil
+}
+
+func newSessionEvent(eventType string, s *Session) *SessionEvent {
+	return &SessionEvent{
+		Type:        eventType,
+		Timestamp:   time.Now().UnixNano() / int64(time.Millisecond),
+		SessionID:   s.UID,
+		Identifiers: s.Identifiers,
+		RemoteIP:    s.RemoteIP,
+		NodeID:      s.node.id,
+	}
+}
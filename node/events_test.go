@@ -0,0 +1,131 @@
+package node
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+type testEventsSink struct {
+	mu     sync.Mutex
+	events []*SessionEvent
+	err    error
+	// Blocks writes until closed (if set)
+	blocker chan struct{}
+}
+
+func (s *testEventsSink) Write(event *SessionEvent) error {
+	if s.blocker != nil {
+		<-s.blocker
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	s.events = append(s.events, event)
+
+	return nil
+}
+
+func (s *testEventsSink) Events() []*SessionEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.events
+}
+
+func TestEventsEmitter(t *testing.T) {
+	t.Run("Drops events when the buffer is full", func(t *testing.T) {
+		m := metrics.NewMetrics(nil, 10)
+		sink := &testEventsSink{blocker: make(chan struct{})}
+		emitter := NewEventsEmitter(sink, 2, false, m)
+
+		for i := 0; i < 3; i++ {
+			emitter.Emit(&SessionEvent{Type: EventSessionOpened})
+		}
+
+		assert.Equal(t, uint64(1), m.Counter(metricsEventsDropped).Value())
+
+		go emitter.Run()
+		close(sink.blocker)
+
+		assert.Nil(t, emitter.Shutdown())
+		assert.Equal(t, 2, len(sink.Events()))
+		assert.Equal(t, uint64(2), m.Counter(metricsEventsEmitted).Value())
+
+		// Events are ignored after shutdown
+		emitter.Emit(&SessionEvent{Type: EventSessionOpened})
+	})
+
+	t.Run("Redacts identifiers", func(t *testing.T) {
+		m := metrics.NewMetrics(nil, 10)
+		sink := &testEventsSink{}
+		emitter := NewEventsEmitter(sink, 2, true, m)
+
+		go emitter.Run()
+
+		emitter.Emit(&SessionEvent{Type: EventSessionAuthenticated, Identifiers: `{"user":"42"}`})
+		assert.Nil(t, emitter.Shutdown())
+
+		assert.Equal(t, `{"user":"[REDACTED]"}`, sink.Events()[0].Identifiers)
+	})
+
+	t.Run("Tracks sink failures", func(t *testing.T) {
+		m := metrics.NewMetrics(nil, 10)
+		sink := &testEventsSink{err: errors.New("Connection refused")}
+		emitter := NewEventsEmitter(sink, 2, false, m)
+
+		go emitter.Run()
+
+		emitter.Emit(&SessionEvent{Type: EventSessionOpened})
+		assert.Nil(t, emitter.Shutdown())
+
+		assert.Equal(t, uint64(1), m.Counter(metricsEventsFailed).Value())
+	})
+}
+
+func TestSessionEvents(t *testing.T) {
+	node := NewMockNode()
+	sink := &testEventsSink{}
+	emitter := NewEventsEmitter(sink, 10, false, node.Metrics)
+	node.SetEventsEmitter(emitter)
+	node.SetID("node-1")
+
+	go emitter.Run()
+
+	session := NewMockSessionWithEnv("1", &node, "/cable", &map[string]string{"id": "test_id"})
+	session.RemoteIP = "10.0.0.1"
+	session.closed = false
+
+	_, err := node.Authenticate(session)
+	assert.Nil(t, err)
+
+	session.Disconnect("server_restart", 1012)
+	session.Disconnect("other", 1000)
+
+	assert.Nil(t, emitter.Shutdown())
+
+	events := sink.Events()
+
+	if assert.Equal(t, 3, len(events)) {
+		assert.Equal(t, EventSessionOpened, events[0].Type)
+		assert.Equal(t, "1", events[0].SessionID)
+		assert.Equal(t, "10.0.0.1", events[0].RemoteIP)
+
+		assert.Equal(t, EventSessionAuthenticated, events[1].Type)
+		assert.Equal(t, "test_id", events[1].Identifiers)
+
+		assert.Equal(t, EventSessionClosed, events[2].Type)
+		assert.Equal(t, "server_restart", events[2].Reason)
+		assert.Equal(t, 1012, events[2].Code)
+		assert.Equal(t, "test_id", events[2].Identifiers)
+		assert.Equal(t, "node-1", events[2].NodeID)
+	}
+}
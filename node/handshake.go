@@ -0,0 +1,47 @@
+package node
+
+import (
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+)
+
+const (
+	metricsHandshakes    = "handshakes_total"
+	metricsTimeToWelcome = "time_to_welcome_seconds"
+)
+
+// Connection attempt stages (used as the handshakes_total metric labels)
+const (
+	HandshakeAccepted       = "accepted"
+	HandshakeTLSFailed      = "tls_failed"
+	HandshakeNotFound       = "not_found"
+	HandshakeOriginRejected = "origin_rejected"
+	HandshakeRateLimited    = "rate_limited"
+	HandshakeSlowStart      = "slow_start_rejected"
+	HandshakeUpgradeFailed  = "upgrade_failed"
+	HandshakeServerFull     = "server_full"
+	HandshakeAuthFailed     = "auth_failed"
+	HandshakeConnected      = "connected"
+)
+
+// TrackHandshake increments the connection attempts counter for the stage
+// (stages preceding authentication are tracked by the transports)
+func (n *Node) TrackHandshake(stage string, count uint64) {
+	n.Metrics.CounterVec(metricsHandshakes).With(stage).Add(count)
+}
+
+// trackAuthentication tracks the session authentication result and the time from accepting
+// the connection to sending the welcome message
+func (n *Node) trackAuthentication(s *Session, res *common.ConnectResult, err error) {
+	if err != nil || res == nil || res.Status != common.SUCCESS {
+		n.TrackHandshake(HandshakeAuthFailed, 1)
+		return
+	}
+
+	n.TrackHandshake(HandshakeConnected, 1)
+
+	if !s.AcceptedAt.IsZero() {
+		n.Metrics.Histogram(metricsTimeToWelcome).Observe(time.Since(s.AcceptedAt).Seconds())
+	}
+}
@@ -0,0 +1,56 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticateHandshakeMetrics(t *testing.T) {
+	node := NewMockNode()
+	handshakes := node.Metrics.CounterVec(metricsHandshakes)
+
+	t.Run("Successful authentication", func(t *testing.T) {
+		session := NewMockSessionWithEnv("1", &node, "/cable", &map[string]string{"id": "test_id"})
+		session.AcceptedAt = time.Now().Add(-50 * time.Millisecond)
+
+		_, err := node.Authenticate(session)
+		assert.Nil(t, err)
+
+		assert.Equal(t, uint64(1), handshakes.With(HandshakeConnected).Value())
+		assert.Equal(t, uint64(1), node.Metrics.Histogram(metricsTimeToWelcome).Count())
+
+		_, _, sum := node.Metrics.Histogram(metricsTimeToWelcome).Snapshot()
+		assert.GreaterOrEqual(t, sum, 0.05)
+	})
+
+	t.Run("Failed authentication", func(t *testing.T) {
+		session := NewMockSessionWithEnv("2", &node, "/failure", &map[string]string{"id": "test_id"})
+
+		_, err := node.Authenticate(session)
+		assert.Nil(t, err)
+
+		assert.Equal(t, uint64(1), handshakes.With(HandshakeAuthFailed).Value())
+		assert.Equal(t, uint64(1), node.Metrics.Histogram(metricsTimeToWelcome).Count())
+	})
+
+	t.Run("Authentication error", func(t *testing.T) {
+		session := NewMockSessionWithEnv("3", &node, "/error", &map[string]string{"id": "test_id"})
+
+		_, err := node.Authenticate(session)
+		assert.Error(t, err)
+
+		assert.Equal(t, uint64(2), handshakes.With(HandshakeAuthFailed).Value())
+	})
+
+	t.Run("Without accept time", func(t *testing.T) {
+		session := NewMockSessionWithEnv("4", &node, "/cable", &map[string]string{"id": "test_id"})
+
+		_, err := node.Authenticate(session)
+		assert.Nil(t, err)
+
+		assert.Equal(t, uint64(2), handshakes.With(HandshakeConnected).Value())
+		assert.Equal(t, uint64(1), node.Metrics.Histogram(metricsTimeToWelcome).Count())
+	})
+}
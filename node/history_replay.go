@@ -0,0 +1,80 @@
+package node
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/anycable/anycable-go/common"
+)
+
+// historyReplays holds live broadcasts for the session subscriptions which history is being replayed,
+// so they're delivered after the replayed messages (and the ones already replayed are skipped)
+type historyReplays struct {
+	// The number of replays in progress (to avoid locking for every broadcast)
+	active int32
+
+	mu   sync.Mutex
+	held map[string][]*common.StreamMessage
+}
+
+// start makes broadcasts for the subscription to be held until the replay is finished
+func (r *historyReplays) start(identifier string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.held == nil {
+		r.held = make(map[string][]*common.StreamMessage)
+	}
+
+	if _, ok := r.held[identifier]; ok {
+		return
+	}
+
+	r.held[identifier] = []*common.StreamMessage{}
+	atomic.AddInt32(&r.active, 1)
+}
+
+// hold returns true if the message has been held (i.e., the subscription history is being replayed)
+func (r *historyReplays) hold(identifier string, msg *common.StreamMessage) bool {
+	if atomic.LoadInt32(&r.active) == 0 {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	held, ok := r.held[identifier]
+
+	if !ok {
+		return false
+	}
+
+	r.held[identifier] = append(held, msg)
+
+	return true
+}
+
+// finish delivers the held messages except from those already replayed (with the offset not greater
+// than the last replayed one for the stream).
+// Messages are delivered under the lock, so concurrent broadcasts can't overtake them.
+func (r *historyReplays) finish(identifier string, epoch string, replayed map[string]uint64, deliver func(*common.StreamMessage)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	held, ok := r.held[identifier]
+
+	if !ok {
+		return
+	}
+
+	delete(r.held, identifier)
+	atomic.AddInt32(&r.active, -1)
+
+	for _, msg := range held {
+		if last, ok := replayed[msg.Stream]; ok && msg.Epoch == epoch && msg.Offset <= last {
+			continue
+		}
+
+		deliver(msg)
+	}
+}
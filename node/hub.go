@@ -2,12 +2,15 @@ package node
 
 import (
 	"encoding/json"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/anycable/anycable-go/common"
 	"github.com/anycable/anycable-go/encoders"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/tracing"
 	"github.com/anycable/anycable-go/utils"
-	"github.com/anycable/anycable-go/ws"
 	"github.com/apex/log"
 )
 
@@ -19,19 +22,34 @@ type HubSubscription struct {
 	identifier string
 }
 
+// streamEvent is a stream broadcast or a stop command
+// (stream events are processed in the order they were received)
+type streamEvent struct {
+	msg  *common.StreamMessage
+	stop *common.StopStreamMessage
+}
+
 // HubRegistration represents registration event ("add" or "remove")
 type HubRegistration struct {
 	event   string
 	session *Session
 }
 
-// Hub stores all the sessions and the corresponding subscriptions info
+// Hub stores all the sessions and the corresponding subscriptions info.
+//
+// Broadcasts are delivered in the order they were received per stream:
+// messages for the same stream are never processed concurrently,
+// and each session enqueues outgoing messages into a FIFO queue.
 type Hub struct {
 	// Registered sessions
 	sessions map[string]*Session
 
-	// Identifiers to session
-	identifiers map[string]map[string]bool
+	// Identifiers to sessions (with registration sequence numbers)
+	// identifier -> sid -> seq
+	identifiers map[string]map[string]uint64
+
+	// Sessions registration sequence (to find the oldest sessions)
+	registrationSeq uint64
 
 	// Maps streams to sessions with identifiers
 	// stream -> sid -> identifier -> true
@@ -41,8 +59,8 @@ type Hub struct {
 	// sid -> identifier -> [stream]
 	sessionsStreams map[string]map[string][]string
 
-	// Messages for specified stream
-	broadcast chan *common.StreamMessage
+	// Messages and stop commands for specified stream
+	broadcast chan streamEvent
 
 	// Remote disconnect commands
 	disconnect chan *common.RemoteDisconnectMessage
@@ -65,6 +83,25 @@ type Hub struct {
 	// go pool
 	pool *utils.GoPool
 
+	// Streams with pending events (stream -> broadcasts and commands).
+	// A stream is present in the map while its events are being processed
+	pendingBroadcasts map[string][]streamEvent
+
+	// Streams history (optional)
+	broker *Broker
+
+	// Broadcast fan-out duration (optional)
+	fanoutTiming *metrics.Timing
+
+	// Tracer for large broadcasts fan-out (optional)
+	tracer *tracing.Tracer
+
+	// Streams namespace prefix to strip from the stream names sent to clients (optional)
+	streamsPrefix string
+
+	// mutex for pending broadcasts
+	pendingMu sync.Mutex
+
 	// mutex for streams mappings
 	streamsMu sync.RWMutex
 
@@ -75,17 +112,18 @@ type Hub struct {
 // NewHub builds new hub instance
 func NewHub(poolSize int) *Hub {
 	return &Hub{
-		broadcast:       make(chan *common.StreamMessage, 256),
-		disconnect:      make(chan *common.RemoteDisconnectMessage, 128),
-		register:        make(chan HubRegistration, 2048),
-		subscribe:       make(chan HubSubscription, 128),
-		sessions:        make(map[string]*Session),
-		identifiers:     make(map[string]map[string]bool),
-		streams:         make(map[string]map[string]map[string]bool),
-		sessionsStreams: make(map[string]map[string][]string),
-		shutdown:        make(chan struct{}),
-		log:             log.WithFields(log.Fields{"context": "hub"}),
-		pool:            utils.NewGoPool("broadcast", poolSize),
+		broadcast:         make(chan streamEvent, 256),
+		disconnect:        make(chan *common.RemoteDisconnectMessage, 128),
+		register:          make(chan HubRegistration, 2048),
+		subscribe:         make(chan HubSubscription, 128),
+		sessions:          make(map[string]*Session),
+		identifiers:       make(map[string]map[string]uint64),
+		streams:           make(map[string]map[string]map[string]bool),
+		sessionsStreams:   make(map[string]map[string][]string),
+		pendingBroadcasts: make(map[string][]streamEvent),
+		shutdown:          make(chan struct{}),
+		log:               log.WithFields(log.Fields{"context": "hub"}),
+		pool:              utils.NewGoPool("broadcast", poolSize),
 	}
 }
 
@@ -110,8 +148,12 @@ func (h *Hub) Run() {
 				h.unsubscribeSession(subinfo.session, subinfo.stream, subinfo.identifier)
 			}
 
-		case message := <-h.broadcast:
-			h.broadcastToStream(message.Stream, message.Data)
+		case event := <-h.broadcast:
+			if event.stop != nil {
+				h.stopStream(event.stop)
+			} else {
+				h.broadcastMessage(event.msg)
+			}
 
 		case command := <-h.disconnect:
 			h.disconnectSessions(command.Identifier, command.Reconnect)
@@ -150,12 +192,12 @@ func (h *Hub) RemoveAllSubscriptions(sid string, identifier string) {
 
 // Broadcast enqueues data broadcasting to a stream
 func (h *Hub) Broadcast(stream string, data string) {
-	h.broadcast <- &common.StreamMessage{Stream: stream, Data: data}
+	h.broadcast <- streamEvent{msg: &common.StreamMessage{Stream: stream, Data: data}}
 }
 
 // BroadcastMessage enqueues broadcasting a pre-built StreamMessage
 func (h *Hub) BroadcastMessage(msg *common.StreamMessage) {
-	h.broadcast <- msg
+	h.broadcast <- streamEvent{msg: msg}
 }
 
 // RemoteDisconnect enqueues remote disconnect command
@@ -163,6 +205,11 @@ func (h *Hub) RemoteDisconnect(msg *common.RemoteDisconnectMessage) {
 	h.disconnect <- msg
 }
 
+// StopStream enqueues stop stream command (it's processed in order with the stream broadcasts)
+func (h *Hub) StopStream(msg *common.StopStreamMessage) {
+	h.broadcast <- streamEvent{stop: msg}
+}
+
 // Shutdown sends shutdown command to hub
 func (h *Hub) Shutdown() {
 	h.shutdown <- struct{}{}
@@ -199,13 +246,54 @@ func (h *Hub) addSession(session *Session) {
 	h.sessionsMu.Lock()
 	defer h.sessionsMu.Unlock()
 
+	h.registerSession(session)
+}
+
+// addSessionWithLimit registers the session if the number of sessions with the same identifiers
+// doesn't exceed the limit (anonymous sessions, i.e., with empty identifiers, are not limited).
+// When kickOldest is true, the oldest session is unregistered and returned instead of rejecting the new one.
+// Returns false if the session hasn't been registered.
+func (h *Hub) addSessionWithLimit(session *Session, limit int, kickOldest bool) (bool, *Session) {
+	h.sessionsMu.Lock()
+	defer h.sessionsMu.Unlock()
+
+	var kicked *Session
+
+	if ids := h.identifiers[session.Identifiers]; session.Identifiers != "" && len(ids) >= limit {
+		if !kickOldest {
+			return false, nil
+		}
+
+		var oldest string
+		var oldestSeq uint64
+
+		for sid, seq := range ids {
+			if oldest == "" || seq < oldestSeq {
+				oldest, oldestSeq = sid, seq
+			}
+		}
+
+		kicked = h.sessions[oldest]
+
+		// Remove from the index right away to make sure concurrent registrations don't kick the same session
+		delete(ids, oldest)
+	}
+
+	h.registerSession(session)
+
+	return true, kicked
+}
+
+// registerSession must be called within sessionsMu lock
+func (h *Hub) registerSession(session *Session) {
 	h.sessions[session.UID] = session
 
 	if _, ok := h.identifiers[session.Identifiers]; !ok {
-		h.identifiers[session.Identifiers] = make(map[string]bool)
+		h.identifiers[session.Identifiers] = make(map[string]uint64)
 	}
 
-	h.identifiers[session.Identifiers][session.UID] = true
+	h.registrationSeq++
+	h.identifiers[session.Identifiers][session.UID] = h.registrationSeq
 
 	h.log.WithField("sid", session.UID).Debugf(
 		"Registered with identifiers: %s",
@@ -213,6 +301,26 @@ func (h *Hub) addSession(session *Session) {
 	)
 }
 
+// updateIdentifiers changes the session identifiers (e.g., when the anonymous session is authenticated)
+func (h *Hub) updateIdentifiers(session *Session, identifiers string) {
+	h.sessionsMu.Lock()
+	defer h.sessionsMu.Unlock()
+
+	if _, ok := h.sessions[session.UID]; !ok {
+		session.Identifiers = identifiers
+		return
+	}
+
+	delete(h.identifiers[session.Identifiers], session.UID)
+
+	if len(h.identifiers[session.Identifiers]) == 0 {
+		delete(h.identifiers, session.Identifiers)
+	}
+
+	session.Identifiers = identifiers
+	h.registerSession(session)
+}
+
 func (h *Hub) removeSession(session *Session) {
 	h.sessionsMu.RLock()
 	if _, ok := h.sessions[session.UID]; !ok {
@@ -339,9 +447,21 @@ func (h *Hub) unsubscribeSession(sid string, stream string, identifier string) {
 }
 
 func (h *Hub) broadcastToStream(stream string, data string) {
+	h.broadcastMessage(&common.StreamMessage{Stream: stream, Data: data})
+}
+
+func (h *Hub) broadcastMessage(msg *common.StreamMessage) {
+	stream := msg.Stream
 	ctx := h.log.WithField("stream", stream)
 
-	ctx.Debugf("Broadcast message: %s", data)
+	// History must contain messages for streams without subscribers, too
+	// (clients could re-subscribe later)
+	if h.broker != nil {
+		msg.Offset = h.broker.Add(stream, msg.Data)
+		msg.Epoch = h.broker.Epoch()
+	}
+
+	ctx.Debugf("Broadcast message: %s", msg.Data)
 
 	h.streamsMu.RLock()
 	if _, ok := h.streams[stream]; !ok {
@@ -351,36 +471,206 @@ func (h *Hub) broadcastToStream(stream string, data string) {
 	}
 	h.streamsMu.RUnlock()
 
+	h.enqueueStreamEvent(stream, streamEvent{msg: msg})
+}
+
+// stopStream enqueues unsubscribing all the sessions from the stream
+// (after delivering the pending broadcasts). Unknown streams are ignored
+func (h *Hub) stopStream(msg *common.StopStreamMessage) {
+	h.streamsMu.RLock()
+	if _, ok := h.streams[msg.Stream]; !ok {
+		h.log.WithField("stream", msg.Stream).Debug("No sessions to stop")
+		h.streamsMu.RUnlock()
+		return
+	}
+	h.streamsMu.RUnlock()
+
+	h.enqueueStreamEvent(msg.Stream, streamEvent{stop: msg})
+}
+
+func (h *Hub) enqueueStreamEvent(stream string, event streamEvent) {
+	h.pendingMu.Lock()
+	if pending, ok := h.pendingBroadcasts[stream]; ok {
+		// Stream events are being processed right now,
+		// the event is picked up by the same worker to preserve the order
+		h.pendingBroadcasts[stream] = append(pending, event)
+		h.pendingMu.Unlock()
+		return
+	}
+
+	h.pendingBroadcasts[stream] = []streamEvent{event}
+	h.pendingMu.Unlock()
+
 	h.pool.Schedule(func() {
-		buf := make(map[string](encoders.EncodedMessage))
+		h.deliverPendingBroadcasts(stream)
+	})
+}
 
-		var bdata encoders.EncodedMessage
+func (h *Hub) deliverPendingBroadcasts(stream string) {
+	for {
+		h.pendingMu.Lock()
+		events := h.pendingBroadcasts[stream]
 
-		h.streamsMu.RLock()
-		streamSessions := streamSessionsSnapshot(h.streams[stream])
-		h.streamsMu.RUnlock()
+		if len(events) == 0 {
+			delete(h.pendingBroadcasts, stream)
+			h.pendingMu.Unlock()
+			return
+		}
+
+		h.pendingBroadcasts[stream] = []streamEvent{}
+		h.pendingMu.Unlock()
+
+		for _, event := range events {
+			if event.stop != nil {
+				h.unsubscribeStream(event.stop)
+			} else {
+				h.deliverToStream(event.msg)
+			}
+		}
+	}
+}
 
-		for sid, ids := range streamSessions {
-			h.sessionsMu.RLock()
-			session, ok := h.sessions[sid]
-			h.sessionsMu.RUnlock()
+// unsubscribeStream removes all the stream subscriptions (the sessions' channel subscriptions are kept).
+// The final payload (if any) is delivered before unsubscribing, and the "stream_stopped" notifications after it
+func (h *Hub) unsubscribeStream(msg *common.StopStreamMessage) {
+	stream := msg.Stream
+
+	if msg.Data != "" {
+		h.deliverToStream(&common.StreamMessage{Stream: stream, Data: msg.Data})
+	}
+
+	h.streamsMu.Lock()
+	streamSessions := streamSessionsSnapshot(h.streams[stream])
+	delete(h.streams, stream)
+
+	for sid, ids := range streamSessions {
+		for _, id := range ids {
+			h.sessionsStreams[sid][id] = removeStream(h.sessionsStreams[sid][id], stream)
+		}
+	}
+	h.streamsMu.Unlock()
+
+	h.log.WithField("stream", stream).Debugf("Stopped stream for %d sessions", len(streamSessions))
+
+	if !msg.Notify {
+		return
+	}
+
+	buf := make(map[string](encoders.EncodedMessage))
+
+	for sid, ids := range streamSessions {
+		h.sessionsMu.RLock()
+		session, ok := h.sessions[sid]
+		h.sessionsMu.RUnlock()
+
+		if !ok {
+			continue
+		}
+
+		for _, id := range ids {
+			notification, ok := buf[id]
 
 			if !ok {
+				notification = NewCachedEncodedMessage(&common.Reply{
+					Type:       common.StreamStoppedType,
+					Identifier: id,
+					Message:    map[string]string{"stream": h.clientStream(stream)},
+				})
+				buf[id] = notification
+			}
+
+			session.Send(notification)
+		}
+	}
+}
+
+func (h *Hub) deliverToStream(msg *common.StreamMessage) {
+	if h.fanoutTiming != nil {
+		start := time.Now()
+		defer func() { h.fanoutTiming.Observe(time.Since(start)) }()
+	}
+
+	stream := msg.Stream
+	buf := make(map[string](encoders.EncodedMessage))
+
+	var bdata encoders.EncodedMessage
+
+	h.streamsMu.RLock()
+	streamSessions := streamSessionsSnapshot(h.streams[stream])
+	h.streamsMu.RUnlock()
+
+	if threshold := h.tracer.BroadcastThreshold(); threshold > 0 && len(streamSessions) >= threshold {
+		span := h.tracer.Start("anycable.broadcast", tracing.SpanKindInternal, tracing.SpanContext{})
+		span.SetString("anycable.stream", stream)
+		span.SetInt("anycable.stream.sessions", len(streamSessions))
+		defer span.End()
+	}
+
+	reply := h.clientMessage(msg)
+
+	for sid, ids := range streamSessions {
+		h.sessionsMu.RLock()
+		session, ok := h.sessions[sid]
+		h.sessionsMu.RUnlock()
+
+		if !ok {
+			continue
+		}
+
+		for _, id := range ids {
+			// Delivered after the history replay is finished
+			if session.replays.hold(id, reply) {
 				continue
 			}
 
-			for _, id := range ids {
-				if msg, ok := buf[id]; ok {
-					bdata = msg
-				} else {
-					bdata = buildMessage(data, id)
-					buf[id] = bdata
-				}
+			// Reliable subscriptions need a message with a unique ID per session
+			if reliable := session.reliableDeliveryFor(id); reliable != nil {
+				reliable.send(buildReply(reply, id))
+				continue
+			}
 
-				session.Send(bdata)
+			if cached, ok := buf[id]; ok {
+				bdata = cached
+			} else {
+				bdata = buildMessage(reply, id)
+				buf[id] = bdata
 			}
+
+			session.Send(bdata)
 		}
-	})
+	}
+}
+
+// clientStream returns the stream name as it's known to clients (without the streams namespace prefix)
+func (h *Hub) clientStream(stream string) string {
+	return strings.TrimPrefix(stream, h.streamsPrefix)
+}
+
+// clientMessage returns the message with the stream name as it's known to clients
+// (the message is returned as is for the empty namespace)
+func (h *Hub) clientMessage(msg *common.StreamMessage) *common.StreamMessage {
+	if h.streamsPrefix == "" {
+		return msg
+	}
+
+	public := *msg
+	public.Stream = h.clientStream(msg.Stream)
+
+	return &public
+}
+
+// subscriptionStreams returns the streams the session is subscribed to for the identifier
+func (h *Hub) subscriptionStreams(sid string, identifier string) []string {
+	h.streamsMu.RLock()
+	defer h.streamsMu.RUnlock()
+
+	streams := []string{}
+
+	if ids, ok := h.sessionsStreams[sid]; ok {
+		streams = append(streams, ids[identifier]...)
+	}
+
+	return streams
 }
 
 func (h *Hub) disconnectSessions(identifier string, reconnect bool) {
@@ -402,12 +692,59 @@ func (h *Hub) disconnectSessions(identifier string, reconnect bool) {
 		for id := range ids {
 			if ses, ok := h.sessions[id]; ok {
 				ses.Send(disconnectMessage)
-				ses.Disconnect("Closed remotely", ws.CloseNormalClosure)
+				ses.Disconnect(remoteDisconnectReason, closeCode(remoteDisconnectReason))
 			}
 		}
 	})
 }
 
+// findSession returns the registered session with the specified uid (if any)
+func (h *Hub) findSession(sid string) *Session {
+	h.sessionsMu.RLock()
+	defer h.sessionsMu.RUnlock()
+
+	return h.sessions[sid]
+}
+
+// sessionStreamsSnapshot returns a copy of the session streams (identifier -> streams)
+func (h *Hub) sessionStreamsSnapshot(sid string) map[string][]string {
+	h.streamsMu.RLock()
+	defer h.streamsMu.RUnlock()
+
+	res := make(map[string][]string)
+
+	for identifier, streams := range h.sessionsStreams[sid] {
+		res[identifier] = append([]string{}, streams...)
+	}
+
+	return res
+}
+
+// streamSnapshot returns the number of sessions subscribed to the stream and up to sampleSize of their uids
+// (returns false if the stream is unknown)
+func (h *Hub) streamSnapshot(stream string, sampleSize int) (int, []string, bool) {
+	h.streamsMu.RLock()
+	defer h.streamsMu.RUnlock()
+
+	sessions, ok := h.streams[stream]
+
+	if !ok {
+		return 0, nil, false
+	}
+
+	sample := make([]string, 0, sampleSize)
+
+	for sid := range sessions {
+		if len(sample) >= sampleSize {
+			break
+		}
+
+		sample = append(sample, sid)
+	}
+
+	return len(sessions), sample, true
+}
+
 func (h *Hub) findByIdentifier(id string) *Session {
 	h.sessionsMu.RLock()
 	defer h.sessionsMu.RUnlock()
@@ -427,13 +764,37 @@ func (h *Hub) findByIdentifier(id string) *Session {
 	return nil
 }
 
-func buildMessage(data string, identifier string) encoders.EncodedMessage {
-	var msg interface{}
+func buildMessage(msg *common.StreamMessage, identifier string) encoders.EncodedMessage {
+	return NewCachedEncodedMessage(buildReply(msg, identifier))
+}
+
+func buildReply(msg *common.StreamMessage, identifier string) *common.Reply {
+	var data interface{}
 
 	// We ignore JSON deserialization failures and consider the message to be a string
-	json.Unmarshal([]byte(data), &msg) // nolint:errcheck
+	json.Unmarshal([]byte(msg.Data), &data) // nolint:errcheck
+
+	reply := &common.Reply{Identifier: identifier, Message: data}
+
+	if msg.Offset > 0 {
+		reply.StreamID = msg.Stream
+		reply.Offset = msg.Offset
+		reply.Epoch = msg.Epoch
+	}
+
+	return reply
+}
+
+func removeStream(streams []string, stream string) []string {
+	res := streams[:0]
+
+	for _, s := range streams {
+		if s != stream {
+			res = append(res, s)
+		}
+	}
 
-	return NewCachedEncodedMessage(&common.Reply{Identifier: identifier, Message: msg})
+	return res
 }
 
 func streamSessionsSnapshot(src map[string]map[string]bool) map[string][]string {
@@ -3,12 +3,20 @@ package node
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/anycable/anycable-go/common"
 	"github.com/anycable/anycable-go/encoders"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/tracing"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -149,15 +157,167 @@ func TestSubscribeSession(t *testing.T) {
 	})
 }
 
+func TestBroadcastOrder(t *testing.T) {
+	hub := NewHub(16)
+	node := NewMockNode()
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	sessionsNum := 2000
+	messagesNum := 100
+
+	sessions := make([]*Session, sessionsNum)
+
+	for i := 0; i < sessionsNum; i++ {
+		sid := fmt.Sprintf("%d", i)
+		session := NewMockSession(sid, &node)
+		sessions[i] = session
+
+		hub.addSession(session)
+		hub.subscribeSession(sid, "test", "test_channel")
+	}
+
+	for i := 0; i < messagesNum; i++ {
+		hub.Broadcast("test", fmt.Sprintf("%d", i))
+	}
+
+	for _, session := range sessions {
+		last := -1
+
+		for i := 0; i < messagesNum; i++ {
+			select {
+			case frame := <-session.sendCh:
+				var reply struct{ Message int }
+
+				assert.Nil(t, json.Unmarshal(frame.Payload, &reply))
+
+				if reply.Message <= last {
+					t.Fatalf("Session %s received messages out of order: %d after %d", session.UID, reply.Message, last)
+				}
+
+				last = reply.Message
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Session %s hasn't received message #%d", session.UID, i)
+			}
+		}
+	}
+}
+
+func TestStopStream(t *testing.T) {
+	hub := NewHub(2)
+	node := NewMockNode()
+
+	go hub.Run()
+	defer hub.Shutdown()
+
+	session := NewMockSession("123", &node)
+	session2 := NewMockSession("321", &node)
+
+	hub.addSession(session)
+	hub.subscribeSession("123", "room_42", "chat_channel")
+	hub.subscribeSession("123", "user_123", "chat_channel")
+
+	hub.addSession(session2)
+	hub.subscribeSession("321", "room_42", "room_channel")
+
+	hub.Broadcast("room_42", "\"hello\"")
+	hub.StopStream(&common.StopStreamMessage{Stream: "room_42", Data: "{\"closed\":true}", Notify: true})
+	hub.Broadcast("room_42", "\"stale\"")
+
+	for _, expected := range []string{
+		"{\"identifier\":\"chat_channel\",\"message\":\"hello\"}",
+		"{\"identifier\":\"chat_channel\",\"message\":{\"closed\":true}}",
+		"{\"type\":\"stream_stopped\",\"identifier\":\"chat_channel\",\"message\":{\"stream\":\"room_42\"}}",
+	} {
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(msg))
+	}
+
+	for _, expected := range []string{
+		"{\"identifier\":\"room_channel\",\"message\":\"hello\"}",
+		"{\"identifier\":\"room_channel\",\"message\":{\"closed\":true}}",
+		"{\"type\":\"stream_stopped\",\"identifier\":\"room_channel\",\"message\":{\"stream\":\"room_42\"}}",
+	} {
+		msg, err := session2.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(msg))
+	}
+
+	_, err := session.conn.Read()
+	assert.NotNil(t, err, "Messages to the stopped stream must not be delivered")
+
+	assert.Equal(t, 1, hub.StreamsSize())
+	assert.Equal(t, []string{"user_123"}, hub.subscriptionStreams("123", "chat_channel"))
+	assert.Equal(t, []string{}, hub.subscriptionStreams("321", "room_channel"))
+
+	// Other streams are not affected
+	hub.Broadcast("user_123", "\"hi\"")
+
+	msg, err := session.conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"identifier\":\"chat_channel\",\"message\":\"hi\"}", string(msg))
+
+	// Stopping unknown (or already stopped) streams is a no-op
+	hub.StopStream(&common.StopStreamMessage{Stream: "room_42", Notify: true})
+	hub.Broadcast("user_123", "\"bye\"")
+
+	msg, err = session.conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"identifier\":\"chat_channel\",\"message\":\"bye\"}", string(msg))
+}
+
+func TestDeliverToStreamTracing(t *testing.T) {
+	bodies := make(chan string, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer srv.Close()
+
+	config := tracing.NewConfig()
+	config.OTLPEndpoint = srv.URL
+	config.BroadcastThreshold = 2
+
+	tracer, err := tracing.NewTracer(&config, metrics.NewMetrics(nil, 0), "")
+	assert.Nil(t, err)
+
+	tracer.Run()
+
+	hub := NewHub(2)
+	hub.tracer = tracer
+	node := NewMockNode()
+
+	hub.addSession(NewMockSession("1", &node))
+	hub.subscribeSession("1", "small", "test_channel")
+	hub.subscribeSession("1", "large", "test_channel")
+
+	hub.addSession(NewMockSession("2", &node))
+	hub.subscribeSession("2", "large", "test_channel")
+
+	hub.deliverToStream(&common.StreamMessage{Stream: "small", Data: "1"})
+	hub.deliverToStream(&common.StreamMessage{Stream: "large", Data: "2"})
+
+	assert.Nil(t, tracer.Shutdown())
+
+	body := <-bodies
+
+	assert.Equal(t, 1, strings.Count(body, `"name":"anycable.broadcast"`))
+	assert.Contains(t, body, `{"key":"anycable.stream","value":{"stringValue":"large"}}`)
+	assert.Contains(t, body, `{"key":"anycable.stream.sessions","value":{"intValue":"2"}}`)
+}
+
 func TestBuildMessageJSON(t *testing.T) {
 	expected := []byte("{\"identifier\":\"chat\",\"message\":{\"text\":\"hello!\"}}")
-	actual := toJSON(buildMessage("{\"text\":\"hello!\"}", "chat"))
+	actual := toJSON(buildMessage(&common.StreamMessage{Data: "{\"text\":\"hello!\"}"}, "chat"))
 	assert.Equal(t, expected, actual)
 }
 
 func TestBuildMessageString(t *testing.T) {
 	expected := []byte("{\"identifier\":\"chat\",\"message\":\"plain string\"}")
-	actual := toJSON(buildMessage("\"plain string\"", "chat"))
+	actual := toJSON(buildMessage(&common.StreamMessage{Data: "\"plain string\""}, "chat"))
 	assert.Equal(t, expected, actual)
 }
 
@@ -260,3 +420,64 @@ func toJSON(msg encoders.EncodedMessage) []byte {
 
 	return b
 }
+
+func TestAddSessionWithLimit(t *testing.T) {
+	node := NewMockNode()
+
+	newSession := func(uid string, identifiers string) *Session {
+		session := NewMockSession(uid, &node)
+		session.Identifiers = identifiers
+		return session
+	}
+
+	t.Run("Rejects sessions over the limit", func(t *testing.T) {
+		hub := NewHub(2)
+
+		ok, _ := hub.addSessionWithLimit(newSession("1", "user_1"), 2, false)
+		assert.True(t, ok)
+
+		ok, _ = hub.addSessionWithLimit(newSession("2", "user_1"), 2, false)
+		assert.True(t, ok)
+
+		ok, _ = hub.addSessionWithLimit(newSession("3", "user_1"), 2, false)
+		assert.False(t, ok)
+
+		ok, _ = hub.addSessionWithLimit(newSession("4", "user_2"), 2, false)
+		assert.True(t, ok)
+
+		assert.Equal(t, 3, hub.Size())
+	})
+
+	t.Run("Doesn't limit anonymous sessions", func(t *testing.T) {
+		hub := NewHub(2)
+
+		for i := 0; i < 3; i++ {
+			ok, _ := hub.addSessionWithLimit(newSession(strconv.Itoa(i), ""), 1, false)
+			assert.True(t, ok)
+		}
+	})
+
+	t.Run("Kicks the oldest session", func(t *testing.T) {
+		hub := NewHub(2)
+
+		first := newSession("1", "user_1")
+		second := newSession("2", "user_1")
+
+		hub.addSessionWithLimit(first, 2, true)
+		hub.addSessionWithLimit(second, 2, true)
+
+		ok, kicked := hub.addSessionWithLimit(newSession("3", "user_1"), 2, true)
+		assert.True(t, ok)
+		assert.Equal(t, first, kicked)
+
+		ok, kicked = hub.addSessionWithLimit(newSession("4", "user_1"), 2, true)
+		assert.True(t, ok)
+		assert.Equal(t, second, kicked)
+
+		hub.removeSession(first)
+		hub.removeSession(second)
+
+		assert.Equal(t, 2, hub.Size())
+		assert.Equal(t, 1, hub.UniqSize())
+	})
+}
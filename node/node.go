@@ -1,22 +1,24 @@
 package node
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/anycable/anycable-go/common"
 	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/tracing"
+	"github.com/anycable/anycable-go/utils"
 	"github.com/anycable/anycable-go/ws"
 	"github.com/apex/log"
 )
 
 const (
-	// serverRestartReason is the disconnect reason on shutdown
-	serverRestartReason    = "server_restart"
-	remoteDisconnectReason = "remote"
-
 	metricsGoroutines      = "goroutines_num"
 	metricsMemSys          = "mem_sys_bytes"
 	metricsClientsNum      = "clients_num"
@@ -28,13 +30,38 @@ const (
 	metricsReceivedMsg           = "client_msg_total"
 	metricsFailedCommandReceived = "failed_client_msg_total"
 	metricsBroadcastMsg          = "broadcast_msg_total"
+	metricsStopStreamMsg         = "stop_stream_msg_total"
 	metricsUnknownBroadcast      = "failed_broadcast_msg_total"
+	metricsBroadcastErrors       = "broadcast_errors_total"
+	metricsBroadcastRejected     = "broadcasts_rejected_total"
+	metricsBroadcastDuplicates   = "broadcast_duplicates_total"
+	metricsBroadcastFanout       = "broadcast_fanout"
+	metricsBinaryDropped         = "binary_broadcast_dropped_total"
+	metricsMessageTooBig         = "client_msg_too_big_total"
+	metricsTooManyConnections    = "too_many_connections_total"
+	metricsDisconnectSkipped     = "disconnect_skipped_total"
+	metricsCommandsCancelled     = "commands_cancelled_total"
+	metricsSubscriptionsRejected = "subscriptions_rejected_total"
 
 	metricsSentMsg    = "server_msg_total"
 	metricsFailedSent = "failed_server_msg_total"
 
+	metricsStaleConnectionsClosed = "stale_connections_closed_total"
+	metricsExpiredSessions        = "sessions_expired_total"
+
 	metricsDataSent     = "data_sent_total"
 	metricsDataReceived = "data_rcvd_total"
+
+	metricsChannelSubscribe   = "channel_subscribe_total"
+	metricsChannelUnsubscribe = "channel_unsubscribe_total"
+	metricsChannelPerform     = "channel_perform_total"
+	metricsChannelFailures    = "channel_failures_total"
+
+	unknownChannel = "unknown"
+	// Per-channel metrics label for unconfirmed channels and channels above the limit
+	otherChannel = "other"
+	// The max number of distinct channels in per-channel metrics
+	perChannelMetricsMaxChannels = 100
 )
 
 // AppNode describes a basic node interface
@@ -48,7 +75,8 @@ type AppNode interface {
 	Disconnect(s *Session) error
 }
 
-// Connection represents underlying connection
+// Connection represents underlying connection.
+// Written messages could be shared between connections (e.g., ping frames), so implementations must not modify them.
 type Connection interface {
 	Write(msg []byte, deadline time.Time) error
 	WriteBinary(msg []byte, deadline time.Time) error
@@ -56,16 +84,68 @@ type Connection interface {
 	Close(code int, reason string)
 }
 
+// Pinger is implemented by connections supporting protocol-level pings
+// (e.g., WebSocket ping/pong frames)
+type Pinger interface {
+	WritePing(deadline time.Time) error
+	OnPong(callback func())
+}
+
+// PooledReader is implemented by connections reading messages into pooled buffers (see utils.BytesPool).
+// Such connections must not retain written messages after Write returns, so the sessions could use pooled buffers
+// for outgoing transmissions, too.
+type PooledReader interface {
+	ReadPooled() ([]byte, error)
+}
+
 // Node represents the whole application
 type Node struct {
+	// Ping interval (in seconds) for new sessions (could be updated at runtime).
+	// Must be the first field to be 64-bit aligned
+	pingInterval int64
+	// The number of sessions holding capacity slots (see Config.MaxSessions); accessed atomically
+	sessionsNum int64
+	// Whether the sessions soft limit warning has been logged since the last crossing
+	softLimitWarned int32
+	// Whether the node has been shut down; accessed atomically
+	closed int32
+
 	Metrics *metrics.Metrics
 
 	config       *Config
+	id           string
 	hub          *Hub
 	controller   Controller
 	disconnector Disconnector
+	broker       *Broker
 	shutdownCh   chan struct{}
 	log          *log.Entry
+
+	// Broadcasts must pass all the filters to be delivered
+	broadcastFilters []BroadcastFilter
+	// Drops broadcasts with already seen IDs (nil unless enabled)
+	broadcastDedup *BroadcastDeduplicator
+	// Session store keys to pass to RPC
+	sessionStoreRPCKeys []string
+	// Encoded ping messages shared by sessions
+	pingFrames *pingFrames
+	// Session lifecycle events emitter (nil unless enabled)
+	events *EventsEmitter
+	// Extra fields for welcome messages: static ones and per-session ones (see SetWelcomeComposer)
+	welcomeExtra    map[string]interface{}
+	welcomeComposer WelcomeComposer
+	// Extra fields for disconnect messages per reason
+	disconnectExtra map[string]map[string]interface{}
+	// Commands rate limits (subscribe/unsubscribe, perform and per-channel perform)
+	subscribeLimit CommandsRateLimit
+	performLimit   CommandsRateLimit
+	channelLimits  map[string]CommandsRateLimit
+	// Streams namespace prefix (empty unless the namespace is configured)
+	streamsPrefix string
+	// Channels confirmed by successful subscriptions (used as per-channel metrics labels)
+	metricsChannels *metricsChannels
+	// Custom controllers by name (see RegisterController)
+	controllers map[string]Controller
 }
 
 var _ AppNode = (*Node)(nil)
@@ -73,17 +153,42 @@ var _ AppNode = (*Node)(nil)
 // NewNode builds new node struct
 func NewNode(controller Controller, metrics *metrics.Metrics, config *Config) *Node {
 	node := &Node{
-		Metrics:    metrics,
-		config:     config,
-		controller: controller,
-		shutdownCh: make(chan struct{}),
-		log:        log.WithFields(log.Fields{"context": "node"}),
+		Metrics:     metrics,
+		config:      config,
+		controller:  controller,
+		controllers: make(map[string]Controller),
+		shutdownCh:  make(chan struct{}),
+		log:         log.WithFields(log.Fields{"context": "node"}),
 	}
 
+	node.pingInterval = int64(config.PingInterval)
+	node.sessionStoreRPCKeys = common.ParseSessionStoreKeys(config.SessionStoreRPCKeys)
+
 	node.hub = NewHub(config.HubGopoolSize)
+	node.pingFrames = newPingFrames()
+
+	if config.HistoryLimit > 0 {
+		node.broker = NewBroker(config, metrics)
+		node.hub.broker = node.broker
+	}
+
+	// Extras must be validated by the caller
+	node.welcomeExtra, _ = ParseWelcomeExtra(config.WelcomeExtra)
+	node.disconnectExtra, _ = ParseDisconnectExtra(config.DisconnectExtra)
+
+	node.subscribeLimit = CommandsRateLimit{Rate: config.CommandsRateLimitSubscribe, Burst: config.CommandsRateLimitSubscribeBurst}
+	node.performLimit = CommandsRateLimit{Rate: config.CommandsRateLimitPerform, Burst: config.CommandsRateLimitPerformBurst}
+	// Must be validated by the caller
+	node.channelLimits, _ = ParseCommandsRateLimitChannels(config.CommandsRateLimitChannels)
+
+	node.streamsPrefix = StreamsNamespacePrefix(config.StreamsNamespace)
+	node.hub.streamsPrefix = node.streamsPrefix
+	node.metricsChannels = &metricsChannels{channels: make(map[string]struct{})}
 
 	node.registerMetrics()
 
+	node.hub.fanoutTiming = metrics.Timing(metricsBroadcastFanout)
+
 	return node
 }
 
@@ -95,15 +200,71 @@ func (n *Node) Start() error {
 	return nil
 }
 
+// SetPingInterval updates the ping interval (in seconds) for new sessions
+func (n *Node) SetPingInterval(interval int) {
+	atomic.StoreInt64(&n.pingInterval, int64(interval))
+}
+
+// PingInterval returns the current ping interval for new sessions
+func (n *Node) PingInterval() time.Duration {
+	return time.Duration(atomic.LoadInt64(&n.pingInterval)) * time.Second
+}
+
+// SetID sets the node identifier (included into welcome messages, session events and debug info)
+func (n *Node) SetID(id string) {
+	n.id = id
+}
+
+// SetTracer sets the tracer used to trace broadcasts fan-out
+// (must be called before the node starts accepting broadcasts)
+func (n *Node) SetTracer(t *tracing.Tracer) {
+	n.hub.tracer = t
+}
+
+// ID returns the node identifier
+func (n *Node) ID() string {
+	return n.id
+}
+
+// Size returns the number of active sessions
+func (n *Node) Size() int {
+	return n.hub.Size()
+}
+
 // SetDisconnector set disconnector for the node
 func (n *Node) SetDisconnector(d Disconnector) {
 	n.disconnector = d
 }
 
+// AddBroadcastFilter adds a filter for incoming broadcasts (must be called before the node starts receiving them)
+func (n *Node) AddBroadcastFilter(f BroadcastFilter) {
+	n.broadcastFilters = append(n.broadcastFilters, f)
+}
+
+// SetEventsEmitter sets the session lifecycle events emitter (must be called before the node starts accepting sessions)
+func (n *Node) SetEventsEmitter(e *EventsEmitter) {
+	n.events = e
+}
+
+// EnableBroadcastDedup makes the node drop broadcasts with the recently seen IDs
+// (must be called before the node starts receiving them)
+func (n *Node) EnableBroadcastDedup() {
+	n.broadcastDedup = NewBroadcastDeduplicator(n.config.BroadcastDedupSize, time.Duration(n.config.BroadcastDedupTTL)*time.Second)
+}
+
 // HandleCommand parses incoming message from client and
 // execute the command (if recognized)
 func (n *Node) HandleCommand(s *Session, msg *common.Message) (err error) {
-	s.Log.Debugf("Incoming message: %s", msg)
+	s.Log.Debugf("Incoming message: %v", msg)
+
+	if !n.allowCommand(s, msg) {
+		return
+	}
+
+	if err = n.reauthenticate(s); err != nil {
+		return
+	}
+
 	switch msg.Command {
 	case "subscribe":
 		_, err = n.Subscribe(s, msg)
@@ -111,6 +272,12 @@ func (n *Node) HandleCommand(s *Session, msg *common.Message) (err error) {
 		_, err = n.Unsubscribe(s, msg)
 	case "message":
 		_, err = n.Perform(s, msg)
+	case "history":
+		err = n.History(s, msg)
+	case "ack":
+		err = n.Ack(s, msg)
+	case "refresh":
+		err = n.Refresh(s, msg)
 	default:
 		err = fmt.Errorf("Unknown command: %s", msg.Command)
 	}
@@ -118,22 +285,87 @@ func (n *Node) HandleCommand(s *Session, msg *common.Message) (err error) {
 	return
 }
 
-// HandlePubSub parses incoming pubsub message and broadcast it
+// HandlePubSub parses incoming pubsub message and broadcast it.
+// Failures are logged and tracked and never affect the subsequent messages
 func (n *Node) HandlePubSub(raw []byte) {
+	n.HandlePubSubFrom("", raw)
+}
+
+// HandlePubSubFrom parses a pubsub message received from the specified origin (broadcasting adapter)
+// and handles it if it passes broadcast filters
+func (n *Node) HandlePubSubFrom(origin string, raw []byte) {
 	msg, err := common.PubSubMessageFromJSON(raw)
 
 	if err != nil {
 		n.Metrics.Counter(metricsUnknownBroadcast).Inc()
-		n.log.Warnf("Failed to parse pubsub message '%s' with error: %v", raw, err)
+		n.broadcastFailed(raw, err)
 		return
 	}
 
 	switch v := msg.(type) {
 	case common.StreamMessage:
+		if !n.inNamespace(v.Stream, raw) || n.isDuplicate(v.BroadcastID) || !n.allowBroadcast(origin, &v, raw) {
+			return
+		}
+
 		n.Broadcast(&v)
 	case common.RemoteDisconnectMessage:
+		if !n.inNamespace(v.Identifier, raw) {
+			return
+		}
+
+		v.Identifier = n.hub.clientStream(v.Identifier)
 		n.RemoteDisconnect(&v)
+	case common.StopStreamMessage:
+		if !n.inNamespace(v.Stream, raw) || n.isDuplicate(v.BroadcastID) || !n.allowBroadcast(origin, &common.StreamMessage{Stream: v.Stream, Data: v.Data}, raw) {
+			return
+		}
+
+		n.StopStream(&v)
+	}
+}
+
+// isDuplicate returns true if the broadcast with the same ID has been already handled
+func (n *Node) isDuplicate(id string) bool {
+	if n.broadcastDedup == nil || id == "" {
+		return false
+	}
+
+	if n.broadcastDedup.Seen(id) {
+		n.Metrics.Counter(metricsBroadcastDuplicates).Inc()
+		return true
+	}
+
+	return false
+}
+
+// allowBroadcast returns false if the message has been rejected by any of the filters
+// (filters receive the stream names without the namespace prefix)
+func (n *Node) allowBroadcast(origin string, msg *common.StreamMessage, raw []byte) bool {
+	if len(n.broadcastFilters) == 0 {
+		return true
+	}
+
+	filtered := n.hub.clientMessage(msg)
+
+	for _, f := range n.broadcastFilters {
+		if !f.AllowBroadcast(origin, filtered) {
+			n.Metrics.Counter(metricsBroadcastRejected).Inc()
+			n.log.WithFields(log.Fields{
+				"origin":  origin,
+				"stream":  msg.Stream,
+				"payload": utils.TruncateBytes(raw, utils.MaxLoggedPayloadSize),
+			}).Warnf("Broadcast rejected by filter")
+			return false
+		}
 	}
+
+	return true
+}
+
+func (n *Node) broadcastFailed(raw []byte, err error) {
+	n.Metrics.Counter(metricsBroadcastErrors).Inc()
+	n.log.WithField("payload", utils.TruncateBytes(raw, utils.MaxLoggedPayloadSize)).Warnf("Failed to handle pubsub message: %v", err)
 }
 
 func (n *Node) LookupSession(id string) *Session {
@@ -142,12 +374,11 @@ func (n *Node) LookupSession(id string) *Session {
 
 // Shutdown stops all services (hub, controller)
 func (n *Node) Shutdown() (err error) {
-	if n.shutdownCh == nil {
+	if !atomic.CompareAndSwapInt32(&n.closed, 0, 1) {
 		return errors.New("Already shut down")
 	}
 
 	close(n.shutdownCh)
-	n.shutdownCh = nil
 
 	if n.hub != nil {
 		n.hub.Shutdown()
@@ -161,7 +392,7 @@ func (n *Node) Shutdown() (err error) {
 			n.hub.sessionsMu.RLock()
 			for _, session := range n.hub.sessions {
 				session.Send(disconnectMessage)
-				session.Disconnect("Shutdown", ws.CloseGoingAway)
+				session.Disconnect(serverRestartReason, closeCode(serverRestartReason))
 			}
 			n.hub.sessionsMu.RUnlock()
 
@@ -194,31 +425,135 @@ func (n *Node) Shutdown() (err error) {
 // Authenticate calls controller to perform authentication.
 // If authentication is successful, session is registered with a hub.
 func (n *Node) Authenticate(s *Session) (res *common.ConnectResult, err error) {
-	res, err = n.controller.Authenticate(s.UID, s.env)
+	if !n.reserveSessionSlot(s) {
+		return n.rejectServerFull(s), nil
+	}
+
+	n.emitOpenedEvent(s)
+
+	defer func() {
+		// Only authenticated sessions occupy slots
+		if err != nil || res == nil || res.Status != common.SUCCESS {
+			n.releaseSessionSlot(s)
+		}
+	}()
+
+	defer func() { n.trackAuthentication(s, res, err) }()
+
+	if n.config.RPCUnavailableStrategy == RPCUnavailableQueue {
+		n.waitForController(s)
+	}
+
+	res, err = n.controllerFor(s).Authenticate(s.UID, s.env)
 
 	if err != nil {
-		s.Disconnect("Auth Error", ws.CloseInternalServerErr)
+		if errors.Is(err, common.ErrRPCUnavailable) {
+			return n.handleRPCUnavailable(s, err)
+		}
+
+		s.Disconnect(serverErrorReason, closeCode(serverErrorReason))
 		return
 	}
 
 	if res.Status == common.SUCCESS {
 		s.Identifiers = res.Identifier
+
+		if res.DisconnectMode != "" {
+			if common.ValidDisconnectMode(res.DisconnectMode) {
+				s.disconnectMode = res.DisconnectMode
+			} else {
+				s.Log.Warnf("Unknown disconnect mode: %s", res.DisconnectMode)
+			}
+		}
+
+		if !n.registerSession(s) {
+			res.Status = common.FAILURE
+			return
+		}
+
 		s.Connected = true
 
-		n.hub.addSession(s)
+		n.scheduleExpiryWarning(s, res.ExpiresAt)
+
+		if n.events != nil {
+			n.events.Emit(newSessionEvent(EventSessionAuthenticated, s))
+		}
 	} else {
 		if res.Status == common.FAILURE {
 			n.Metrics.Counter(metricsFailedAuths).Inc()
 		}
 
-		defer s.Disconnect("Auth Failed", ws.CloseNormalClosure)
+		defer s.Disconnect(unauthorizedReason, closeCode(unauthorizedReason))
 	}
 
+	res.Transmissions = n.composeWelcome(s, res.Transmissions)
+
 	n.handleCallReply(s, res.ToCallResult())
 
 	return
 }
 
+// emitOpenedEvent emits the opened event and starts tracking the session lifecycle events
+func (n *Node) emitOpenedEvent(s *Session) {
+	if n.events == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.eventsTracked = true
+	s.mu.Unlock()
+
+	n.events.Emit(newSessionEvent(EventSessionOpened, s))
+}
+
+func (n *Node) emitClosedEvent(s *Session) {
+	event := newSessionEvent(EventSessionClosed, s)
+
+	s.mu.Lock()
+	event.Reason = s.closeReason
+	event.Code = s.closeCode
+	s.mu.Unlock()
+
+	event.Duration = int64(time.Since(s.CreatedAt) / time.Millisecond)
+
+	n.events.Emit(event)
+}
+
+// registerSession adds an authenticated session to the hub respecting the connections per identifier limit.
+// Returns false if the session has been rejected.
+func (n *Node) registerSession(s *Session) bool {
+	if n.config.MaxConnectionsPerIdentifier <= 0 {
+		n.hub.addSession(s)
+		return true
+	}
+
+	kickOldest := n.config.ConnectionsLimitMode == ConnectionsLimitKickOldest
+
+	ok, kicked := n.hub.addSessionWithLimit(s, n.config.MaxConnectionsPerIdentifier, kickOldest)
+
+	if !ok {
+		n.Metrics.Counter(metricsTooManyConnections).Inc()
+		s.Log.Debugf("Connection rejected: too many connections for %s", s.Identifiers)
+
+		// Connect has been performed, so we must notify the application about disconnect
+		n.disconnector.Enqueue(s) // nolint:errcheck
+
+		s.Send(newDisconnectMessage(tooManyConnectionsReason, false))
+		s.Disconnect(tooManyConnectionsReason, closeCode(tooManyConnectionsReason))
+		return false
+	}
+
+	if kicked != nil {
+		n.Metrics.Counter(metricsTooManyConnections).Inc()
+		kicked.Log.Debugf("Connection closed: too many connections for %s", kicked.Identifiers)
+
+		kicked.Send(newDisconnectMessage(tooManyConnectionsReason, false))
+		kicked.Disconnect(tooManyConnectionsReason, closeCode(tooManyConnectionsReason))
+	}
+
+	return true
+}
+
 // Subscribe subscribes session to a channel
 func (n *Node) Subscribe(s *Session, msg *common.Message) (res *common.CommandResult, err error) {
 	s.smu.Lock()
@@ -229,7 +564,24 @@ func (n *Node) Subscribe(s *Session, msg *common.Message) (res *common.CommandRe
 		return
 	}
 
-	res, err = n.controller.Subscribe(s.UID, s.env, s.Identifiers, msg.Identifier)
+	res, err = n.controllerSubscribe(s, msg.Identifier)
+
+	n.trackChannelCommand(metricsChannelSubscribe, msg.Identifier, res, err)
+
+	if n.commandCancelled(s, "subscribe", msg.Identifier) {
+		// Keep the subscription (if any) to pass it to the Disconnect call
+		if err == nil && res.Status == common.SUCCESS {
+			s.subscriptions[msg.Identifier] = true
+		}
+
+		s.smu.Unlock()
+		n.handleCancelledReply(s, res)
+		return nil, nil
+	}
+
+	if reason := rejectionReason(res, err); reason != "" {
+		n.trackRejection(s, msg.Identifier, reason)
+	}
 
 	if err != nil {
 		if res == nil || res.Status == common.ERROR {
@@ -238,6 +590,15 @@ func (n *Node) Subscribe(s *Session, msg *common.Message) (res *common.CommandRe
 	} else {
 		s.subscriptions[msg.Identifier] = true
 		s.Log.Debugf("Subscribed to channel: %s", msg.Identifier)
+
+		if res.Status == common.SUCCESS {
+			s.everSubscribed = true
+		}
+
+		// Must be enabled before subscribing to streams
+		if msg.Reliable && res.Status == common.SUCCESS && n.config.ReliableBufferSize > 0 {
+			s.EnableReliableDelivery(msg.Identifier)
+		}
 	}
 
 	s.smu.Unlock()
@@ -246,9 +607,113 @@ func (n *Node) Subscribe(s *Session, msg *common.Message) (res *common.CommandRe
 		n.handleCommandReply(s, msg, res)
 	}
 
+	// Protocol v1.1 clients could request the history along with subscription
+	if err == nil && res != nil && res.Status == common.SUCCESS && msg.History != nil && s.ProtocolVersion == ws.ProtocolV11 {
+		n.replayHistory(s, msg.Identifier, res.Streams, msg.History)
+	}
+
 	return
 }
 
+// Ack handles the message acknowledgement for subscriptions with reliable delivery
+func (n *Node) Ack(s *Session, msg *common.Message) error {
+	reliable := s.reliableDeliveryFor(msg.Identifier)
+
+	if reliable == nil {
+		return fmt.Errorf("Reliable delivery is not enabled for %s", msg.Identifier)
+	}
+
+	if !reliable.ack(msg.ID) {
+		s.Log.Debugf("Unknown message acknowledged: %d", msg.ID)
+	}
+
+	return nil
+}
+
+// History replays the subscription streams messages from the history
+func (n *Node) History(s *Session, msg *common.Message) error {
+	s.smu.Lock()
+	_, ok := s.subscriptions[msg.Identifier]
+	s.smu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("Unknown subscription %s", msg.Identifier)
+	}
+
+	if msg.History == nil {
+		return errors.New("History request is missing")
+	}
+
+	n.replayHistory(s, msg.Identifier, n.hub.subscriptionStreams(s.UID, msg.Identifier), msg.History)
+
+	return nil
+}
+
+// replayHistory sends the requested streams messages followed by the confirmation.
+// If any stream history couldn't be provided, nothing is sent and the request is rejected.
+// Live broadcasts for the subscription are held during the replay and delivered after the reply
+// (skipping the already replayed ones).
+func (n *Node) replayHistory(s *Session, identifier string, streams []string, req *common.HistoryRequest) {
+	if n.broker == nil {
+		s.Log.Debugf("History is requested for %s but not enabled", identifier)
+		sendHistoryReply(s, identifier, common.HistoryRejectedType)
+		return
+	}
+
+	messages := []*common.StreamMessage{}
+	replayed := make(map[string]uint64)
+
+	s.replays.start(identifier)
+	defer s.replays.finish(identifier, n.broker.Epoch(), replayed, func(msg *common.StreamMessage) {
+		s.deliverBroadcast(msg, identifier)
+	})
+
+	for _, stream := range streams {
+		var entries []historyEntry
+
+		// Clients use the stream names without the namespace prefix
+		if pos, ok := req.Streams[n.hub.clientStream(stream)]; ok {
+			var err error
+
+			entries, err = n.broker.HistoryFrom(stream, pos.Epoch, pos.Offset)
+
+			if err != nil {
+				s.Log.Debugf("Failed to fetch history for %s: %v", stream, err)
+				n.Metrics.Counter(metricsHistoryRejected).Inc()
+				sendHistoryReply(s, identifier, common.HistoryRejectedType)
+				return
+			}
+		} else if req.Since > 0 {
+			entries = n.broker.HistorySince(stream, req.Since)
+		}
+
+		for _, entry := range entries {
+			messages = append(messages, &common.StreamMessage{Stream: n.hub.clientStream(stream), Data: entry.data, Offset: entry.offset, Epoch: n.broker.Epoch()})
+		}
+	}
+
+	for _, msg := range messages {
+		replayed[msg.Stream] = msg.Offset
+	}
+
+	for _, msg := range messages {
+		s.Send(buildMessage(msg, identifier))
+	}
+
+	n.Metrics.Counter(metricsHistoryReplayed).Add(uint64(len(messages)))
+
+	sendHistoryReply(s, identifier, common.HistoryConfirmedType)
+}
+
+func sendHistoryReply(s *Session, identifier string, replyType string) {
+	reply, _ := json.Marshal(struct {
+		Type       string `json:"type"`
+		Identifier string `json:"identifier"`
+	}{replyType, identifier})
+
+	s.SendJSONTransmission(string(reply))
+}
+
 // Unsubscribe unsubscribes session from a channel
 func (n *Node) Unsubscribe(s *Session, msg *common.Message) (res *common.CommandResult, err error) {
 	s.smu.Lock()
@@ -259,7 +724,9 @@ func (n *Node) Unsubscribe(s *Session, msg *common.Message) (res *common.Command
 		return
 	}
 
-	res, err = n.controller.Unsubscribe(s.UID, s.env, s.Identifiers, msg.Identifier)
+	res, err = n.controllerFor(s).Unsubscribe(s.UID, s.env, s.Identifiers, msg.Identifier)
+
+	n.trackChannelCommand(metricsChannelUnsubscribe, msg.Identifier, res, err)
 
 	if err != nil {
 		if res == nil || res.Status == common.ERROR {
@@ -271,6 +738,10 @@ func (n *Node) Unsubscribe(s *Session, msg *common.Message) (res *common.Command
 
 		delete(s.subscriptions, msg.Identifier)
 
+		if reliable := s.reliableDeliveryFor(msg.Identifier); reliable != nil {
+			reliable.unsubscribe(msg.Identifier)
+		}
+
 		s.Log.Debugf("Unsubscribed from channel: %s", msg.Identifier)
 	}
 
@@ -302,7 +773,14 @@ func (n *Node) Perform(s *Session, msg *common.Message) (res *common.CommandResu
 		return
 	}
 
-	res, err = n.controller.Perform(s.UID, s.env, s.Identifiers, msg.Identifier, data)
+	res, err = n.controllerPerform(s, msg.Identifier, data)
+
+	n.trackChannelCommand(metricsChannelPerform, msg.Identifier, res, err)
+
+	if n.commandCancelled(s, "perform", msg.Identifier) {
+		n.handleCancelledReply(s, res)
+		return nil, nil
+	}
 
 	if err != nil {
 		if res == nil || res.Status == common.ERROR {
@@ -319,16 +797,124 @@ func (n *Node) Perform(s *Session, msg *common.Message) (res *common.CommandResu
 	return
 }
 
+// controllerSubscribe performs the Subscribe call bound to the session lifecycle (if the controller supports it)
+func (n *Node) controllerSubscribe(s *Session, identifier string) (*common.CommandResult, error) {
+	controller := n.controllerFor(s)
+
+	if cc, ok := controller.(CancelableController); ok && s.ctx != nil {
+		return cc.SubscribeContext(s.ctx, s.UID, s.env, s.Identifiers, identifier)
+	}
+
+	return controller.Subscribe(s.UID, s.env, s.Identifiers, identifier)
+}
+
+// controllerPerform performs the Perform call bound to the session lifecycle (if the controller supports it)
+func (n *Node) controllerPerform(s *Session, identifier string, data string) (*common.CommandResult, error) {
+	controller := n.controllerFor(s)
+
+	if cc, ok := controller.(CancelableController); ok && s.ctx != nil {
+		return cc.PerformContext(s.ctx, s.UID, s.env, s.Identifiers, identifier, data)
+	}
+
+	return controller.Perform(s.UID, s.env, s.Identifiers, identifier, data)
+}
+
+// RegisterController adds a custom controller which could be used by sessions (see Session.UseController).
+// Controllers are referenced by names in the persisted sessions data (e.g., pending Disconnect calls),
+// so they must be registered before the data is restored and sessions are served.
+func (n *Node) RegisterController(name string, c Controller) {
+	n.controllers[name] = c
+}
+
+// controllerFor returns the controller to perform the session commands
+func (n *Node) controllerFor(s *Session) Controller {
+	if s.controller != nil {
+		return s.controller
+	}
+
+	return n.controller
+}
+
+func withCustomControllers(sessions []*Session) bool {
+	for _, s := range sessions {
+		if s.controller != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// commandCancelled returns true if the session has been closed while the command was in flight.
+// Only the parts of the result not addressed to the client must be handled in this case (see handleCancelledReply).
+func (n *Node) commandCancelled(s *Session, command string, identifier string) bool {
+	if s.ctx == nil || s.ctx.Err() == nil {
+		return false
+	}
+
+	n.Metrics.Counter(metricsCommandsCancelled).Inc()
+	s.Log.Debugf("Session has been closed during %s, discarding the result: %s", command, identifier)
+
+	return true
+}
+
+// handleCancelledReply handles the result of the command cancelled due to the session close:
+// broadcasts are still delivered to other clients and the connection state is kept for the Disconnect call,
+// transmissions are dropped (there is no client to send them to)
+func (n *Node) handleCancelledReply(s *Session, reply *common.CommandResult) {
+	if reply == nil {
+		return
+	}
+
+	res := reply.ToCallResult()
+	res.Transmissions = nil
+
+	n.handleCallReply(s, res)
+}
+
 // Broadcast message to stream
 func (n *Node) Broadcast(msg *common.StreamMessage) {
 	n.Metrics.Counter(metricsBroadcastMsg).Inc()
 	n.log.Debugf("Incoming pubsub message: %v", msg)
+
+	if msg.Binary != nil && !n.prepareBinaryBroadcast(msg) {
+		return
+	}
+
 	n.hub.BroadcastMessage(msg)
 }
 
-// Disconnect adds session to disconnector queue and unregister session from hub
+// prepareBinaryBroadcast converts the binary payload according to the binary broadcasts mode.
+// All sessions use JSON, so the payload is either dropped or sent as a base64-encoded string.
+// Returns false if the message must be dropped.
+func (n *Node) prepareBinaryBroadcast(msg *common.StreamMessage) bool {
+	if n.config.BinaryBroadcasts != BinaryBroadcastsBase64 {
+		n.Metrics.Counter(metricsBinaryDropped).Inc()
+		n.log.Debugf("Binary broadcast to %s dropped (%d bytes)", msg.Stream, len(msg.Binary))
+		return false
+	}
+
+	// Marshaling a string never fails
+	encoded, _ := json.Marshal(base64.StdEncoding.EncodeToString(msg.Binary))
+
+	msg.Data = string(encoded)
+	msg.Binary = nil
+
+	return true
+}
+
+// Disconnect adds session to disconnector queue and unregister session from hub.
+// The Disconnect call is skipped if the session disconnect mode doesn't require it.
 func (n *Node) Disconnect(s *Session) error {
 	n.hub.RemoveSession(s)
+
+	if !s.disconnectRequired() {
+		n.Metrics.Counter(metricsDisconnectSkipped).Inc()
+		s.Log.Debugf("Disconnect call skipped (mode: %s)", s.disconnectMode)
+		s.clearStore()
+		return nil
+	}
+
 	return n.disconnector.Enqueue(s)
 }
 
@@ -338,7 +924,7 @@ func (n *Node) DisconnectNow(s *Session) error {
 
 	s.Log.Debugf("Disconnect %s %s %v %v", s.Identifiers, s.env.URL, s.env.Headers, sessionSubscriptions)
 
-	err := n.controller.Disconnect(
+	err := n.controllerFor(s).Disconnect(
 		s.UID,
 		s.env,
 		s.Identifiers,
@@ -349,9 +935,55 @@ func (n *Node) DisconnectNow(s *Session) error {
 		s.Log.Errorf("Disconnect error: %v", err)
 	}
 
+	s.clearStore()
+
 	return err
 }
 
+// DisconnectNowBatch executes disconnect for multiple sessions on controller.
+// Uses a single call if controller supports batching and fallbacks to one-by-one calls otherwise
+// (including batches with sessions using custom controllers).
+// Returns the list of errors corresponding to the sessions.
+func (n *Node) DisconnectNowBatch(sessions []*Session) []error {
+	bc, ok := n.controller.(BatchDisconnectController)
+
+	if !ok || len(sessions) == 1 || withCustomControllers(sessions) {
+		errs := make([]error, len(sessions))
+
+		for i, s := range sessions {
+			errs[i] = n.DisconnectNow(s)
+		}
+		return errs
+	}
+
+	requests := make([]*common.DisconnectRequest, len(sessions))
+
+	for i, s := range sessions {
+		requests[i] = &common.DisconnectRequest{
+			UID:           s.UID,
+			Env:           s.env,
+			Identifiers:   s.Identifiers,
+			Subscriptions: subscriptionsList(s.subscriptions),
+		}
+	}
+
+	n.log.Debugf("Disconnect batch of %d sessions", len(requests))
+
+	errs := bc.DisconnectBatch(requests)
+
+	for i, err := range errs {
+		if err != nil && i < len(sessions) {
+			sessions[i].Log.Errorf("Disconnect error: %v", err)
+		}
+	}
+
+	for _, s := range sessions {
+		s.clearStore()
+	}
+
+	return errs
+}
+
 // RemoteDisconnect find a session by identifier and closes it
 func (n *Node) RemoteDisconnect(msg *common.RemoteDisconnectMessage) {
 	n.Metrics.Counter(metricsBroadcastMsg).Inc()
@@ -359,6 +991,14 @@ func (n *Node) RemoteDisconnect(msg *common.RemoteDisconnectMessage) {
 	n.hub.RemoteDisconnect(msg)
 }
 
+// StopStream unsubscribes all the sessions from the stream.
+// No Unsubscribe calls are made (the stop is initiated by the application)
+func (n *Node) StopStream(msg *common.StopStreamMessage) {
+	n.Metrics.Counter(metricsStopStreamMsg).Inc()
+	n.log.Debugf("Incoming pubsub command: %v", msg)
+	n.hub.StopStream(msg)
+}
+
 func transmit(s *Session, transmissions []string) {
 	for _, msg := range transmissions {
 		s.SendJSONTransmission(msg)
@@ -370,6 +1010,10 @@ func (n *Node) handleCommandReply(s *Session, msg *common.Message, reply *common
 		defer s.Disconnect("Command Failed", ws.CloseAbnormalClosure)
 	}
 
+	// Stream names are rewritten in place, so the history replay uses the same names as the hub
+	reply.Streams = n.namespacedStreams(reply.Streams)
+	reply.StoppedStreams = n.namespacedStreams(reply.StoppedStreams)
+
 	if reply.StopAllStreams {
 		n.hub.RemoveAllSubscriptions(s.UID, msg.Identifier)
 	} else if reply.StoppedStreams != nil {
@@ -402,7 +1046,7 @@ func (n *Node) handleCallReply(s *Session, reply *common.CallResult) {
 
 	if reply.Broadcasts != nil {
 		for _, broadcast := range reply.Broadcasts {
-			n.Broadcast(broadcast)
+			n.Broadcast(n.namespacedBroadcast(broadcast))
 		}
 	}
 
@@ -435,6 +1079,7 @@ func (n *Node) collectStatsOnce() {
 	n.Metrics.Gauge(metricsUniqClientsNum).Set(n.hub.UniqSize())
 	n.Metrics.Gauge(metricsStreamsNum).Set(n.hub.StreamsSize())
 	n.Metrics.Gauge(metricsDisconnectQueue).Set(n.disconnector.Size())
+	n.Metrics.Gauge(metricsSessionsReserved).Set(int(atomic.LoadInt64(&n.sessionsNum)))
 }
 
 func (n *Node) registerMetrics() {
@@ -447,16 +1092,146 @@ func (n *Node) registerMetrics() {
 	n.Metrics.RegisterGauge(metricsDisconnectQueue, "The size of delayed disconnect")
 
 	n.Metrics.RegisterCounter(metricsFailedAuths, "The total number of failed authentication attempts")
+	n.Metrics.RegisterCounterVec(metricsHandshakes, "The total number of connection attempts per stage", "stage")
+	n.Metrics.RegisterHistogram(metricsTimeToWelcome, "The time from accepting a connection to sending the welcome message", nil)
+	n.Metrics.RegisterCounter(metricsRPCUnavailableAuths, "The total number of sessions failed to authenticate due to unavailable RPC")
+	n.Metrics.RegisterCounterVec(metricsAnonymousReauths, "The total number of anonymous sessions re-authentication attempts", "result")
+	n.Metrics.RegisterCounter(metricsRefreshes, "The total number of refreshed session credentials")
+	n.Metrics.RegisterCounter(metricsFailedRefreshes, "The total number of rejected session credentials refreshes")
+	n.Metrics.RegisterCounter(metricsExpiryWarnings, "The total number of credentials expiration warnings sent to clients")
+	n.Metrics.RegisterCounter(metricsSessionsRejectedCapacity, "The total number of sessions rejected due to the max sessions limit")
+	n.Metrics.RegisterGauge(metricsSessionsReserved, "The number of sessions counted towards the max sessions limit")
+	n.Metrics.RegisterCounter(metricsCommandsRateLimited, "The total number of commands rejected due to the session commands rate limit")
+	n.Metrics.RegisterCounter(metricsTooManyConnections, "The total number of connections rejected or closed due to the connections per identifier limit")
+	n.Metrics.RegisterCounter(metricsCommandsCancelled, "The total number of in-flight commands cancelled (or discarded) due to the session close")
+	n.Metrics.RegisterCounter(metricsDisconnectSkipped, "The total number of closed sessions which didn't require Disconnect RPC calls")
 	n.Metrics.RegisterCounter(metricsReceivedMsg, "The total number of received messages from clients")
 	n.Metrics.RegisterCounter(metricsFailedCommandReceived, "The total number of unrecognized messages received from clients")
 	n.Metrics.RegisterCounter(metricsBroadcastMsg, "The total number of messages received through PubSub (for broadcast)")
+	n.Metrics.RegisterCounter(metricsStopStreamMsg, "The total number of stop_stream commands received through PubSub")
 	n.Metrics.RegisterCounter(metricsUnknownBroadcast, "The total number of unrecognized messages received through PubSub")
+	n.Metrics.RegisterCounter(metricsBroadcastDuplicates, "The total number of duplicate broadcasts dropped")
+	n.Metrics.RegisterCounter(metricsBroadcastErrors, "The total number of PubSub messages failed to be handled")
+	n.Metrics.RegisterCounter(metricsBroadcastRejected, "The total number of PubSub messages rejected by broadcast filters")
+	n.Metrics.RegisterTiming(metricsBroadcastFanout, "The time to deliver a broadcast to all the stream subscribers during the last interval")
+	n.Metrics.RegisterCounter(metricsBinaryDropped, "The total number of binary broadcasts dropped (not delivered to JSON clients)")
+	n.Metrics.RegisterCounter(metricsMessageTooBig, "The total number of connections closed due to exceeding the max message size")
+	n.Metrics.RegisterCounter(metricsDeliveryRetries, "The total number of messages re-sent due to missing acknowledgements")
+	n.Metrics.RegisterCounter(metricsDeliveryFailed, "The total number of sessions closed due to missing acknowledgements")
+	n.Metrics.RegisterHistogram(metricsDeliveryAckDuration, "The time between sending a reliable message and receiving the acknowledgement", nil)
 
 	n.Metrics.RegisterCounter(metricsSentMsg, "The total number of messages sent to clients")
 	n.Metrics.RegisterCounter(metricsFailedSent, "The total number of messages failed to send to clients")
 
+	n.Metrics.RegisterCounter(metricsStaleConnectionsClosed, "The total number of sessions closed due to missing pongs")
+	n.Metrics.RegisterCounter(metricsExpiredSessions, "The total number of sessions closed due to exceeding the max lifetime")
+
+	n.Metrics.RegisterCounterVec(metricsSubscriptionsRejected, "The total number of rejected subscriptions per reason", "reason")
+
 	n.Metrics.RegisterCounter(metricsDataSent, "The total amount of bytes sent to clients")
 	n.Metrics.RegisterCounter(metricsDataReceived, "The total amount of bytes received from clients")
+
+	if n.streamsPrefix != "" {
+		n.Metrics.RegisterCounter(metricsBroadcastNamespaceMismatch, "The total number of PubSub messages dropped due to the streams namespace mismatch")
+	}
+
+	if n.config.MetricsPerChannel {
+		n.Metrics.RegisterCounterVec(metricsChannelSubscribe, "The total number of subscribe commands per channel", "channel")
+		n.Metrics.RegisterCounterVec(metricsChannelUnsubscribe, "The total number of unsubscribe commands per channel", "channel")
+		n.Metrics.RegisterCounterVec(metricsChannelPerform, "The total number of perform commands per channel", "channel")
+		n.Metrics.RegisterCounterVec(metricsChannelFailures, "The total number of failed or rejected commands per channel", "channel")
+	}
+}
+
+// trackChannelCommand updates per-channel metrics (if enabled)
+func (n *Node) trackChannelCommand(metric string, identifier string, res *common.CommandResult, err error) {
+	if !n.config.MetricsPerChannel {
+		return
+	}
+
+	// Only the channels confirmed by RPC are used as labels, so clients can't create arbitrary series
+	confirmed := metric == metricsChannelSubscribe && err == nil && res != nil && res.Status == common.SUCCESS
+	channel := n.metricsChannelLabel(identifier, confirmed)
+
+	n.Metrics.CounterVec(metric).With(channel).Inc()
+
+	if err != nil || (res != nil && res.Status != common.SUCCESS) {
+		n.Metrics.CounterVec(metricsChannelFailures).With(channel).Inc()
+	}
+}
+
+// metricsChannels keeps the channels used as per-channel metrics labels
+type metricsChannels struct {
+	mu       sync.RWMutex
+	channels map[string]struct{}
+}
+
+// metricsChannelLabel returns the channel name if it has been confirmed (or it's being confirmed and the limit is not reached)
+// and "other" otherwise
+func (n *Node) metricsChannelLabel(identifier string, confirm bool) string {
+	channel := channelFromIdentifier(identifier)
+	registry := n.metricsChannels
+
+	registry.mu.RLock()
+	_, known := registry.channels[channel]
+	registry.mu.RUnlock()
+
+	if known {
+		return channel
+	}
+
+	if !confirm {
+		return otherChannel
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if _, known = registry.channels[channel]; !known && len(registry.channels) >= perChannelMetricsMaxChannels {
+		return otherChannel
+	}
+
+	registry.channels[channel] = struct{}{}
+
+	return channel
+}
+
+// trackRejection logs the subscription rejection and updates the per-reason metrics
+func (n *Node) trackRejection(s *Session, identifier string, reason string) {
+	s.Log.WithFields(log.Fields{"channel": channelFromIdentifier(identifier), "reason": reason}).Debugf("Subscription rejected")
+
+	n.Metrics.CounterVec(metricsSubscriptionsRejected).With(reason).Inc()
+}
+
+// rejectionReason returns the reason why the subscription has been rejected (or an empty string if it hasn't)
+func rejectionReason(res *common.CommandResult, err error) string {
+	if err != nil && (res == nil || res.Status == common.ERROR) {
+		return common.RejectionRPCError
+	}
+
+	if res == nil || res.Status != common.FAILURE {
+		return ""
+	}
+
+	if res.RejectionReason != "" {
+		return res.RejectionReason
+	}
+
+	return common.RejectionRPCRejected
+}
+
+// channelFromIdentifier extracts the channel name from the subscription identifier
+// (we do not use the whole identifier to avoid high cardinality)
+func channelFromIdentifier(identifier string) string {
+	var id struct {
+		Channel string `json:"channel"`
+	}
+
+	if err := json.Unmarshal([]byte(identifier), &id); err != nil || id.Channel == "" {
+		return unknownChannel
+	}
+
+	return id.Channel
 }
 
 func subscriptionsList(m map[string]bool) []string {
@@ -1,6 +1,7 @@
 package node
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -117,6 +118,7 @@ func NewMockSession(uid string, node *Node) *Session {
 	}
 
 	session.conn = NewMockConnection(&session)
+	session.ctx, session.cancel = context.WithCancel(context.Background())
 
 	return &session
 }
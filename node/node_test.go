@@ -1,9 +1,15 @@
 package node
 
 import (
+	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/anycable/anycable-go/ws"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -50,6 +56,31 @@ func TestAuthenticate(t *testing.T) {
 		assert.Equal(t, 0, node.hub.Size())
 	})
 
+	t.Run("With too many connections", func(t *testing.T) {
+		node.config.MaxConnectionsPerIdentifier = 1
+		defer func() { node.config.MaxConnectionsPerIdentifier = 0 }()
+
+		session := NewMockSessionWithEnv("1", &node, "/cable", &map[string]string{"id": "limited_id"})
+		defer node.hub.removeSession(session)
+
+		_, err := node.Authenticate(session)
+		assert.Nil(t, err)
+		assert.True(t, session.Connected)
+
+		another := NewMockSessionWithEnv("2", &node, "/cable", &map[string]string{"id": "limited_id"})
+
+		res, err := node.Authenticate(another)
+		assert.Nil(t, err)
+		assert.Equal(t, common.FAILURE, res.Status)
+		assert.False(t, another.Connected)
+
+		msg, err := another.conn.Read()
+		assert.Nil(t, err)
+
+		assert.Equal(t, string(toJSON(newDisconnectMessage("too_many_connections", false))), string(msg))
+		assert.Equal(t, 1, node.hub.Size())
+	})
+
 	t.Run("With connection state", func(t *testing.T) {
 		session := NewMockSessionWithEnv("1", &node, "/cable", &map[string]string{"x-session-test": "my_session", "id": "session_id"})
 		defer node.hub.removeSession(session)
@@ -110,6 +141,8 @@ func TestSubscribe(t *testing.T) {
 	t.Run("Error during subscription", func(t *testing.T) {
 		_, err := node.Subscribe(session, &common.Message{Identifier: "error"})
 		assert.NotNil(t, err, "Error must not be nil")
+
+		assert.Equal(t, uint64(1), node.Metrics.CounterVec(metricsSubscriptionsRejected).With(common.RejectionRPCError).Value())
 	})
 
 	t.Run("Rejected subscription", func(t *testing.T) {
@@ -117,6 +150,8 @@ func TestSubscribe(t *testing.T) {
 
 		assert.Equal(t, common.FAILURE, res.Status)
 		assert.Nil(t, err, "Error must be nil")
+
+		assert.Equal(t, uint64(1), node.Metrics.CounterVec(metricsSubscriptionsRejected).With(common.RejectionRPCRejected).Value())
 	})
 }
 
@@ -230,6 +265,77 @@ func TestPerform(t *testing.T) {
 	})
 }
 
+func TestPerChannelMetrics(t *testing.T) {
+	controller := mocks.NewMockController()
+	config := NewConfig()
+	config.MetricsPerChannel = true
+	node := NewNode(&controller, metrics.NewMetrics(nil, 10), &config)
+	session := NewMockSession("14", node)
+
+	identifier := `{"channel":"ChatChannel","id":"42"}`
+
+	_, err := node.Subscribe(session, &common.Message{Identifier: identifier})
+	assert.Nil(t, err)
+
+	_, err = node.Perform(session, &common.Message{Identifier: identifier, Data: "hello"})
+	assert.Nil(t, err)
+
+	_, err = node.Subscribe(session, &common.Message{Identifier: "failure"})
+	assert.Nil(t, err)
+
+	_, err = node.Unsubscribe(session, &common.Message{Identifier: identifier})
+	assert.Nil(t, err)
+
+	assert.Equal(t, uint64(1), node.Metrics.CounterVec(metricsChannelSubscribe).With("ChatChannel").Value())
+	assert.Equal(t, uint64(1), node.Metrics.CounterVec(metricsChannelPerform).With("ChatChannel").Value())
+	assert.Equal(t, uint64(1), node.Metrics.CounterVec(metricsChannelUnsubscribe).With("ChatChannel").Value())
+	assert.Equal(t, uint64(0), node.Metrics.CounterVec(metricsChannelFailures).With("ChatChannel").Value())
+
+	assert.Equal(t, uint64(1), node.Metrics.CounterVec(metricsChannelSubscribe).With(otherChannel).Value())
+	assert.Equal(t, uint64(1), node.Metrics.CounterVec(metricsChannelFailures).With(otherChannel).Value())
+}
+
+func TestPerChannelMetricsCardinality(t *testing.T) {
+	controller := mocks.NewMockController()
+	config := NewConfig()
+	config.MetricsPerChannel = true
+	node := NewNode(&controller, metrics.NewMetrics(nil, 10), &config)
+	session := NewMockSession("14", node)
+
+	go node.hub.Run()
+	defer node.hub.Shutdown()
+
+	for i := 0; i < perChannelMetricsMaxChannels*2; i++ {
+		identifier := fmt.Sprintf(`{"channel":"Channel%d"}`, i)
+
+		_, err := node.Subscribe(session, &common.Message{Identifier: identifier})
+		assert.Nil(t, err)
+
+		// Channels are not confirmed by unsubscribe and perform commands
+		unknown := fmt.Sprintf(`{"channel":"Random%d"}`, i)
+		session.subscriptions[unknown] = true
+
+		_, err = node.Perform(session, &common.Message{Identifier: unknown, Data: "hello"})
+		assert.Nil(t, err)
+
+		_, err = node.Unsubscribe(session, &common.Message{Identifier: unknown})
+		assert.Nil(t, err)
+	}
+
+	labels := func(metric string) []string {
+		values := []string{}
+		node.Metrics.CounterVec(metric).Each(func(value string, _ *metrics.Counter) { values = append(values, value) })
+		return values
+	}
+
+	assert.Equal(t, perChannelMetricsMaxChannels+1, len(labels(metricsChannelSubscribe)))
+	assert.Equal(t, []string{otherChannel}, labels(metricsChannelPerform))
+	assert.Equal(t, []string{otherChannel}, labels(metricsChannelUnsubscribe))
+
+	assert.Equal(t, uint64(perChannelMetricsMaxChannels), node.Metrics.CounterVec(metricsChannelSubscribe).With(otherChannel).Value())
+	assert.Equal(t, uint64(1), node.Metrics.CounterVec(metricsChannelSubscribe).With("Channel0").Value())
+}
+
 func TestDisconnect(t *testing.T) {
 	node := NewMockNode()
 	session := NewMockSession("14", &node)
@@ -247,7 +353,193 @@ func TestDisconnect(t *testing.T) {
 	assert.Equal(t, node.disconnector.Size(), 1, "Expected disconnect to have 1 task in a queue")
 
 	task := <-node.disconnector.(*DisconnectQueue).disconnect
-	assert.Equal(t, session, task, "Expected to disconnect session")
+	assert.Equal(t, session, task.session, "Expected to disconnect session")
+}
+
+func TestDisconnectNowClearsStore(t *testing.T) {
+	node := NewMockNode()
+	session := NewMockSession("14", &node)
+
+	assert.Nil(t, session.Store().Set("locale", []byte("en")))
+	assert.Nil(t, node.DisconnectNow(session))
+
+	assert.Equal(t, 0, session.Store().Size())
+}
+
+func TestDisconnectModes(t *testing.T) {
+	node := NewMockNode()
+
+	t.Run("Never", func(t *testing.T) {
+		session := NewMockSessionWithEnv("1", &node, "/cable", &map[string]string{"id": "test_id", "x-disconnect-mode": "never"})
+		_, err := node.Authenticate(session)
+		assert.Nil(t, err)
+		assert.Equal(t, common.DisconnectModeNever, session.disconnectMode)
+
+		assert.Nil(t, node.Disconnect(session))
+
+		assert.Equal(t, 0, node.disconnector.Size())
+		assert.Equal(t, uint64(1), node.Metrics.Counter(metricsDisconnectSkipped).Value())
+	})
+
+	t.Run("Only if subscribed without subscriptions", func(t *testing.T) {
+		session := NewMockSession("2", &node)
+		session.disconnectMode = common.DisconnectModeOnlyIfSubscribed
+
+		// Rejected subscriptions don't count
+		_, err := node.Subscribe(session, &common.Message{Identifier: "failure"})
+		assert.Nil(t, err)
+
+		assert.Nil(t, node.Disconnect(session))
+
+		assert.Equal(t, 0, node.disconnector.Size())
+		assert.Equal(t, uint64(2), node.Metrics.Counter(metricsDisconnectSkipped).Value())
+	})
+
+	t.Run("Only if subscribed with subscription", func(t *testing.T) {
+		session := NewMockSession("3", &node)
+		session.disconnectMode = common.DisconnectModeOnlyIfSubscribed
+
+		_, err := node.Subscribe(session, &common.Message{Identifier: "test_channel"})
+		assert.Nil(t, err)
+
+		_, err = node.Unsubscribe(session, &common.Message{Identifier: "test_channel"})
+		assert.Nil(t, err)
+
+		assert.Nil(t, node.Disconnect(session))
+
+		assert.Equal(t, 1, node.disconnector.Size())
+		<-node.disconnector.(*DisconnectQueue).disconnect
+	})
+
+	t.Run("Unknown mode falls back to default", func(t *testing.T) {
+		session := NewMockSessionWithEnv("4", &node, "/cable", &map[string]string{"id": "test_id", "x-disconnect-mode": "sometimes"})
+		session.disconnectMode = common.DisconnectModeAlways
+
+		_, err := node.Authenticate(session)
+		assert.Nil(t, err)
+		assert.Equal(t, common.DisconnectModeAlways, session.disconnectMode)
+	})
+}
+
+// cancelableController blocks commands until the context is cancelled
+type cancelableController struct {
+	mocks.MockController
+	started chan struct{}
+}
+
+func (c *cancelableController) SubscribeContext(ctx context.Context, sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error) {
+	close(c.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (c *cancelableController) PerformContext(ctx context.Context, sid string, env *common.SessionEnv, id string, channel string, data string) (*common.CommandResult, error) {
+	close(c.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// lateController closes the session before returning the results
+type lateController struct {
+	mocks.MockController
+	session *Session
+}
+
+func (c *lateController) Subscribe(sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error) {
+	c.session.close()
+
+	res, err := c.MockController.Subscribe(sid, env, id, channel)
+	res.Streams = []string{"stream"}
+
+	return res, err
+}
+
+func (c *lateController) Perform(sid string, env *common.SessionEnv, id string, channel string, data string) (*common.CommandResult, error) {
+	c.session.close()
+
+	return &common.CommandResult{
+		Status:        common.SUCCESS,
+		Transmissions: []string{"{\"message\":\"ok\"}"},
+		Broadcasts:    []*common.StreamMessage{{Stream: "chat", Data: "\"bye\""}},
+	}, nil
+}
+
+func TestCommandsCancellation(t *testing.T) {
+	node := NewMockNode()
+
+	t.Run("Perform is cancelled on close", func(t *testing.T) {
+		controller := &cancelableController{MockController: mocks.NewMockController(), started: make(chan struct{})}
+		node.controller = controller
+
+		session := NewMockSession("14", &node)
+		session.closed = false
+		session.subscriptions["test_channel"] = true
+
+		done := make(chan struct{})
+
+		go func() {
+			res, err := node.Perform(session, &common.Message{Identifier: "test_channel", Data: "hello"})
+			assert.Nil(t, res)
+			assert.Nil(t, err)
+			close(done)
+		}()
+
+		<-controller.started
+		session.close()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			assert.Fail(t, "Perform hasn't been cancelled")
+		}
+
+		assert.Equal(t, uint64(1), node.Metrics.Counter(metricsCommandsCancelled).Value())
+
+		_, err := session.conn.Read()
+		assert.NotNil(t, err, "Nothing must be sent to the closed session")
+	})
+
+	t.Run("Late subscribe response is discarded", func(t *testing.T) {
+		session := NewMockSession("15", &node)
+		session.closed = false
+		node.controller = &lateController{MockController: mocks.NewMockController(), session: session}
+		node.hub.addSession(session)
+		defer node.hub.removeSession(session)
+
+		res, err := node.Subscribe(session, &common.Message{Identifier: "test_channel"})
+		assert.Nil(t, res)
+		assert.Nil(t, err)
+
+		assert.Equal(t, uint64(2), node.Metrics.Counter(metricsCommandsCancelled).Value())
+		// Subscription is kept to be passed to Disconnect
+		assert.Contains(t, session.subscriptions, "test_channel")
+		assert.Equal(t, 0, node.hub.StreamsSize())
+
+		_, err = session.conn.Read()
+		assert.NotNil(t, err, "Nothing must be sent to the closed session")
+	})
+
+	t.Run("Late perform response broadcasts are delivered", func(t *testing.T) {
+		session := NewMockSession("16", &node)
+		session.closed = false
+		session.subscriptions["test_channel"] = true
+		node.controller = &lateController{MockController: mocks.NewMockController(), session: session}
+
+		go func() {
+			res, err := node.Perform(session, &common.Message{Identifier: "test_channel", Data: "bye"})
+			assert.Nil(t, res)
+			assert.Nil(t, err)
+		}()
+
+		broadcast := <-node.hub.broadcast
+
+		assert.Equal(t, "chat", broadcast.msg.Stream)
+		assert.Equal(t, "\"bye\"", broadcast.msg.Data)
+		assert.Equal(t, uint64(3), node.Metrics.Counter(metricsCommandsCancelled).Value())
+
+		_, err := session.conn.Read()
+		assert.NotNil(t, err, "Nothing must be sent to the closed session")
+	})
 }
 
 func TestHandlePubSub(t *testing.T) {
@@ -278,6 +570,256 @@ func TestHandlePubSub(t *testing.T) {
 	assert.Equalf(t, expected, string(msg2), "Expected to receive %s but got %s", expected, string(msg2))
 }
 
+func TestHandlePubSubDuplicates(t *testing.T) {
+	node := NewMockNode()
+
+	go node.hub.Run()
+	defer node.hub.Shutdown()
+
+	session := NewMockSession("14", &node)
+
+	node.hub.addSession(session)
+	node.hub.subscribeSession("14", "test", "test_channel")
+
+	t.Run("Without deduplication", func(t *testing.T) {
+		node.HandlePubSub([]byte("{\"stream\":\"test\",\"data\":\"1\",\"broadcast_id\":\"x\"}"))
+		node.HandlePubSub([]byte("{\"stream\":\"test\",\"data\":\"1\",\"broadcast_id\":\"x\"}"))
+
+		for i := 0; i < 2; i++ {
+			msg, err := session.conn.Read()
+			assert.Nil(t, err)
+			assert.Equal(t, "{\"identifier\":\"test_channel\",\"message\":1}", string(msg))
+		}
+	})
+
+	t.Run("With deduplication", func(t *testing.T) {
+		node.EnableBroadcastDedup()
+
+		node.HandlePubSubFrom("redis", []byte("{\"stream\":\"test\",\"data\":\"2\",\"broadcast_id\":\"y\"}"))
+		node.HandlePubSubFrom("http", []byte("{\"stream\":\"test\",\"data\":\"2\",\"broadcast_id\":\"y\"}"))
+		// Messages without IDs are never deduplicated
+		node.HandlePubSub([]byte("{\"stream\":\"test\",\"data\":\"3\"}"))
+		node.HandlePubSub([]byte("{\"stream\":\"test\",\"data\":\"3\"}"))
+
+		for _, expected := range []string{"2", "3", "3"} {
+			msg, err := session.conn.Read()
+			assert.Nil(t, err)
+			assert.Equal(t, "{\"identifier\":\"test_channel\",\"message\":"+expected+"}", string(msg))
+		}
+
+		assert.Equal(t, uint64(1), node.Metrics.Counter(metricsBroadcastDuplicates).Value())
+	})
+}
+
+func TestHandlePubSubErrors(t *testing.T) {
+	node := NewMockNode()
+
+	go node.hub.Run()
+	defer node.hub.Shutdown()
+
+	session := NewMockSession("14", &node)
+
+	node.hub.addSession(session)
+	node.hub.subscribeSession("14", "test", "test_channel")
+
+	t.Run("Garbage between valid messages", func(t *testing.T) {
+		node.HandlePubSub([]byte("{\"stream\":\"test\",\"data\":\"1\"}"))
+		node.HandlePubSub([]byte("{\"stream\":"))
+		node.HandlePubSub([]byte("{\"stream\":\"test\",\"data\":\"2\"}"))
+		node.HandlePubSub([]byte("{\"command\":\"disconnect\",\"payload\":42}"))
+		node.HandlePubSub([]byte("{\"command\":\"unknown\"}"))
+		node.HandlePubSub([]byte("{\"stream\":\"test\",\"data\":\"3\"}"))
+
+		for _, expected := range []string{"1", "2", "3"} {
+			msg, err := session.conn.Read()
+			assert.Nil(t, err)
+			assert.Equal(t, "{\"identifier\":\"test_channel\",\"message\":"+expected+"}", string(msg))
+		}
+
+		assert.Equal(t, uint64(3), node.Metrics.Counter(metricsBroadcastErrors).Value())
+	})
+}
+
+func TestHandlePubSubWhenHubIsBusy(t *testing.T) {
+	node := NewMockNode()
+
+	// The hub is not running and cannot accept messages
+	node.hub.broadcast = make(chan streamEvent)
+
+	handled := make(chan struct{})
+
+	go func() {
+		node.HandlePubSub([]byte("{\"stream\":\"test\",\"data\":\"1\"}"))
+		close(handled)
+	}()
+
+	// Broadcasts are never dropped: the subscriber waits for the hub (backpressure)
+	select {
+	case <-handled:
+		t.Fatal("Broadcast must block until the hub accepts it")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	assert.Equal(t, "1", (<-node.hub.broadcast).msg.Data)
+
+	<-handled
+
+	assert.Equal(t, uint64(0), node.Metrics.Counter(metricsBroadcastErrors).Value())
+}
+
+func TestHandlePubSubBinary(t *testing.T) {
+	node := NewMockNode()
+
+	go node.hub.Run()
+	defer node.hub.Shutdown()
+
+	session := NewMockSession("14", &node)
+
+	node.hub.addSession(session)
+	node.hub.subscribeSession("14", "test", "test_channel")
+
+	t.Run("Drop", func(t *testing.T) {
+		node.HandlePubSub([]byte("{\"stream\":\"test\",\"binary\":\"CgVoZWxsbw==\"}"))
+
+		_, err := session.conn.Read()
+		assert.NotNil(t, err)
+		assert.Equal(t, uint64(1), node.Metrics.Counter(metricsBinaryDropped).Value())
+	})
+
+	t.Run("Base64", func(t *testing.T) {
+		node.config.BinaryBroadcasts = BinaryBroadcastsBase64
+
+		node.HandlePubSub([]byte("{\"stream\":\"test\",\"binary\":\"CgVoZWxsbw==\"}"))
+
+		expected := "{\"identifier\":\"test_channel\",\"message\":\"CgVoZWxsbw==\"}"
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(msg))
+	})
+}
+
+func TestHistory(t *testing.T) {
+	controller := mocks.NewMockController()
+	config := NewConfig()
+	config.HubGopoolSize = 2
+	config.HistoryLimit = 10
+	node := NewNode(&controller, metrics.NewMetrics(nil, 10), &config)
+
+	session := NewMockSession("14", node)
+	node.hub.addSession(session)
+
+	_, err := node.Subscribe(session, &common.Message{Identifier: "with_stream"})
+	assert.Nil(t, err)
+
+	_, err = session.conn.Read()
+	assert.Nil(t, err)
+
+	epoch := node.broker.Epoch()
+
+	node.hub.broadcastToStream("stream", "1")
+
+	msg, err := session.conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"identifier\":\"with_stream\",\"message\":1,\"stream_id\":\"stream\",\"offset\":1,\"epoch\":\""+epoch+"\"}", string(msg))
+
+	node.hub.broadcastToStream("stream", "2")
+
+	_, err = session.conn.Read()
+	assert.Nil(t, err)
+
+	t.Run("Replay after offset", func(t *testing.T) {
+		err := node.HandleCommand(session, &common.Message{
+			Command:    "history",
+			Identifier: "with_stream",
+			History:    &common.HistoryRequest{Streams: map[string]common.HistoryPosition{"stream": {Epoch: epoch, Offset: 1}}},
+		})
+		assert.Nil(t, err)
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"identifier\":\"with_stream\",\"message\":2,\"stream_id\":\"stream\",\"offset\":2,\"epoch\":\""+epoch+"\"}", string(msg))
+
+		msg, err = session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"type\":\"confirm_history\",\"identifier\":\"with_stream\"}", string(msg))
+	})
+
+	t.Run("Replay since timestamp", func(t *testing.T) {
+		err := node.History(session, &common.Message{
+			Identifier: "with_stream",
+			History:    &common.HistoryRequest{Since: 1},
+		})
+		assert.Nil(t, err)
+
+		for _, expected := range []string{"1", "2"} {
+			msg, err := session.conn.Read()
+			assert.Nil(t, err)
+			assert.Contains(t, string(msg), "\"message\":"+expected+",")
+		}
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Contains(t, string(msg), "confirm_history")
+	})
+
+	t.Run("Unknown epoch", func(t *testing.T) {
+		err := node.History(session, &common.Message{
+			Identifier: "with_stream",
+			History:    &common.HistoryRequest{Streams: map[string]common.HistoryPosition{"stream": {Epoch: "old", Offset: 1}}},
+		})
+		assert.Nil(t, err)
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"type\":\"reject_history\",\"identifier\":\"with_stream\"}", string(msg))
+	})
+
+	t.Run("Subscribe with history (protocol v1.1)", func(t *testing.T) {
+		session := NewMockSession("15", node)
+		session.ProtocolVersion = ws.ProtocolV11
+		node.hub.addSession(session)
+
+		_, err := node.Subscribe(session, &common.Message{Identifier: "with_stream", History: &common.HistoryRequest{Since: 1}})
+		assert.Nil(t, err)
+
+		for _, expected := range []string{"15", "\"offset\":1,", "\"offset\":2,", "confirm_history"} {
+			msg, err := session.conn.Read()
+			assert.Nil(t, err)
+			assert.Contains(t, string(msg), expected)
+		}
+	})
+
+	t.Run("Unknown subscription", func(t *testing.T) {
+		err := node.History(session, &common.Message{Identifier: "unknown", History: &common.HistoryRequest{Since: 1}})
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Live broadcasts during replay", func(t *testing.T) {
+		// Emulate the broadcast arriving while the history is being fetched
+		session.replays.start("with_stream")
+		node.hub.deliverToStream(&common.StreamMessage{Stream: "stream", Data: "3", Offset: node.broker.Add("stream", "3"), Epoch: epoch})
+
+		err := node.History(session, &common.Message{
+			Identifier: "with_stream",
+			History:    &common.HistoryRequest{Streams: map[string]common.HistoryPosition{"stream": {Epoch: epoch, Offset: 1}}},
+		})
+		assert.Nil(t, err)
+
+		node.hub.broadcastToStream("stream", "4")
+
+		// The held broadcast has been replayed, so it's not delivered twice
+		for _, expected := range []string{"\"offset\":2,", "\"offset\":3,", "confirm_history", "\"offset\":4,"} {
+			msg, err := session.conn.Read()
+			assert.Nil(t, err)
+			assert.Contains(t, string(msg), expected)
+		}
+
+		_, err = session.conn.Read()
+		assert.NotNil(t, err)
+	})
+}
+
 func TestHandlePubSubWithCommand(t *testing.T) {
 	node := NewMockNode()
 
@@ -297,6 +839,68 @@ func TestHandlePubSubWithCommand(t *testing.T) {
 	assert.True(t, session.closed)
 }
 
+func TestHandlePubSubWithStopStream(t *testing.T) {
+	node := NewMockNode()
+
+	go node.hub.Run()
+	defer node.hub.Shutdown()
+
+	session := NewMockSession("14", &node)
+	session.subscriptions["test_channel"] = true
+	node.hub.addSession(session)
+	node.hub.subscribeSession("14", "room_42", "test_channel")
+
+	node.HandlePubSub([]byte("{\"command\":\"stop_stream\",\"stream\":\"room_42\"}"))
+
+	assert.Eventually(t, func() bool { return node.hub.StreamsSize() == 0 }, time.Second, 10*time.Millisecond)
+
+	// Channel subscription is kept, and no notifications are sent
+	assert.Contains(t, session.subscriptions, "test_channel")
+
+	_, err := session.conn.Read()
+	assert.NotNil(t, err)
+
+	assert.Equal(t, uint64(1), node.Metrics.Counter(metricsStopStreamMsg).Value())
+	assert.Equal(t, uint64(0), node.Metrics.Counter(metricsBroadcastMsg).Value())
+}
+
+func TestHandlePubSubWithFilters(t *testing.T) {
+	node := NewMockNode()
+
+	go node.hub.Run()
+	defer node.hub.Shutdown()
+
+	session := NewMockSession("14", &node)
+	node.hub.addSession(session)
+	node.hub.subscribeSession("14", "tenant_1:room", "test_channel")
+	node.hub.subscribeSession("14", "room", "test_channel")
+
+	filter, err := NewStreamPatternFilter("^tenant_[0-9]+:")
+	assert.Nil(t, err)
+
+	node.AddBroadcastFilter(filter)
+
+	origins := []string{}
+
+	node.AddBroadcastFilter(BroadcastFilterFunc(func(origin string, msg *common.StreamMessage) bool {
+		origins = append(origins, origin)
+		return origin != "http"
+	}))
+
+	node.HandlePubSubFrom("redis", []byte("{\"stream\":\"room\",\"data\":\"\\\"leak\\\"\"}"))
+	node.HandlePubSubFrom("http", []byte("{\"stream\":\"tenant_1:room\",\"data\":\"\\\"http\\\"\"}"))
+	node.HandlePubSubFrom("redis", []byte("{\"command\":\"stop_stream\",\"stream\":\"room\"}"))
+	node.HandlePubSubFrom("redis", []byte("{\"stream\":\"tenant_1:room\",\"data\":\"\\\"hello\\\"\"}"))
+
+	msg, err := session.conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"identifier\":\"test_channel\",\"message\":\"hello\"}", string(msg))
+
+	assert.Equal(t, []string{"http", "redis"}, origins)
+	assert.Equal(t, uint64(3), node.Metrics.Counter(metricsBroadcastRejected).Value())
+	assert.Equal(t, 2, node.hub.StreamsSize())
+}
+
 func TestLookupSession(t *testing.T) {
 	node := NewMockNode()
 
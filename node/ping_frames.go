@@ -0,0 +1,59 @@
+package node
+
+import (
+	"sync"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/encoders"
+	"github.com/anycable/anycable-go/ws"
+)
+
+type pingFrameKey struct {
+	encoder   string
+	precision string
+}
+
+type pingFrame struct {
+	ts    int64
+	frame *ws.SentFrame
+}
+
+// pingFrames caches encoded ping messages to share them between sessions:
+// sessions using the same encoder and pinged within the same timestamp unit get the same frame.
+// NOTE: cached frames are shared, so they must never be modified by the write path.
+type pingFrames struct {
+	mu     sync.RWMutex
+	frames map[pingFrameKey]*pingFrame
+}
+
+func newPingFrames() *pingFrames {
+	return &pingFrames{frames: make(map[pingFrameKey]*pingFrame)}
+}
+
+// fetch returns the encoded ping message with the specified timestamp (encoding it if it's not cached yet)
+func (p *pingFrames) fetch(enc encoders.Encoder, precision string, ts int64) (*ws.SentFrame, error) {
+	key := pingFrameKey{enc.ID(), precision}
+
+	p.mu.RLock()
+	cached, ok := p.frames[key]
+	p.mu.RUnlock()
+
+	if ok && cached.ts == ts {
+		return cached.frame, nil
+	}
+
+	frame, err := enc.Encode(&common.PingMessage{Type: "ping", Message: ts})
+
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	// Do not replace the frame with an older one
+	if cached, ok := p.frames[key]; !ok || cached.ts < ts {
+		p.frames[key] = &pingFrame{ts: ts, frame: frame}
+	}
+	p.mu.Unlock()
+
+	return frame, nil
+}
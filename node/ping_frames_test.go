@@ -0,0 +1,141 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/encoders"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/stretchr/testify/assert"
+)
+
+type customEncoder struct {
+	encoders.JSON
+}
+
+func (customEncoder) ID() string {
+	return "custom"
+}
+
+func TestPingFrames(t *testing.T) {
+	frames := newPingFrames()
+
+	first, err := frames.fetch(encoders.JSON{}, "s", 42)
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"type\":\"ping\",\"message\":42}", string(first.Payload))
+
+	t.Run("Reuses the frame within the same timestamp", func(t *testing.T) {
+		frame, _ := frames.fetch(encoders.JSON{}, "s", 42)
+		assert.Same(t, first, frame)
+	})
+
+	t.Run("Encodes the frame for a new timestamp", func(t *testing.T) {
+		frame, _ := frames.fetch(encoders.JSON{}, "s", 43)
+		assert.NotSame(t, first, frame)
+		assert.Equal(t, "{\"type\":\"ping\",\"message\":43}", string(frame.Payload))
+
+		// Outdated timestamps do not replace the cached frame
+		frames.fetch(encoders.JSON{}, "s", 42) // nolint:errcheck
+		again, _ := frames.fetch(encoders.JSON{}, "s", 43)
+		assert.Same(t, frame, again)
+	})
+
+	t.Run("Keeps frames per precision", func(t *testing.T) {
+		frame, _ := frames.fetch(encoders.JSON{}, "ms", 42)
+		assert.NotSame(t, first, frame)
+	})
+}
+
+func TestSessionPingFrame(t *testing.T) {
+	node := NewMockNode()
+
+	t.Run("Shares the frame between sessions", func(t *testing.T) {
+		a := NewMockSession("1", &node)
+		b := NewMockSession("2", &node)
+
+		frames := make(map[*ws.SentFrame]bool)
+
+		for i := 0; i < 2; i++ {
+			fa, err := a.pingFrame()
+			assert.Nil(t, err)
+			fb, err := b.pingFrame()
+			assert.Nil(t, err)
+
+			frames[fa] = true
+			frames[fb] = true
+		}
+
+		// Allow one extra frame in case the second has changed in between
+		assert.LessOrEqual(t, len(frames), 2)
+	})
+
+	t.Run("Falls back to the slow path for custom encoders", func(t *testing.T) {
+		a := NewMockSession("1", &node)
+		a.encoder = customEncoder{}
+
+		fa, err := a.pingFrame()
+		assert.Nil(t, err)
+
+		fb, _ := a.pingFrame()
+		assert.NotSame(t, fa, fb)
+		assert.Contains(t, string(fa.Payload), "\"type\":\"ping\"")
+	})
+
+	t.Run("Doesn't cache frames with sub-second precision", func(t *testing.T) {
+		node := NewMockNode()
+		a := NewMockSession("1", &node)
+		a.pingTimestampPrecision = "ms"
+
+		frame, err := a.pingFrame()
+		assert.Nil(t, err)
+		assert.Contains(t, string(frame.Payload), "\"type\":\"ping\"")
+		assert.Empty(t, node.pingFrames.frames)
+	})
+
+	t.Run("Write path does not mutate the shared frame", func(t *testing.T) {
+		session := NewMockSession("1", &node)
+		session.closed = false
+		// Do not schedule the next ping right away
+		session.pingInterval = time.Hour
+
+		frame, _ := session.pingFrame()
+		payload := string(frame.Payload)
+
+		session.sendPing()
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Contains(t, string(msg), "\"type\":\"ping\"")
+		assert.Equal(t, payload, string(frame.Payload))
+	})
+}
+
+func BenchmarkPing(b *testing.B) {
+	sessionsNum := 50000
+
+	node := NewMockNode()
+	sessions := make([]*Session, sessionsNum)
+
+	for i := 0; i < sessionsNum; i++ {
+		sessions[i] = NewMockSession(fmt.Sprintf("%d", i), &node)
+	}
+
+	b.Run("slow path", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			session := sessions[i%sessionsNum]
+			session.encodeMessage(&common.PingMessage{Type: "ping", Message: pingTimestamp(session.pingTimestampPrecision)}) // nolint:errcheck
+		}
+	})
+
+	b.Run("fast path", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			sessions[i%sessionsNum].pingFrame() // nolint:errcheck
+		}
+	})
+}
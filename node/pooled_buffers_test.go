@@ -0,0 +1,224 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// pooledConnection emulates WebSocket connections reading messages into pooled buffers
+type pooledConnection struct {
+	incoming chan []byte
+
+	mu       sync.Mutex
+	received []string
+	closed   bool
+}
+
+func newPooledConnection() *pooledConnection {
+	return &pooledConnection{incoming: make(chan []byte, 10)}
+}
+
+func (c *pooledConnection) Write(msg []byte, deadline time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Real connections write the data right away, so we copy it here
+	c.received = append(c.received, string(msg))
+
+	return nil
+}
+
+func (c *pooledConnection) WriteBinary(msg []byte, deadline time.Time) error {
+	return c.Write(msg, deadline)
+}
+
+func (c *pooledConnection) Read() ([]byte, error) {
+	return nil, errors.New("Pooled reads must be used")
+}
+
+func (c *pooledConnection) ReadPooled() ([]byte, error) {
+	msg, ok := <-c.incoming
+
+	if !ok {
+		return nil, errors.New("Closed")
+	}
+
+	b := utils.GetBytes(len(msg))
+	copy(b, msg)
+
+	return b, nil
+}
+
+func (c *pooledConnection) Close(code int, reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.incoming)
+	}
+}
+
+func (c *pooledConnection) Received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string{}, c.received...)
+}
+
+func waitReceived(conn *pooledConnection, n int) []string {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		if received := conn.Received(); len(received) >= n {
+			return received
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return conn.Received()
+}
+
+func waitOutstanding(expected int64) int64 {
+	deadline := time.Now().Add(time.Second)
+
+	for time.Now().Before(deadline) {
+		if utils.DefaultBytesPool().Stats().Outstanding == expected {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return utils.DefaultBytesPool().Stats().Outstanding
+}
+
+func TestPooledBuffersFanout(t *testing.T) {
+	node := NewMockNode()
+	node.config.PingInterval = 0
+
+	go node.hub.Run()
+	defer node.hub.Shutdown()
+
+	outstanding := utils.DefaultBytesPool().Stats().Outstanding
+
+	const sessionsNum = 20
+	const messagesNum = 50
+
+	sessions := make([]*Session, sessionsNum)
+	conns := make([]*pooledConnection, sessionsNum)
+
+	for i := range sessions {
+		conns[i] = newPooledConnection()
+		sessions[i] = NewSession(&node, conns[i], "/cable", &map[string]string{}, fmt.Sprintf("s%d", i))
+
+		assert.True(t, sessions[i].pooled)
+
+		node.hub.addSession(sessions[i])
+		node.hub.subscribeSession(sessions[i].UID, "test", "test_channel")
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < messagesNum; i++ {
+			node.hub.Broadcast("test", fmt.Sprintf("\"b%d\"", i))
+		}
+	}()
+
+	for _, session := range sessions {
+		wg.Add(1)
+
+		go func(s *Session) {
+			defer wg.Done()
+
+			for i := 0; i < messagesNum; i++ {
+				s.SendJSONTransmission(fmt.Sprintf("{\"t\":\"%s-%d\"}", s.UID, i))
+			}
+		}(session)
+	}
+
+	wg.Wait()
+
+	for i, session := range sessions {
+		received := waitReceived(conns[i], 2*messagesNum)
+
+		broadcasts := []string{}
+		transmissions := []string{}
+
+		for _, msg := range received {
+			if strings.HasPrefix(msg, "{\"t\"") {
+				transmissions = append(transmissions, msg)
+			} else {
+				broadcasts = append(broadcasts, msg)
+			}
+		}
+
+		if !assert.Len(t, broadcasts, messagesNum) || !assert.Len(t, transmissions, messagesNum) {
+			continue
+		}
+
+		for j := 0; j < messagesNum; j++ {
+			assert.Equal(t, fmt.Sprintf("{\"identifier\":\"test_channel\",\"message\":\"b%d\"}", j), broadcasts[j])
+			assert.Equal(t, fmt.Sprintf("{\"t\":\"%s-%d\"}", session.UID, j), transmissions[j])
+		}
+	}
+
+	for _, session := range sessions {
+		session.Disconnect("test", 1000)
+	}
+
+	assert.Equal(t, outstanding, waitOutstanding(outstanding))
+}
+
+func TestPooledBuffersRead(t *testing.T) {
+	node := NewMockNode()
+	node.config.PingInterval = 0
+
+	go node.hub.Run()
+	defer node.hub.Shutdown()
+
+	outstanding := utils.DefaultBytesPool().Stats().Outstanding
+
+	conn := newPooledConnection()
+	session := NewSession(&node, conn, "/cable", &map[string]string{"id": "john"}, "s1")
+
+	_, err := node.Authenticate(session)
+	assert.Nil(t, err)
+
+	done := make(chan struct{})
+	assert.Nil(t, session.Serve(func() { close(done) }))
+
+	for i := 0; i < 10; i++ {
+		conn.incoming <- []byte(fmt.Sprintf("{\"command\":\"subscribe\",\"identifier\":\"channel_%d\"}", i))
+	}
+
+	received := waitReceived(conn, 11)
+
+	if !assert.Len(t, received, 11) {
+		return
+	}
+
+	assert.Equal(t, "welcome", received[0])
+
+	for _, msg := range received[1:] {
+		assert.Equal(t, "s1", msg)
+	}
+
+	session.Disconnect("test", 1000)
+	<-done
+
+	assert.Equal(t, outstanding, waitOutstanding(outstanding))
+}
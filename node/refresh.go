@@ -0,0 +1,141 @@
+package node
+
+import (
+	"errors"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/ws"
+)
+
+const (
+	metricsRefreshes        = "refreshes_total"
+	metricsFailedRefreshes  = "refreshes_failed_total"
+	metricsExpiryWarnings   = "expiry_warnings_total"
+	tokenExpiringWarning    = "token_expiring"
+	refreshConfirmedMessage = "{\"type\":\"" + common.RefreshConfirmedType + "\"}"
+)
+
+// Refresh re-authenticates the session with the new credentials (protocol v1.1 only).
+// The Connect request is performed with the refresh headers (see common.RefreshHeader) added to the session env.
+// On success, the session identifiers are replaced (subscriptions are kept) and the confirmation is sent;
+// otherwise, the session is closed with the "token_expired" reason.
+func (n *Node) Refresh(s *Session, msg *common.Message) error {
+	if s.ProtocolVersion != ws.ProtocolV11 {
+		return errors.New("Refresh is not supported by the client protocol")
+	}
+
+	if msg.Token == "" {
+		return errors.New("Refresh token is missing")
+	}
+
+	res, err := n.controllerFor(s).Authenticate(s.UID, refreshEnv(s, msg.Token))
+
+	if err != nil {
+		return err
+	}
+
+	if res.Status != common.SUCCESS {
+		n.Metrics.Counter(metricsFailedRefreshes).Inc()
+
+		s.Log.Debugf("Session credentials refresh rejected")
+		s.Send(newDisconnectMessage(tokenExpiredReason, false))
+		s.Disconnect(tokenExpiredReason, closeCode(tokenExpiredReason))
+
+		return nil
+	}
+
+	n.Metrics.Counter(metricsRefreshes).Inc()
+
+	n.hub.updateIdentifiers(s, res.Identifier)
+
+	if res.CState != nil {
+		s.smu.Lock()
+		s.env.MergeConnectionState(&res.CState)
+		s.smu.Unlock()
+	}
+
+	n.scheduleExpiryWarning(s, res.ExpiresAt)
+
+	if n.events != nil {
+		n.events.Emit(newSessionEvent(EventSessionAuthenticated, s))
+	}
+
+	s.Log.Debugf("Session credentials refreshed: %s", res.Identifier)
+
+	// Connect transmissions (e.g., welcome) are not sent to already connected clients
+	s.SendJSONTransmission(refreshConfirmedMessage)
+
+	return nil
+}
+
+// refreshEnv returns a copy of the session env with the refresh headers added
+func refreshEnv(s *Session, token string) *common.SessionEnv {
+	s.smu.Lock()
+	defer s.smu.Unlock()
+
+	headers := make(map[string]string)
+
+	if s.env.Headers != nil {
+		for k, v := range *s.env.Headers {
+			headers[k] = v
+		}
+	}
+
+	headers[common.RefreshHeader] = "1"
+	headers[common.RefreshTokenHeader] = token
+
+	state := make(map[string]string)
+
+	if s.env.ConnectionState != nil {
+		for k, v := range *s.env.ConnectionState {
+			state[k] = v
+		}
+	}
+
+	return &common.SessionEnv{
+		URL:             s.env.URL,
+		Headers:         &headers,
+		ConnectionState: &state,
+		ChannelStates:   s.env.ChannelStates,
+		Store:           s.env.Store,
+	}
+}
+
+// scheduleExpiryWarning sets up the warning message to be sent before the session credentials expire
+// (the previously scheduled warning is cancelled)
+func (n *Node) scheduleExpiryWarning(s *Session, expiresAt int64) {
+	s.stopExpiryTimer()
+
+	if n.config.ExpiryWarning <= 0 || expiresAt <= 0 {
+		return
+	}
+
+	delay := time.Until(time.Unix(expiresAt, 0)) - time.Duration(n.config.ExpiryWarning)*time.Second
+
+	if delay < 0 {
+		delay = 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	s.expiryTimer = time.AfterFunc(delay, func() {
+		n.Metrics.Counter(metricsExpiryWarnings).Inc()
+		s.Send(&common.WarningMessage{Type: common.WarningType, Reason: tokenExpiringWarning, ExpiresAt: expiresAt})
+	})
+}
+
+func (s *Session) stopExpiryTimer() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.expiryTimer != nil {
+		s.expiryTimer.Stop()
+		s.expiryTimer = nil
+	}
+}
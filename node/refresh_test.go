@@ -0,0 +1,167 @@
+package node
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/stretchr/testify/assert"
+)
+
+// refreshController identifies refreshed sessions by the token ("expired" tokens are rejected)
+// and sets the credentials expiration time from the "x-expires-at" header
+type refreshController struct {
+	mocks.MockController
+}
+
+func (c *refreshController) Authenticate(sid string, env *common.SessionEnv) (*common.ConnectResult, error) {
+	res, err := c.MockController.Authenticate(sid, env)
+
+	if err != nil || res.Status != common.SUCCESS {
+		return res, err
+	}
+
+	if token, ok := (*env.Headers)[common.RefreshTokenHeader]; ok {
+		if token == "expired" {
+			return &common.ConnectResult{Status: common.FAILURE, Transmissions: []string{"unauthorized"}}, nil
+		}
+
+		res.Identifier = token
+	}
+
+	if expiresAt := (*env.Headers)["x-expires-at"]; expiresAt != "" {
+		res.ExpiresAt, _ = strconv.ParseInt(expiresAt, 10, 64)
+	}
+
+	return res, nil
+}
+
+func newRefreshNode() *Node {
+	config := NewConfig()
+	config.HubGopoolSize = 2
+	node := NewNode(&refreshController{MockController: mocks.NewMockController()}, metrics.NewMetrics(nil, 10), &config)
+	dconfig := NewDisconnectQueueConfig()
+	node.SetDisconnector(NewDisconnectQueue(node, &dconfig))
+	return node
+}
+
+func TestRefresh(t *testing.T) {
+	node := newRefreshNode()
+
+	newSession := func() *Session {
+		session := NewMockSessionWithEnv("14", node, "/cable", &map[string]string{"id": "john"})
+		session.closed = false
+		session.SetProtocolVersion(ws.ProtocolV11)
+
+		_, err := node.Authenticate(session)
+		assert.Nil(t, err)
+
+		_, err = session.conn.Read()
+		assert.Nil(t, err)
+
+		_, err = node.Subscribe(session, &common.Message{Identifier: "test_channel"})
+		assert.Nil(t, err)
+
+		_, err = session.conn.Read()
+		assert.Nil(t, err)
+
+		return session
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		session := newSession()
+
+		err := node.HandleCommand(session, &common.Message{Command: "refresh", Token: "jack"})
+		assert.Nil(t, err)
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"type\":\"confirm_refresh\"}", string(msg))
+
+		assert.Equal(t, "jack", session.Identifiers)
+		assert.True(t, session.Connected)
+		assert.Contains(t, session.subscriptions, "test_channel")
+		assert.Same(t, session, node.hub.findByIdentifier("jack"))
+		assert.Nil(t, node.hub.findByIdentifier("john"))
+
+		// Refresh headers must not leak into the session env
+		assert.NotContains(t, *session.env.Headers, common.RefreshTokenHeader)
+
+		node.hub.removeSession(session)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		session := newSession()
+
+		err := node.HandleCommand(session, &common.Message{Command: "refresh", Token: "expired"})
+		assert.Nil(t, err)
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"type\":\"disconnect\",\"reason\":\"token_expired\",\"reconnect\":false}", string(msg))
+
+		assert.False(t, session.Connected)
+		assert.Equal(t, ws.CloseTokenExpired, session.closeCode)
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		session := newSession()
+
+		err := node.HandleCommand(session, &common.Message{Command: "refresh"})
+		assert.Error(t, err)
+		assert.Equal(t, "john", session.Identifiers)
+
+		node.hub.removeSession(session)
+	})
+
+	t.Run("Protocol v1", func(t *testing.T) {
+		session := newSession()
+		session.SetProtocolVersion(ws.ProtocolV1)
+
+		err := node.HandleCommand(session, &common.Message{Command: "refresh", Token: "jack"})
+		assert.Error(t, err)
+		assert.Equal(t, "john", session.Identifiers)
+
+		node.hub.removeSession(session)
+	})
+}
+
+func TestExpiryWarning(t *testing.T) {
+	node := newRefreshNode()
+	node.config.ExpiryWarning = 60
+
+	expiresAt := time.Now().Add(30 * time.Second).Unix()
+
+	session := NewMockSessionWithEnv("14", node, "/cable", &map[string]string{"id": "john", "x-expires-at": strconv.FormatInt(expiresAt, 10)})
+	session.closed = false
+
+	_, err := node.Authenticate(session)
+	assert.Nil(t, err)
+
+	_, err = session.conn.Read()
+	assert.Nil(t, err)
+
+	msg, err := session.conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"type\":\"warning\",\"reason\":\"token_expiring\",\"expires_at\":"+strconv.FormatInt(expiresAt, 10)+"}", string(msg))
+
+	t.Run("Rescheduled on refresh", func(t *testing.T) {
+		later := time.Now().Add(time.Hour).Unix()
+		(*session.env.Headers)["x-expires-at"] = strconv.FormatInt(later, 10)
+		session.SetProtocolVersion(ws.ProtocolV11)
+
+		assert.Nil(t, node.Refresh(session, &common.Message{Command: "refresh", Token: "john"}))
+
+		_, err = session.conn.Read()
+		assert.Nil(t, err)
+
+		assert.NotNil(t, session.expiryTimer)
+
+		session.Disconnect("test", ws.CloseNormalClosure)
+		assert.Nil(t, session.expiryTimer)
+	})
+}
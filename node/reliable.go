@@ -0,0 +1,203 @@
+package node
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+)
+
+const (
+	metricsDeliveryRetries     = "delivery_retries_total"
+	metricsDeliveryFailed      = "delivery_failed_total"
+	metricsDeliveryAckDuration = "delivery_ack_duration_seconds"
+)
+
+// pendingMessage is a message waiting for the client acknowledgement
+type pendingMessage struct {
+	reply      *common.Reply
+	sentAt     time.Time
+	lastSentAt time.Time
+	attempts   int
+}
+
+// reliableDelivery implements at-least-once delivery for the session subscriptions which requested it:
+// every broadcasted message gets an ID and is kept in the pending buffer until the client acknowledges it.
+// Unacknowledged messages are re-sent after the timeout; the session is closed when the retries limit
+// is exceeded (or the buffer is full).
+type reliableDelivery struct {
+	session    *Session
+	bufferSize int
+	timeout    time.Duration
+	maxRetries int
+
+	mu            sync.Mutex
+	subscriptions map[string]bool
+	seq           uint64
+	pending       map[uint64]*pendingMessage
+	timer         *time.Timer
+	closed        bool
+}
+
+func newReliableDelivery(s *Session, c *Config) *reliableDelivery {
+	return &reliableDelivery{
+		session:       s,
+		bufferSize:    c.ReliableBufferSize,
+		timeout:       time.Duration(c.ReliableAckTimeout) * time.Second,
+		maxRetries:    c.ReliableMaxRetries,
+		subscriptions: make(map[string]bool),
+		pending:       make(map[uint64]*pendingMessage),
+	}
+}
+
+func (d *reliableDelivery) subscribe(identifier string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.subscriptions[identifier] = true
+}
+
+func (d *reliableDelivery) unsubscribe(identifier string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.subscriptions, identifier)
+
+	for id, msg := range d.pending {
+		if msg.reply.Identifier == identifier {
+			delete(d.pending, id)
+		}
+	}
+}
+
+func (d *reliableDelivery) subscribed(identifier string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.subscriptions[identifier]
+}
+
+// send assigns an ID to the message, stores it in the pending buffer and sends to the client
+func (d *reliableDelivery) send(reply *common.Reply) {
+	d.mu.Lock()
+
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+
+	if len(d.pending) >= d.bufferSize {
+		d.mu.Unlock()
+		d.session.Log.Debugf("Pending messages buffer is full (%d), closing the session", d.bufferSize)
+		d.fail()
+		return
+	}
+
+	d.seq++
+	reply.ID = d.seq
+
+	now := time.Now()
+	d.pending[reply.ID] = &pendingMessage{reply: reply, sentAt: now, lastSentAt: now}
+
+	if d.timer == nil {
+		d.timer = time.AfterFunc(d.timeout, d.retry)
+	}
+
+	d.mu.Unlock()
+
+	d.session.Send(reply)
+}
+
+// ack removes the message from the pending buffer
+func (d *reliableDelivery) ack(id uint64) bool {
+	d.mu.Lock()
+	msg, ok := d.pending[id]
+	delete(d.pending, id)
+	d.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	d.session.node.Metrics.Histogram(metricsDeliveryAckDuration).Observe(time.Since(msg.sentAt).Seconds())
+
+	return true
+}
+
+// retry re-sends the messages which haven't been acknowledged in time
+func (d *reliableDelivery) retry() {
+	d.mu.Lock()
+
+	if d.closed {
+		d.mu.Unlock()
+		return
+	}
+
+	d.timer = nil
+
+	now := time.Now()
+	resend := []*common.Reply{}
+	failed := false
+
+	ids := make([]uint64, 0, len(d.pending))
+	for id := range d.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	for _, id := range ids {
+		msg := d.pending[id]
+
+		if now.Sub(msg.lastSentAt) < d.timeout {
+			continue
+		}
+
+		if msg.attempts >= d.maxRetries {
+			failed = true
+			break
+		}
+
+		msg.attempts++
+		msg.lastSentAt = now
+		resend = append(resend, msg.reply)
+	}
+
+	if !failed && len(d.pending) > 0 {
+		d.timer = time.AfterFunc(d.timeout, d.retry)
+	}
+
+	d.mu.Unlock()
+
+	if failed {
+		d.session.Log.Debugf("Message hasn't been acknowledged after %d retries, closing the session", d.maxRetries)
+		d.fail()
+		return
+	}
+
+	if len(resend) > 0 {
+		d.session.node.Metrics.Counter(metricsDeliveryRetries).Add(uint64(len(resend)))
+	}
+
+	for _, reply := range resend {
+		d.session.Send(reply)
+	}
+}
+
+func (d *reliableDelivery) fail() {
+	d.session.node.Metrics.Counter(metricsDeliveryFailed).Inc()
+	d.session.Disconnect(deliveryFailedReason, closeCode(deliveryFailedReason))
+}
+
+// stop cancels pending retries (when the session is closed)
+func (d *reliableDelivery) stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+}
@@ -0,0 +1,88 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReliableDelivery(t *testing.T) {
+	node := NewMockNode()
+
+	session := NewMockSession("14", &node)
+	plain := NewMockSession("15", &node)
+
+	node.hub.addSession(session)
+	node.hub.addSession(plain)
+
+	_, err := node.Subscribe(session, &common.Message{Identifier: "with_stream", Reliable: true})
+	assert.Nil(t, err)
+
+	_, err = session.conn.Read()
+	assert.Nil(t, err)
+
+	_, err = node.Subscribe(plain, &common.Message{Identifier: "with_stream"})
+	assert.Nil(t, err)
+
+	_, err = plain.conn.Read()
+	assert.Nil(t, err)
+
+	reliable := session.reliableDeliveryFor("with_stream")
+
+	if !assert.NotNil(t, reliable) {
+		return
+	}
+
+	assert.Nil(t, plain.reliableDeliveryFor("with_stream"))
+
+	reliable.timeout = 50 * time.Millisecond
+	reliable.maxRetries = 1
+
+	t.Run("Acknowledged message", func(t *testing.T) {
+		node.hub.broadcastToStream("stream", "42")
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"identifier\":\"with_stream\",\"message\":42,\"id\":1}", string(msg))
+
+		// Other sessions are not affected
+		msg, err = plain.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"identifier\":\"with_stream\",\"message\":42}", string(msg))
+
+		assert.Nil(t, node.HandleCommand(session, &common.Message{Command: "ack", Identifier: "with_stream", ID: 1}))
+
+		// No redeliveries
+		_, err = session.conn.Read()
+		assert.NotNil(t, err)
+
+		assert.Equal(t, uint64(0), node.Metrics.Counter(metricsDeliveryRetries).Value())
+	})
+
+	t.Run("Ack for a non-reliable subscription", func(t *testing.T) {
+		assert.NotNil(t, node.Ack(plain, &common.Message{Identifier: "with_stream", ID: 1}))
+	})
+
+	t.Run("Redelivery and failure", func(t *testing.T) {
+		node.hub.broadcastToStream("stream", "43")
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"identifier\":\"with_stream\",\"message\":43,\"id\":2}", string(msg))
+
+		msg, err = session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"identifier\":\"with_stream\",\"message\":43,\"id\":2}", string(msg))
+
+		assert.Equal(t, uint64(1), node.Metrics.Counter(metricsDeliveryRetries).Value())
+
+		// The session is closed after the retries limit is exceeded
+		msg, err = session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "", string(msg))
+
+		assert.Equal(t, uint64(1), node.Metrics.Counter(metricsDeliveryFailed).Value())
+	})
+}
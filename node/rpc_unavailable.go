@@ -0,0 +1,159 @@
+package node
+
+import (
+	"errors"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+)
+
+const (
+	metricsRPCUnavailableAuths = "auth_rpc_unavailable_total"
+	metricsAnonymousReauths    = "anonymous_reauth_total"
+
+	// How often to check whether the controller became ready ("queue" strategy)
+	controllerReadyCheckInterval = 100 * time.Millisecond
+
+	welcomeTransmission = "{\"type\":\"welcome\"}"
+)
+
+// errReauthFailed is returned when the anonymous session hasn't passed authentication
+var errReauthFailed = errors.New("Anonymous session re-authentication failed")
+
+// waitForController holds the session until the controller is ready (if it supports readiness checks),
+// the queue timeout expires, or the session is closed
+func (n *Node) waitForController(s *Session) {
+	probe, ok := n.controllerFor(s).(interface{ Ready() error })
+
+	if !ok || probe.Ready() == nil {
+		return
+	}
+
+	s.Log.Debugf("RPC is not ready, waiting for up to %ds", n.config.RPCUnavailableQueueTimeout)
+
+	timeout := time.After(time.Duration(n.config.RPCUnavailableQueueTimeout) * time.Second)
+	ticker := time.NewTicker(controllerReadyCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if probe.Ready() == nil {
+				return
+			}
+		case <-timeout:
+			return
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// handleRPCUnavailable handles the session which couldn't be authenticated due to unavailable RPC
+// according to the configured strategy
+func (n *Node) handleRPCUnavailable(s *Session, err error) (*common.ConnectResult, error) {
+	n.Metrics.Counter(metricsRPCUnavailableAuths).Inc()
+
+	if n.config.RPCUnavailableStrategy == RPCUnavailableAllowAnonymous {
+		s.Log.Debugf("Accepting anonymous session: %v", err)
+		return n.acceptAnonymous(s), nil
+	}
+
+	s.Send(&common.DisconnectMessage{
+		Type:       "disconnect",
+		Reason:     rpcUnavailableReason,
+		Reconnect:  true,
+		RetryAfter: n.config.RPCUnavailableRetryAfter,
+	})
+	s.Disconnect(rpcUnavailableReason, closeCode(rpcUnavailableReason))
+
+	return nil, err
+}
+
+// acceptAnonymous registers the session with the anonymous identifiers (the session is authenticated on the next command)
+func (n *Node) acceptAnonymous(s *Session) *common.ConnectResult {
+	s.Identifiers = n.config.AnonymousIdentifiers
+
+	s.mu.Lock()
+	s.anonymous = true
+	s.mu.Unlock()
+
+	// The application hasn't seen the session yet
+	s.disconnectMode = common.DisconnectModeNever
+
+	if !n.registerSession(s) {
+		return &common.ConnectResult{Status: common.FAILURE}
+	}
+
+	s.Connected = true
+	s.SendJSONTransmission(n.composeWelcome(s, []string{welcomeTransmission})[0])
+
+	return &common.ConnectResult{Status: common.SUCCESS, Identifier: s.Identifiers}
+}
+
+// reauthenticate performs authentication for the anonymous session.
+// Sessions are kept anonymous while RPC is unavailable; rejected sessions are disconnected.
+func (n *Node) reauthenticate(s *Session) error {
+	s.mu.Lock()
+	anonymous := s.anonymous
+	s.mu.Unlock()
+
+	if !anonymous {
+		return nil
+	}
+
+	res, err := n.controllerFor(s).Authenticate(s.UID, s.env)
+
+	if err != nil {
+		if errors.Is(err, common.ErrRPCUnavailable) {
+			return nil
+		}
+
+		return err
+	}
+
+	n.Metrics.CounterVec(metricsAnonymousReauths).With(reauthResult(res.Status)).Inc()
+
+	if res.Status != common.SUCCESS {
+		n.Metrics.Counter(metricsFailedAuths).Inc()
+
+		transmit(s, res.Transmissions)
+		s.Disconnect(unauthorizedReason, closeCode(unauthorizedReason))
+
+		return errReauthFailed
+	}
+
+	s.mu.Lock()
+	s.anonymous = false
+	s.mu.Unlock()
+
+	s.disconnectMode = n.config.DisconnectMode
+
+	if res.DisconnectMode != "" && common.ValidDisconnectMode(res.DisconnectMode) {
+		s.disconnectMode = res.DisconnectMode
+	}
+
+	n.hub.updateIdentifiers(s, res.Identifier)
+
+	if res.CState != nil {
+		s.smu.Lock()
+		s.env.MergeConnectionState(&res.CState)
+		s.smu.Unlock()
+	}
+
+	if n.events != nil {
+		n.events.Emit(newSessionEvent(EventSessionAuthenticated, s))
+	}
+
+	s.Log.Debugf("Anonymous session authenticated: %s", res.Identifier)
+
+	return nil
+}
+
+func reauthResult(status int) string {
+	if status == common.SUCCESS {
+		return "success"
+	}
+
+	return "failure"
+}
@@ -0,0 +1,151 @@
+package node
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/stretchr/testify/assert"
+)
+
+// unavailableController emulates RPC outages
+type unavailableController struct {
+	mocks.MockController
+
+	mu        sync.Mutex
+	available bool
+}
+
+func (c *unavailableController) SetAvailable(val bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.available = val
+}
+
+func (c *unavailableController) Ready() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.available {
+		return fmt.Errorf("%w: connection refused", common.ErrRPCUnavailable)
+	}
+
+	return nil
+}
+
+func (c *unavailableController) Authenticate(sid string, env *common.SessionEnv) (*common.ConnectResult, error) {
+	if err := c.Ready(); err != nil {
+		return nil, err
+	}
+
+	return c.MockController.Authenticate(sid, env)
+}
+
+func newUnavailableNode(strategy string) (*Node, *unavailableController) {
+	controller := &unavailableController{MockController: mocks.NewMockController()}
+	config := NewConfig()
+	config.RPCUnavailableStrategy = strategy
+	config.RPCUnavailableQueueTimeout = 1
+	config.AnonymousIdentifiers = "anonymous"
+
+	node := NewNode(controller, metrics.NewMetrics(nil, 10), &config)
+	dconfig := NewDisconnectQueueConfig()
+	node.SetDisconnector(NewDisconnectQueue(node, &dconfig))
+
+	return node, controller
+}
+
+func TestAuthenticateWhenRPCUnavailable(t *testing.T) {
+	t.Run("Reject", func(t *testing.T) {
+		node, _ := newUnavailableNode(RPCUnavailableReject)
+		session := NewMockSessionWithEnv("1", node, "/cable", &map[string]string{"id": "test_id"})
+
+		_, err := node.Authenticate(session)
+		assert.ErrorIs(t, err, common.ErrRPCUnavailable)
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"type\":\"disconnect\",\"reason\":\"rpc_unavailable\",\"reconnect\":true,\"retry_after\":5}", string(msg))
+
+		assert.False(t, session.Connected)
+		assert.Equal(t, ws.CloseTryAgainLater, session.closeCode)
+		assert.Equal(t, 0, node.hub.Size())
+		assert.Equal(t, uint64(1), node.Metrics.Counter(metricsRPCUnavailableAuths).Value())
+	})
+
+	t.Run("Allow anonymous", func(t *testing.T) {
+		node, controller := newUnavailableNode(RPCUnavailableAllowAnonymous)
+		session := NewMockSessionWithEnv("1", node, "/cable", &map[string]string{"id": "test_id"})
+
+		res, err := node.Authenticate(session)
+		assert.Nil(t, err)
+		assert.Equal(t, common.SUCCESS, res.Status)
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"type\":\"welcome\"}", string(msg))
+
+		assert.True(t, session.Connected)
+		assert.Equal(t, "anonymous", session.Identifiers)
+		assert.Equal(t, common.DisconnectModeNever, session.disconnectMode)
+
+		// RPC is still unavailable: the session stays anonymous
+		assert.Nil(t, node.reauthenticate(session))
+		assert.Equal(t, "anonymous", session.Identifiers)
+
+		controller.SetAvailable(true)
+
+		assert.Nil(t, node.HandleCommand(session, &common.Message{Command: "subscribe", Identifier: "test_channel"}))
+
+		assert.Equal(t, "test_id", session.Identifiers)
+		assert.False(t, session.anonymous)
+		assert.Equal(t, common.DisconnectModeAlways, session.disconnectMode)
+		assert.Same(t, session, node.hub.findByIdentifier("test_id"))
+		assert.Nil(t, node.hub.findByIdentifier("anonymous"))
+	})
+
+	t.Run("Allow anonymous when rejected after recovery", func(t *testing.T) {
+		node, controller := newUnavailableNode(RPCUnavailableAllowAnonymous)
+		session := NewMockSessionWithEnv("1", node, "/failure", &map[string]string{"id": "test_id"})
+
+		_, err := node.Authenticate(session)
+		assert.Nil(t, err)
+
+		controller.SetAvailable(true)
+
+		err = node.HandleCommand(session, &common.Message{Command: "subscribe", Identifier: "test_channel"})
+		assert.Equal(t, errReauthFailed, err)
+		assert.False(t, session.Connected)
+		assert.Equal(t, unauthorizedReason, session.closeReason)
+	})
+
+	t.Run("Queue", func(t *testing.T) {
+		node, controller := newUnavailableNode(RPCUnavailableQueue)
+		session := NewMockSessionWithEnv("1", node, "/cable", &map[string]string{"id": "test_id"})
+
+		time.AfterFunc(200*time.Millisecond, func() { controller.SetAvailable(true) })
+
+		res, err := node.Authenticate(session)
+		assert.Nil(t, err)
+		assert.Equal(t, common.SUCCESS, res.Status)
+		assert.Equal(t, "test_id", session.Identifiers)
+	})
+
+	t.Run("Queue timeout", func(t *testing.T) {
+		node, _ := newUnavailableNode(RPCUnavailableQueue)
+		session := NewMockSessionWithEnv("1", node, "/cable", &map[string]string{"id": "test_id"})
+
+		start := time.Now()
+
+		_, err := node.Authenticate(session)
+		assert.ErrorIs(t, err, common.ErrRPCUnavailable)
+		assert.GreaterOrEqual(t, time.Since(start), time.Second)
+		assert.Equal(t, rpcUnavailableReason, session.closeReason)
+	})
+}
@@ -1,12 +1,16 @@
 package node
 
 import (
+	"context"
 	"errors"
+	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/anycable/anycable-go/common"
 	"github.com/anycable/anycable-go/encoders"
+	"github.com/anycable/anycable-go/utils"
 	"github.com/anycable/anycable-go/ws"
 	"github.com/apex/log"
 )
@@ -35,15 +39,61 @@ type Session struct {
 	smu sync.Mutex
 
 	sendCh chan *ws.SentFrame
+	// Whether the connection reads messages into pooled buffers (see PooledReader)
+	pooled bool
 
 	pingTimer    *time.Timer
 	pingInterval time.Duration
 
 	pingTimestampPrecision string
 
+	// The max number of pings sent without any response from the client
+	pongTimeout int
+	// The number of pings sent since the last client activity
+	missedPings int32
+
+	// Closes the session when the max lifetime is exceeded
+	lifetimeTimer *time.Timer
+	// Sends the warning message before the session credentials expire
+	expiryTimer *time.Timer
+
 	UID         string
 	Identifiers string
 	Connected   bool
+	// Session creation time
+	CreatedAt time.Time
+	// Client protocol version (see ws.ProtocolVersion)
+	ProtocolVersion string
+	// Client IP (used for audit events)
+	RemoteIP string
+	// When the underlying connection has been accepted (used to measure the time to welcome)
+	AcceptedAt time.Time
+	// Close reason and code (the first ones win)
+	closeReason string
+	closeCode   int
+	// Whether the session lifecycle events are being emitted (set when the opened event is emitted)
+	eventsTracked bool
+	// Whether the session holds a capacity slot (see Config.MaxSessions)
+	slotReserved bool
+	// Whether the session has been accepted without authentication (see RPCUnavailableAllowAnonymous)
+	anonymous bool
+	// Commands rate limiting state (created on the first rate-limited command)
+	commandsLimiter *commandsLimiter
+	// At-least-once delivery state (created on the first reliable subscription)
+	reliable *reliableDelivery
+	// Live broadcasts held while the subscriptions history is being replayed
+	replays historyReplays
+	// Whether to call Disconnect RPC when the session is closed (see common.DisconnectMode*)
+	disconnectMode string
+	// Whether the session has ever been successfully subscribed to a channel
+	everSubscribed bool
+	// Controller to perform the session commands (the node controller is used if nil)
+	controller Controller
+	// The name of the custom controller (see Node.RegisterController)
+	controllerName string
+	// Session lifecycle context (cancelled on close to abort in-flight commands)
+	ctx    context.Context
+	cancel context.CancelFunc
 	// Could be used to store arbitrary data within a session
 	InternalState map[string]interface{}
 	Log           *log.Entry
@@ -59,8 +109,10 @@ func NewSession(node *Node, conn Connection, url string, headers *map[string]str
 		sendCh:                 make(chan *ws.SentFrame, 256),
 		closed:                 false,
 		Connected:              false,
-		pingInterval:           time.Duration(node.config.PingInterval) * time.Second,
+		pingInterval:           node.PingInterval(),
 		pingTimestampPrecision: node.config.PingTimestampPrecision,
+		pongTimeout:            node.config.PongTimeout,
+		disconnectMode:         node.config.DisconnectMode,
 		// Use JSON by default
 		encoder: encoders.JSON{},
 		// Use Action Cable executor by default (implemented by node)
@@ -68,6 +120,10 @@ func NewSession(node *Node, conn Connection, url string, headers *map[string]str
 	}
 
 	session.UID = uid
+	session.CreatedAt = time.Now()
+	session.env.Store = common.NewSessionStore(node.config.SessionStoreMaxSize, node.sessionStoreRPCKeys)
+	session.ProtocolVersion = ws.ProtocolV1
+	session.ctx, session.cancel = context.WithCancel(context.Background())
 
 	ctx := node.log.WithFields(log.Fields{
 		"sid": session.UID,
@@ -75,12 +131,68 @@ func NewSession(node *Node, conn Connection, url string, headers *map[string]str
 
 	session.Log = ctx
 
+	_, session.pooled = conn.(PooledReader)
+
+	if pinger, ok := conn.(Pinger); ok && session.pongTimeout > 0 {
+		pinger.OnPong(session.resetMissedPings)
+	}
+
 	session.addPing()
+
+	if lifetime := sessionLifetime(node.config); lifetime > 0 {
+		session.lifetimeTimer = time.AfterFunc(lifetime, session.expire)
+	}
+
 	go session.SendMessages()
 
 	return session
 }
 
+// pendingMessages returns the number of messages waiting to be written to the connection
+func (s *Session) pendingMessages() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.sendCh)
+}
+
+// Store returns the session-scoped key-value storage (cleared when the session is disconnected)
+func (s *Session) Store() *common.SessionStore {
+	return s.env.Store
+}
+
+// UseController sets the custom controller registered with the specified name to perform the session commands
+// (e.g., for sessions served via additional mounts). Returns false if there is no such controller.
+func (s *Session) UseController(name string) bool {
+	c, ok := s.node.controllers[name]
+
+	if !ok {
+		return false
+	}
+
+	s.controller = c
+	s.controllerName = name
+
+	return true
+}
+
+// SetPingInterval overrides the node ping interval for the session
+// (must be called right after the session is created)
+func (s *Session) SetPingInterval(interval time.Duration) {
+	if s.pingTimer != nil {
+		s.pingTimer.Stop()
+	}
+
+	s.pingInterval = interval
+	s.addPing()
+}
+
+func (s *Session) clearStore() {
+	if s.env.Store != nil {
+		s.env.Store.Clear()
+	}
+}
+
 func (s *Session) SetEncoder(enc encoders.Encoder) {
 	s.encoder = enc
 }
@@ -115,18 +227,65 @@ func (s *Session) MergeEnv(env *common.SessionEnv) {
 	}
 }
 
+// SetProtocolVersion sets the negotiated client protocol version
+func (s *Session) SetProtocolVersion(version string) {
+	s.ProtocolVersion = version
+	s.Log = s.Log.WithField("protocol", version)
+}
+
+// EnableReliableDelivery turns on at-least-once delivery for the subscription
+func (s *Session) EnableReliableDelivery(identifier string) {
+	s.mu.Lock()
+
+	if s.reliable == nil {
+		s.reliable = newReliableDelivery(s, s.node.config)
+	}
+
+	reliable := s.reliable
+	s.mu.Unlock()
+
+	reliable.subscribe(identifier)
+}
+
+// reliableDeliveryFor returns the reliable delivery state if it's enabled for the subscription
+func (s *Session) reliableDeliveryFor(identifier string) *reliableDelivery {
+	s.mu.Lock()
+	reliable := s.reliable
+	s.mu.Unlock()
+
+	if reliable == nil || !reliable.subscribed(identifier) {
+		return nil
+	}
+
+	return reliable
+}
+
+// deliverBroadcast sends the broadcasted message to the subscription (using reliable delivery if enabled)
+func (s *Session) deliverBroadcast(msg *common.StreamMessage, identifier string) {
+	if reliable := s.reliableDeliveryFor(identifier); reliable != nil {
+		reliable.send(buildReply(msg, identifier))
+		return
+	}
+
+	s.Send(buildMessage(msg, identifier))
+}
+
 // Serve enters a loop to read incoming data
 func (s *Session) Serve(callback func()) error {
 	go func() {
 		defer callback()
 
 		for {
-			message, err := s.conn.Read()
+			message, err := s.readFrame()
 
 			if err != nil {
 				if ws.IsCloseError(err) {
 					s.Log.Debugf("Websocket closed: %v", err)
 					s.disconnectNow("Read closed", ws.CloseNormalClosure)
+				} else if ws.IsMessageTooBigError(err) {
+					s.Log.Warnf("Incoming message exceeds the max message size, closing connection: %v", err)
+					s.node.Metrics.Counter(metricsMessageTooBig).Inc()
+					s.disconnectNow(messageTooBigReason, closeCode(messageTooBigReason))
 				} else {
 					s.Log.Debugf("Websocket close error: %v", err)
 					s.disconnectNow("Read failed", ws.CloseAbnormalClosure)
@@ -136,6 +295,11 @@ func (s *Session) Serve(callback func()) error {
 
 			err = s.ReadMessage(message)
 
+			// Decoded commands don't refer to the raw message, so the buffer could be reused
+			if s.pooled {
+				utils.PutBytes(message)
+			}
+
 			if err != nil {
 				return
 			}
@@ -145,12 +309,21 @@ func (s *Session) Serve(callback func()) error {
 	return nil
 }
 
+func (s *Session) readFrame() ([]byte, error) {
+	if s.pooled {
+		return s.conn.(PooledReader).ReadPooled()
+	}
+
+	return s.conn.Read()
+}
+
 // SendMessages waits for incoming messages and send them to the client connection
 func (s *Session) SendMessages() {
 	defer s.disconnectNow("Write Failed", ws.CloseAbnormalClosure)
 
 	for message := range s.sendCh {
 		err := s.writeFrame(message)
+		message.Release()
 
 		if err != nil {
 			s.node.Metrics.Counter(metricsFailedSent).Inc()
@@ -165,6 +338,10 @@ func (s *Session) SendMessages() {
 func (s *Session) ReadMessage(message []byte) error {
 	s.node.Metrics.Counter(metricsDataReceived).Add(uint64(len(message)))
 
+	if s.pongTimeout > 0 {
+		s.resetMissedPings()
+	}
+
 	command, err := s.decodeMessage(message)
 
 	if err != nil {
@@ -188,6 +365,10 @@ func (s *Session) ReadMessage(message []byte) error {
 
 // Send schedules a data transmission
 func (s *Session) Send(msg encoders.EncodedMessage) {
+	if dmsg, ok := msg.(*common.DisconnectMessage); ok && s.node != nil {
+		msg = s.node.withDisconnectExtra(dmsg)
+	}
+
 	if b, err := s.encodeMessage(msg); err == nil {
 		if b != nil {
 			s.sendFrame(b)
@@ -211,6 +392,7 @@ func (s *Session) SendJSONTransmission(msg string) {
 
 // Disconnect schedules connection disconnect
 func (s *Session) Disconnect(reason string, code int) {
+	s.setCloseReason(reason, code)
 	s.disconnectFromNode()
 	s.sendClose(reason, code)
 	s.close()
@@ -225,7 +407,23 @@ func (s *Session) disconnectFromNode() {
 	s.mu.Unlock()
 }
 
+// disconnectRequired returns true if Disconnect RPC must be called for the session
+func (s *Session) disconnectRequired() bool {
+	switch s.disconnectMode {
+	case common.DisconnectModeNever:
+		return false
+	case common.DisconnectModeOnlyIfSubscribed:
+		s.smu.Lock()
+		defer s.smu.Unlock()
+
+		return s.everSubscribed
+	}
+
+	return true
+}
+
 func (s *Session) disconnectNow(reason string, code int) {
+	s.setCloseReason(reason, code)
 	s.disconnectFromNode()
 	s.writeFrame(&ws.SentFrame{ // nolint:errcheck
 		FrameType:   ws.CloseFrame,
@@ -234,12 +432,20 @@ func (s *Session) disconnectNow(reason string, code int) {
 	})
 
 	s.mu.Lock()
-	if s.sendCh != nil {
-		close(s.sendCh)
+	ch := s.sendCh
+	if ch != nil {
+		close(ch)
 		s.sendCh = nil
 	}
 	s.mu.Unlock()
 
+	// Release the pending frames (they're never written after the close frame)
+	if ch != nil {
+		for frame := range ch {
+			frame.Release()
+		}
+	}
+
 	s.close()
 }
 
@@ -252,11 +458,43 @@ func (s *Session) close() {
 	}
 
 	s.closed = true
+	reliable := s.reliable
+	trackEvents := s.eventsTracked
 	s.mu.Unlock()
 
+	s.node.releaseSessionSlot(s)
+
+	if trackEvents {
+		s.node.emitClosedEvent(s)
+	}
+
+	if s.cancel != nil {
+		s.cancel()
+	}
+
+	if reliable != nil {
+		reliable.stop()
+	}
+
 	if s.pingTimer != nil {
 		s.pingTimer.Stop()
 	}
+
+	if s.lifetimeTimer != nil {
+		s.lifetimeTimer.Stop()
+	}
+
+	s.stopExpiryTimer()
+}
+
+func (s *Session) setCloseReason(reason string, code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closeReason == "" {
+		s.closeReason = reason
+		s.closeCode = code
+	}
 }
 
 func (s *Session) sendClose(reason string, code int) {
@@ -272,12 +510,15 @@ func (s *Session) sendFrame(message *ws.SentFrame) {
 
 	if s.sendCh == nil {
 		s.mu.Unlock()
+		message.Release()
 		return
 	}
 
 	select {
 	case s.sendCh <- message:
 	default:
+		message.Release()
+
 		if s.sendCh != nil {
 			close(s.sendCh)
 			defer s.Disconnect("Write failed", ws.CloseAbnormalClosure)
@@ -324,9 +565,17 @@ func (s *Session) sendPing() {
 		return
 	}
 
+	if s.pongTimeout > 0 && int(atomic.AddInt32(&s.missedPings, 1)) > s.pongTimeout {
+		s.Log.Debugf("No response received after %d pings", s.pongTimeout)
+		s.node.Metrics.Counter(metricsStaleConnectionsClosed).Inc()
+		s.Send(newDisconnectMessage(noPongReason, true))
+		s.Disconnect(noPongReason, closeCode(noPongReason))
+		return
+	}
+
 	deadline := time.Now().Add(s.pingInterval / 2)
 
-	b, err := s.encodeMessage(newPingMessage(s.pingTimestampPrecision))
+	b, err := s.pingFrame()
 
 	if err != nil {
 		s.Log.Errorf("Failed to encode ping message: %v", err)
@@ -334,6 +583,14 @@ func (s *Session) sendPing() {
 		err = s.writeFrameWithDeadline(b, deadline)
 	}
 
+	// Action Cable clients do not respond to ping messages,
+	// so we rely on protocol-level pongs to detect stale connections
+	if err == nil && s.pongTimeout > 0 {
+		if pinger, ok := s.conn.(Pinger); ok {
+			err = pinger.WritePing(deadline)
+		}
+	}
+
 	if err != nil {
 		s.Disconnect("Ping failed", ws.CloseAbnormalClosure)
 		return
@@ -342,23 +599,74 @@ func (s *Session) sendPing() {
 	s.addPing()
 }
 
+// expire asks the client to reconnect and closes the session when the max lifetime is exceeded
+func (s *Session) expire() {
+	if s.closed {
+		return
+	}
+
+	s.Log.Debugf("Session max lifetime exceeded")
+	s.node.Metrics.Counter(metricsExpiredSessions).Inc()
+	s.Send(newDisconnectMessage(sessionExpiredReason, true))
+	s.Disconnect(sessionExpiredReason, closeCode(sessionExpiredReason))
+}
+
+// sessionLifetime returns the max lifetime for a new session
+// with a random jitter subtracted (so sessions created at the same time do not expire together)
+func sessionLifetime(c *Config) time.Duration {
+	if c.SessionMaxLifetime <= 0 {
+		return 0
+	}
+
+	lifetime := time.Duration(c.SessionMaxLifetime) * time.Second
+
+	if c.SessionLifetimeJitter <= 0 {
+		return lifetime
+	}
+
+	maxJitter := int64(lifetime) * int64(c.SessionLifetimeJitter) / 100
+
+	if maxJitter <= 0 {
+		return lifetime
+	}
+
+	return lifetime - time.Duration(rand.Int63n(maxJitter+1)) // #nosec
+}
+
+func (s *Session) resetMissedPings() {
+	atomic.StoreInt32(&s.missedPings, 0)
+}
+
 func (s *Session) addPing() {
 	s.pingTimer = time.AfterFunc(s.pingInterval, s.sendPing)
 }
 
-func newPingMessage(format string) *common.PingMessage {
-	var ts int64
+// pingFrame returns the encoded ping message.
+// Sessions with built-in encoders and seconds precision use the frames shared via the node cache (to avoid allocations),
+// custom encoders are called every time. Sub-second timestamps are (almost) unique per ping, so caching them makes no sense.
+func (s *Session) pingFrame() (*ws.SentFrame, error) {
+	ts := pingTimestamp(s.pingTimestampPrecision)
+
+	if _, ok := s.encoder.(encoders.JSON); ok && s.node.pingFrames != nil && !subSecondPrecision(s.pingTimestampPrecision) {
+		return s.node.pingFrames.fetch(s.encoder, s.pingTimestampPrecision, ts)
+	}
 
+	return s.encodeMessage(&common.PingMessage{Type: "ping", Message: ts})
+}
+
+func subSecondPrecision(format string) bool {
+	return format == "ms" || format == "ns"
+}
+
+func pingTimestamp(format string) int64 {
 	switch format {
 	case "ns":
-		ts = time.Now().UnixNano()
+		return time.Now().UnixNano()
 	case "ms":
-		ts = time.Now().UnixNano() / int64(time.Millisecond)
+		return time.Now().UnixNano() / int64(time.Millisecond)
 	default:
-		ts = time.Now().Unix()
+		return time.Now().Unix()
 	}
-
-	return (&common.PingMessage{Type: "ping", Message: ts})
 }
 
 func (s *Session) encodeMessage(msg encoders.EncodedMessage) (*ws.SentFrame, error) {
@@ -374,6 +682,12 @@ func (s *Session) encodeMessage(msg encoders.EncodedMessage) (*ws.SentFrame, err
 }
 
 func (s *Session) encodeTransmission(msg string) (*ws.SentFrame, error) {
+	if s.pooled {
+		if pe, ok := s.encoder.(encoders.PooledEncoder); ok {
+			return pe.EncodeTransmissionPooled(msg)
+		}
+	}
+
 	return s.encoder.EncodeTransmission(msg)
 }
 
@@ -3,6 +3,7 @@ package node
 import (
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/anycable/anycable-go/common"
 	"github.com/anycable/anycable-go/ws"
@@ -143,3 +144,76 @@ func TestMergeEnv(t *testing.T) {
 	assert.Equal(t, "z", origEnv.GetChannelStateField("test_channel", "a"))
 	assert.Equal(t, "time", origEnv.GetChannelStateField("another_channel", "wasting"))
 }
+
+func TestSessionPongTimeout(t *testing.T) {
+	node := NewMockNode()
+	session := NewMockSession("123", &node)
+	session.closed = false
+	session.executor = &node
+	session.pingInterval = time.Hour
+	session.pongTimeout = 2
+
+	for i := 1; i <= 2; i++ {
+		session.sendPing()
+
+		_, err := session.conn.Read()
+		assert.Nil(t, err)
+	}
+
+	assert.Nil(t, session.ReadMessage([]byte("{\"command\":\"test\"}")))
+
+	for i := 1; i <= 2; i++ {
+		session.sendPing()
+
+		_, err := session.conn.Read()
+		assert.Nil(t, err)
+	}
+
+	assert.False(t, session.closed)
+
+	session.sendPing()
+
+	msg, err := session.conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, string(toJSON(newDisconnectMessage("no_pong", true))), string(msg))
+	// 1006 (abnormal closure) is reserved and must not be sent in close frames
+	assert.Equal(t, ws.ClosePongTimeout, closeCode(noPongReason))
+
+	assert.True(t, session.closed)
+	assert.Equal(t, uint64(1), node.Metrics.Counter(metricsStaleConnectionsClosed).Value())
+}
+
+func TestSessionExpire(t *testing.T) {
+	node := NewMockNode()
+	session := NewMockSession("123", &node)
+	session.closed = false
+
+	session.expire()
+
+	msg, err := session.conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, string(toJSON(newDisconnectMessage("session_expired", true))), string(msg))
+
+	assert.True(t, session.closed)
+	assert.Equal(t, uint64(1), node.Metrics.Counter(metricsExpiredSessions).Value())
+}
+
+func TestSessionLifetime(t *testing.T) {
+	c := NewConfig()
+
+	assert.Equal(t, time.Duration(0), sessionLifetime(&c))
+
+	c.SessionMaxLifetime = 100
+	c.SessionLifetimeJitter = 0
+
+	assert.Equal(t, 100*time.Second, sessionLifetime(&c))
+
+	c.SessionLifetimeJitter = 20
+
+	for i := 0; i < 100; i++ {
+		lifetime := sessionLifetime(&c)
+
+		assert.LessOrEqual(t, int64(lifetime), int64(100*time.Second))
+		assert.GreaterOrEqual(t, int64(lifetime), int64(80*time.Second))
+	}
+}
@@ -0,0 +1,76 @@
+package node
+
+import (
+	"strings"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/utils"
+	"github.com/apex/log"
+)
+
+const (
+	metricsBroadcastNamespaceMismatch = "broadcasts_namespace_mismatch_total"
+
+	// StreamsNamespaceSeparator separates the namespace from the stream name (e.g., "staging:chat_1")
+	StreamsNamespaceSeparator = ":"
+)
+
+// StreamsNamespacePrefix returns the prefix of the streams within the namespace (empty for the empty namespace)
+func StreamsNamespacePrefix(namespace string) string {
+	if namespace == "" {
+		return ""
+	}
+
+	return namespace + StreamsNamespaceSeparator
+}
+
+// namespacedStream returns the name of the stream within the node's namespace
+func (n *Node) namespacedStream(stream string) string {
+	return n.streamsPrefix + stream
+}
+
+// namespacedStreams returns the names of the streams within the node's namespace
+// (the slice is returned as is for the empty namespace)
+func (n *Node) namespacedStreams(streams []string) []string {
+	if n.streamsPrefix == "" || streams == nil {
+		return streams
+	}
+
+	res := make([]string, len(streams))
+
+	for i, stream := range streams {
+		res[i] = n.namespacedStream(stream)
+	}
+
+	return res
+}
+
+// namespacedBroadcast returns the copy of the broadcast with the stream within the node's namespace
+// (the message is returned as is for the empty namespace)
+func (n *Node) namespacedBroadcast(msg *common.StreamMessage) *common.StreamMessage {
+	if n.streamsPrefix == "" {
+		return msg
+	}
+
+	namespaced := *msg
+	namespaced.Stream = n.namespacedStream(msg.Stream)
+
+	return &namespaced
+}
+
+// inNamespace returns false (and tracks the mismatch) if the broadcast stream (or the remote command target)
+// doesn't belong to the node's namespace.
+// Stream names are not stripped: the hub stores streams with the namespace prefix (the same way they're subscribed to).
+func (n *Node) inNamespace(name string, raw []byte) bool {
+	if n.streamsPrefix == "" || (strings.HasPrefix(name, n.streamsPrefix) && len(name) > len(n.streamsPrefix)) {
+		return true
+	}
+
+	n.Metrics.Counter(metricsBroadcastNamespaceMismatch).Inc()
+	n.log.WithFields(log.Fields{
+		"stream":  name,
+		"payload": utils.TruncateBytes(raw, utils.MaxLoggedPayloadSize),
+	}).Debugf("Broadcast from another namespace dropped")
+
+	return false
+}
@@ -0,0 +1,181 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func NewMockNodeWithNamespace(namespace string) *Node {
+	controller := mocks.NewMockController()
+	config := NewConfig()
+	config.HubGopoolSize = 2
+	config.StreamsNamespace = namespace
+
+	return NewNode(&controller, metrics.NewMetrics(nil, 10), &config)
+}
+
+func TestStreamsNamespacePrefix(t *testing.T) {
+	assert.Equal(t, "", StreamsNamespacePrefix(""))
+	assert.Equal(t, "staging:", StreamsNamespacePrefix("staging"))
+}
+
+func TestHandlePubSubWithStreamsNamespace(t *testing.T) {
+	node := NewMockNodeWithNamespace("staging")
+
+	go node.hub.Run()
+	defer node.hub.Shutdown()
+
+	session := NewMockSession("14", node)
+	node.hub.addSession(session)
+	node.hub.subscribeSession("14", "staging:room", "test_channel")
+
+	node.HandlePubSub([]byte("{\"stream\":\"room\",\"data\":\"\\\"bare\\\"\"}"))
+	node.HandlePubSub([]byte("{\"stream\":\"preview:room\",\"data\":\"\\\"preview\\\"\"}"))
+	node.HandlePubSub([]byte("{\"stream\":\"staging:\",\"data\":\"\\\"empty\\\"\"}"))
+	node.HandlePubSub([]byte("{\"command\":\"stop_stream\",\"stream\":\"preview:room\"}"))
+	node.HandlePubSub([]byte("{\"stream\":\"staging:room\",\"data\":\"\\\"hello\\\"\"}"))
+
+	msg, err := session.conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"identifier\":\"test_channel\",\"message\":\"hello\"}", string(msg))
+
+	assert.Equal(t, uint64(4), node.Metrics.Counter(metricsBroadcastNamespaceMismatch).Value())
+	assert.Equal(t, 1, node.hub.StreamsSize())
+}
+
+func TestCommandRepliesWithStreamsNamespace(t *testing.T) {
+	node := NewMockNodeWithNamespace("staging")
+	session := NewMockSession("14", node)
+
+	node.hub.addSession(session)
+	defer node.hub.removeSession(session)
+
+	t.Run("Subscribe", func(t *testing.T) {
+		_, err := node.Subscribe(session, &common.Message{Identifier: "with_stream"})
+		assert.Nil(t, err)
+
+		_, err = session.conn.Read()
+		assert.Nil(t, err)
+
+		assert.Equal(t, []string{"staging:stream"}, node.hub.subscriptionStreams("14", "with_stream"))
+
+		node.hub.broadcastToStream("staging:stream", "41")
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"identifier\":\"with_stream\",\"message\":41}", string(msg))
+	})
+
+	t.Run("Perform with stopped streams", func(t *testing.T) {
+		session.subscriptions["test_channel"] = true
+
+		_, err := node.Perform(session, &common.Message{Identifier: "test_channel", Data: "stop_stream"})
+		assert.Nil(t, err)
+
+		subscription := <-node.hub.subscribe
+
+		assert.Equal(t, "staging:stop_stream", subscription.stream)
+	})
+
+	t.Run("Broadcasts", func(t *testing.T) {
+		node.handleCallReply(session, &common.CallResult{
+			Broadcasts: []*common.StreamMessage{{Stream: "stream", Data: "42"}},
+		})
+
+		broadcast := <-node.hub.broadcast
+
+		assert.Equal(t, "staging:stream", broadcast.msg.Stream)
+	})
+}
+
+func TestCommandRepliesWithoutStreamsNamespace(t *testing.T) {
+	node := NewMockNode()
+	session := NewMockSession("14", &node)
+
+	node.hub.addSession(session)
+	defer node.hub.removeSession(session)
+
+	_, err := node.Subscribe(session, &common.Message{Identifier: "with_stream"})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"stream"}, node.hub.subscriptionStreams("14", "with_stream"))
+	assert.Nil(t, node.Metrics.Counter(metricsBroadcastNamespaceMismatch))
+}
+
+func TestRemoteDisconnectWithStreamsNamespace(t *testing.T) {
+	node := NewMockNodeWithNamespace("staging")
+
+	go node.hub.Run()
+	defer node.hub.Shutdown()
+
+	session := NewMockSession("14", node)
+	session.closed = false
+	node.hub.addSession(session)
+
+	// Commands for the same identifier from another namespace (or without any) are dropped
+	node.HandlePubSub([]byte("{\"command\":\"disconnect\",\"payload\":{\"identifier\":\"14\",\"reconnect\":false}}"))
+	node.HandlePubSub([]byte("{\"command\":\"disconnect\",\"payload\":{\"identifier\":\"preview:14\",\"reconnect\":false}}"))
+
+	assert.Equal(t, uint64(2), node.Metrics.Counter(metricsBroadcastNamespaceMismatch).Value())
+
+	node.HandlePubSub([]byte("{\"command\":\"disconnect\",\"payload\":{\"identifier\":\"staging:14\",\"reconnect\":false}}"))
+
+	msg, err := session.conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, string(toJSON(newDisconnectMessage("remote", false))), string(msg))
+}
+
+func TestBroadcastFiltersWithStreamsNamespace(t *testing.T) {
+	node := NewMockNodeWithNamespace("staging")
+
+	go node.hub.Run()
+	defer node.hub.Shutdown()
+
+	session := NewMockSession("14", node)
+	node.hub.addSession(session)
+	node.hub.subscribeSession("14", "staging:chat_1", "test_channel")
+	node.hub.subscribeSession("14", "staging:room", "test_channel")
+
+	// Filters don't know about the namespace
+	filter, err := NewStreamPatternFilter("^chat_")
+	assert.Nil(t, err)
+
+	node.AddBroadcastFilter(filter)
+
+	node.HandlePubSub([]byte("{\"stream\":\"staging:room\",\"data\":\"\\\"rejected\\\"\"}"))
+	node.HandlePubSub([]byte("{\"stream\":\"staging:chat_1\",\"data\":\"\\\"hello\\\"\"}"))
+
+	msg, err := session.conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"identifier\":\"test_channel\",\"message\":\"hello\"}", string(msg))
+
+	assert.Equal(t, uint64(1), node.Metrics.Counter(metricsBroadcastRejected).Value())
+}
+
+func TestClientStreamsWithStreamsNamespace(t *testing.T) {
+	node := NewMockNodeWithNamespace("staging")
+
+	session := NewMockSession("14", node)
+	node.hub.addSession(session)
+	node.hub.subscribeSession("14", "staging:room", "test_channel")
+
+	t.Run("Stream IDs", func(t *testing.T) {
+		node.hub.deliverToStream(&common.StreamMessage{Stream: "staging:room", Data: "1", Offset: 1, Epoch: "x"})
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"identifier\":\"test_channel\",\"message\":1,\"stream_id\":\"room\",\"offset\":1,\"epoch\":\"x\"}", string(msg))
+	})
+
+	t.Run("Stop stream notifications", func(t *testing.T) {
+		node.hub.unsubscribeStream(&common.StopStreamMessage{Stream: "staging:room", Notify: true})
+
+		msg, err := session.conn.Read()
+		assert.Nil(t, err)
+		assert.Equal(t, "{\"type\":\"stream_stopped\",\"identifier\":\"test_channel\",\"message\":{\"stream\":\"room\"}}", string(msg))
+	})
+}
@@ -0,0 +1,117 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/ws"
+)
+
+// WelcomeComposer returns extra fields to add to the session welcome message (could return nil).
+// It's called synchronously during authentication, so it must be fast.
+type WelcomeComposer func(s *Session) map[string]interface{}
+
+// SetWelcomeComposer sets the function returning per-session welcome message fields
+// (must be called before the node starts accepting sessions)
+func (n *Node) SetWelcomeComposer(fn WelcomeComposer) {
+	n.welcomeComposer = fn
+}
+
+// ParseWelcomeExtra parses the welcome message extra fields (a JSON object)
+func ParseWelcomeExtra(raw string) (map[string]interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var fields map[string]interface{}
+
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return nil, fmt.Errorf("Welcome extra must be a JSON object: %v", err)
+	}
+
+	return fields, nil
+}
+
+// ParseDisconnectExtra parses the disconnect messages extra fields (a JSON object with reasons as keys and objects as values)
+func ParseDisconnectExtra(raw string) (map[string]map[string]interface{}, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	var fields map[string]map[string]interface{}
+
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return nil, fmt.Errorf("Disconnect extra must be a JSON object with reasons as keys and objects as values: %v", err)
+	}
+
+	return fields, nil
+}
+
+// composeWelcome adds extra fields to the welcome message: the static ones, the composed ones,
+// and the node identifier (for protocol v1.1 clients). The original fields are never overridden.
+// Other transmissions are returned as is.
+func (n *Node) composeWelcome(s *Session, transmissions []string) []string {
+	withNodeID := n.id != "" && s.ProtocolVersion == ws.ProtocolV11
+
+	if !withNodeID && n.welcomeExtra == nil && n.welcomeComposer == nil {
+		return transmissions
+	}
+
+	for i, transmission := range transmissions {
+		if !strings.Contains(transmission, common.WelcomeType) {
+			continue
+		}
+
+		var msg map[string]interface{}
+
+		if err := json.Unmarshal([]byte(transmission), &msg); err != nil || msg["type"] != common.WelcomeType {
+			continue
+		}
+
+		fields := make(map[string]interface{}, len(msg)+len(n.welcomeExtra)+1)
+
+		for k, v := range n.welcomeExtra {
+			fields[k] = v
+		}
+
+		if n.welcomeComposer != nil {
+			for k, v := range n.welcomeComposer(s) {
+				fields[k] = v
+			}
+		}
+
+		if withNodeID {
+			fields["node_id"] = n.id
+		}
+
+		for k, v := range msg {
+			fields[k] = v
+		}
+
+		if encoded, err := json.Marshal(fields); err == nil {
+			transmissions[i] = string(encoded)
+		} else {
+			s.Log.Warnf("Failed to compose welcome message: %v", err)
+		}
+	}
+
+	return transmissions
+}
+
+// withDisconnectExtra returns a copy of the disconnect message with the extra fields for its reason
+// (or the message itself if there are no extras)
+func (n *Node) withDisconnectExtra(msg *common.DisconnectMessage) *common.DisconnectMessage {
+	extra, ok := n.disconnectExtra[msg.Reason]
+
+	if !ok || msg.Extra != nil {
+		return msg
+	}
+
+	// Messages could be shared between sessions, so we must not modify them
+	dup := *msg
+	dup.Extra = extra
+
+	return &dup
+}
@@ -0,0 +1,106 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComposeWelcomeNodeID(t *testing.T) {
+	node := NewMockNode()
+	node.SetID("node-1")
+
+	session := NewMockSession("1", &node)
+	session.ProtocolVersion = ws.ProtocolV11
+
+	t.Run("Protocol v1.1", func(t *testing.T) {
+		transmissions := node.composeWelcome(session, []string{"{\"type\":\"welcome\",\"sid\":\"s1\"}", "{\"type\":\"ping\"}", "welcome"})
+
+		assert.Equal(t, []string{"{\"node_id\":\"node-1\",\"sid\":\"s1\",\"type\":\"welcome\"}", "{\"type\":\"ping\"}", "welcome"}, transmissions)
+	})
+
+	t.Run("Protocol v1", func(t *testing.T) {
+		legacy := NewMockSession("2", &node)
+		legacy.ProtocolVersion = ws.ProtocolV1
+
+		transmissions := node.composeWelcome(legacy, []string{"{\"type\":\"welcome\"}"})
+
+		assert.Equal(t, []string{"{\"type\":\"welcome\"}"}, transmissions)
+	})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		session := NewMockSessionWithEnv("3", &node, "/cable", &map[string]string{"id": "test_id"})
+		session.ProtocolVersion = ws.ProtocolV11
+
+		res, err := node.Authenticate(session)
+		assert.Nil(t, err)
+		// Mock controller doesn't send JSON welcome messages
+		assert.Equal(t, []string{"welcome"}, res.Transmissions)
+
+		info := node.SessionInfo("3")
+
+		if assert.NotNil(t, info) {
+			assert.Equal(t, "node-1", info.NodeID)
+		}
+	})
+}
+
+func TestComposeWelcomeExtra(t *testing.T) {
+	node := NewMockNode()
+	node.welcomeExtra, _ = ParseWelcomeExtra("{\"features\":[\"history\"],\"type\":\"custom\",\"sid\":\"static\"}")
+
+	node.SetWelcomeComposer(func(s *Session) map[string]interface{} {
+		return map[string]interface{}{"sid": s.UID, "server_time": 1634567890}
+	})
+
+	session := NewMockSession("s1", &node)
+	session.ProtocolVersion = ws.ProtocolV1
+
+	transmissions := node.composeWelcome(session, []string{"{\"type\":\"welcome\",\"server_time\":42}", "{\"type\":\"ping\"}"})
+
+	assert.Equal(t, []string{
+		"{\"features\":[\"history\"],\"server_time\":42,\"sid\":\"s1\",\"type\":\"welcome\"}",
+		"{\"type\":\"ping\"}",
+	}, transmissions)
+}
+
+func TestParseExtras(t *testing.T) {
+	welcome, err := ParseWelcomeExtra("")
+	assert.Nil(t, err)
+	assert.Nil(t, welcome)
+
+	_, err = ParseWelcomeExtra("[1,2]")
+	assert.Contains(t, err.Error(), "Welcome extra must be a JSON object")
+
+	disconnect, err := ParseDisconnectExtra("{\"server_full\":{\"retry_in\":30}}")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"retry_in": float64(30)}, disconnect["server_full"])
+
+	_, err = ParseDisconnectExtra("{\"server_full\":30}")
+	assert.Contains(t, err.Error(), "Disconnect extra must be a JSON object")
+}
+
+func TestDisconnectExtra(t *testing.T) {
+	node := NewMockNode()
+	node.disconnectExtra, _ = ParseDisconnectExtra("{\"server_full\":{\"retry_in\":30,\"reason\":\"other\"}}")
+
+	session := NewMockSession("1", &node)
+
+	shared := &common.DisconnectMessage{Type: "disconnect", Reason: "server_full", Reconnect: true}
+
+	session.Send(shared)
+	session.Send(&common.DisconnectMessage{Type: "disconnect", Reason: "server_restart", Reconnect: true})
+
+	msg, err := session.conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"reason\":\"server_full\",\"reconnect\":true,\"retry_in\":30,\"type\":\"disconnect\"}", string(msg))
+
+	msg, err = session.conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "{\"type\":\"disconnect\",\"reason\":\"server_restart\",\"reconnect\":true}", string(msg))
+
+	// The original message is not modified
+	assert.Nil(t, shared.Extra)
+}
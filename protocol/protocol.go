@@ -3,6 +3,7 @@ package protocol
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 
 	"github.com/anycable/anycable-go/common"
 
@@ -49,6 +50,17 @@ func ParseConnectResponse(response *pb.ConnectionResponse) (*common.ConnectResul
 
 	if response.Env != nil {
 		reply.CState = response.Env.Cstate
+
+		// Disconnect mode is passed via the connection state, it must not be stored
+		if mode, ok := reply.CState[common.DisconnectModeStateKey]; ok {
+			reply.DisconnectMode = mode
+			delete(reply.CState, common.DisconnectModeStateKey)
+		}
+
+		if expiresAt, ok := reply.CState[common.ExpiresAtStateKey]; ok {
+			reply.ExpiresAt, _ = strconv.ParseInt(expiresAt, 10, 64)
+			delete(reply.CState, common.ExpiresAtStateKey)
+		}
 	}
 
 	if response.Status.String() == "SUCCESS" {
@@ -106,6 +118,21 @@ func ParseDisconnectResponse(response *pb.DisconnectResponse) error {
 
 func buildEnv(env *common.SessionEnv) *pb.Env {
 	protoEnv := pb.Env{Url: env.URL, Headers: *env.Headers}
+	if env.Store != nil {
+		if entries := env.Store.RPCHeaders(); len(entries) > 0 {
+			headers := make(map[string]string, len(protoEnv.Headers)+len(entries))
+
+			for k, v := range protoEnv.Headers {
+				headers[k] = v
+			}
+
+			for k, v := range entries {
+				headers[k] = v
+			}
+
+			protoEnv.Headers = headers
+		}
+	}
 	if env.ConnectionState != nil {
 		protoEnv.Cstate = *env.ConnectionState
 	}
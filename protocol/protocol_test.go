@@ -64,6 +64,18 @@ func TestNewCommandMessage(t *testing.T) {
 		assert.Equal(t, cstate, msg.Env.Cstate)
 		assert.Equal(t, istate, msg.Env.Istate)
 	})
+
+	t.Run("With session store keys", func(t *testing.T) {
+		env := buildSessionEnv()
+		env.Store = common.NewSessionStore(0, []string{"locale", "tenant"})
+		env.Store.Set("locale", []byte("en"))    // nolint:errcheck
+		env.Store.Set("token", []byte("secret")) // nolint:errcheck
+
+		msg := NewCommandMessage(env, "subscribe", "test_channel", "user=john", "")
+
+		assert.Equal(t, map[string]string{"cookie": "token=secret;", "x-anycable-store-locale": "en"}, msg.Env.Headers)
+		assert.Equal(t, map[string]string{"cookie": "token=secret;"}, *env.Headers)
+	})
 }
 
 func TestNewDisconnectRequest(t *testing.T) {
@@ -104,6 +116,34 @@ func TestParseConnectResponse(t *testing.T) {
 		assert.Equal(t, common.SUCCESS, result.Status)
 	})
 
+	t.Run("Success with disconnect mode", func(t *testing.T) {
+		res := pb.ConnectionResponse{
+			Identifiers: "user=john",
+			Status:      pb.Status_SUCCESS,
+			Env:         &pb.EnvResponse{Cstate: map[string]string{"_s_": "test-session", common.DisconnectModeStateKey: "never"}},
+		}
+
+		result, err := ParseConnectResponse(&res)
+
+		assert.Nil(t, err)
+		assert.Equal(t, common.DisconnectModeNever, result.DisconnectMode)
+		assert.Equal(t, map[string]string{"_s_": "test-session"}, result.CState)
+	})
+
+	t.Run("Success with expiration time", func(t *testing.T) {
+		res := pb.ConnectionResponse{
+			Identifiers: "user=john",
+			Status:      pb.Status_SUCCESS,
+			Env:         &pb.EnvResponse{Cstate: map[string]string{"_s_": "test-session", common.ExpiresAtStateKey: "1700000000"}},
+		}
+
+		result, err := ParseConnectResponse(&res)
+
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1700000000), result.ExpiresAt)
+		assert.Equal(t, map[string]string{"_s_": "test-session"}, result.CState)
+	})
+
 	t.Run("Failure", func(t *testing.T) {
 		res := pb.ConnectionResponse{
 			Transmissions: []string{"unauthorized"},
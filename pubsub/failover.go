@@ -0,0 +1,145 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/apex/log"
+)
+
+// NamedSubscriber is a subscriber with the adapter name attached
+type NamedSubscriber struct {
+	Name string
+	Subscriber
+}
+
+// Healthy returns the subscriber health state (falls back to readiness if health checks are not supported)
+func (ns *NamedSubscriber) Healthy() error {
+	if checker, ok := ns.Subscriber.(HealthChecker); ok {
+		return checker.Healthy()
+	}
+
+	if probe, ok := ns.Subscriber.(interface{ Ready() error }); ok {
+		return probe.Ready()
+	}
+
+	return nil
+}
+
+// FailoverSubscriber runs the primary and the secondary subscribers simultaneously,
+// so broadcasts keep flowing while one of the adapters is unavailable.
+// Messages published via both adapters must contain broadcast IDs to be deduplicated by the node.
+type FailoverSubscriber struct {
+	primary   *NamedSubscriber
+	secondary *NamedSubscriber
+
+	mu      sync.Mutex
+	stopped bool
+
+	log *log.Entry
+}
+
+var _ Subscriber = (*FailoverSubscriber)(nil)
+
+// NewFailoverSubscriber builds a new FailoverSubscriber
+func NewFailoverSubscriber(primary *NamedSubscriber, secondary *NamedSubscriber) *FailoverSubscriber {
+	return &FailoverSubscriber{
+		primary:   primary,
+		secondary: secondary,
+		log:       log.WithFields(log.Fields{"context": "pubsub"}),
+	}
+}
+
+// Adapters returns the primary and the secondary subscribers
+func (s *FailoverSubscriber) Adapters() []*NamedSubscriber {
+	return []*NamedSubscriber{s.primary, s.secondary}
+}
+
+// Start starts both subscribers and blocks until they are stopped.
+// A failure of one of the subscribers is logged and doesn't affect the other one;
+// an error is returned only if both subscribers failed.
+func (s *FailoverSubscriber) Start() error {
+	adapters := s.Adapters()
+	errs := make(chan error, len(adapters))
+
+	for _, adapter := range adapters {
+		adapter := adapter
+
+		go func() {
+			err := adapter.Start()
+
+			if err != nil {
+				err = fmt.Errorf("%s: %v", adapter.Name, err)
+
+				if !s.isStopped() {
+					s.log.Errorf("Broadcast adapter failed: %v", err)
+				}
+			}
+
+			errs <- err
+		}()
+	}
+
+	failures := []string{}
+
+	for range adapters {
+		if err := <-errs; err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+
+	if len(failures) == len(adapters) {
+		return errors.New(strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+// Ready returns nil if any of the subscribers is ready
+func (s *FailoverSubscriber) Ready() error {
+	failures := []string{}
+
+	for _, adapter := range s.Adapters() {
+		probe, ok := adapter.Subscriber.(interface{ Ready() error })
+
+		if !ok {
+			return nil
+		}
+
+		err := probe.Ready()
+
+		if err == nil {
+			return nil
+		}
+
+		failures = append(failures, fmt.Sprintf("%s: %v", adapter.Name, err))
+	}
+
+	return errors.New(strings.Join(failures, "; "))
+}
+
+// Shutdown stops both subscribers
+func (s *FailoverSubscriber) Shutdown() error {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	var res error
+
+	for _, adapter := range s.Adapters() {
+		if err := adapter.Shutdown(); err != nil {
+			res = fmt.Errorf("%s: %v", adapter.Name, err)
+		}
+	}
+
+	return res
+}
+
+func (s *FailoverSubscriber) isStopped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stopped
+}
@@ -0,0 +1,76 @@
+package pubsub
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testSubscriber struct {
+	startErr error
+	ready    error
+	done     chan struct{}
+}
+
+func newTestSubscriber(startErr error, ready error) *testSubscriber {
+	return &testSubscriber{startErr: startErr, ready: ready, done: make(chan struct{})}
+}
+
+func (s *testSubscriber) Start() error {
+	if s.startErr != nil {
+		return s.startErr
+	}
+
+	<-s.done
+	return nil
+}
+
+func (s *testSubscriber) Shutdown() error {
+	close(s.done)
+	return nil
+}
+
+func (s *testSubscriber) Ready() error {
+	return s.ready
+}
+
+func TestFailoverSubscriber(t *testing.T) {
+	t.Run("Keeps running when one of the adapters fails", func(t *testing.T) {
+		primary := newTestSubscriber(errors.New("connection refused"), errors.New("Not connected"))
+		secondary := newTestSubscriber(nil, nil)
+
+		s := NewFailoverSubscriber(&NamedSubscriber{Name: "redis", Subscriber: primary}, &NamedSubscriber{Name: "http", Subscriber: secondary})
+
+		assert.Nil(t, s.Ready())
+		assert.NotNil(t, s.Adapters()[0].Healthy())
+		assert.Nil(t, s.Adapters()[1].Healthy())
+
+		res := make(chan error, 1)
+
+		go func() { res <- s.Start() }()
+
+		select {
+		case <-res:
+			t.Fatal("Subscriber must keep running")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		assert.Nil(t, s.Shutdown())
+		assert.Nil(t, <-res)
+	})
+
+	t.Run("Fails when both adapters fail", func(t *testing.T) {
+		primary := newTestSubscriber(errors.New("connection refused"), errors.New("Not connected"))
+		secondary := newTestSubscriber(errors.New("port is busy"), errors.New("Not started"))
+
+		s := NewFailoverSubscriber(&NamedSubscriber{Name: "redis", Subscriber: primary}, &NamedSubscriber{Name: "http", Subscriber: secondary})
+
+		assert.Equal(t, "redis: Not connected; http: Not started", s.Ready().Error())
+
+		err := s.Start()
+		assert.Contains(t, err.Error(), "redis: connection refused")
+		assert.Contains(t, err.Error(), "http: port is busy")
+	})
+}
@@ -105,7 +105,7 @@ func (s *HTTPSubscriber) Handler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.node.HandlePubSub(body)
+	handleSafely(s.node, HTTPOrigin, body, s.log)
 
 	w.WriteHeader(201)
 }
@@ -0,0 +1,74 @@
+package pubsub
+
+import "sync"
+
+// HandlerFunc is an adapter to use ordinary functions as handlers
+type HandlerFunc func(json []byte)
+
+// HandlePubSub calls fn(json)
+func (fn HandlerFunc) HandlePubSub(json []byte) {
+	fn(json)
+}
+
+// Middleware wraps the handler to intercept raw broadcast messages:
+// a middleware could drop a message (by not calling the next handler), pass a modified payload or pass it through
+type Middleware func(next Handler) Handler
+
+// middlewareHandler passes messages through the middlewares chain to the handler
+// preserving the broadcast origins (chains are built per origin lazily)
+type middlewareHandler struct {
+	handler     Handler
+	middlewares []Middleware
+
+	mu     sync.RWMutex
+	chains map[string]Handler
+}
+
+// NewMiddlewareHandler returns a handler passing messages through the middlewares to the handler
+// (the first middleware is the outermost one). If the handler is an OriginHandler, it still receives the message origin.
+func NewMiddlewareHandler(handler Handler, middlewares []Middleware) Handler {
+	if len(middlewares) == 0 {
+		return handler
+	}
+
+	return &middlewareHandler{handler: handler, middlewares: middlewares, chains: make(map[string]Handler)}
+}
+
+func (h *middlewareHandler) HandlePubSub(json []byte) {
+	h.chain("").HandlePubSub(json)
+}
+
+func (h *middlewareHandler) HandlePubSubFrom(origin string, json []byte) {
+	h.chain(origin).HandlePubSub(json)
+}
+
+func (h *middlewareHandler) chain(origin string) Handler {
+	h.mu.RLock()
+	chain, ok := h.chains[origin]
+	h.mu.RUnlock()
+
+	if ok {
+		return chain
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if chain, ok = h.chains[origin]; ok {
+		return chain
+	}
+
+	chain = h.handler
+
+	if oh, ok := h.handler.(OriginHandler); ok && origin != "" {
+		chain = HandlerFunc(func(json []byte) { oh.HandlePubSubFrom(origin, json) })
+	}
+
+	for i := len(h.middlewares) - 1; i >= 0; i-- {
+		chain = h.middlewares[i](chain)
+	}
+
+	h.chains[origin] = chain
+
+	return chain
+}
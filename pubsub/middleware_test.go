@@ -0,0 +1,113 @@
+package pubsub
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingHandler records received messages along with their origins
+type recordingHandler struct {
+	received []string
+}
+
+func (h *recordingHandler) HandlePubSub(msg []byte) {
+	h.received = append(h.received, string(msg))
+}
+
+func (h *recordingHandler) HandlePubSubFrom(origin string, msg []byte) {
+	h.received = append(h.received, origin+":"+string(msg))
+}
+
+func TestMiddlewareHandler(t *testing.T) {
+	t.Run("Without middlewares", func(t *testing.T) {
+		handler := &recordingHandler{}
+		assert.Same(t, handler, NewMiddlewareHandler(handler, nil))
+	})
+
+	t.Run("Ordering, dropping and origins", func(t *testing.T) {
+		handler := &recordingHandler{}
+		calls := []string{}
+
+		tracer := func(name string) Middleware {
+			return func(next Handler) Handler {
+				return HandlerFunc(func(msg []byte) {
+					calls = append(calls, name)
+					next.HandlePubSub(msg)
+				})
+			}
+		}
+
+		dropper := func(next Handler) Handler {
+			return HandlerFunc(func(msg []byte) {
+				if bytes.Contains(msg, []byte("secret")) {
+					return
+				}
+
+				next.HandlePubSub(msg)
+			})
+		}
+
+		mh := NewMiddlewareHandler(handler, []Middleware{tracer("first"), tracer("second"), dropper})
+
+		mh.(OriginHandler).HandlePubSubFrom("redis", []byte("a"))
+		mh.(OriginHandler).HandlePubSubFrom("http", []byte("secret"))
+		mh.HandlePubSub([]byte("b"))
+
+		assert.Equal(t, []string{"first", "second", "first", "second", "first", "second"}, calls)
+		assert.Equal(t, []string{"redis:a", "b"}, handler.received)
+	})
+}
+
+func TestMiddlewareHandlerWithRedisSubscriber(t *testing.T) {
+	handler := &recordingHandler{}
+
+	// Migrate legacy stream names
+	rewrite := func(next Handler) Handler {
+		return HandlerFunc(func(msg []byte) {
+			next.HandlePubSub(bytes.Replace(msg, []byte("\"stream\":\"legacy/"), []byte("\"stream\":\"v2:"), 1))
+		})
+	}
+
+	teed := []string{}
+
+	tee := func(next Handler) Handler {
+		return HandlerFunc(func(msg []byte) {
+			teed = append(teed, string(msg))
+			next.HandlePubSub(msg)
+		})
+	}
+
+	config := NewRedisConfig()
+	subscriber := NewRedisSubscriber(NewMiddlewareHandler(handler, []Middleware{tee, rewrite}), &config)
+
+	message := func(data string) interface{} {
+		return []interface{}{[]byte("message"), []byte("__anycable__"), []byte(data)}
+	}
+
+	conn := &fakeRedisConn{
+		replies: []interface{}{
+			message("{\"stream\":\"legacy/chat_1\",\"data\":\"1\"}"),
+			message("{\"stream\":\"v2:chat_2\",\"data\":\"2\"}"),
+		},
+	}
+
+	done := make(chan error, 1)
+
+	subscriber.receive(&redis.PubSubConn{Conn: conn}, done)
+
+	assert.Equal(t, io.EOF, <-done)
+
+	assert.Equal(t, []string{
+		"{\"stream\":\"legacy/chat_1\",\"data\":\"1\"}",
+		"{\"stream\":\"v2:chat_2\",\"data\":\"2\"}",
+	}, teed)
+
+	assert.Equal(t, []string{
+		"redis:{\"stream\":\"v2:chat_1\",\"data\":\"1\"}",
+		"redis:{\"stream\":\"v2:chat_2\",\"data\":\"2\"}",
+	}, handler.received)
+}
@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/FZambia/sentinel"
@@ -39,6 +40,25 @@ func NewRedisConfig() RedisConfig {
 	return RedisConfig{KeepalivePingInterval: defaultKeepaliveInterval}
 }
 
+// Validate checks that Redis URL is correct
+func (config *RedisConfig) Validate() error {
+	redisURL, err := url.Parse(config.URL)
+
+	if err != nil {
+		return fmt.Errorf("Malformed Redis URL: %v", err)
+	}
+
+	if redisURL.Scheme != "redis" && redisURL.Scheme != "rediss" {
+		return fmt.Errorf("Redis URL must start with redis:// or rediss://, got: %s", redisURL.Redacted())
+	}
+
+	if redisURL.Hostname() == "" {
+		return fmt.Errorf("Redis URL must contain a host, got: %s", redisURL.Redacted())
+	}
+
+	return nil
+}
+
 // RedisSubscriber contains information about Redis pubsub connection
 type RedisSubscriber struct {
 	node                      Handler
@@ -48,7 +68,11 @@ type RedisSubscriber struct {
 	pingInterval              time.Duration
 	channel                   string
 	reconnectAttempt          int
-	log                       *log.Entry
+	// Set to 1 after the first successful subscription
+	subscribed int32
+	// Set to 1 while subscribed to the channel
+	connected int32
+	log       *log.Entry
 }
 
 // NewRedisSubscriber returns new RedisSubscriber struct
@@ -75,60 +99,19 @@ func (s *RedisSubscriber) Start() error {
 		return err
 	}
 
-	var sntnl *sentinel.Sentinel
-
-	if s.sentinels != "" {
-		masterName := redisURL.Hostname()
+	dialer := newRedisDialer(s.url, s.sentinels, s.log)
+	defer dialer.close()
 
+	if dialer.sentinel != nil {
 		s.log.Debug("Redis sentinel enabled")
-		s.log.Debugf("Redis sentinel parameters:  sentinels: %s,  masterName: %s", s.sentinels, masterName)
-		sentinels := strings.Split(s.sentinels, ",")
-		sntnl = &sentinel.Sentinel{
-			Addrs:      sentinels,
-			MasterName: masterName,
-			Dial: func(addr string) (redis.Conn, error) {
-				timeout := 500 * time.Millisecond
-
-				sentinelHost := addr
-				dialOptions := []redis.DialOption{
-					redis.DialConnectTimeout(timeout),
-					redis.DialReadTimeout(timeout),
-					redis.DialReadTimeout(timeout),
-					redis.DialTLSSkipVerify(true),
-				}
-
-				sentinelURI, err := url.Parse(fmt.Sprintf("redis://%s", addr))
-
-				if err == nil {
-					sentinelHost = sentinelURI.Host
-					password, hasPassword := sentinelURI.User.Password()
-					if hasPassword {
-						dialOptions = append(dialOptions, redis.DialPassword(password))
-					}
-				}
-
-				c, err := redis.Dial(
-					"tcp",
-					sentinelHost,
-					dialOptions...,
-				)
-				if err != nil {
-					s.log.Debugf("Failed to connect to sentinel %s", addr)
-					return nil, err
-				}
-				s.log.Debugf("Successfully connected to sentinel %s", addr)
-				return c, nil
-			},
-		}
-
-		defer sntnl.Close()
+		s.log.Debugf("Redis sentinel parameters:  sentinels: %s,  masterName: %s", s.sentinels, redisURL.Hostname())
 
 		// Periodically discover new Sentinels.
 		ctx, cancel := context.WithCancel(context.Background())
 		defer cancel()
 
 		go func() {
-			err := sntnl.Discover()
+			err := dialer.sentinel.Discover()
 			if err != nil {
 				s.log.Warn("Failed to discover sentinels")
 			}
@@ -138,7 +121,7 @@ func (s *RedisSubscriber) Start() error {
 					return
 
 				case <-time.After(s.sentinelDiscoveryInterval * time.Second):
-					err := sntnl.Discover()
+					err := dialer.sentinel.Discover()
 					if err != nil {
 						s.log.Warn("Failed to discover sentinels")
 					}
@@ -148,18 +131,16 @@ func (s *RedisSubscriber) Start() error {
 	}
 
 	for {
-
-		if s.sentinels != "" {
-			masterAddress, err := sntnl.MasterAddr()
+		if dialer.sentinel != nil {
+			masterURL, err := dialer.masterURL()
 
 			if err != nil {
 				s.log.Warn("Failed to get master address from sentinel.")
 				return err
 			}
-			s.log.Debugf("Got master address from sentinel: %s", masterAddress)
+			s.log.Debugf("Got master address from sentinel: %s", masterURL.Host)
 
-			redisURL.Host = masterAddress
-			s.url = redisURL.String()
+			s.url = masterURL.String()
 		}
 
 		if err := s.listen(); err != nil {
@@ -181,27 +162,36 @@ func (s *RedisSubscriber) Start() error {
 	}
 }
 
+// Ready returns nil if the subscriber has been subscribed to the channel at least once
+func (s *RedisSubscriber) Ready() error {
+	if atomic.LoadInt32(&s.subscribed) == 0 {
+		return errors.New("Not subscribed to Redis channel yet")
+	}
+
+	return nil
+}
+
+// Healthy returns nil if the subscriber is currently subscribed to the channel
+func (s *RedisSubscriber) Healthy() error {
+	if atomic.LoadInt32(&s.connected) == 0 {
+		return errors.New("Not subscribed to Redis channel")
+	}
+
+	return nil
+}
+
 // Shutdown is no-op for Redis
 func (s *RedisSubscriber) Shutdown() error {
 	return nil
 }
 
 func (s *RedisSubscriber) listen() error {
-	dialOptions := []redis.DialOption{
-		redis.DialTLSSkipVerify(true),
-	}
-	c, err := redis.DialURL(s.url, dialOptions...)
+	c, err := dialRedis(s.url, s.sentinels != "")
 
 	if err != nil {
 		return err
 	}
 
-	if s.sentinels != "" {
-		if !sentinel.TestRole(c, "master") {
-			return errors.New("Failed master role check")
-		}
-	}
-
 	defer c.Close()
 
 	psc := redis.PubSubConn{Conn: c}
@@ -214,20 +204,7 @@ func (s *RedisSubscriber) listen() error {
 
 	done := make(chan error, 1)
 
-	go func() {
-		for {
-			switch v := psc.Receive().(type) {
-			case redis.Message:
-				s.log.Debugf("Incoming pubsub message from Redis: %s", v.Data)
-				s.node.HandlePubSub(v.Data)
-			case redis.Subscription:
-				s.log.Infof("Subscribed to Redis channel: %s\n", v.Channel)
-			case error:
-				s.log.Errorf("Redis subscription error: %v", v)
-				done <- v
-			}
-		}
-	}()
+	go s.receive(&psc, done)
 
 	ticker := time.NewTicker(s.pingInterval * time.Second)
 	defer ticker.Stop()
@@ -249,6 +226,148 @@ loop:
 	return <-done
 }
 
+// receive reads messages from the pub/sub connection until an error occurs
+func (s *RedisSubscriber) receive(psc *redis.PubSubConn, done chan error) {
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			s.log.Debugf("Incoming pubsub message from Redis: %s", v.Data)
+			handleSafely(s.node, RedisOrigin, v.Data, s.log)
+		case redis.Subscription:
+			if v.Kind == "subscribe" {
+				atomic.StoreInt32(&s.subscribed, 1)
+				atomic.StoreInt32(&s.connected, 1)
+			} else if v.Kind == "unsubscribe" {
+				atomic.StoreInt32(&s.connected, 0)
+			}
+
+			s.log.Infof("Subscribed to Redis channel: %s\n", v.Channel)
+		case error:
+			atomic.StoreInt32(&s.connected, 0)
+			s.log.Errorf("Redis subscription error: %v", v)
+			done <- v
+			return
+		}
+	}
+}
+
+// redisDialer connects to Redis directly or via Sentinels (to the current master)
+type redisDialer struct {
+	url string
+	// Nil if sentinels are not used
+	sentinel *sentinel.Sentinel
+}
+
+func newRedisDialer(redisURL string, sentinels string, l *log.Entry) *redisDialer {
+	d := &redisDialer{url: redisURL}
+
+	if sentinels == "" {
+		return d
+	}
+
+	masterName := ""
+
+	if parsed, err := url.Parse(redisURL); err == nil {
+		masterName = parsed.Hostname()
+	}
+
+	d.sentinel = &sentinel.Sentinel{
+		Addrs:      strings.Split(sentinels, ","),
+		MasterName: masterName,
+		Dial: func(addr string) (redis.Conn, error) {
+			timeout := 500 * time.Millisecond
+
+			sentinelHost := addr
+			dialOptions := []redis.DialOption{
+				redis.DialConnectTimeout(timeout),
+				redis.DialReadTimeout(timeout),
+				redis.DialReadTimeout(timeout),
+				redis.DialTLSSkipVerify(true),
+			}
+
+			sentinelURI, err := url.Parse(fmt.Sprintf("redis://%s", addr))
+
+			if err == nil {
+				sentinelHost = sentinelURI.Host
+				password, hasPassword := sentinelURI.User.Password()
+				if hasPassword {
+					dialOptions = append(dialOptions, redis.DialPassword(password))
+				}
+			}
+
+			c, err := redis.Dial(
+				"tcp",
+				sentinelHost,
+				dialOptions...,
+			)
+			if err != nil {
+				l.Debugf("Failed to connect to sentinel %s", addr)
+				return nil, err
+			}
+			l.Debugf("Successfully connected to sentinel %s", addr)
+			return c, nil
+		},
+	}
+
+	return d
+}
+
+// masterURL returns the Redis URL with the current master address (provided by sentinels)
+func (d *redisDialer) masterURL() (*url.URL, error) {
+	redisURL, err := url.Parse(d.url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	masterAddress, err := d.sentinel.MasterAddr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	redisURL.Host = masterAddress
+
+	return redisURL, nil
+}
+
+// dial connects to Redis (to the current master if sentinels are used)
+func (d *redisDialer) dial() (redis.Conn, error) {
+	if d.sentinel == nil {
+		return dialRedis(d.url, false)
+	}
+
+	masterURL, err := d.masterURL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return dialRedis(masterURL.String(), true)
+}
+
+func (d *redisDialer) close() {
+	if d.sentinel != nil {
+		d.sentinel.Close()
+	}
+}
+
+// dialRedis connects to Redis (and checks that it's a master if it's been resolved via sentinels)
+func dialRedis(redisURL string, checkMaster bool) (redis.Conn, error) {
+	c, err := redis.DialURL(redisURL, redis.DialTLSSkipVerify(true))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if checkMaster && !sentinel.TestRole(c, "master") {
+		c.Close()
+		return nil, errors.New("Failed master role check")
+	}
+
+	return c, nil
+}
+
 func nextRetry(step int) time.Duration {
 	secs := (step * step) + (rand.Intn(step*4) * (step + 1)) // #nosec
 	return time.Duration(secs) * time.Second
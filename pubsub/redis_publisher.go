@@ -0,0 +1,59 @@
+package pubsub
+
+import (
+	"errors"
+	"time"
+
+	"github.com/FZambia/sentinel"
+	"github.com/apex/log"
+	"github.com/gomodule/redigo/redis"
+)
+
+// RedisPublisher publishes messages to a Redis channel
+type RedisPublisher struct {
+	channel string
+	dialer  *redisDialer
+	pool    *redis.Pool
+}
+
+// NewRedisPublisher builds a new publisher for the channel (connections are established lazily).
+// Connections are established the same way as the subscriber's ones (i.e., via sentinels if configured).
+func NewRedisPublisher(config *RedisConfig, channel string) *RedisPublisher {
+	dialer := newRedisDialer(config.URL, config.Sentinels, log.WithField("context", "pubsub"))
+
+	pool := &redis.Pool{
+		MaxIdle:     1,
+		IdleTimeout: 5 * time.Minute,
+		Dial:        dialer.dial,
+	}
+
+	if dialer.sentinel != nil {
+		// Make sure we don't publish to the former master after failover
+		pool.TestOnBorrow = func(c redis.Conn, _ time.Time) error {
+			if !sentinel.TestRole(c, "master") {
+				return errors.New("Failed master role check")
+			}
+
+			return nil
+		}
+	}
+
+	return &RedisPublisher{channel: channel, dialer: dialer, pool: pool}
+}
+
+// Publish publishes the payload to the channel
+func (p *RedisPublisher) Publish(payload []byte) error {
+	c := p.pool.Get()
+	defer c.Close()
+
+	_, err := c.Do("PUBLISH", p.channel, payload)
+
+	return err
+}
+
+// Shutdown closes the connections pool
+func (p *RedisPublisher) Shutdown() error {
+	p.dialer.close()
+
+	return p.pool.Close()
+}
@@ -0,0 +1,114 @@
+package pubsub
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedisConn replays the predefined pub/sub replies
+type fakeRedisConn struct {
+	replies []interface{}
+}
+
+func (c *fakeRedisConn) Close() error { return nil }
+func (c *fakeRedisConn) Err() error   { return nil }
+func (c *fakeRedisConn) Flush() error { return nil }
+
+func (c *fakeRedisConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func (c *fakeRedisConn) Send(cmd string, args ...interface{}) error {
+	return nil
+}
+
+func (c *fakeRedisConn) Receive() (interface{}, error) {
+	if len(c.replies) == 0 {
+		return nil, io.EOF
+	}
+
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+
+	return reply, nil
+}
+
+// panickyHandler fails on any message except valid broadcasts
+type panickyHandler struct {
+	received []string
+}
+
+func (h *panickyHandler) HandlePubSub(msg []byte) {
+	if string(msg) != "{\"stream\":\"test\",\"data\":\"1\"}" {
+		panic(errors.New("malformed message"))
+	}
+
+	h.received = append(h.received, string(msg))
+}
+
+// originHandler records the origins of received messages
+type originHandler struct {
+	origins []string
+}
+
+func (h *originHandler) HandlePubSub(msg []byte) {
+	h.origins = append(h.origins, "")
+}
+
+func (h *originHandler) HandlePubSubFrom(origin string, msg []byte) {
+	h.origins = append(h.origins, origin)
+}
+
+func TestRedisReceive(t *testing.T) {
+	handler := &panickyHandler{}
+	config := NewRedisConfig()
+	subscriber := NewRedisSubscriber(handler, &config)
+
+	valid := "{\"stream\":\"test\",\"data\":\"1\"}"
+
+	message := func(data string) interface{} {
+		return []interface{}{[]byte("message"), []byte("__anycable__"), []byte(data)}
+	}
+
+	conn := &fakeRedisConn{
+		replies: []interface{}{
+			[]interface{}{[]byte("subscribe"), []byte("__anycable__"), int64(1)},
+			message(valid),
+			message("{\"stream\":"),
+			message(valid),
+			message("\x00\x01\x02"),
+			message(valid),
+		},
+	}
+
+	done := make(chan error, 1)
+
+	subscriber.receive(&redis.PubSubConn{Conn: conn}, done)
+
+	assert.Equal(t, io.EOF, <-done)
+	assert.Equal(t, []string{valid, valid, valid}, handler.received)
+	assert.Nil(t, subscriber.Ready())
+}
+
+func TestRedisReceiveWithOrigin(t *testing.T) {
+	handler := &originHandler{}
+	config := NewRedisConfig()
+	subscriber := NewRedisSubscriber(handler, &config)
+
+	conn := &fakeRedisConn{
+		replies: []interface{}{
+			[]interface{}{[]byte("message"), []byte("__anycable__"), []byte("{\"stream\":\"test\",\"data\":\"1\"}")},
+		},
+	}
+
+	done := make(chan error, 1)
+
+	subscriber.receive(&redis.PubSubConn{Conn: conn}, done)
+
+	assert.Equal(t, io.EOF, <-done)
+	assert.Equal(t, []string{"redis"}, handler.origins)
+}
@@ -2,6 +2,9 @@ package pubsub
 
 import (
 	"fmt"
+
+	"github.com/anycable/anycable-go/utils"
+	"github.com/apex/log"
 )
 
 // Subscriber is responsible for receiving broadcast messages
@@ -11,10 +14,28 @@ type Subscriber interface {
 	Shutdown() error
 }
 
+// HealthChecker could be implemented by subscribers to report the current connection state
+// (subscribers not implementing it are considered healthy)
+type HealthChecker interface {
+	Healthy() error
+}
+
+// Broadcast origins (adapter names) passed to handlers
+const (
+	RedisOrigin = "redis"
+	HTTPOrigin  = "http"
+)
+
 type Handler interface {
 	HandlePubSub(json []byte)
 }
 
+// OriginHandler is a handler which takes into account the broadcast origin
+// (the name of the adapter the message has been received from)
+type OriginHandler interface {
+	HandlePubSubFrom(origin string, json []byte)
+}
+
 // NewSubscriber creates an instance of the provided adapter
 func NewSubscriber(node Handler, adapter string, redis *RedisConfig, http *HTTPConfig) (Subscriber, error) {
 	switch adapter {
@@ -26,3 +47,20 @@ func NewSubscriber(node Handler, adapter string, redis *RedisConfig, http *HTTPC
 
 	return nil, fmt.Errorf("Unknown adapter type: %s", adapter)
 }
+
+// handleSafely passes the message (and its origin if supported) to the handler recovering from panics,
+// so a single malformed message never stops the subscriber
+func handleSafely(node Handler, origin string, msg []byte, l *log.Entry) {
+	defer func() {
+		if r := recover(); r != nil {
+			l.WithField("payload", utils.TruncateBytes(msg, utils.MaxLoggedPayloadSize)).Errorf("Pubsub message handler panicked: %v", r)
+		}
+	}()
+
+	if h, ok := node.(OriginHandler); ok {
+		h.HandlePubSubFrom(origin, msg)
+		return
+	}
+
+	node.HandlePubSub(msg)
+}
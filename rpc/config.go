@@ -13,6 +13,8 @@ type Dialer = func(c *Config) (pb.RPCClient, ClientHelper, error)
 
 // Config contains RPC controller configuration
 type Config struct {
+	// RPC implementation ("grpc" or "none" for the standalone mode)
+	Implementation string
 	// RPC instance host
 	Host string
 	// The max number of simulteneous requests.
@@ -31,5 +33,5 @@ type Config struct {
 
 // NewConfig builds a new config
 func NewConfig() Config {
-	return Config{Concurrency: 28, EnableTLS: false}
+	return Config{Implementation: "grpc", Concurrency: 28, EnableTLS: false}
 }
@@ -6,12 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/anycable/anycable-go/common"
 	"github.com/anycable/anycable-go/metrics"
 	"github.com/anycable/anycable-go/protocol"
+	"github.com/anycable/anycable-go/tracing"
 	"github.com/apex/log"
 
 	pb "github.com/anycable/anycable-go/protos"
@@ -37,6 +40,17 @@ const (
 	metricsRPCRetries  = "rpc_retries_total"
 	metricsRPCFailures = "rpc_error_total"
 	metricsRPCPending  = "rpc_pending_num"
+	metricsRPCDuration = "rpc_call_duration_seconds"
+	metricsRPCTiming   = "rpc_call"
+
+	// RPC methods (used as the duration metric label)
+	rpcMethodConnect    = "connect"
+	rpcMethodCommand    = "command"
+	rpcMethodDisconnect = "disconnect"
+
+	unixSocketScheme = "unix://"
+	// Authority used for Unix domain socket connections
+	unixSocketAuthority = "localhost"
 )
 
 type grpcClientHelper struct {
@@ -69,6 +83,11 @@ func (st *grpcClientHelper) Ready() error {
 	return nil
 }
 
+// Connected returns true if the connection is established
+func (st *grpcClientHelper) Connected() bool {
+	return st.conn.GetState() == connectivity.Ready
+}
+
 func (st *grpcClientHelper) Close() {
 	st.conn.Close()
 }
@@ -107,6 +126,9 @@ type Controller struct {
 	metrics     *metrics.Metrics
 	log         *log.Entry
 	clientState ClientHelper
+
+	// Guards the client state (it's set by Start, which could run concurrently with readiness checks)
+	stateMu sync.RWMutex
 }
 
 // NewController builds new Controller
@@ -115,6 +137,8 @@ func NewController(metrics *metrics.Metrics, config *Config) *Controller {
 	metrics.RegisterCounter(metricsRPCRetries, "The total number of RPC call retries")
 	metrics.RegisterCounter(metricsRPCFailures, "The total number of failed RPC calls")
 	metrics.RegisterGauge(metricsRPCPending, "The number of pending RPC calls")
+	metrics.RegisterHistogramVec(metricsRPCDuration, "The duration of RPC calls (including retries)", "method", nil)
+	metrics.RegisterTiming(metricsRPCTiming, "The duration of RPC calls (including retries) during the last interval")
 
 	return &Controller{log: log.WithField("context", "rpc"), metrics: metrics, config: config}
 }
@@ -131,6 +155,10 @@ func (c *Controller) Start() error {
 		dialer = c.config.DialFun
 	} else {
 		dialer = defaultDialer
+
+		if _, unix := unixSocketPath(host); unix && enableTLS {
+			c.log.Warn("TLS is not used for Unix domain socket connections")
+		}
 	}
 
 	client, state, err := dialer(c.config)
@@ -141,18 +169,50 @@ func (c *Controller) Start() error {
 		c.log.Infof("RPC controller initialized: %s (concurrency: %d, enable_tls: %t, proto_versions: %s)", host, capacity, enableTLS, ProtoVersions)
 	}
 
+	c.stateMu.Lock()
 	c.client = client
 	c.clientState = state
+	c.stateMu.Unlock()
+
 	return err
 }
 
+func (c *Controller) state() ClientHelper {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+
+	return c.clientState
+}
+
+// Ready returns nil if the RPC connection has been established.
+// Client helpers which can't report the connection state are considered connected if they're ready to make calls.
+func (c *Controller) Ready() error {
+	state := c.state()
+
+	if state == nil {
+		return errors.New("RPC controller is not started")
+	}
+
+	if helper, ok := state.(interface{ Connected() bool }); ok {
+		if !helper.Connected() {
+			return errors.New("RPC connection is not established yet")
+		}
+
+		return nil
+	}
+
+	return state.Ready()
+}
+
 // Shutdown closes connections
 func (c *Controller) Shutdown() error {
-	if c.clientState == nil {
+	state := c.state()
+
+	if state == nil {
 		return nil
 	}
 
-	defer c.clientState.Close()
+	defer state.Close()
 
 	busy := c.busy()
 
@@ -161,7 +221,7 @@ func (c *Controller) Shutdown() error {
 	}
 
 	// Wait for active connections
-	_, err := c.retry("", func() (interface{}, error) {
+	_, err := c.retry("", "", func() (interface{}, error) {
 		busy := c.busy()
 
 		if busy > 0 {
@@ -177,6 +237,12 @@ func (c *Controller) Shutdown() error {
 
 // Authenticate performs Connect RPC call
 func (c *Controller) Authenticate(sid string, env *common.SessionEnv) (*common.ConnectResult, error) {
+	return c.AuthenticateContext(context.Background(), sid, env)
+}
+
+// AuthenticateContext performs Connect RPC call propagating the trace context (if any).
+// NOTE: the call is not aborted when the context is cancelled.
+func (c *Controller) AuthenticateContext(ctx context.Context, sid string, env *common.SessionEnv) (*common.ConnectResult, error) {
 	c.metrics.Gauge(metricsRPCPending).Inc()
 	<-c.sem
 	defer func() { c.sem <- struct{}{} }()
@@ -184,19 +250,19 @@ func (c *Controller) Authenticate(sid string, env *common.SessionEnv) (*common.C
 
 	op := func() (interface{}, error) {
 		return c.client.Connect(
-			newContext(sid),
+			newTracedContext(ctx, sid),
 			protocol.NewConnectMessage(env),
 		)
 	}
 
 	c.metrics.Counter(metricsRPCCalls).Inc()
 
-	response, err := c.retry(sid, op)
+	response, err := c.retry(sid, rpcMethodConnect, op)
 
 	if err != nil {
 		c.metrics.Counter(metricsRPCFailures).Inc()
 
-		return nil, err
+		return nil, unavailableError(err)
 	}
 
 	if r, ok := response.(*pb.ConnectionResponse); ok {
@@ -215,25 +281,23 @@ func (c *Controller) Authenticate(sid string, env *common.SessionEnv) (*common.C
 
 // Subscribe performs Command RPC call with "subscribe" command
 func (c *Controller) Subscribe(sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error) {
-	c.metrics.Gauge(metricsRPCPending).Inc()
-	<-c.sem
-	defer func() { c.sem <- struct{}{} }()
-	c.metrics.Gauge(metricsRPCPending).Dec()
-
-	op := func() (interface{}, error) {
-		return c.client.Command(
-			newContext(sid),
-			protocol.NewCommandMessage(env, "subscribe", channel, id, ""),
-		)
-	}
-
-	response, err := c.retry(sid, op)
+	return c.SubscribeContext(context.Background(), sid, env, id, channel)
+}
 
-	return c.parseCommandResponse(sid, response, err)
+// SubscribeContext performs Command RPC call with "subscribe" command,
+// which is aborted when the context is cancelled
+func (c *Controller) SubscribeContext(ctx context.Context, sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error) {
+	return c.cancelableCommand(ctx, sid, protocol.NewCommandMessage(env, "subscribe", channel, id, ""))
 }
 
 // Unsubscribe performs Command RPC call with "unsubscribe" command
 func (c *Controller) Unsubscribe(sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error) {
+	return c.UnsubscribeContext(context.Background(), sid, env, id, channel)
+}
+
+// UnsubscribeContext performs Command RPC call with "unsubscribe" command propagating the trace context (if any).
+// NOTE: the call is not aborted when the context is cancelled.
+func (c *Controller) UnsubscribeContext(ctx context.Context, sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error) {
 	c.metrics.Gauge(metricsRPCPending).Inc()
 	<-c.sem
 	defer func() { c.sem <- struct{}{} }()
@@ -241,37 +305,67 @@ func (c *Controller) Unsubscribe(sid string, env *common.SessionEnv, id string,
 
 	op := func() (interface{}, error) {
 		return c.client.Command(
-			newContext(sid),
+			newTracedContext(ctx, sid),
 			protocol.NewCommandMessage(env, "unsubscribe", channel, id, ""),
 		)
 	}
 
-	response, err := c.retry(sid, op)
+	response, err := c.retry(sid, rpcMethodCommand, op)
 
 	return c.parseCommandResponse(sid, response, err)
 }
 
 // Perform performs Command RPC call with "perform" command
 func (c *Controller) Perform(sid string, env *common.SessionEnv, id string, channel string, data string) (*common.CommandResult, error) {
+	return c.PerformContext(context.Background(), sid, env, id, channel, data)
+}
+
+// PerformContext performs Command RPC call with "perform" command,
+// which is aborted when the context is cancelled
+func (c *Controller) PerformContext(ctx context.Context, sid string, env *common.SessionEnv, id string, channel string, data string) (*common.CommandResult, error) {
+	return c.cancelableCommand(ctx, sid, protocol.NewCommandMessage(env, "message", channel, id, data))
+}
+
+// cancelableCommand performs Command RPC call bound to the context.
+// Only the call itself is cancelled, the underlying connection is not affected.
+// Cancelled calls are not considered failures.
+func (c *Controller) cancelableCommand(ctx context.Context, sid string, msg *pb.CommandMessage) (*common.CommandResult, error) {
 	c.metrics.Gauge(metricsRPCPending).Inc()
-	<-c.sem
+
+	select {
+	case <-c.sem:
+	case <-ctx.Done():
+		c.metrics.Gauge(metricsRPCPending).Dec()
+		return nil, ctx.Err()
+	}
+
 	defer func() { c.sem <- struct{}{} }()
 	c.metrics.Gauge(metricsRPCPending).Dec()
 
 	op := func() (interface{}, error) {
-		return c.client.Command(
-			newContext(sid),
-			protocol.NewCommandMessage(env, "message", channel, id, data),
-		)
+		return c.client.Command(newContextFrom(ctx, sid), msg)
 	}
 
-	response, err := c.retry(sid, op)
+	response, err := c.retryContext(ctx, sid, rpcMethodCommand, op)
+
+	if err != nil && ctx.Err() != nil {
+		c.metrics.Counter(metricsRPCCalls).Inc()
+		c.log.WithField("sid", sid).Debugf("Command cancelled: %v", err)
+
+		return nil, ctx.Err()
+	}
 
 	return c.parseCommandResponse(sid, response, err)
 }
 
 // Disconnect performs disconnect RPC call
 func (c *Controller) Disconnect(sid string, env *common.SessionEnv, id string, subscriptions []string) error {
+	return c.DisconnectContext(context.Background(), sid, env, id, subscriptions)
+}
+
+// DisconnectContext performs disconnect RPC call propagating the trace context (if any).
+// NOTE: the call is not aborted when the context is cancelled.
+func (c *Controller) DisconnectContext(ctx context.Context, sid string, env *common.SessionEnv, id string, subscriptions []string) error {
 	c.metrics.Gauge(metricsRPCPending).Inc()
 	<-c.sem
 	defer func() { c.sem <- struct{}{} }()
@@ -279,14 +373,14 @@ func (c *Controller) Disconnect(sid string, env *common.SessionEnv, id string, s
 
 	op := func() (interface{}, error) {
 		return c.client.Disconnect(
-			newContext(sid),
+			newTracedContext(ctx, sid),
 			protocol.NewDisconnectMessage(env, id, subscriptions),
 		)
 	}
 
 	c.metrics.Counter(metricsRPCCalls).Inc()
 
-	response, err := c.retry(sid, op)
+	response, err := c.retry(sid, rpcMethodDisconnect, op)
 
 	if err != nil {
 		c.metrics.Counter(metricsRPCFailures).Inc()
@@ -308,6 +402,18 @@ func (c *Controller) Disconnect(sid string, env *common.SessionEnv, id string, s
 	return errors.New("Failed to deserialize disconnect response")
 }
 
+// DisconnectBatch performs disconnect RPC calls for multiple sessions.
+// Currently, it makes a call per session, since the RPC protocol doesn't support batching.
+func (c *Controller) DisconnectBatch(requests []*common.DisconnectRequest) []error {
+	errs := make([]error, len(requests))
+
+	for i, req := range requests {
+		errs[i] = c.Disconnect(req.UID, req.Env, req.Identifiers, req.Subscriptions)
+	}
+
+	return errs
+}
+
 func (c *Controller) parseCommandResponse(sid string, response interface{}, err error) (*common.CommandResult, error) {
 	c.metrics.Counter(metricsRPCCalls).Inc()
 
@@ -337,14 +443,32 @@ func (c *Controller) busy() int {
 	return c.config.Concurrency - len(c.sem)
 }
 
-func (c *Controller) retry(sid string, callback func() (interface{}, error)) (res interface{}, err error) {
+func (c *Controller) retry(sid string, method string, callback func() (interface{}, error)) (res interface{}, err error) {
+	return c.retryContext(context.Background(), sid, method, callback)
+}
+
+// retryContext performs the call with retries (until the context is cancelled).
+// The duration is tracked per RPC method (non-RPC operations have no method and are not tracked)
+func (c *Controller) retryContext(ctx context.Context, sid string, method string, callback func() (interface{}, error)) (res interface{}, err error) {
 	retryAge := 0
 	attempt := 0
 	wasExhausted := false
 
+	start := time.Now()
+	defer func() {
+		if method == "" {
+			return
+		}
+
+		duration := time.Since(start)
+
+		c.metrics.HistogramVec(metricsRPCDuration).With(method).Observe(duration.Seconds())
+		c.metrics.Timing(metricsRPCTiming).Observe(duration)
+	}()
+
 	for {
-		if stErr := c.clientState.Ready(); stErr != nil {
-			return nil, stErr
+		if stErr := c.state().Ready(); stErr != nil {
+			return nil, fmt.Errorf("%w: %v", common.ErrRPCUnavailable, stErr)
 		}
 
 		res, err = callback()
@@ -390,7 +514,11 @@ func (c *Controller) retry(sid string, callback func() (interface{}, error)) (re
 
 		c.metrics.Counter(metricsRPCRetries).Inc()
 
-		time.Sleep(delay * time.Millisecond)
+		select {
+		case <-time.After(delay * time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 
 		attempt++
 	}
@@ -403,36 +531,82 @@ func (c *Controller) initSemaphore(capacity int) {
 	}
 }
 
-func newContext(sessionID string) context.Context {
-	md := metadata.Pairs("sid", sessionID, "protov", ProtoVersions)
-	return metadata.NewOutgoingContext(context.Background(), md)
+// newTracedContext returns a non-cancelable call context carrying the parent trace context
+func newTracedContext(parent context.Context, sessionID string) context.Context {
+	return newContextFrom(tracing.ContextWithSpan(context.Background(), tracing.SpanFromContext(parent)), sessionID)
 }
 
-func defaultDialer(conf *Config) (client pb.RPCClient, state ClientHelper, err error) {
-	host := conf.Host
-	enableTLS := conf.EnableTLS
+func newContextFrom(parent context.Context, sessionID string) context.Context {
+	md := metadata.Pairs("sid", sessionID, "protov", ProtoVersions)
 
-	kacp := keepalive.ClientParameters{
-		Time:                10 * time.Second, // send pings every 10 seconds if there is no activity
-		PermitWithoutStream: true,             // send pings even without active streams
+	if span := tracing.SpanFromContext(parent); span != nil {
+		md.Set(tracing.TraceParentHeader, span.Context().TraceParent())
 	}
 
-	dialOptions := []grpc.DialOption{
-		grpc.WithKeepaliveParams(kacp),
-		grpc.WithBalancerName("round_robin"), // nolint:staticcheck
+	return metadata.NewOutgoingContext(parent, md)
+}
+
+func defaultDialer(conf *Config) (pb.RPCClient, ClientHelper, error) {
+	if path, ok := unixSocketPath(conf.Host); ok {
+		return NewContextDialer(unixSocketAuthority, func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		})(conf)
 	}
 
-	if enableTLS {
+	var credentialsOption grpc.DialOption
+
+	if conf.EnableTLS {
 		tlsConfig := &tls.Config{
 			InsecureSkipVerify: false,
 			MinVersion:         tls.VersionTLS12,
 		}
 
-		dialOptions = append(dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+		credentialsOption = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
 	} else {
-		dialOptions = append(dialOptions, grpc.WithInsecure())
+		credentialsOption = grpc.WithInsecure()
+	}
+
+	return dial(conf, conf.Host, credentialsOption)
+}
+
+// NewContextDialer returns a dialer using a custom transport (e.g., Unix domain sockets or in-process connections)
+// instead of TCP. TLS is not used for custom transports; the authority is passed as the :authority header.
+// Reconnection works the same way as for TCP connections (the dial function is called on every attempt).
+func NewContextDialer(authority string, dialFn func(context.Context, string) (net.Conn, error)) Dialer {
+	return func(conf *Config) (pb.RPCClient, ClientHelper, error) {
+		return dial(
+			conf,
+			"passthrough:///"+authority,
+			grpc.WithInsecure(),
+			grpc.WithContextDialer(dialFn),
+			grpc.WithAuthority(authority),
+		)
+	}
+}
+
+// unixSocketPath returns the socket path if the host uses the unix:// scheme
+func unixSocketPath(host string) (string, bool) {
+	if !strings.HasPrefix(host, unixSocketScheme) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(host, unixSocketScheme), true
+}
+
+func dial(conf *Config, target string, opts ...grpc.DialOption) (client pb.RPCClient, state ClientHelper, err error) {
+	kacp := keepalive.ClientParameters{
+		Time:                10 * time.Second, // send pings every 10 seconds if there is no activity
+		PermitWithoutStream: true,             // send pings even without active streams
 	}
 
+	dialOptions := []grpc.DialOption{
+		grpc.WithKeepaliveParams(kacp),
+		grpc.WithBalancerName("round_robin"), // nolint:staticcheck
+	}
+
+	dialOptions = append(dialOptions, opts...)
+
 	var callOptions = []grpc.CallOption{}
 
 	// Zero is the default
@@ -449,7 +623,7 @@ func defaultDialer(conf *Config) (client pb.RPCClient, state ClientHelper, err e
 	}
 
 	conn, err := grpc.Dial(
-		host,
+		target,
 		dialOptions...,
 	)
 
@@ -462,3 +636,16 @@ func defaultDialer(conf *Config) (client pb.RPCClient, state ClientHelper, err e
 
 	return
 }
+
+// unavailableError wraps the error with common.ErrRPCUnavailable if the RPC server couldn't be reached
+// (so the node could handle such failures according to the configured strategy)
+func unavailableError(err error) error {
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.Unavailable, codes.DeadlineExceeded:
+			return fmt.Errorf("%w: %v", common.ErrRPCUnavailable, err)
+		}
+	}
+
+	return err
+}
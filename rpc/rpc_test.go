@@ -1,15 +1,25 @@
 package rpc
 
 import (
+	"context"
 	"errors"
+	"net"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/anycable/anycable-go/common"
 	"github.com/anycable/anycable-go/metrics"
 	"github.com/anycable/anycable-go/mocks"
 	pb "github.com/anycable/anycable-go/protos"
+	"github.com/anycable/anycable-go/tracing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type MockState struct {
@@ -63,6 +73,7 @@ func TestAuthenticate(t *testing.T) {
 		assert.Equal(t, "user=john", res.Identifier)
 		assert.Equal(t, map[string]string{"_s_": "test-session"}, res.CState)
 		assert.Empty(t, res.Broadcasts)
+		assert.Equal(t, uint64(1), controller.metrics.HistogramVec(metricsRPCDuration).With(rpcMethodConnect).Count())
 	})
 
 	t.Run("Failure", func(t *testing.T) {
@@ -111,6 +122,42 @@ func TestAuthenticate(t *testing.T) {
 	})
 }
 
+func TestAuthenticateContextTraceParent(t *testing.T) {
+	controller := NewTestController()
+	client := mocks.RPCClient{}
+	controller.client = &client
+
+	config := tracing.NewConfig()
+	config.OTLPEndpoint = "http://localhost:4318"
+	tracer, _ := tracing.NewTracer(&config, metrics.NewMetrics(nil, 0), "")
+
+	span := tracer.Start("test", tracing.SpanKindClient, tracing.SpanContext{})
+	ctx := tracing.ContextWithSpan(context.Background(), span)
+
+	var traceparent []string
+
+	client.On("Connect", mock.MatchedBy(func(ctx context.Context) bool {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		traceparent = md.Get(tracing.TraceParentHeader)
+		return true
+	}), mock.Anything).Return(
+		&pb.ConnectionResponse{
+			Identifiers:   "user=john",
+			Transmissions: []string{"welcome"},
+			Status:        pb.Status_SUCCESS,
+		}, nil)
+
+	_, err := controller.AuthenticateContext(ctx, "42", common.NewSessionEnv("/cable", &map[string]string{}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{span.Context().TraceParent()}, traceparent)
+
+	_, err = controller.Authenticate("42", common.NewSessionEnv("/cable", &map[string]string{}))
+
+	assert.Nil(t, err)
+	assert.Empty(t, traceparent)
+}
+
 func TestPerform(t *testing.T) {
 	controller := NewTestController()
 	client := mocks.RPCClient{}
@@ -301,6 +348,48 @@ func TestPerform(t *testing.T) {
 	})
 }
 
+func TestPerformContext(t *testing.T) {
+	controller := NewTestController()
+	client := mocks.RPCClient{}
+	controller.client = &client
+
+	env := common.NewSessionEnv("/cable-test", &map[string]string{})
+
+	t.Run("Cancelled in-flight", func(t *testing.T) {
+		client.On("Command", mock.Anything, mock.MatchedBy(func(msg *pb.CommandMessage) bool { return msg.Data == "slow" })).Return(
+			nil,
+			func(ctx context.Context, _ *pb.CommandMessage, _ ...grpc.CallOption) error {
+				<-ctx.Done()
+				return status.Error(codes.Canceled, ctx.Err().Error())
+			})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		res, err := controller.PerformContext(ctx, "42", env, "ids", "test_channel", "slow")
+
+		assert.Nil(t, res)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, uint64(0), controller.metrics.Counter(metricsRPCFailures).Value())
+		// The semaphore ticket is released
+		assert.Equal(t, 1, len(controller.sem))
+	})
+
+	t.Run("Cancelled while waiting for a slot", func(t *testing.T) {
+		<-controller.sem
+		defer func() { controller.sem <- struct{}{} }()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		res, err := controller.PerformContext(ctx, "42", env, "ids", "test_channel", "hello")
+
+		assert.Nil(t, res)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, uint64(0), controller.metrics.Gauge(metricsRPCPending).Value())
+	})
+}
+
 func TestSubscribe(t *testing.T) {
 	controller := NewTestController()
 	client := mocks.RPCClient{}
@@ -432,5 +521,123 @@ func TestDisconnect(t *testing.T) {
 			[]string{"chat_42"},
 		)
 		assert.Nil(t, err)
+
+		durations := controller.metrics.HistogramVec(metricsRPCDuration)
+		assert.Equal(t, uint64(1), durations.With(rpcMethodDisconnect).Count())
+		assert.Equal(t, uint64(0), durations.With(rpcMethodConnect).Count())
 	})
 }
+
+type MockConnectedState struct {
+	MockState
+	connected bool
+}
+
+func (st MockConnectedState) Connected() bool {
+	return st.connected
+}
+
+func TestReady(t *testing.T) {
+	config := NewConfig()
+	controller := NewController(metrics.NewMetrics(nil, 0), &config)
+
+	assert.NotNil(t, controller.Ready())
+
+	controller.clientState = MockState{false, false}
+	assert.NotNil(t, controller.Ready())
+
+	controller.clientState = MockState{true, false}
+	assert.Nil(t, controller.Ready())
+
+	controller.clientState = MockConnectedState{MockState{true, false}, false}
+	assert.NotNil(t, controller.Ready())
+
+	controller.clientState = MockConnectedState{MockState{true, false}, true}
+	assert.Nil(t, controller.Ready())
+}
+
+func TestUnixSocketDialer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rpc.sock")
+
+	serve := func() *grpc.Server {
+		lis, err := net.Listen("unix", path)
+		assert.Nil(t, err)
+
+		server := grpc.NewServer()
+		go server.Serve(lis) // nolint:errcheck
+
+		return server
+	}
+
+	waitForState := func(conn *grpc.ClientConn, expected connectivity.State) bool {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		for {
+			state := conn.GetState()
+
+			if state == expected {
+				return true
+			}
+
+			if !conn.WaitForStateChange(ctx, state) {
+				return false
+			}
+		}
+	}
+
+	server := serve()
+
+	config := NewConfig()
+	config.Host = "unix://" + path
+	// TLS is ignored for Unix sockets
+	config.EnableTLS = true
+
+	_, helper, err := defaultDialer(&config)
+	assert.Nil(t, err)
+	defer helper.Close()
+
+	conn := helper.(*grpcClientHelper).conn
+
+	assert.True(t, waitForState(conn, connectivity.Ready))
+	assert.Equal(t, "localhost", conn.Target()[len("passthrough:///"):])
+
+	// Socket disappears (e.g., RPC server restarts)
+	server.Stop()
+
+	assert.True(t, waitForState(conn, connectivity.TransientFailure))
+
+	server = serve()
+	defer server.Stop()
+
+	conn.ResetConnectBackoff()
+
+	assert.True(t, waitForState(conn, connectivity.Ready))
+}
+
+func TestUnixSocketPath(t *testing.T) {
+	path, ok := unixSocketPath("unix:///var/run/anycable-rpc.sock")
+	assert.True(t, ok)
+	assert.Equal(t, "/var/run/anycable-rpc.sock", path)
+
+	_, ok = unixSocketPath("localhost:50051")
+	assert.False(t, ok)
+}
+
+func TestUnavailableError(t *testing.T) {
+	err := unavailableError(status.Error(codes.Unavailable, "connection refused"))
+	assert.ErrorIs(t, err, common.ErrRPCUnavailable)
+
+	err = unavailableError(status.Error(codes.DeadlineExceeded, "timeout"))
+	assert.ErrorIs(t, err, common.ErrRPCUnavailable)
+
+	// The server is reachable but overloaded, so it's not treated as unavailable
+	err = unavailableError(status.Error(codes.ResourceExhausted, "too many requests"))
+	assert.False(t, errors.Is(err, common.ErrRPCUnavailable))
+
+	err = unavailableError(status.Error(codes.Internal, "boom"))
+	assert.False(t, errors.Is(err, common.ErrRPCUnavailable))
+
+	err = unavailableError(errors.New("boom"))
+	assert.False(t, errors.Is(err, common.ErrRPCUnavailable))
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+)
+
+type accessLogContextKey struct{}
+
+// AccessLogConfig contains HTTP access logging parameters
+type AccessLogConfig struct {
+	Enabled bool
+	// Comma-separated list of paths to skip (e.g., health checks)
+	ExcludePaths string
+}
+
+// NewAccessLogConfig builds a new AccessLogConfig struct
+func NewAccessLogConfig() AccessLogConfig {
+	return AccessLogConfig{}
+}
+
+// Excluded returns true if requests to the path shouldn't be logged
+func (c *AccessLogConfig) Excluded(path string) bool {
+	if c.ExcludePaths == "" {
+		return false
+	}
+
+	for _, excluded := range strings.Split(c.ExcludePaths, ",") {
+		if strings.TrimSpace(excluded) == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// accessLogEntry contains request info collected by handlers
+type accessLogEntry struct {
+	subprotocol string
+}
+
+// SetAccessLogSubprotocol stores the negotiated WebSocket subprotocol to be logged
+func SetAccessLogSubprotocol(r *http.Request, protocol string) {
+	if entry, ok := r.Context().Value(accessLogContextKey{}).(*accessLogEntry); ok {
+		entry.subprotocol = protocol
+	}
+}
+
+// AccessLogHandler logs HTTP requests (the log format is the same as for the main log)
+func AccessLogHandler(next http.Handler, config *AccessLogConfig) http.Handler {
+	ctx := log.WithField("context", "access_log")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if config.Excluded(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		entry := &accessLogEntry{}
+		rw := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), accessLogContextKey{}, entry)))
+
+		fields := log.Fields{
+			"method":   r.Method,
+			"path":     r.URL.Path,
+			"status":   rw.Status(),
+			"duration": time.Since(start).Milliseconds(),
+			"remote":   ClientIP(r),
+		}
+
+		if entry.subprotocol != "" {
+			fields["subprotocol"] = entry.subprotocol
+		}
+
+		ctx.WithFields(fields).Info("HTTP request")
+	})
+}
+
+// statusRecorder tracks the response status without buffering the body
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack is required for WebSocket upgrades
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+
+	if !ok {
+		return nil, nil, errors.New("Response writer doesn't support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+
+	if err == nil && r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, rw, err
+}
+
+// Status returns the response status code
+func (r *statusRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+
+	return r.status
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLogHandler(t *testing.T) {
+	var entries []*log.Entry
+
+	logger := log.Log.(*log.Logger)
+	prevHandler := logger.Handler
+	defer func() { logger.Handler = prevHandler }()
+
+	log.SetHandler(log.HandlerFunc(func(e *log.Entry) error {
+		entries = append(entries, e)
+		return nil
+	}))
+
+	config := AccessLogConfig{Enabled: true, ExcludePaths: "/health"}
+
+	handler := AccessLogHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetAccessLogSubprotocol(r, "actioncable-v1-json")
+		w.WriteHeader(http.StatusNotFound)
+	}), &config)
+
+	t.Run("Logs request", func(t *testing.T) {
+		entries = nil
+
+		req := httptest.NewRequest("GET", "/cable", nil)
+		req.RemoteAddr = "10.0.0.1:4321"
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Len(t, entries, 1)
+
+		fields := entries[0].Fields
+		assert.Equal(t, "GET", fields["method"])
+		assert.Equal(t, "/cable", fields["path"])
+		assert.Equal(t, http.StatusNotFound, fields["status"])
+		assert.Equal(t, "10.0.0.1", fields["remote"])
+		assert.Equal(t, "actioncable-v1-json", fields["subprotocol"])
+	})
+
+	t.Run("Skips excluded paths", func(t *testing.T) {
+		entries = nil
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/health", nil))
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Len(t, entries, 0)
+	})
+}
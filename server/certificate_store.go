@@ -0,0 +1,198 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// How often to check certificate files for updates
+const certificateCheckInterval = 10 * time.Second
+
+type certificateEntry struct {
+	certPath string
+	keyPath  string
+	modTime  time.Time
+	cert     *tls.Certificate
+}
+
+// CertificateStore serves TLS certificates reloading them when files change.
+// When multiple certificates are configured, the certificate is selected by the SNI hostname
+// (the first one is used by default).
+type CertificateStore struct {
+	entries   []*certificateEntry
+	mu        sync.RWMutex
+	checkedAt time.Time
+	interval  time.Duration
+	log       *log.Entry
+}
+
+// NewCertificateStore loads certificates from the SSL config
+func NewCertificateStore(ssl *SSLConfig) (*CertificateStore, error) {
+	pairs := [][2]string{{ssl.CertPath, ssl.KeyPath}}
+
+	extra, err := ssl.ExtraPairs()
+
+	if err != nil {
+		return nil, err
+	}
+
+	pairs = append(pairs, extra...)
+
+	store := &CertificateStore{
+		interval:  certificateCheckInterval,
+		checkedAt: time.Now(),
+		log:       log.WithField("context", "http"),
+	}
+
+	for _, pair := range pairs {
+		entry := &certificateEntry{certPath: pair[0], keyPath: pair[1]}
+
+		if err := entry.load(); err != nil {
+			return nil, fmt.Errorf("Failed to load SSL certificate: %s.", err)
+		}
+
+		store.entries = append(store.entries, entry)
+	}
+
+	return store, nil
+}
+
+// GetCertificate returns a certificate for the client hello (could be used as tls.Config.GetCertificate)
+func (s *CertificateStore) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.refresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if hello.ServerName != "" && len(s.entries) > 1 {
+		for _, entry := range s.entries {
+			if entry.cert.Leaf != nil && entry.cert.Leaf.VerifyHostname(hello.ServerName) == nil {
+				return entry.cert, nil
+			}
+		}
+	}
+
+	return s.entries[0].cert, nil
+}
+
+// refresh reloads changed certificates (at most once per check interval).
+// Failed reloads are logged and the previous certificates are kept.
+func (s *CertificateStore) refresh() {
+	s.mu.RLock()
+	due := time.Since(s.checkedAt) >= s.interval
+	s.mu.RUnlock()
+
+	if !due {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Someone else has already checked
+	if time.Since(s.checkedAt) < s.interval {
+		return
+	}
+
+	s.checkedAt = time.Now()
+
+	for _, entry := range s.entries {
+		if !entry.changed() {
+			continue
+		}
+
+		if err := entry.load(); err != nil {
+			s.log.Errorf("Failed to reload SSL certificate %s, keep using the previous one: %v", entry.certPath, err)
+			continue
+		}
+
+		s.log.Infof("SSL certificate reloaded: %s", entry.certPath)
+	}
+}
+
+func (e *certificateEntry) changed() bool {
+	modTime, err := e.lastModified()
+
+	if err != nil {
+		return false
+	}
+
+	return modTime.After(e.modTime)
+}
+
+func (e *certificateEntry) lastModified() (time.Time, error) {
+	certInfo, err := os.Stat(e.certPath)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	keyInfo, err := os.Stat(e.keyPath)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	modTime := certInfo.ModTime()
+
+	if keyInfo.ModTime().After(modTime) {
+		modTime = keyInfo.ModTime()
+	}
+
+	return modTime, nil
+}
+
+func (e *certificateEntry) load() error {
+	modTime, err := e.lastModified()
+
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(e.certPath, e.keyPath)
+
+	if err != nil {
+		// Do not try to reload the broken files until they change again
+		e.modTime = modTime
+		return err
+	}
+
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		e.modTime = modTime
+		return err
+	}
+
+	e.cert = &cert
+	e.modTime = modTime
+
+	return nil
+}
+
+// ExtraPairs returns additional certificate and key paths pairs
+func (opts *SSLConfig) ExtraPairs() ([][2]string, error) {
+	pairs := [][2]string{}
+
+	if opts.ExtraCerts == "" {
+		return pairs, nil
+	}
+
+	for _, raw := range strings.Split(opts.ExtraCerts, ",") {
+		parts := strings.SplitN(strings.TrimSpace(raw), ":", 2)
+
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.New("Invalid extra SSL certificate, must be in the cert_path:key_path format: " + raw)
+		}
+
+		pairs = append(pairs, [2]string{parts[0], parts[1]})
+	}
+
+	return pairs, nil
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificateStore(t *testing.T) {
+	dir := t.TempDir()
+
+	cert, key := writeKeyPair(t, dir, "default", "example.com")
+	extraCert, extraKey := writeKeyPair(t, dir, "extra", "*.anycable.io")
+
+	config := NewSSLConfig()
+	config.CertPath = cert
+	config.KeyPath = key
+	config.ExtraCerts = extraCert + ":" + extraKey
+
+	store, err := NewCertificateStore(&config)
+	assert.Nil(t, err)
+
+	t.Run("Selects certificate by SNI", func(t *testing.T) {
+		res, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: "ws.anycable.io"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"*.anycable.io"}, res.Leaf.DNSNames)
+
+		res, err = store.GetCertificate(&tls.ClientHelloInfo{ServerName: "example.com"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"example.com"}, res.Leaf.DNSNames)
+	})
+
+	t.Run("Falls back to the default certificate", func(t *testing.T) {
+		res, err := store.GetCertificate(&tls.ClientHelloInfo{ServerName: "unknown.org"})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"example.com"}, res.Leaf.DNSNames)
+
+		res, err = store.GetCertificate(&tls.ClientHelloInfo{})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"example.com"}, res.Leaf.DNSNames)
+	})
+
+	t.Run("Reloads changed certificate", func(t *testing.T) {
+		store.interval = 0
+
+		writeKeyPair(t, dir, "default", "example.org")
+		touch(t, cert, key)
+
+		res, err := store.GetCertificate(&tls.ClientHelloInfo{})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"example.org"}, res.Leaf.DNSNames)
+	})
+
+	t.Run("Keeps previous certificate when reload fails", func(t *testing.T) {
+		store.interval = 0
+
+		assert.Nil(t, ioutil.WriteFile(cert, []byte("broken"), 0600))
+		touch(t, cert, key)
+
+		res, err := store.GetCertificate(&tls.ClientHelloInfo{})
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"example.org"}, res.Leaf.DNSNames)
+	})
+}
+
+func TestCertificateStoreErrors(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := writeKeyPair(t, dir, "default", "example.com")
+
+	t.Run("With missing files", func(t *testing.T) {
+		config := SSLConfig{CertPath: cert, KeyPath: filepath.Join(dir, "missing.key")}
+
+		_, err := NewCertificateStore(&config)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("With invalid extra certificates", func(t *testing.T) {
+		config := SSLConfig{CertPath: cert, KeyPath: key, ExtraCerts: cert}
+
+		_, err := NewCertificateStore(&config)
+		assert.NotNil(t, err)
+	})
+}
+
+func writeKeyPair(t *testing.T, dir string, name string, host string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+
+	assert.Nil(t, ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.Nil(t, ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+
+	return certPath, keyPath
+}
+
+// Make sure modification time changes even on file systems with coarse timestamps
+func touch(t *testing.T, paths ...string) {
+	future := time.Now().Add(time.Minute)
+
+	for _, path := range paths {
+		assert.Nil(t, os.Chtimes(path, future, future))
+	}
+}
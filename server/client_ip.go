@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const forwardedForHeader = "X-Forwarded-For"
+
+// TrustedProxies is a list of networks to accept the X-Forwarded-For header from
+var TrustedProxies []*net.IPNet
+
+// ParseCIDRs parses a comma-separated list of CIDRs (single IPs are also allowed)
+func ParseCIDRs(raw string) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+
+	for _, cidr := range strings.Split(raw, ",") {
+		cidr = strings.TrimSpace(cidr)
+
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(cidr)
+
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CIDR: %s", cidr)
+		}
+
+		nets = append(nets, n)
+	}
+
+	return nets, nil
+}
+
+// ClientIP returns the request's client IP.
+// If the direct peer is a trusted proxy, the X-Forwarded-For header is processed from right to left,
+// and the first untrusted address is returned.
+// The header is ignored if no trusted proxies configured.
+func ClientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if len(TrustedProxies) == 0 || !isTrustedProxy(ip) {
+		return ip
+	}
+
+	hops := []string{}
+
+	for _, value := range r.Header.Values(forwardedForHeader) {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+
+		// Do not trust anything beyond a malformed value
+		if net.ParseIP(hop) == nil {
+			return ip
+		}
+
+		ip = hop
+
+		if !isTrustedProxy(ip) {
+			return ip
+		}
+	}
+
+	return ip
+}
+
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range TrustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCIDRs(t *testing.T) {
+	nets, err := ParseCIDRs("10.0.0.0/8, 127.0.0.1,::1")
+	assert.Nil(t, err)
+	assert.Len(t, nets, 3)
+	assert.Equal(t, "127.0.0.1/32", nets[1].String())
+
+	_, err = ParseCIDRs("10.0.0.0/8,localhost")
+	assert.NotNil(t, err)
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.2:4321"
+	req.Header.Set("X-Forwarded-For", "1.1.1.1, 2.2.2.2, 10.0.0.3")
+
+	t.Run("Without trusted proxies", func(t *testing.T) {
+		assert.Equal(t, "10.0.0.2", ClientIP(req))
+	})
+
+	var err error
+
+	TrustedProxies, err = ParseCIDRs("10.0.0.0/8")
+	assert.Nil(t, err)
+	defer func() { TrustedProxies = nil }()
+
+	t.Run("With trusted peer", func(t *testing.T) {
+		assert.Equal(t, "2.2.2.2", ClientIP(req))
+	})
+
+	t.Run("With multiple headers", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.2:4321"
+		req.Header.Add("X-Forwarded-For", "3.3.3.3")
+		req.Header.Add("X-Forwarded-For", "10.0.0.4")
+
+		assert.Equal(t, "3.3.3.3", ClientIP(req))
+	})
+
+	t.Run("With untrusted peer", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "5.5.5.5:4321"
+		req.Header.Set("X-Forwarded-For", "1.1.1.1")
+
+		assert.Equal(t, "5.5.5.5", ClientIP(req))
+	})
+
+	t.Run("With malformed header", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.2:4321"
+		req.Header.Set("X-Forwarded-For", "1.1.1.1, unknown, 10.0.0.3")
+
+		assert.Equal(t, "10.0.0.3", ClientIP(req))
+	})
+
+	t.Run("With all hops trusted", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.2:4321"
+		req.Header.Set("X-Forwarded-For", "10.0.0.5")
+
+		assert.Equal(t, "10.0.0.5", ClientIP(req))
+	})
+}
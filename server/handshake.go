@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type acceptedAtKey struct{}
+type connTrackerKey struct{}
+
+// connTracker keeps the connection accept time until the first request is served
+type connTracker struct {
+	acceptedAt time.Time
+	requests   uint32
+}
+
+// HandshakeStats contains cumulative stats of the connection attempts failed before reaching the handlers
+type HandshakeStats struct {
+	// The total number of accepted TCP connections
+	Accepted uint64
+	// The total number of TLS connections closed before completing the handshake
+	TLSFailed uint64
+	// The total number of WebSocket upgrade requests to unknown paths
+	NotFound uint64
+}
+
+// handshakeTracker collects the handshake stats of a single server
+type handshakeTracker struct {
+	accepted  uint64
+	tlsFailed uint64
+	notFound  uint64
+}
+
+func (t *handshakeTracker) stats() HandshakeStats {
+	return HandshakeStats{
+		Accepted:  atomic.LoadUint64(&t.accepted),
+		TLSFailed: atomic.LoadUint64(&t.tlsFailed),
+		NotFound:  atomic.LoadUint64(&t.notFound),
+	}
+}
+
+// AcceptedAt returns the time the request connection has been accepted at (zero if unknown).
+// Only the first request of the connection is bound to the accept time; for the subsequent ones
+// (e.g., keep-alive requests) the time the server started handling the request is returned.
+func AcceptedAt(ctx context.Context) time.Time {
+	if ts, ok := ctx.Value(acceptedAtKey{}).(time.Time); ok {
+		return ts
+	}
+
+	return time.Time{}
+}
+
+func connContext(ctx context.Context, _ net.Conn) context.Context {
+	return context.WithValue(ctx, connTrackerKey{}, &connTracker{acceptedAt: time.Now()})
+}
+
+// acceptedAtHandler stamps the request with the time it's been accepted at (see AcceptedAt)
+func acceptedAtHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts := time.Now()
+
+		if tracker, ok := r.Context().Value(connTrackerKey{}).(*connTracker); ok && atomic.AddUint32(&tracker.requests, 1) == 1 {
+			ts = tracker.acceptedAt
+		}
+
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), acceptedAtKey{}, ts)))
+	})
+}
+
+// trackConnState counts accepted connections and failed TLS handshakes
+// (TLS connections closed without any request are considered failed, too)
+func (t *handshakeTracker) trackConnState(conn net.Conn, state http.ConnState) {
+	switch state { // nolint:exhaustive
+	case http.StateNew:
+		atomic.AddUint64(&t.accepted, 1)
+	case http.StateClosed:
+		if tc, ok := conn.(*tls.Conn); ok && !tc.ConnectionState().HandshakeComplete {
+			atomic.AddUint64(&t.tlsFailed, 1)
+		}
+	}
+}
+
+// upgradeNotFoundHandler counts WebSocket upgrade requests to the paths not handled by the mux
+func (t *handshakeTracker) upgradeNotFoundHandler(handler http.Handler, mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			if _, pattern := mux.Handler(r); pattern == "" {
+				atomic.AddUint64(&t.notFound, 1)
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
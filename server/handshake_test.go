@@ -0,0 +1,99 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandshakeTracking(t *testing.T) {
+	var acceptedAt time.Time
+
+	tracker := &handshakeTracker{}
+
+	srv := httptest.NewUnstartedServer(acceptedAtHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptedAt = AcceptedAt(r.Context())
+	})))
+	srv.Config.ConnContext = connContext
+	srv.Config.ConnState = tracker.trackConnState
+	srv.StartTLS()
+	defer srv.Close()
+
+	t.Run("Successful request", func(t *testing.T) {
+		stats := tracker.stats()
+
+		res, err := srv.Client().Get(srv.URL)
+		assert.Nil(t, err)
+		res.Body.Close()
+
+		assert.Equal(t, stats.Accepted+1, tracker.stats().Accepted)
+		assert.Equal(t, stats.TLSFailed, tracker.stats().TLSFailed)
+		assert.False(t, acceptedAt.IsZero())
+	})
+
+	t.Run("Keep-alive request", func(t *testing.T) {
+		stats := tracker.stats()
+		prev := acceptedAt
+
+		time.Sleep(10 * time.Millisecond)
+
+		res, err := srv.Client().Get(srv.URL)
+		assert.Nil(t, err)
+		res.Body.Close()
+
+		// The connection is reused, so the request time is used
+		assert.Equal(t, stats.Accepted, tracker.stats().Accepted)
+		assert.GreaterOrEqual(t, int64(acceptedAt.Sub(prev)), int64(10*time.Millisecond))
+	})
+
+	t.Run("Failed TLS handshake", func(t *testing.T) {
+		stats := tracker.stats()
+
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		assert.Nil(t, err)
+
+		_, err = conn.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+		assert.Nil(t, err)
+		conn.Close()
+
+		assert.Eventually(t, func() bool {
+			return tracker.stats().TLSFailed == stats.TLSFailed+1
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Equal(t, stats.Accepted+1, tracker.stats().Accepted)
+	})
+}
+
+func TestUpgradeNotFoundHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/cable", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tracker := &handshakeTracker{}
+	handler := tracker.upgradeNotFoundHandler(mux, mux)
+
+	request := func(path string, upgrade bool) int {
+		req := httptest.NewRequest("GET", path, nil)
+
+		if upgrade {
+			req.Header.Set("Upgrade", "websocket")
+		}
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		return rr.Code
+	}
+
+	stats := tracker.stats()
+
+	assert.Equal(t, http.StatusOK, request("/cable", true))
+	assert.Equal(t, http.StatusNotFound, request("/unknown", false))
+	assert.Equal(t, stats.NotFound, tracker.stats().NotFound)
+
+	assert.Equal(t, http.StatusNotFound, request("/cabel", true))
+	assert.Equal(t, stats.NotFound+1, tracker.stats().NotFound)
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/apex/log"
+)
+
+var headerLimitExceeded uint64
+
+// HeaderLimitExceeded returns the total number of requests rejected due to the headers size limit
+func HeaderLimitExceeded() uint64 {
+	return atomic.LoadUint64(&headerLimitExceeded)
+}
+
+// HeaderLimitHandler rejects requests with headers (including the request line) larger than the limit
+// with the 431 status and logs them.
+// NOTE: the underlying http.Server must accept larger headers (see NewServer); otherwise,
+// requests are rejected before reaching the handler.
+func HeaderLimitHandler(next http.Handler, limit int) http.Handler {
+	ctx := log.WithField("context", "http")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if size := requestHeaderSize(r); size > limit {
+			atomic.AddUint64(&headerLimitExceeded, 1)
+
+			ctx.WithField("remote", ClientIP(r)).Warnf("Request headers are too large: %d bytes (limit: %d)", size, limit)
+
+			http.Error(w, http.StatusText(http.StatusRequestHeaderFieldsTooLarge), http.StatusRequestHeaderFieldsTooLarge)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestHeaderSize returns the size of the request line and headers as they were received
+func requestHeaderSize(r *http.Request) int {
+	// "GET /path HTTP/1.1\r\n"
+	size := len(r.Method) + len(r.RequestURI) + len(r.Proto) + 4
+
+	// Host header is removed from the headers map
+	if r.Host != "" {
+		size += len("Host: ") + len(r.Host) + 2
+	}
+
+	for key, values := range r.Header {
+		for _, value := range values {
+			// "Key: value\r\n"
+			size += len(key) + len(value) + 4
+		}
+	}
+
+	return size
+}
@@ -0,0 +1,244 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+// The max time to wait for the PROXY protocol header
+const proxyProtocolHeaderTimeout = 5 * time.Second
+
+var (
+	proxyProtocolV1Prefix  = []byte("PROXY ")
+	proxyProtocolV2Sig     = []byte("\r\n\r\n\x00\r\nQUIT\n")
+	errMissingProxyHeader  = errors.New("PROXY protocol header is missing")
+	errInvalidProxyHeader  = errors.New("Invalid PROXY protocol header")
+	proxyProtocolV1MaxSize = 107
+)
+
+// ProxyProtocolConfig contains PROXY protocol parameters
+type ProxyProtocolConfig struct {
+	Enabled bool
+	// Comma-separated list of CIDRs allowed to connect without the PROXY header (e.g., load balancer health checks)
+	OptionalCIDRs string
+}
+
+// NewProxyProtocolConfig builds a new ProxyProtocolConfig struct
+func NewProxyProtocolConfig() ProxyProtocolConfig {
+	return ProxyProtocolConfig{}
+}
+
+// proxyProtocolListener decodes PROXY protocol (v1 and v2) headers
+// and uses the source address from the header as the connection remote address
+type proxyProtocolListener struct {
+	net.Listener
+	optional []*net.IPNet
+	timeout  time.Duration
+}
+
+// NewProxyProtocolListener wraps the listener to support PROXY protocol.
+// Connections without the header are rejected unless they come from the optional networks.
+func NewProxyProtocolListener(ln net.Listener, optional []*net.IPNet) net.Listener {
+	return &proxyProtocolListener{Listener: ln, optional: optional, timeout: proxyProtocolHeaderTimeout}
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &proxyProtocolConn{
+		Conn:     conn,
+		reader:   bufio.NewReader(conn),
+		optional: l.optionalPeer(conn.RemoteAddr()),
+		timeout:  l.timeout,
+	}, nil
+}
+
+func (l *proxyProtocolListener) optionalPeer(addr net.Addr) bool {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+
+	if !ok {
+		return false
+	}
+
+	for _, n := range l.optional {
+		if n.Contains(tcpAddr.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// proxyProtocolConn reads the header lazily (on the first Read or RemoteAddr call),
+// so a slow client doesn't block the accept loop
+type proxyProtocolConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	optional   bool
+	timeout    time.Duration
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b)
+}
+
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyProtocolConn) readHeader() {
+	c.Conn.SetReadDeadline(time.Now().Add(c.timeout)) // nolint:errcheck
+	defer c.Conn.SetReadDeadline(time.Time{})         // nolint:errcheck
+
+	c.remoteAddr, c.err = c.parseHeader()
+
+	if c.err != nil {
+		log.WithField("context", "http").Debugf("Rejected connection from %s: %v", c.Conn.RemoteAddr(), c.err)
+	}
+}
+
+func (c *proxyProtocolConn) parseHeader() (net.Addr, error) {
+	first, err := c.reader.Peek(1)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] == proxyProtocolV2Sig[0] {
+		if sig, err := c.reader.Peek(len(proxyProtocolV2Sig)); err == nil && bytes.Equal(sig, proxyProtocolV2Sig) {
+			return c.parseV2()
+		}
+	} else if prefix, err := c.reader.Peek(len(proxyProtocolV1Prefix)); err == nil && bytes.Equal(prefix, proxyProtocolV1Prefix) {
+		return c.parseV1()
+	}
+
+	if c.optional {
+		return nil, nil
+	}
+
+	return nil, errMissingProxyHeader
+}
+
+// parseV1 parses a human-readable header, e.g.: "PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n"
+func (c *proxyProtocolConn) parseV1() (net.Addr, error) {
+	line := make([]byte, 0, proxyProtocolV1MaxSize)
+
+	for {
+		b, err := c.reader.ReadByte()
+
+		if err != nil {
+			return nil, err
+		}
+
+		line = append(line, b)
+
+		if b == '\n' {
+			break
+		}
+
+		if len(line) >= proxyProtocolV1MaxSize {
+			return nil, errInvalidProxyHeader
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errInvalidProxyHeader
+	}
+
+	parts := strings.Split(strings.TrimSuffix(string(line), "\r\n"), " ")
+
+	if len(parts) >= 2 && parts[1] == "UNKNOWN" {
+		return nil, nil
+	}
+
+	if len(parts) != 6 || (parts[1] != "TCP4" && parts[1] != "TCP6") {
+		return nil, errInvalidProxyHeader
+	}
+
+	ip := net.ParseIP(parts[2])
+	port, err := strconv.Atoi(parts[4])
+
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, errInvalidProxyHeader
+	}
+
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// parseV2 parses a binary header (see https://www.haproxy.org/download/2.4/doc/proxy-protocol.txt)
+func (c *proxyProtocolConn) parseV2() (net.Addr, error) {
+	header := make([]byte, 16)
+
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return nil, err
+	}
+
+	version := header[12] >> 4
+	command := header[12] & 0x0F
+	family := header[13] >> 4
+	length := int(binary.BigEndian.Uint16(header[14:16]))
+
+	if version != 2 || command > 1 {
+		return nil, errInvalidProxyHeader
+	}
+
+	payload := make([]byte, length)
+
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return nil, err
+	}
+
+	// LOCAL command (e.g., health checks from the proxy itself)
+	if command == 0 {
+		return nil, nil
+	}
+
+	switch family {
+	case 1: // AF_INET
+		if length < 12 {
+			return nil, errInvalidProxyHeader
+		}
+
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 2: // AF_INET6
+		if length < 36 {
+			return nil, errInvalidProxyHeader
+		}
+
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	case 0, 3: // AF_UNSPEC, AF_UNIX
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported PROXY protocol address family: %d", family)
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxyProtocolListener(t *testing.T) {
+	v2Header := append([]byte("\r\n\r\n\x00\r\nQUIT\n"), 0x21, 0x11, 0x00, 0x0C)
+	v2Header = append(v2Header, 192, 168, 0, 1, 10, 0, 0, 1, 0xDC, 0x04, 0x01, 0xBB)
+
+	tests := []struct {
+		name     string
+		payload  string
+		optional string
+		remote   string
+		err      bool
+	}{
+		{name: "v1 TCP4", payload: "PROXY TCP4 192.168.0.1 10.0.0.1 56324 443\r\nGET /", remote: "192.168.0.1:56324"},
+		{name: "v1 TCP6", payload: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\nGET /", remote: "[2001:db8::1]:56324"},
+		{name: "v1 UNKNOWN", payload: "PROXY UNKNOWN\r\nGET /", remote: "127.0.0.1"},
+		{name: "v1 invalid", payload: "PROXY TCP4 localhost 10.0.0.1 56324 443\r\nGET /", err: true},
+		{name: "v2 TCP4", payload: string(v2Header) + "GET /", remote: "192.168.0.1:56324"},
+		{name: "Missing header", payload: "PUT / HTTP/1.1\r\n", err: true},
+		{name: "Missing header from optional peer", payload: "PUT / HTTP/1.1\r\n", optional: "127.0.0.1", remote: "127.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			optional, err := ParseCIDRs(tt.optional)
+			assert.Nil(t, err)
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.Nil(t, err)
+
+			pln := NewProxyProtocolListener(ln, optional)
+			defer pln.Close()
+
+			go func() {
+				client, err := net.Dial("tcp", ln.Addr().String())
+
+				if err != nil {
+					return
+				}
+
+				client.Write([]byte(tt.payload)) // nolint:errcheck
+				client.Close()
+			}()
+
+			conn, err := pln.Accept()
+			assert.Nil(t, err)
+			defer conn.Close()
+
+			data, err := ioutil.ReadAll(conn)
+
+			if tt.err {
+				assert.NotNil(t, err)
+				return
+			}
+
+			assert.Nil(t, err)
+
+			if tt.optional == "" {
+				assert.Equal(t, "GET /", string(data))
+			} else {
+				assert.Equal(t, tt.payload, string(data))
+			}
+
+			host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+
+			if host == "127.0.0.1" {
+				assert.Equal(t, tt.remote, host)
+			} else {
+				assert.Equal(t, tt.remote, conn.RemoteAddr().String())
+			}
+		})
+	}
+}
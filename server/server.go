@@ -3,26 +3,41 @@ package server
 import (
 	"context"
 	"crypto/tls"
-	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/apex/log"
 	"golang.org/x/net/netutil"
 )
 
+// UnixSocketPrefix is used to specify Unix domain socket as a host (e.g., unix:///tmp/anycable.sock)
+const UnixSocketPrefix = "unix://"
+
 // HTTPServer is wrapper over http.Server
 type HTTPServer struct {
-	server   *http.Server
-	addr     string
-	secured  bool
-	shutdown bool
-	started  bool
-	maxConn  int
-	mu       sync.Mutex
-	log      *log.Entry
+	server     *http.Server
+	network    string
+	addr       string
+	socketPerm os.FileMode
+	timeout    time.Duration
+	secured    bool
+	shutdown   bool
+	started    bool
+	ready      chan struct{}
+	maxConn    int
+	mu         sync.Mutex
+	log        *log.Entry
+
+	// Networks allowed to connect without the PROXY protocol header (nil if PROXY protocol is disabled)
+	proxyProtocol []*net.IPNet
+
+	handshakes *handshakeTracker
 
 	Mux *http.ServeMux
 }
@@ -35,6 +50,19 @@ var (
 	SSL *SSLConfig
 	// MaxConn is a default configuration for maximum connections
 	MaxConn int
+	// SocketPermissions is a file mode for Unix domain sockets (octal, e.g. "0660"; empty means system default)
+	SocketPermissions string
+	// AccessLog is a default configuration for HTTP access logging
+	AccessLog *AccessLogConfig
+	// ShutdownTimeout is the max time to wait for active requests to complete during shutdown
+	ShutdownTimeout = 30 * time.Second
+	// ReadHeaderTimeout is the max time to read request headers (0 – no limit)
+	ReadHeaderTimeout = 10 * time.Second
+	// HandshakeTimeout is the max time to read the whole request and to complete the WebSocket upgrade (0 – no limit).
+	// Upgraded WebSocket connections are not affected
+	HandshakeTimeout = 30 * time.Second
+	// MaxHeaderBytes is the max size of request headers, including the request line (0 – Go default)
+	MaxHeaderBytes = 64 * 1024
 )
 
 // ForPort creates new or returns the existing server for the specified port
@@ -51,36 +79,94 @@ func ForPort(port string) (*HTTPServer, error) {
 }
 
 // NewServer builds HTTPServer from config params
+// Host could be a Unix domain socket path prefixed with unix://, in this case port is ignored.
 func NewServer(host string, port string, ssl *SSLConfig, maxConn int) (*HTTPServer, error) {
 	mux := http.NewServeMux()
+
+	network := "tcp"
 	addr := net.JoinHostPort(host, port)
 
-	server := &http.Server{Addr: addr, Handler: mux}
+	var socketPerm os.FileMode
+
+	if strings.HasPrefix(host, UnixSocketPrefix) {
+		network = "unix"
+		addr = strings.TrimPrefix(host, UnixSocketPrefix)
+
+		if SocketPermissions != "" {
+			perm, err := strconv.ParseUint(SocketPermissions, 8, 32)
+
+			if err != nil {
+				return nil, fmt.Errorf("Invalid socket permissions: %s", SocketPermissions)
+			}
+
+			socketPerm = os.FileMode(perm)
+		}
+	}
+
+	handshakes := &handshakeTracker{}
+
+	var handler http.Handler = acceptedAtHandler(handshakes.upgradeNotFoundHandler(mux, mux))
+
+	if MaxHeaderBytes > 0 {
+		handler = HeaderLimitHandler(handler, MaxHeaderBytes)
+	}
+
+	if AccessLog != nil && AccessLog.Enabled {
+		handler = AccessLogHandler(handler, AccessLog)
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: ReadHeaderTimeout,
+		ReadTimeout:       HandshakeTimeout,
+		ConnContext:       connContext,
+		ConnState:         handshakes.trackConnState,
+	}
+
+	if MaxHeaderBytes > 0 {
+		// Requests exceeding the limit must reach the handler to be logged;
+		// much larger requests are rejected by the server right away
+		server.MaxHeaderBytes = 2 * MaxHeaderBytes
+	}
 
 	secured := (ssl != nil) && ssl.Available()
 
 	if secured {
-		cer, err := tls.LoadX509KeyPair(ssl.CertPath, ssl.KeyPath)
+		store, err := NewCertificateStore(ssl)
 		if err != nil {
-			msg := fmt.Sprintf("Failed to load SSL certificate: %s.", err)
-			return nil, errors.New(msg)
+			return nil, err
 		}
 
-		server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cer}, MinVersion: tls.VersionTLS12}
+		server.TLSConfig = &tls.Config{GetCertificate: store.GetCertificate, MinVersion: tls.VersionTLS12}
+
+		if err := ssl.ConfigureClientAuth(server.TLSConfig); err != nil {
+			return nil, err
+		}
 	}
 
 	return &HTTPServer{
-		server:   server,
-		addr:     addr,
-		Mux:      mux,
-		secured:  secured,
-		shutdown: false,
-		started:  false,
-		maxConn:  maxConn,
-		log:      log.WithField("context", "http"),
+		server:     server,
+		network:    network,
+		addr:       addr,
+		socketPerm: socketPerm,
+		timeout:    ShutdownTimeout,
+		Mux:        mux,
+		secured:    secured,
+		shutdown:   false,
+		started:    false,
+		ready:      make(chan struct{}),
+		maxConn:    maxConn,
+		handshakes: handshakes,
+		log:        log.WithField("context", "http"),
 	}, nil
 }
 
+// HandshakeStats returns the current handshake stats of the server
+func (s *HTTPServer) HandshakeStats() HandshakeStats {
+	return s.handshakes.stats()
+}
+
 // Start server
 func (s *HTTPServer) Start() error {
 	s.mu.Lock()
@@ -92,16 +178,35 @@ func (s *HTTPServer) Start() error {
 	s.started = true
 	s.mu.Unlock()
 
-	ln, err := net.Listen("tcp", s.addr)
+	if s.network == "unix" {
+		if err := removeStaleSocket(s.addr); err != nil {
+			return err
+		}
+	}
+
+	ln, err := net.Listen(s.network, s.addr)
 	if err != nil {
 		return err
 	}
+	// NOTE: closing a Unix listener also removes the socket file
 	defer ln.Close()
 
+	if s.network == "unix" && s.socketPerm != 0 {
+		if err := os.Chmod(s.addr, s.socketPerm); err != nil {
+			return fmt.Errorf("Failed to set socket permissions: %v", err)
+		}
+	}
+
+	if s.proxyProtocol != nil {
+		ln = NewProxyProtocolListener(ln, s.proxyProtocol)
+	}
+
 	if s.maxConn > 0 {
 		ln = netutil.LimitListener(ln, s.maxConn)
 	}
 
+	close(s.ready)
+
 	if s.secured {
 		return s.server.ServeTLS(ln, "", "")
 	}
@@ -109,6 +214,16 @@ func (s *HTTPServer) Start() error {
 	return s.server.Serve(ln)
 }
 
+// EnableProxyProtocol makes server accept PROXY protocol headers (must be called before Start).
+// Connections from the optional networks are accepted without the header.
+func (s *HTTPServer) EnableProxyProtocol(optional []*net.IPNet) {
+	if optional == nil {
+		optional = []*net.IPNet{}
+	}
+
+	s.proxyProtocol = optional
+}
+
 // StartAndAnnounce prints server info and starts server
 func (s *HTTPServer) StartAndAnnounce(name string) error {
 	s.mu.Lock()
@@ -124,12 +239,20 @@ func (s *HTTPServer) StartAndAnnounce(name string) error {
 	return s.Start()
 }
 
+// Ready returns a channel which is closed when the server starts listening
+func (s *HTTPServer) Ready() <-chan struct{} {
+	return s.ready
+}
+
 // Running returns true if server has been started
 func (s *HTTPServer) Running() bool {
 	return s.started
 }
 
 // Shutdown shuts down server gracefully.
+// If active requests are not completed in time, the server is closed forcefully
+// and context.DeadlineExceeded is returned.
+// NOTE: hijacked (WebSocket) connections are not tracked by the server, they're closed by the node.
 func (s *HTTPServer) Shutdown() error {
 	s.mu.Lock()
 	if s.shutdown {
@@ -139,7 +262,17 @@ func (s *HTTPServer) Shutdown() error {
 	s.shutdown = true
 	s.mu.Unlock()
 
-	return s.server.Shutdown(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	err := s.server.Shutdown(ctx)
+
+	if err == context.DeadlineExceeded {
+		s.log.Warnf("Server at %s hasn't been shut down in %v, closing forcefully", s.Address(), s.timeout)
+		s.server.Close() // nolint:errcheck
+	}
+
+	return err
 }
 
 // Stopped return true iff server has been stopped by user
@@ -150,8 +283,12 @@ func (s *HTTPServer) Stopped() bool {
 	return val
 }
 
-// Address returns server scheme://host:port
+// Address returns server scheme://host:port (or unix://path for Unix domain sockets)
 func (s *HTTPServer) Address() string {
+	if s.network == "unix" {
+		return UnixSocketPrefix + s.addr
+	}
+
 	var scheme string
 
 	if s.secured {
@@ -162,3 +299,20 @@ func (s *HTTPServer) Address() string {
 
 	return fmt.Sprintf("%s%s", scheme, s.addr)
 }
+
+// removeStaleSocket removes the socket file left from the previous run
+// (unless someone is still listening on it)
+func removeStaleSocket(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	conn, err := net.Dial("unix", path)
+
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("Socket %s is already in use", path)
+	}
+
+	return os.Remove(path)
+}
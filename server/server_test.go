@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnixSocketServer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anycable.sock")
+
+	// Stale socket file
+	assert.Nil(t, ioutil.WriteFile(path, []byte{}, 0644))
+
+	SocketPermissions = "0660"
+	defer func() { SocketPermissions = "" }()
+
+	srv, err := NewServer(UnixSocketPrefix+path, "8080", nil, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "unix://"+path, srv.Address())
+
+	srv.Mux.Handle("/health", http.HandlerFunc(HealthHandler))
+
+	go srv.Start() // nolint:errcheck
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", path)
+			},
+		},
+	}
+
+	var res *http.Response
+
+	for i := 0; i < 50; i++ {
+		if res, err = client.Get("http://unix/health"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	res.Body.Close()
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0660), info.Mode().Perm())
+
+	// Doesn't remove the socket in use
+	another, err := NewServer(UnixSocketPrefix+path, "8080", nil, 0)
+	assert.Nil(t, err)
+	assert.NotNil(t, another.Start())
+
+	assert.Nil(t, srv.Shutdown())
+
+	for i := 0; i < 50; i++ {
+		if _, err = os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestHeaderLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anycable.sock")
+
+	MaxHeaderBytes = 1024
+	defer func() { MaxHeaderBytes = 64 * 1024 }()
+
+	srv, err := NewServer(UnixSocketPrefix+path, "8080", nil, 0)
+	assert.Nil(t, err)
+	defer srv.Shutdown() // nolint:errcheck
+
+	assert.Equal(t, 10*time.Second, srv.server.ReadHeaderTimeout)
+	assert.Equal(t, 30*time.Second, srv.server.ReadTimeout)
+
+	srv.Mux.Handle("/health", http.HandlerFunc(HealthHandler))
+
+	go srv.Start() // nolint:errcheck
+	<-srv.Ready()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", path)
+			},
+		},
+	}
+
+	res, err := client.Get("http://unix/health")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	res.Body.Close()
+
+	before := HeaderLimitExceeded()
+
+	req, _ := http.NewRequest("GET", "http://unix/health", nil)
+	req.Header.Set("X-Large", strings.Repeat("a", 1024))
+
+	res, err = client.Do(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusRequestHeaderFieldsTooLarge, res.StatusCode)
+	res.Body.Close()
+
+	assert.Equal(t, before+1, HeaderLimitExceeded())
+}
+
+func TestInvalidSocketPermissions(t *testing.T) {
+	SocketPermissions = "rw"
+	defer func() { SocketPermissions = "" }()
+
+	_, err := NewServer(UnixSocketPrefix+"/tmp/test.sock", "8080", nil, 0)
+	assert.NotNil(t, err)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "anycable.sock")
+
+	ShutdownTimeout = 50 * time.Millisecond
+	defer func() { ShutdownTimeout = 30 * time.Second }()
+
+	srv, err := NewServer(UnixSocketPrefix+path, "8080", nil, 0)
+	assert.Nil(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+
+	srv.Mux.Handle("/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+
+	go srv.Start() // nolint:errcheck
+
+	for i := 0; i < 50; i++ {
+		if _, err = os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn, err := net.Dial("unix", path)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("GET /slow HTTP/1.1\r\nHost: unix\r\n\r\n"))
+	assert.Nil(t, err)
+
+	<-started
+
+	assert.Equal(t, context.DeadlineExceeded, srv.Shutdown())
+}
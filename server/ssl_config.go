@@ -1,9 +1,29 @@
 package server
 
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// Client certificates verification modes
+const (
+	VerifyClientOff      = "off"
+	VerifyClientOptional = "optional"
+	VerifyClientRequire  = "require"
+)
+
 // SSLConfig contains SSL parameters
 type SSLConfig struct {
 	CertPath string
 	KeyPath  string
+	// Path to the CA certificates bundle to verify client certificates
+	ClientCAPath string
+	// Client certificates verification mode (off, optional, require)
+	VerifyClient string
+	// Additional certificates selected by SNI ("cert_path:key_path" pairs separated by commas)
+	ExtraCerts string
 }
 
 // NewSSLConfig build a new SSLConfig struct
@@ -15,3 +35,46 @@ func NewSSLConfig() SSLConfig {
 func (opts *SSLConfig) Available() bool {
 	return opts.CertPath != "" && opts.KeyPath != ""
 }
+
+// Validate checks that certificates could be loaded and client verification is configured properly
+func (opts *SSLConfig) Validate() error {
+	if _, err := NewCertificateStore(opts); err != nil {
+		return err
+	}
+
+	return opts.ConfigureClientAuth(&tls.Config{}) // #nosec
+}
+
+// ConfigureClientAuth configures client certificates verification
+func (opts *SSLConfig) ConfigureClientAuth(config *tls.Config) error {
+	switch opts.VerifyClient {
+	case "", VerifyClientOff:
+		return nil
+	case VerifyClientOptional:
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case VerifyClientRequire:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return fmt.Errorf("Unknown client verification mode: %s. Use off, optional or require", opts.VerifyClient)
+	}
+
+	if opts.ClientCAPath == "" {
+		return fmt.Errorf("Client CA path must be specified to verify client certificates")
+	}
+
+	pem, err := ioutil.ReadFile(opts.ClientCAPath)
+
+	if err != nil {
+		return fmt.Errorf("Failed to read client CA: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("No valid certificates found in client CA: %s", opts.ClientCAPath)
+	}
+
+	config.ClientCAs = pool
+
+	return nil
+}
@@ -1,7 +1,18 @@
 package server
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -16,3 +27,71 @@ func TestSSLAvailable(t *testing.T) {
 	config.KeyPath = "secret.key"
 	assert.True(t, config.Available())
 }
+
+func TestConfigureClientAuth(t *testing.T) {
+	caPath := filepath.Join(t.TempDir(), "ca.pem")
+	assert.Nil(t, ioutil.WriteFile(caPath, generateCertPEM(t), 0600))
+
+	t.Run("Off", func(t *testing.T) {
+		config := NewSSLConfig()
+		tlsConfig := &tls.Config{}
+
+		assert.Nil(t, config.ConfigureClientAuth(tlsConfig))
+		assert.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+	})
+
+	t.Run("Require", func(t *testing.T) {
+		config := SSLConfig{VerifyClient: "require", ClientCAPath: caPath}
+		tlsConfig := &tls.Config{}
+
+		assert.Nil(t, config.ConfigureClientAuth(tlsConfig))
+		assert.Equal(t, tls.RequireAndVerifyClientCert, tlsConfig.ClientAuth)
+		assert.NotNil(t, tlsConfig.ClientCAs)
+	})
+
+	t.Run("Optional", func(t *testing.T) {
+		config := SSLConfig{VerifyClient: "optional", ClientCAPath: caPath}
+		tlsConfig := &tls.Config{}
+
+		assert.Nil(t, config.ConfigureClientAuth(tlsConfig))
+		assert.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+	})
+
+	t.Run("Without CA", func(t *testing.T) {
+		config := SSLConfig{VerifyClient: "require"}
+		assert.NotNil(t, config.ConfigureClientAuth(&tls.Config{}))
+	})
+
+	t.Run("With invalid CA", func(t *testing.T) {
+		invalidPath := filepath.Join(t.TempDir(), "invalid.pem")
+		assert.Nil(t, ioutil.WriteFile(invalidPath, []byte("not a cert"), 0600))
+
+		config := SSLConfig{VerifyClient: "require", ClientCAPath: invalidPath}
+		assert.NotNil(t, config.ConfigureClientAuth(&tls.Config{}))
+	})
+
+	t.Run("With unknown mode", func(t *testing.T) {
+		config := SSLConfig{VerifyClient: "always", ClientCAPath: caPath}
+		assert.NotNil(t, config.ConfigureClientAuth(&tls.Config{}))
+	})
+}
+
+func generateCertPEM(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
@@ -0,0 +1,21 @@
+package sse
+
+// Config contains SSE transport configuration
+type Config struct {
+	// Path to mount the SSE endpoint to (empty – disabled)
+	Path string
+	// For how long to keep a session without attached streams before closing it (seconds)
+	IdleTimeout int
+	// The max number of messages to keep per session to resume streams (via Last-Event-ID)
+	BufferSize int
+}
+
+// NewConfig build a new Config struct
+func NewConfig() Config {
+	return Config{IdleTimeout: 30, BufferSize: 100}
+}
+
+// Enabled returns true if the SSE endpoint should be mounted
+func (c *Config) Enabled() bool {
+	return c.Path != ""
+}
@@ -0,0 +1,232 @@
+package sse
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	errClosed          = errors.New("Connection closed")
+	errBinaryMessages  = errors.New("Binary messages are not supported")
+	errCommandsTimeout = errors.New("Commands queue is full")
+)
+
+// event is a message sent to the client
+type event struct {
+	seq  uint64
+	data []byte
+}
+
+// stream represents an HTTP response attached to the connection
+type stream struct {
+	// Closed when the stream must be stopped (e.g., replaced by a new one)
+	stop chan struct{}
+	// Signalled when new events are available
+	notify chan struct{}
+}
+
+// Connection is an SSE implementation of Connection.
+// Outgoing messages are buffered and delivered to the currently attached stream (HTTP response);
+// incoming messages are received via separate HTTP requests.
+type Connection struct {
+	token       string
+	bufferSize  int
+	idleTimeout time.Duration
+
+	mu        sync.Mutex
+	seq       uint64
+	events    []*event
+	stream    *stream
+	idleTimer *time.Timer
+	closed    bool
+
+	commands chan []byte
+	done     chan struct{}
+	onClose  func(*Connection)
+}
+
+// NewConnection creates a new SSE connection with the specified token
+func NewConnection(token string, config *Config) *Connection {
+	return &Connection{
+		token:       token,
+		bufferSize:  config.BufferSize,
+		idleTimeout: time.Duration(config.IdleTimeout) * time.Second,
+		commands:    make(chan []byte, 16),
+		done:        make(chan struct{}),
+	}
+}
+
+// Token returns the connection token used by clients to resume streams and send commands
+func (c *Connection) Token() string {
+	return c.token
+}
+
+// Write adds a message to the buffer and notifies the attached stream (if any)
+func (c *Connection) Write(msg []byte, deadline time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errClosed
+	}
+
+	c.seq++
+	c.events = append(c.events, &event{seq: c.seq, data: msg})
+
+	if len(c.events) > c.bufferSize {
+		c.events = c.events[len(c.events)-c.bufferSize:]
+	}
+
+	if c.stream != nil {
+		select {
+		case c.stream.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// WriteBinary is not supported by SSE (only JSON encoding could be used)
+func (c *Connection) WriteBinary(msg []byte, deadline time.Time) error {
+	return errBinaryMessages
+}
+
+// Read waits for the next incoming message
+func (c *Connection) Read() ([]byte, error) {
+	select {
+	case msg := <-c.commands:
+		return msg, nil
+	case <-c.done:
+		return nil, errClosed
+	}
+}
+
+// Close closes the connection (attached stream is closed after sending the buffered messages)
+func (c *Connection) Close(code int, reason string) {
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+
+	c.closed = true
+	close(c.done)
+
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+		c.idleTimer = nil
+	}
+
+	onClose := c.onClose
+	c.mu.Unlock()
+
+	if onClose != nil {
+		onClose(c)
+	}
+}
+
+// Closed returns true if the connection has been closed
+func (c *Connection) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.closed
+}
+
+// push enqueues an incoming message (waits for the queue to have space until the timeout)
+func (c *Connection) push(msg []byte, timeout time.Duration) error {
+	select {
+	case <-c.done:
+		return errClosed
+	default:
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case c.commands <- msg:
+		return nil
+	case <-c.done:
+		return errClosed
+	case <-timer.C:
+		return errCommandsTimeout
+	}
+}
+
+// attach registers a new stream (the previous one is stopped)
+func (c *Connection) attach() *stream {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stream != nil {
+		close(c.stream.stop)
+	}
+
+	c.stream = &stream{stop: make(chan struct{}), notify: make(chan struct{}, 1)}
+
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+		c.idleTimer = nil
+	}
+
+	return c.stream
+}
+
+// stopStream stops the attached stream (if any)
+func (c *Connection) stopStream() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stream != nil {
+		close(c.stream.stop)
+		c.stream = nil
+	}
+}
+
+// detach unregisters the stream and starts the idle timer
+func (c *Connection) detach(s *stream) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The stream has been already replaced
+	if c.stream != s {
+		return
+	}
+
+	c.stream = nil
+
+	if !c.closed {
+		c.idleTimer = time.AfterFunc(c.idleTimeout, c.expire)
+	}
+}
+
+func (c *Connection) expire() {
+	c.Close(0, "Idle timeout")
+}
+
+// eventsSince returns the buffered events with sequence numbers greater than the specified one.
+// Returns false if some of the requested events are no longer in the buffer.
+func (c *Connection) eventsSince(seq uint64) ([]*event, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.events) == 0 {
+		return nil, seq >= c.seq
+	}
+
+	first := c.events[0].seq
+
+	if seq+1 < first {
+		return nil, false
+	}
+
+	if seq >= c.seq {
+		return nil, true
+	}
+
+	return c.events[seq+1-first:], true
+}
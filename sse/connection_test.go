@@ -0,0 +1,79 @@
+package sse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionBuffer(t *testing.T) {
+	config := NewConfig()
+	config.BufferSize = 2
+
+	conn := NewConnection("test", &config)
+
+	for _, msg := range []string{"a", "b", "c"} {
+		assert.Nil(t, conn.Write([]byte(msg), time.Now()))
+	}
+
+	events, ok := conn.eventsSince(1)
+	assert.True(t, ok)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "b", string(events[0].data))
+	assert.Equal(t, "c", string(events[1].data))
+
+	events, ok = conn.eventsSince(3)
+	assert.True(t, ok)
+	assert.Empty(t, events)
+
+	_, ok = conn.eventsSince(0)
+	assert.False(t, ok)
+
+	assert.NotNil(t, conn.WriteBinary([]byte("d"), time.Now()))
+
+	conn.Close(0, "")
+
+	assert.Equal(t, errClosed, conn.Write([]byte("d"), time.Now()))
+}
+
+func TestConnectionRead(t *testing.T) {
+	config := NewConfig()
+	conn := NewConnection("test", &config)
+
+	assert.Nil(t, conn.push([]byte("hello"), time.Second))
+
+	msg, err := conn.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(msg))
+
+	conn.Close(0, "")
+
+	_, err = conn.Read()
+	assert.Equal(t, errClosed, err)
+	assert.Equal(t, errClosed, conn.push([]byte("bye"), time.Second))
+}
+
+func TestConnectionIdleTimeout(t *testing.T) {
+	config := NewConfig()
+	conn := NewConnection("test", &config)
+	conn.idleTimeout = 50 * time.Millisecond
+
+	closed := make(chan struct{})
+	conn.onClose = func(*Connection) { close(closed) }
+
+	s := conn.attach()
+
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, conn.Closed())
+
+	conn.detach(s)
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Connection hasn't been closed after the idle timeout")
+	}
+
+	assert.True(t, conn.Closed())
+}
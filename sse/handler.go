@@ -0,0 +1,378 @@
+package sse
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anycable/anycable-go/version"
+	"github.com/anycable/anycable-go/ws"
+	"github.com/apex/log"
+	nanoid "github.com/matoous/go-nanoid"
+)
+
+const (
+	// Header (or query parameter) to pass the connection token with commands
+	sessionHeader = "X-AnyCable-Session"
+	sessionParam  = "sid"
+	// Query parameter to pass the last event ID (for clients which couldn't set headers)
+	lastEventIDParam = "lastEventId"
+	// The name of the event containing the connection token (sent first to the new streams)
+	sessionEvent = "session"
+	// For how long to wait for the incoming commands queue to have space
+	commandTimeout = 5 * time.Second
+)
+
+type sessionHandler = func(conn *Connection, info *ws.RequestInfo, callback func()) error
+
+// Handler serves the SSE transport: GET requests open (or resume) event streams
+// and POST requests deliver client commands
+type Handler struct {
+	config         *Config
+	wsConfig       *ws.Config
+	checkOrigin    func(r *http.Request) bool
+	infoBuilder    *ws.RequestInfoBuilder
+	sessionHandler sessionHandler
+
+	mu          sync.RWMutex
+	connections map[string]*Connection
+	shutdown    bool
+}
+
+var _ http.Handler = (*Handler)(nil)
+
+// NewHandler creates a new SSE handler. Origin checks and request info (headers, cookies, env meta)
+// are the same as for WebSocket connections.
+func NewHandler(headersToFetch []string, wsConfig *ws.Config, config *Config, sessionHandler sessionHandler) *Handler {
+	return &Handler{
+		config:         config,
+		wsConfig:       wsConfig,
+		checkOrigin:    ws.OriginChecker(wsConfig),
+		infoBuilder:    ws.NewRequestInfoBuilder(headersToFetch, wsConfig),
+		sessionHandler: sessionHandler,
+		connections:    make(map[string]*Connection),
+	}
+}
+
+// ServeHTTP implements http.Handler interface
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := log.WithField("context", "sse")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.checkOrigin(r) {
+		origin := r.Header.Get("Origin")
+
+		ctx.Warnf("SSE request rejected: origin is not allowed: %q", origin)
+
+		if h.wsConfig.OnOriginRejected != nil {
+			h.wsConfig.OnOriginRejected(origin)
+		}
+
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		h.serveCommand(w, r)
+		return
+	}
+
+	h.serveStream(w, r, ctx)
+}
+
+// Shutdown stops accepting new streams and closes the active ones
+// (sessions are kept until disconnected by the node)
+func (h *Handler) Shutdown() error {
+	h.mu.Lock()
+	h.shutdown = true
+	connections := make([]*Connection, 0, len(h.connections))
+
+	for _, conn := range h.connections {
+		connections = append(connections, conn)
+	}
+	h.mu.Unlock()
+
+	for _, conn := range connections {
+		conn.stopStream()
+	}
+
+	return nil
+}
+
+func (h *Handler) serveStream(w http.ResponseWriter, r *http.Request, ctx *log.Entry) {
+	flusher, ok := w.(http.Flusher)
+
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	if h.shuttingDown() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, lastSeq := h.resumedConnection(r, ctx)
+	resumed := conn != nil
+
+	if !resumed {
+		var err error
+
+		conn, err = h.newSession(r)
+
+		if err != nil {
+			ctx.Errorf("Failed to create SSE session: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	header.Set("X-AnyCable-Version", version.Version())
+	header.Set(sessionHeader, conn.Token())
+	w.WriteHeader(http.StatusOK)
+
+	// New streams receive the connection token first
+	// (the event ID allows resuming the stream even if no messages have been sent yet)
+	if !resumed {
+		if err := writeEvent(w, sessionEvent, eventID(conn.Token(), 0), []byte(conn.Token())); err != nil {
+			return
+		}
+	}
+
+	h.stream(w, flusher, r, conn, lastSeq, ctx)
+}
+
+// resumedConnection returns the connection to resume the stream for (if any) and the last received event number.
+// Connections which couldn't be resumed (missing messages) are closed.
+func (h *Handler) resumedConnection(r *http.Request, ctx *log.Entry) (*Connection, uint64) {
+	id := lastEventID(r)
+
+	if id == "" {
+		return nil, 0
+	}
+
+	token, seq := parseEventID(id)
+	conn := h.lookup(token)
+
+	if conn == nil {
+		ctx.Debugf("SSE session not found, creating a new one")
+		return nil, 0
+	}
+
+	if _, ok := conn.eventsSince(seq); !ok {
+		ctx.Debugf("SSE stream couldn't be resumed: messages have been lost, creating a new session")
+		conn.Close(ws.CloseNormalClosure, "Stream couldn't be resumed")
+		return nil, 0
+	}
+
+	return conn, seq
+}
+
+func (h *Handler) newSession(r *http.Request) (*Connection, error) {
+	info, err := h.infoBuilder.Build(r, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := nanoid.Nanoid()
+
+	if err != nil {
+		return nil, err
+	}
+
+	conn := NewConnection(token, h.config)
+	conn.onClose = h.remove
+
+	h.mu.Lock()
+	h.connections[token] = conn
+	h.mu.Unlock()
+
+	sessionCtx := log.WithFields(log.Fields{"sid": info.UID, "protocol": info.Protocol, "context": "sse"})
+
+	// Authentication could take a while, so we start streaming right away
+	go func() {
+		sessionCtx.Debugf("SSE session established")
+		serr := h.sessionHandler(conn, info, func() {
+			sessionCtx.Debugf("SSE session completed")
+		})
+
+		if serr != nil {
+			sessionCtx.Errorf("SSE session failed: %v", serr)
+			conn.Close(ws.CloseAbnormalClosure, serr.Error())
+		}
+	}()
+
+	return conn, nil
+}
+
+// stream writes events to the response until the connection is closed,
+// the stream is replaced or the client goes away
+func (h *Handler) stream(w io.Writer, flusher http.Flusher, r *http.Request, conn *Connection, lastSeq uint64, ctx *log.Entry) {
+	s := conn.attach()
+	defer conn.detach(s)
+
+	for {
+		closed := conn.Closed()
+		events, ok := conn.eventsSince(lastSeq)
+
+		if !ok {
+			ctx.Debugf("SSE stream is too slow: messages have been lost")
+			conn.Close(ws.CloseAbnormalClosure, "Stream is too slow")
+			return
+		}
+
+		for _, e := range events {
+			if err := writeEvent(w, "", eventID(conn.Token(), e.seq), e.data); err != nil {
+				return
+			}
+
+			lastSeq = e.seq
+		}
+
+		flusher.Flush()
+
+		if closed {
+			return
+		}
+
+		select {
+		case <-s.notify:
+		case <-conn.done:
+		case <-s.stop:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (h *Handler) serveCommand(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get(sessionHeader)
+
+	if token == "" {
+		token = r.URL.Query().Get(sessionParam)
+	}
+
+	conn := h.lookup(token)
+
+	if conn == nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if h.wsConfig.MaxMessageSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.wsConfig.MaxMessageSize)
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err := conn.push(body, commandTimeout); err != nil {
+		if err == errClosed {
+			http.Error(w, "Session not found", http.StatusNotFound)
+		} else {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		}
+
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Handler) lookup(token string) *Connection {
+	if token == "" {
+		return nil
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.connections[token]
+}
+
+func (h *Handler) remove(conn *Connection) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.connections, conn.Token())
+}
+
+func (h *Handler) shuttingDown() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.shutdown
+}
+
+func lastEventID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+
+	return r.URL.Query().Get(lastEventIDParam)
+}
+
+// eventID returns an event ID containing the connection token and the event sequence number
+func eventID(token string, seq uint64) string {
+	return token + ":" + strconv.FormatUint(seq, 10)
+}
+
+func parseEventID(id string) (string, uint64) {
+	parts := strings.SplitN(id, ":", 2)
+
+	if len(parts) != 2 {
+		return "", 0
+	}
+
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+
+	if err != nil {
+		return "", 0
+	}
+
+	return parts[0], seq
+}
+
+// writeEvent writes an event in the text/event-stream format
+// (multiline data is split into several data fields)
+func writeEvent(w io.Writer, name string, id string, data []byte) error {
+	var buf bytes.Buffer
+
+	if name != "" {
+		fmt.Fprintf(&buf, "event: %s\n", name)
+	}
+
+	fmt.Fprintf(&buf, "id: %s\n", id)
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(bytes.TrimSuffix(line, []byte("\r")))
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("\n")
+
+	_, err := w.Write(buf.Bytes())
+
+	return err
+}
@@ -0,0 +1,242 @@
+package sse
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/ws"
+	"github.com/stretchr/testify/assert"
+)
+
+type sseEvent struct {
+	name string
+	id   string
+	data string
+}
+
+func readEvent(t *testing.T, r *bufio.Reader) *sseEvent {
+	e := &sseEvent{}
+	data := []string{}
+
+	for {
+		line, err := r.ReadString('\n')
+
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+
+		if line == "" {
+			break
+		}
+
+		parts := strings.SplitN(line, ": ", 2)
+
+		switch parts[0] {
+		case "event":
+			e.name = parts[1]
+		case "id":
+			e.id = parts[1]
+		case "data":
+			data = append(data, parts[1])
+		}
+	}
+
+	e.data = strings.Join(data, "\n")
+
+	return e
+}
+
+func openStream(t *testing.T, url string, lastEventID string) (*http.Response, *bufio.Reader) {
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Cookie", "token=secret")
+
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+
+	return res, bufio.NewReader(res.Body)
+}
+
+func TestHandler(t *testing.T) {
+	config := NewConfig()
+	wsConfig := ws.NewConfig()
+	wsConfig.MaxMessageSize = 16
+
+	connections := make(chan *Connection, 1)
+
+	handler := NewHandler([]string{"cookie"}, &wsConfig, &config, func(conn *Connection, info *ws.RequestInfo, callback func()) error {
+		assert.Equal(t, "token=secret", (*info.Headers)["cookie"])
+
+		conn.Write([]byte("{\"type\":\"welcome\"}"), time.Now()) // nolint:errcheck
+		connections <- conn
+
+		go func() {
+			defer callback()
+
+			for {
+				msg, err := conn.Read()
+
+				if err != nil {
+					return
+				}
+
+				conn.Write([]byte("echo\n"+string(msg)), time.Now()) // nolint:errcheck
+			}
+		}()
+
+		return nil
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	res, stream := openStream(t, server.URL, "")
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	session := readEvent(t, stream)
+	assert.Equal(t, "session", session.name)
+	assert.Equal(t, session.data+":0", session.id)
+	assert.Equal(t, session.data, res.Header.Get(sessionHeader))
+
+	welcome := readEvent(t, stream)
+	assert.Equal(t, "{\"type\":\"welcome\"}", welcome.data)
+	assert.Equal(t, session.data+":1", welcome.id)
+
+	conn := <-connections
+
+	t.Run("Sending commands", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", server.URL, strings.NewReader("ping"))
+		req.Header.Set(sessionHeader, session.data)
+
+		cres, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusAccepted, cres.StatusCode)
+
+		echo := readEvent(t, stream)
+		assert.Equal(t, "echo\nping", echo.data)
+		assert.Equal(t, session.data+":2", echo.id)
+	})
+
+	t.Run("Sending commands to unknown session", func(t *testing.T) {
+		cres, err := http.Post(server.URL+"?sid=unknown", "text/plain", strings.NewReader("ping"))
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusNotFound, cres.StatusCode)
+	})
+
+	t.Run("Sending too large commands", func(t *testing.T) {
+		cres, err := http.Post(server.URL+"?sid="+session.data, "text/plain", strings.NewReader(strings.Repeat("a", 32)))
+		assert.Nil(t, err)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, cres.StatusCode)
+	})
+
+	t.Run("Resuming stream", func(t *testing.T) {
+		res.Body.Close()
+
+		conn.Write([]byte("missed"), time.Now()) // nolint:errcheck
+
+		resumed, resumedStream := openStream(t, server.URL, session.data+":2")
+		defer resumed.Body.Close()
+
+		missed := readEvent(t, resumedStream)
+		assert.Equal(t, "", missed.name)
+		assert.Equal(t, "missed", missed.data)
+		assert.Equal(t, session.data+":3", missed.id)
+	})
+
+	t.Run("Closing connection", func(t *testing.T) {
+		resumed, resumedStream := openStream(t, server.URL, session.data+":3")
+		defer resumed.Body.Close()
+
+		conn.Write([]byte("bye"), time.Now()) // nolint:errcheck
+		conn.Close(ws.CloseNormalClosure, "")
+
+		bye := readEvent(t, resumedStream)
+		assert.Equal(t, "bye", bye.data)
+
+		_, err := resumedStream.ReadString('\n')
+		assert.NotNil(t, err)
+
+		assert.Nil(t, handler.lookup(session.data))
+	})
+
+	t.Run("Resuming closed session", func(t *testing.T) {
+		resumed, resumedStream := openStream(t, server.URL, session.data+":4")
+		defer resumed.Body.Close()
+
+		newSession := readEvent(t, resumedStream)
+		assert.Equal(t, "session", newSession.name)
+		assert.NotEqual(t, session.data, newSession.data)
+
+		(<-connections).Close(ws.CloseNormalClosure, "")
+	})
+}
+
+func TestHandlerOrigin(t *testing.T) {
+	config := NewConfig()
+	wsConfig := ws.NewConfig()
+	wsConfig.AllowedOrigins = "example.com"
+
+	rejected := ""
+	wsConfig.OnOriginRejected = func(origin string) { rejected = origin }
+
+	handler := NewHandler([]string{}, &wsConfig, &config, func(conn *Connection, info *ws.RequestInfo, callback func()) error {
+		return nil
+	})
+
+	req := httptest.NewRequest("GET", "/sse", nil)
+	req.Header.Set("Origin", "http://evil.com")
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "http://evil.com", rejected)
+}
+
+func TestHandlerShutdown(t *testing.T) {
+	config := NewConfig()
+	wsConfig := ws.NewConfig()
+
+	handler := NewHandler([]string{}, &wsConfig, &config, func(conn *Connection, info *ws.RequestInfo, callback func()) error {
+		return nil
+	})
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	res, stream := openStream(t, server.URL, "")
+	defer res.Body.Close()
+
+	readEvent(t, stream)
+
+	assert.Nil(t, handler.Shutdown())
+
+	_, err := stream.ReadString('\n')
+	assert.NotNil(t, err)
+
+	res, err = http.Get(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+}
+
+func TestEventID(t *testing.T) {
+	token, seq := parseEventID(eventID("abc", 42))
+
+	assert.Equal(t, "abc", token)
+	assert.Equal(t, uint64(42), seq)
+
+	token, _ = parseEventID("abc")
+	assert.Equal(t, "", token)
+}
@@ -0,0 +1,179 @@
+// Package standalone implements a controller for broadcast-only use cases (public streams) which doesn't require RPC.
+package standalone
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/apex/log"
+)
+
+const (
+	// Identifier parameter containing the stream name
+	streamNameParam = "stream_name"
+
+	welcomeMessage = "{\"type\":\"welcome\"}"
+)
+
+// Config contains standalone controller configuration
+type Config struct {
+	// Comma-separated list of channel names (or patterns, e.g., "Public*") allowed to subscribe to
+	Channels string
+}
+
+// NewConfig builds a new config
+func NewConfig() Config {
+	return Config{}
+}
+
+// ParseChannels parses the list of channel patterns
+func ParseChannels(list string) ([]string, error) {
+	patterns := []string{}
+
+	for _, pattern := range strings.Split(list, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("Malformed channel pattern: %s", pattern)
+		}
+
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns, nil
+}
+
+// Controller implements node.Controller interface without RPC:
+// all connections are accepted (with anonymous identifiers), subscriptions are allowed for the configured channels
+// (the stream name is taken from the "stream_name" parameter or equals to the channel name),
+// and actions are rejected.
+type Controller struct {
+	channels []string
+	log      *log.Entry
+}
+
+// NewController builds new Controller from config
+func NewController(c *Config) *Controller {
+	// Patterns must be validated by the caller
+	channels, _ := ParseChannels(c.Channels)
+
+	return &Controller{channels: channels, log: log.WithField("context", "standalone")}
+}
+
+// Start is no-op
+func (c *Controller) Start() error {
+	c.log.Infof("Standalone mode enabled (no RPC), public channels: %s", strings.Join(c.channels, ","))
+	return nil
+}
+
+// Shutdown is no-op
+func (c *Controller) Shutdown() error {
+	return nil
+}
+
+// Authenticate accepts all connections and uses the session ID as identifier
+func (c *Controller) Authenticate(sid string, env *common.SessionEnv) (*common.ConnectResult, error) {
+	identifiers, err := json.Marshal(map[string]string{"sid": sid})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &common.ConnectResult{
+		Status:        common.SUCCESS,
+		Identifier:    string(identifiers),
+		Transmissions: []string{welcomeMessage},
+	}, nil
+}
+
+// Subscribe allows subscribing to the configured channels
+func (c *Controller) Subscribe(sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error) {
+	stream, err := c.streamFor(channel)
+
+	if err != nil {
+		c.log.WithField("sid", sid).Debugf("Subscription rejected: %v", err)
+
+		return common.NewRejectedSubscriptionResult(channel, common.RejectionUnknownChannel), nil
+	}
+
+	return &common.CommandResult{
+		Status:        common.SUCCESS,
+		Streams:       []string{stream},
+		Transmissions: []string{subscriptionReply(channel, common.ConfirmedType)},
+	}, nil
+}
+
+// Unsubscribe stops all the channel streams
+func (c *Controller) Unsubscribe(sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error) {
+	return &common.CommandResult{Status: common.SUCCESS, StopAllStreams: true}, nil
+}
+
+// Perform rejects all actions
+func (c *Controller) Perform(sid string, env *common.SessionEnv, id string, channel string, data string) (*common.CommandResult, error) {
+	return &common.CommandResult{Status: common.FAILURE}, nil
+}
+
+// Disconnect is no-op
+func (c *Controller) Disconnect(sid string, env *common.SessionEnv, id string, subscriptions []string) error {
+	return nil
+}
+
+// streamFor returns the stream name for the subscription identifier
+// (or an error if the channel is not allowed)
+func (c *Controller) streamFor(identifier string) (string, error) {
+	var params map[string]interface{}
+
+	if err := json.Unmarshal([]byte(identifier), &params); err != nil {
+		return "", fmt.Errorf("Malformed identifier: %s", identifier)
+	}
+
+	channel, ok := params["channel"].(string)
+
+	if !ok || channel == "" {
+		return "", errors.New("Channel is missing")
+	}
+
+	if !c.allowed(channel) {
+		return "", fmt.Errorf("Channel is not allowed: %s", channel)
+	}
+
+	stream, ok := params[streamNameParam]
+
+	if !ok {
+		return channel, nil
+	}
+
+	name, ok := stream.(string)
+
+	if !ok || name == "" {
+		return "", fmt.Errorf("Invalid stream name: %v", stream)
+	}
+
+	return name, nil
+}
+
+func (c *Controller) allowed(channel string) bool {
+	for _, pattern := range c.channels {
+		if matched, _ := path.Match(pattern, channel); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func subscriptionReply(identifier string, replyType string) string {
+	// Marshaling strings never fails
+	reply, _ := json.Marshal(struct {
+		Identifier string `json:"identifier"`
+		Type       string `json:"type"`
+	}{identifier, replyType})
+
+	return string(reply)
+}
@@ -0,0 +1,78 @@
+package standalone
+
+import (
+	"testing"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestController(t *testing.T) {
+	controller := NewController(&Config{Channels: "ScoresChannel, Public*"})
+
+	t.Run("Authenticate", func(t *testing.T) {
+		res, err := controller.Authenticate("42", nil)
+
+		assert.Nil(t, err)
+		assert.Equal(t, common.SUCCESS, res.Status)
+		assert.Equal(t, "{\"sid\":\"42\"}", res.Identifier)
+		assert.Equal(t, []string{"{\"type\":\"welcome\"}"}, res.Transmissions)
+	})
+
+	t.Run("Subscribe", func(t *testing.T) {
+		tests := map[string]string{
+			"{\"channel\":\"ScoresChannel\"}":                            "ScoresChannel",
+			"{\"channel\":\"ScoresChannel\",\"stream_name\":\"game_1\"}": "game_1",
+			"{\"channel\":\"PublicDashboardChannel\"}":                   "PublicDashboardChannel",
+		}
+
+		for identifier, stream := range tests {
+			res, err := controller.Subscribe("42", nil, "", identifier)
+
+			assert.Nil(t, err)
+			assert.Equal(t, common.SUCCESS, res.Status, identifier)
+			assert.Equal(t, []string{stream}, res.Streams)
+			assert.Contains(t, res.Transmissions[0], "confirm_subscription")
+		}
+	})
+
+	t.Run("Subscribe rejected", func(t *testing.T) {
+		for _, identifier := range []string{
+			"{\"channel\":\"ChatChannel\"}",
+			"{\"channel\":\"ScoresChannel\",\"stream_name\":42}",
+			"{\"stream_name\":\"game_1\"}",
+			"ScoresChannel",
+		} {
+			res, err := controller.Subscribe("42", nil, "", identifier)
+
+			assert.Nil(t, err)
+			assert.Equal(t, common.FAILURE, res.Status, identifier)
+			assert.Empty(t, res.Streams)
+			assert.Contains(t, res.Transmissions[0], "reject_subscription")
+			assert.Equal(t, common.RejectionUnknownChannel, res.RejectionReason)
+		}
+	})
+
+	t.Run("Perform", func(t *testing.T) {
+		res, err := controller.Perform("42", nil, "", "{\"channel\":\"ScoresChannel\"}", "{\"action\":\"speak\"}")
+
+		assert.Nil(t, err)
+		assert.Equal(t, common.FAILURE, res.Status)
+	})
+
+	t.Run("Unsubscribe", func(t *testing.T) {
+		res, err := controller.Unsubscribe("42", nil, "", "{\"channel\":\"ScoresChannel\"}")
+
+		assert.Nil(t, err)
+		assert.True(t, res.StopAllStreams)
+	})
+}
+
+func TestParseChannels(t *testing.T) {
+	channels, err := ParseChannels(" ScoresChannel,,Public* ")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ScoresChannel", "Public*"}, channels)
+
+	_, err = ParseChannels("Scores[")
+	assert.NotNil(t, err)
+}
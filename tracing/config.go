@@ -0,0 +1,48 @@
+package tracing
+
+import "fmt"
+
+// Config contains tracing configuration
+type Config struct {
+	// OTLP/HTTP collector endpoint (tracing is disabled if empty)
+	OTLPEndpoint string
+	// Headers to add to export requests (format: key1=value1,key2=value2)
+	OTLPHeaders string
+	// The ratio of traces to sample (from 0 to 1); the parent sampling decision is respected
+	SampleRate float64
+	// The min number of stream subscribers to trace broadcasts fan-out (0 – disabled)
+	BroadcastThreshold int
+	// Export interval in seconds
+	ExportInterval int
+}
+
+// NewConfig builds a new config with defaults
+func NewConfig() Config {
+	return Config{SampleRate: 1, BroadcastThreshold: 100, ExportInterval: 5}
+}
+
+// Enabled returns true iff OTLPEndpoint is not empty
+func (c *Config) Enabled() bool {
+	return c.OTLPEndpoint != ""
+}
+
+// Validate checks the configuration values
+func (c *Config) Validate() error {
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("Sample rate must be between 0 and 1, got: %v", c.SampleRate)
+	}
+
+	if c.BroadcastThreshold < 0 {
+		return fmt.Errorf("Broadcast threshold must be non-negative, got: %d", c.BroadcastThreshold)
+	}
+
+	if c.ExportInterval <= 0 {
+		return fmt.Errorf("Export interval must be positive, got: %d", c.ExportInterval)
+	}
+
+	if _, err := parseHeaders(c.OTLPHeaders); err != nil {
+		return fmt.Errorf("Invalid OTLP headers: %v", err)
+	}
+
+	return nil
+}
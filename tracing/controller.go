@@ -0,0 +1,252 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/anycable/anycable-go/common"
+)
+
+// controller is the subset of node.Controller used by the decorator
+// (we cannot import the node package here due to cyclic dependencies)
+type controller interface {
+	Start() error
+	Shutdown() error
+	Authenticate(sid string, env *common.SessionEnv) (*common.ConnectResult, error)
+	Subscribe(sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error)
+	Unsubscribe(sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error)
+	Perform(sid string, env *common.SessionEnv, id string, channel string, data string) (*common.CommandResult, error)
+	Disconnect(sid string, env *common.SessionEnv, id string, subscriptions []string) error
+}
+
+// Controllers supporting trace context propagation (e.g., rpc.Controller)
+type authenticateContextController interface {
+	AuthenticateContext(ctx context.Context, sid string, env *common.SessionEnv) (*common.ConnectResult, error)
+}
+
+type commandContextController interface {
+	SubscribeContext(ctx context.Context, sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error)
+	UnsubscribeContext(ctx context.Context, sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error)
+	PerformContext(ctx context.Context, sid string, env *common.SessionEnv, id string, channel string, data string) (*common.CommandResult, error)
+}
+
+type disconnectContextController interface {
+	DisconnectContext(ctx context.Context, sid string, env *common.SessionEnv, id string, subscriptions []string) error
+}
+
+// Controller wraps a controller to create a span per call.
+// The incoming trace context is taken from the session's traceparent header (if present).
+type Controller struct {
+	controller
+	tracer *Tracer
+}
+
+// NewController wraps the controller with tracing
+func NewController(inner controller, tracer *Tracer) *Controller {
+	return &Controller{controller: inner, tracer: tracer}
+}
+
+// Ready returns the wrapped controller readiness (controllers without readiness probes are always ready)
+func (c *Controller) Ready() error {
+	if probe, ok := c.controller.(interface{ Ready() error }); ok {
+		return probe.Ready()
+	}
+
+	return nil
+}
+
+// Authenticate performs the traced Authenticate call
+func (c *Controller) Authenticate(sid string, env *common.SessionEnv) (*common.ConnectResult, error) {
+	span := c.start("anycable.rpc.connect", sid, env)
+	defer span.End()
+
+	var res *common.ConnectResult
+	var err error
+
+	if cc, ok := c.controller.(authenticateContextController); ok {
+		res, err = cc.AuthenticateContext(ContextWithSpan(context.Background(), span), sid, env)
+	} else {
+		res, err = c.controller.Authenticate(sid, env)
+	}
+
+	if res != nil {
+		finish(span, res.Status, err)
+	} else {
+		finish(span, common.ERROR, err)
+	}
+
+	return res, err
+}
+
+// Subscribe performs the traced Subscribe call
+func (c *Controller) Subscribe(sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error) {
+	return c.SubscribeContext(context.Background(), sid, env, id, channel)
+}
+
+// SubscribeContext performs the traced Subscribe call (bound to the context if the wrapped controller supports it)
+func (c *Controller) SubscribeContext(ctx context.Context, sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error) {
+	span := c.startCommand("subscribe", sid, env, id)
+	defer span.End()
+
+	var res *common.CommandResult
+	var err error
+
+	if cc, ok := c.controller.(commandContextController); ok {
+		res, err = cc.SubscribeContext(ContextWithSpan(ctx, span), sid, env, id, channel)
+	} else {
+		res, err = c.controller.Subscribe(sid, env, id, channel)
+	}
+
+	finishCommand(span, res, err)
+
+	return res, err
+}
+
+// Unsubscribe performs the traced Unsubscribe call
+func (c *Controller) Unsubscribe(sid string, env *common.SessionEnv, id string, channel string) (*common.CommandResult, error) {
+	span := c.startCommand("unsubscribe", sid, env, id)
+	defer span.End()
+
+	var res *common.CommandResult
+	var err error
+
+	if cc, ok := c.controller.(commandContextController); ok {
+		res, err = cc.UnsubscribeContext(ContextWithSpan(context.Background(), span), sid, env, id, channel)
+	} else {
+		res, err = c.controller.Unsubscribe(sid, env, id, channel)
+	}
+
+	finishCommand(span, res, err)
+
+	return res, err
+}
+
+// Perform performs the traced Perform call
+func (c *Controller) Perform(sid string, env *common.SessionEnv, id string, channel string, data string) (*common.CommandResult, error) {
+	return c.PerformContext(context.Background(), sid, env, id, channel, data)
+}
+
+// PerformContext performs the traced Perform call (bound to the context if the wrapped controller supports it)
+func (c *Controller) PerformContext(ctx context.Context, sid string, env *common.SessionEnv, id string, channel string, data string) (*common.CommandResult, error) {
+	span := c.startCommand("perform", sid, env, id)
+	defer span.End()
+
+	if span != nil {
+		if action := actionFromData(data); action != "" {
+			span.SetString("anycable.action", action)
+		}
+	}
+
+	var res *common.CommandResult
+	var err error
+
+	if cc, ok := c.controller.(commandContextController); ok {
+		res, err = cc.PerformContext(ContextWithSpan(ctx, span), sid, env, id, channel, data)
+	} else {
+		res, err = c.controller.Perform(sid, env, id, channel, data)
+	}
+
+	finishCommand(span, res, err)
+
+	return res, err
+}
+
+// Disconnect performs the traced Disconnect call
+func (c *Controller) Disconnect(sid string, env *common.SessionEnv, id string, subscriptions []string) error {
+	span := c.start("anycable.rpc.disconnect", sid, env)
+	defer span.End()
+
+	var err error
+
+	if cc, ok := c.controller.(disconnectContextController); ok {
+		err = cc.DisconnectContext(ContextWithSpan(context.Background(), span), sid, env, id, subscriptions)
+	} else {
+		err = c.controller.Disconnect(sid, env, id, subscriptions)
+	}
+
+	finish(span, common.SUCCESS, err)
+
+	return err
+}
+
+// DisconnectBatch performs traced Disconnect calls for multiple sessions
+// (the wrapped controller batching is not used, since we need a span per session)
+func (c *Controller) DisconnectBatch(requests []*common.DisconnectRequest) []error {
+	errs := make([]error, len(requests))
+
+	for i, req := range requests {
+		errs[i] = c.Disconnect(req.UID, req.Env, req.Identifiers, req.Subscriptions)
+	}
+
+	return errs
+}
+
+func (c *Controller) start(name string, sid string, env *common.SessionEnv) *Span {
+	var parent SpanContext
+
+	if env != nil && env.Headers != nil {
+		if header, ok := (*env.Headers)[TraceParentHeader]; ok {
+			parent, _ = ParseTraceParent(header)
+		}
+	}
+
+	span := c.tracer.Start(name, SpanKindClient, parent)
+	span.SetString("anycable.session.uid", sid)
+
+	return span
+}
+
+func (c *Controller) startCommand(command string, sid string, env *common.SessionEnv, id string) *Span {
+	span := c.start("anycable.rpc."+command, sid, env)
+
+	if span != nil {
+		span.SetString("anycable.command", command)
+		span.SetString("anycable.channel", channelFromIdentifier(id))
+	}
+
+	return span
+}
+
+func finishCommand(span *Span, res *common.CommandResult, err error) {
+	if res != nil {
+		finish(span, res.Status, err)
+	} else {
+		finish(span, common.ERROR, err)
+	}
+}
+
+func finish(span *Span, status int, err error) {
+	if span == nil {
+		return
+	}
+
+	if err != nil {
+		span.SetError(err.Error())
+	} else if status == common.ERROR {
+		span.SetError("Application error")
+	}
+}
+
+func channelFromIdentifier(identifier string) string {
+	var id struct {
+		Channel string `json:"channel"`
+	}
+
+	if err := json.Unmarshal([]byte(identifier), &id); err != nil {
+		return ""
+	}
+
+	return id.Channel
+}
+
+func actionFromData(data string) string {
+	var msg struct {
+		Action string `json:"action"`
+	}
+
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		return ""
+	}
+
+	return msg.Action
+}
@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/anycable/anycable-go/common"
+	"github.com/anycable/anycable-go/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+type contextController struct {
+	mocks.MockController
+	span *Span
+}
+
+func (c *contextController) AuthenticateContext(ctx context.Context, sid string, env *common.SessionEnv) (*common.ConnectResult, error) {
+	c.span = SpanFromContext(ctx)
+	return c.Authenticate(sid, env)
+}
+
+func nextSpan(t *Tracer) *Span {
+	select {
+	case span := <-t.queue:
+		return span
+	default:
+		return nil
+	}
+}
+
+func attributesMap(span *Span) map[string]Attribute {
+	attrs := make(map[string]Attribute)
+
+	for _, attr := range span.attributes {
+		attrs[attr.Key] = attr
+	}
+
+	return attrs
+}
+
+func TestControllerAuthenticate(t *testing.T) {
+	tracer := newTestTracer("http://localhost:4318", 1)
+	inner := &contextController{MockController: mocks.NewMockController()}
+	controller := NewController(inner, tracer)
+
+	t.Run("With traceparent header", func(t *testing.T) {
+		env := common.NewSessionEnv("/cable", &map[string]string{
+			"id":          "john",
+			"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		})
+
+		res, err := controller.Authenticate("s1", env)
+
+		assert.Nil(t, err)
+		assert.Equal(t, "john", res.Identifier)
+
+		span := nextSpan(tracer)
+
+		if assert.NotNil(t, span) {
+			assert.Equal(t, "anycable.rpc.connect", span.name)
+			assert.Equal(t, SpanKindClient, span.kind)
+			assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", hex.EncodeToString(span.ctx.TraceID[:]))
+			assert.Equal(t, "00f067aa0ba902b7", hex.EncodeToString(span.parentID[:]))
+			assert.Equal(t, "s1", attributesMap(span)["anycable.session.uid"].String)
+			assert.Equal(t, "", span.errMsg)
+			// The span is passed to the inner controller
+			assert.Equal(t, span, inner.span)
+		}
+	})
+
+	t.Run("With error", func(t *testing.T) {
+		env := common.NewSessionEnv("/error", &map[string]string{})
+
+		_, err := controller.Authenticate("s2", env)
+
+		assert.NotNil(t, err)
+
+		span := nextSpan(tracer)
+
+		if assert.NotNil(t, span) {
+			assert.Equal(t, "Unknown", span.errMsg)
+			assert.Equal(t, [8]byte{}, span.parentID)
+		}
+	})
+
+	t.Run("With unsampled parent", func(t *testing.T) {
+		env := common.NewSessionEnv("/cable", &map[string]string{
+			"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+		})
+
+		_, err := controller.Authenticate("s3", env)
+
+		assert.Nil(t, err)
+		assert.Nil(t, nextSpan(tracer))
+		assert.Nil(t, inner.span)
+	})
+}
+
+func TestControllerCommands(t *testing.T) {
+	tracer := newTestTracer("http://localhost:4318", 1)
+	inner := mocks.NewMockController()
+	controller := NewController(&inner, tracer)
+
+	env := common.NewSessionEnv("/cable", &map[string]string{})
+	identifier := `{"channel":"ChatChannel","room":"1"}`
+
+	_, err := controller.Perform("s1", env, identifier, "chat", `{"action":"speak"}`)
+
+	assert.Nil(t, err)
+
+	span := nextSpan(tracer)
+
+	if assert.NotNil(t, span) {
+		attrs := attributesMap(span)
+
+		assert.Equal(t, "anycable.rpc.perform", span.name)
+		assert.Equal(t, "perform", attrs["anycable.command"].String)
+		assert.Equal(t, "ChatChannel", attrs["anycable.channel"].String)
+		assert.Equal(t, "speak", attrs["anycable.action"].String)
+	}
+
+	_, err = controller.Subscribe("s1", env, identifier, "error")
+
+	assert.NotNil(t, err)
+
+	span = nextSpan(tracer)
+
+	if assert.NotNil(t, span) {
+		assert.Equal(t, "anycable.rpc.subscribe", span.name)
+		assert.Equal(t, "Subscription Failure", span.errMsg)
+	}
+
+	errs := controller.DisconnectBatch([]*common.DisconnectRequest{{UID: "s1", Env: env}, {UID: "s2", Env: env}})
+
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Equal(t, "anycable.rpc.disconnect", nextSpan(tracer).name)
+	assert.Equal(t, "anycable.rpc.disconnect", nextSpan(tracer).name)
+}
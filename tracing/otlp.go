@@ -0,0 +1,223 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/anycable/anycable-go/version"
+)
+
+const (
+	otlpServiceName       = "anycable-go"
+	otlpRequestTimeout    = 10 * time.Second
+	otlpRetryInitialDelay = time.Second
+	otlpTracesPath        = "/v1/traces"
+	otlpStatusError       = 2
+)
+
+// send exports the batch of spans and retries with exponential backoff until the deadline
+func (t *Tracer) send(batch []*Span, deadline time.Time) {
+	payload, err := json.Marshal(t.buildRequest(batch))
+
+	if err != nil {
+		t.log.Errorf("Failed to encode OTLP spans: %v", err)
+		t.metrics.Counter(metricsSpansDropped).Add(uint64(len(batch)))
+		return
+	}
+
+	delay := otlpRetryInitialDelay
+
+	for {
+		err := t.post(payload)
+
+		if err == nil {
+			t.metrics.Counter(metricsSpansExported).Add(uint64(len(batch)))
+			return
+		}
+
+		var permanent *otlpPermanentError
+
+		if errors.As(err, &permanent) || time.Now().Add(delay).After(deadline) {
+			t.log.Warnf("Failed to export spans to OTLP endpoint: %v", err)
+			t.metrics.Counter(metricsSpansDropped).Add(uint64(len(batch)))
+			return
+		}
+
+		t.log.Debugf("Failed to export spans to OTLP endpoint, retrying in %v: %v", delay, err)
+
+		time.Sleep(delay)
+
+		delay *= 2
+	}
+}
+
+type otlpPermanentError struct {
+	status int
+}
+
+func (e *otlpPermanentError) Error() string {
+	return fmt.Sprintf("OTLP endpoint responded with %d", e.status)
+}
+
+func (t *Tracer) post(payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(payload))
+
+	if err != nil {
+		return &otlpPermanentError{}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := t.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body) // nolint:errcheck
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+
+	// See https://github.com/open-telemetry/opentelemetry-specification/blob/main/specification/protocol/otlp.md#retryable-response-codes
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return fmt.Errorf("OTLP endpoint responded with %d", res.StatusCode)
+	default:
+		return &otlpPermanentError{status: res.StatusCode}
+	}
+}
+
+// OTLP JSON encoding structs (see opentelemetry-proto/opentelemetry/proto/trace/v1).
+// Note that 64-bit integers are encoded as strings and IDs are hex-encoded.
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpAttribute struct {
+	Key   string             `json:"key"`
+	Value otlpAttributeValue `json:"value"`
+}
+
+type otlpAttributeValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func stringAttribute(key string, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpAttributeValue{StringValue: &value}}
+}
+
+func (t *Tracer) buildRequest(batch []*Span) *otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+
+	for _, span := range batch {
+		spans = append(spans, encodeSpan(span))
+	}
+
+	attributes := []otlpAttribute{
+		stringAttribute("service.name", otlpServiceName),
+		stringAttribute("service.version", version.Version()),
+	}
+
+	if t.instance != "" {
+		attributes = append(attributes, stringAttribute("service.instance.id", t.instance))
+	}
+
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{
+			{
+				Resource: otlpResource{Attributes: attributes},
+				ScopeSpans: []otlpScopeSpans{
+					{
+						Scope: otlpScope{Name: otlpServiceName, Version: version.Version()},
+						Spans: spans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func encodeSpan(span *Span) otlpSpan {
+	span.mu.Lock()
+	defer span.mu.Unlock()
+
+	encoded := otlpSpan{
+		TraceID:           hex.EncodeToString(span.ctx.TraceID[:]),
+		SpanID:            hex.EncodeToString(span.ctx.SpanID[:]),
+		Name:              span.name,
+		Kind:              span.kind,
+		StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+	}
+
+	if span.parentID != [8]byte{} {
+		encoded.ParentSpanID = hex.EncodeToString(span.parentID[:])
+	}
+
+	for _, attr := range span.attributes {
+		if attr.IsInt {
+			value := strconv.FormatInt(attr.Int, 10)
+			encoded.Attributes = append(encoded.Attributes, otlpAttribute{Key: attr.Key, Value: otlpAttributeValue{IntValue: &value}})
+		} else {
+			encoded.Attributes = append(encoded.Attributes, stringAttribute(attr.Key, attr.String))
+		}
+	}
+
+	if span.errMsg != "" {
+		encoded.Status = &otlpStatus{Code: otlpStatusError, Message: span.errMsg}
+	}
+
+	return encoded
+}
@@ -0,0 +1,181 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TraceParentHeader is the W3C Trace Context header (and gRPC metadata key) carrying the trace context
+const TraceParentHeader = "traceparent"
+
+// Span kinds (according to OTLP)
+const (
+	SpanKindInternal = 1
+	SpanKindServer   = 2
+	SpanKindClient   = 3
+)
+
+// SpanContext identifies the span within a trace
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// IsValid returns true if both trace and span IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// TraceParent returns the W3C traceparent header value
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+
+	if sc.Sampled {
+		flags = "01"
+	}
+
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" + flags
+}
+
+// ParseTraceParent parses the W3C traceparent header value (version 00 format).
+// Returns false if the value is invalid.
+func ParseTraceParent(value string) (SpanContext, bool) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+
+	if err != nil {
+		return sc, false
+	}
+
+	sc.Sampled = flags[0]&1 == 1
+
+	return sc, sc.IsValid()
+}
+
+// Attribute is a span attribute (only string and int values are supported)
+type Attribute struct {
+	Key    string
+	String string
+	Int    int64
+	IsInt  bool
+}
+
+// Span represents a sampled operation. All the methods are no-op for nil spans
+// (unsampled operations or disabled tracing), so callers don't need to check.
+type Span struct {
+	tracer *Tracer
+
+	mu         sync.Mutex
+	ctx        SpanContext
+	parentID   [8]byte
+	name       string
+	kind       int
+	start      time.Time
+	end        time.Time
+	attributes []Attribute
+	errMsg     string
+	ended      bool
+}
+
+// Context returns the span context (empty for nil spans)
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+
+	return s.ctx
+}
+
+// SetString adds a string attribute
+func (s *Span) SetString(key string, value string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.attributes = append(s.attributes, Attribute{Key: key, String: value})
+	s.mu.Unlock()
+}
+
+// SetInt adds an integer attribute
+func (s *Span) SetInt(key string, value int) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.attributes = append(s.attributes, Attribute{Key: key, Int: int64(value), IsInt: true})
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(msg string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.errMsg = msg
+	s.mu.Unlock()
+}
+
+// End completes the span and schedules it for export (subsequent calls are ignored)
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	s.tracer.export(s)
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns a copy of the context carrying the span (the context is returned as is for nil spans)
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the span carried by the context (or nil)
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+
+	return span
+}
@@ -0,0 +1,216 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"fmt"
+	mrand "math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/apex/log"
+)
+
+const (
+	metricsSpansExported = "tracing_spans_exported_total"
+	metricsSpansDropped  = "tracing_spans_dropped_total"
+
+	// The max number of finished spans waiting for export (new spans are dropped when the queue is full)
+	spansQueueSize = 2048
+	// The max number of spans per export request
+	spansBatchSize = 512
+)
+
+// Tracer creates spans and exports them to an OpenTelemetry collector
+// using OTLP/HTTP protocol with JSON encoding.
+//
+// A nil tracer is valid and creates no spans, so the instrumented code has near-zero overhead
+// when tracing is disabled.
+type Tracer struct {
+	endpoint   string
+	headers    map[string]string
+	sampleRate float64
+	threshold  int
+	interval   time.Duration
+	instance   string
+	client     *http.Client
+
+	queue     chan *Span
+	shutdown  chan struct{}
+	done      chan struct{}
+	runOnce   sync.Once
+	closeOnce sync.Once
+
+	metrics *metrics.Metrics
+	log     *log.Entry
+}
+
+// NewTracer creates a new tracer (instance is used as the service.instance.id resource attribute)
+func NewTracer(config *Config, m *metrics.Metrics, instance string) (*Tracer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	headers, _ := parseHeaders(config.OTLPHeaders)
+
+	endpoint := strings.TrimSuffix(config.OTLPEndpoint, "/")
+
+	if !strings.HasSuffix(endpoint, otlpTracesPath) {
+		endpoint += otlpTracesPath
+	}
+
+	interval := time.Duration(config.ExportInterval) * time.Second
+
+	m.RegisterCounter(metricsSpansExported, "The total number of exported tracing spans")
+	m.RegisterCounter(metricsSpansDropped, "The total number of tracing spans dropped due to the full export queue or export failures")
+
+	return &Tracer{
+		endpoint:   endpoint,
+		headers:    headers,
+		sampleRate: config.SampleRate,
+		threshold:  config.BroadcastThreshold,
+		interval:   interval,
+		instance:   instance,
+		client:     &http.Client{Timeout: otlpRequestTimeout},
+		queue:      make(chan *Span, spansQueueSize),
+		shutdown:   make(chan struct{}),
+		done:       make(chan struct{}),
+		metrics:    m,
+		log:        log.WithField("context", "tracing"),
+	}, nil
+}
+
+// Run starts the exporting loop
+func (t *Tracer) Run() {
+	t.log.Infof("Export traces to OTLP endpoint %s (sample rate: %v)", t.endpoint, t.sampleRate)
+
+	t.runOnce.Do(func() { go t.loop() })
+}
+
+// Shutdown exports the pending spans and stops the exporting loop
+func (t *Tracer) Shutdown() error {
+	t.closeOnce.Do(func() {
+		close(t.shutdown)
+	})
+
+	// The loop hasn't been started, nothing to wait for
+	t.runOnce.Do(func() { close(t.done) })
+
+	<-t.done
+
+	return nil
+}
+
+// BroadcastThreshold returns the min number of stream subscribers to trace broadcasts fan-out (0 – disabled)
+func (t *Tracer) BroadcastThreshold() int {
+	if t == nil {
+		return 0
+	}
+
+	return t.threshold
+}
+
+// Start creates a new span (nil if the span is not sampled).
+// The span becomes a child of the parent span context if it's valid; the parent sampling decision is respected.
+func (t *Tracer) Start(name string, kind int, parent SpanContext) *Span {
+	if t == nil {
+		return nil
+	}
+
+	span := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+
+	if parent.IsValid() {
+		if !parent.Sampled {
+			return nil
+		}
+
+		span.ctx.TraceID = parent.TraceID
+		span.parentID = parent.SpanID
+	} else {
+		if t.sampleRate <= 0 || (t.sampleRate < 1 && mrand.Float64() >= t.sampleRate) {
+			return nil
+		}
+
+		rand.Read(span.ctx.TraceID[:]) // nolint:errcheck
+	}
+
+	rand.Read(span.ctx.SpanID[:]) // nolint:errcheck
+	span.ctx.Sampled = true
+
+	return span
+}
+
+func (t *Tracer) export(span *Span) {
+	select {
+	case t.queue <- span:
+	default:
+		t.metrics.Counter(metricsSpansDropped).Inc()
+	}
+}
+
+func (t *Tracer) loop() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, spansBatchSize)
+
+	flush := func(deadline time.Time) {
+		if len(batch) == 0 {
+			return
+		}
+
+		t.send(batch, deadline)
+		batch = make([]*Span, 0, spansBatchSize)
+	}
+
+	for {
+		select {
+		case <-t.shutdown:
+			for {
+				select {
+				case span := <-t.queue:
+					batch = append(batch, span)
+
+					if len(batch) == spansBatchSize {
+						flush(time.Now())
+					}
+				default:
+					flush(time.Now())
+					return
+				}
+			}
+		case span := <-t.queue:
+			batch = append(batch, span)
+
+			if len(batch) == spansBatchSize {
+				flush(time.Now().Add(t.interval))
+			}
+		case <-ticker.C:
+			flush(time.Now().Add(t.interval))
+		}
+	}
+}
+
+func parseHeaders(raw string) (map[string]string, error) {
+	headers := make(map[string]string)
+
+	if raw == "" {
+		return headers, nil
+	}
+
+	for _, pair := range strings.Split(raw, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("Invalid key-value pair: %s", pair)
+		}
+
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return headers, nil
+}
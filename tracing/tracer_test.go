@@ -0,0 +1,163 @@
+package tracing
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTracer(endpoint string, rate float64) *Tracer {
+	config := NewConfig()
+	config.OTLPEndpoint = endpoint
+	config.SampleRate = rate
+
+	tracer, _ := NewTracer(&config, metrics.NewMetrics(nil, 0), "node-1")
+
+	return tracer
+}
+
+func TestTracerSampling(t *testing.T) {
+	parent, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	unsampled, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+	t.Run("Nil tracer", func(t *testing.T) {
+		var tracer *Tracer
+
+		assert.Nil(t, tracer.Start("test", SpanKindInternal, parent))
+		assert.Equal(t, 0, tracer.BroadcastThreshold())
+	})
+
+	t.Run("Without parent", func(t *testing.T) {
+		tracer := newTestTracer("http://localhost:4318", 1)
+
+		span := tracer.Start("test", SpanKindInternal, SpanContext{})
+
+		assert.NotNil(t, span)
+		assert.True(t, span.Context().IsValid())
+		assert.True(t, span.Context().Sampled)
+
+		assert.Nil(t, newTestTracer("http://localhost:4318", 0).Start("test", SpanKindInternal, SpanContext{}))
+	})
+
+	t.Run("With parent", func(t *testing.T) {
+		tracer := newTestTracer("http://localhost:4318", 0)
+
+		span := tracer.Start("test", SpanKindClient, parent)
+
+		assert.NotNil(t, span)
+		assert.Equal(t, parent.TraceID, span.Context().TraceID)
+		assert.NotEqual(t, parent.SpanID, span.Context().SpanID)
+		assert.Equal(t, parent.SpanID, span.parentID)
+
+		assert.Nil(t, newTestTracer("http://localhost:4318", 1).Start("test", SpanKindClient, unsampled))
+	})
+}
+
+func TestTracerExport(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	config := NewConfig()
+	config.OTLPEndpoint = srv.URL
+	config.OTLPHeaders = "Authorization=Bearer secret"
+
+	m := metrics.NewMetrics(nil, 0)
+	tracer, err := NewTracer(&config, m, "node-1")
+	assert.Nil(t, err)
+
+	tracer.Run()
+
+	parent, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	span := tracer.Start("anycable.rpc.connect", SpanKindClient, parent)
+	span.SetString("anycable.session.uid", "s1")
+	span.SetInt("anycable.stream.sessions", 42)
+	span.SetError("Application error")
+	span.End()
+	// Subsequent calls are ignored
+	span.End()
+
+	assert.Nil(t, tracer.Shutdown())
+
+	req := <-received
+	assert.Equal(t, "/v1/traces", req.URL.Path)
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+
+	var payload otlpRequest
+	assert.Nil(t, json.Unmarshal(<-bodies, &payload))
+
+	resource := payload.ResourceSpans[0]
+	assert.Equal(t, "service.instance.id", resource.Resource.Attributes[2].Key)
+	assert.Equal(t, "node-1", *resource.Resource.Attributes[2].Value.StringValue)
+
+	spans := resource.ScopeSpans[0].Spans
+
+	if assert.Len(t, spans, 1) {
+		encoded := spans[0]
+
+		assert.Equal(t, "anycable.rpc.connect", encoded.Name)
+		assert.Equal(t, SpanKindClient, encoded.Kind)
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", encoded.TraceID)
+		assert.Equal(t, "00f067aa0ba902b7", encoded.ParentSpanID)
+		assert.Equal(t, "anycable.session.uid", encoded.Attributes[0].Key)
+		assert.Equal(t, "s1", *encoded.Attributes[0].Value.StringValue)
+		assert.Equal(t, "42", *encoded.Attributes[1].Value.IntValue)
+		assert.Equal(t, otlpStatusError, encoded.Status.Code)
+		assert.Equal(t, "Application error", encoded.Status.Message)
+	}
+
+	assert.Equal(t, uint64(1), m.Counter(metricsSpansExported).Value())
+	assert.Equal(t, uint64(0), m.Counter(metricsSpansDropped).Value())
+}
+
+func TestTracerExportFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	config := NewConfig()
+	config.OTLPEndpoint = srv.URL
+
+	m := metrics.NewMetrics(nil, 0)
+	tracer, _ := NewTracer(&config, m, "")
+
+	tracer.Run()
+
+	tracer.Start("test", SpanKindInternal, SpanContext{}).End()
+	tracer.Start("test", SpanKindInternal, SpanContext{}).End()
+
+	assert.Nil(t, tracer.Shutdown())
+
+	assert.Equal(t, uint64(0), m.Counter(metricsSpansExported).Value())
+	assert.Equal(t, uint64(2), m.Counter(metricsSpansDropped).Value())
+}
+
+func TestConfigValidate(t *testing.T) {
+	config := NewConfig()
+	assert.Nil(t, config.Validate())
+
+	config.SampleRate = -0.1
+	assert.NotNil(t, config.Validate())
+
+	config = NewConfig()
+	config.OTLPHeaders = "Authorization"
+	assert.NotNil(t, config.Validate())
+
+	config = NewConfig()
+	config.BroadcastThreshold = -1
+	assert.NotNil(t, config.Validate())
+}
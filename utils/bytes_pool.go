@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"io"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// The smallest size class of the default bytes pool
+	bytesPoolMinSize = 256
+	// The largest size class of the default bytes pool (larger buffers are not pooled)
+	bytesPoolMaxSize = 64 << 10
+)
+
+// BytesPool is a size-classed pool of byte slices (classes are powers of two).
+// Buffers must be returned via Put once they're no longer used (and must not be used after that).
+type BytesPool struct {
+	// Stats (updated atomically; must be the first fields to be 64-bit aligned)
+	gets        uint64
+	hits        uint64
+	outstanding int64
+
+	minShift int
+	classes  []sync.Pool
+}
+
+// BytesPoolStats contains cumulative pool stats
+type BytesPoolStats struct {
+	// The total number of requested buffers
+	Gets uint64
+	// The total number of buffers reused from the pool
+	Hits uint64
+	// The number of pooled buffers taken and not returned yet
+	Outstanding int64
+}
+
+var defaultBytesPool = NewBytesPool(bytesPoolMinSize, bytesPoolMaxSize)
+
+// DefaultBytesPool returns the bytes pool shared by connections, encoders and sessions
+func DefaultBytesPool() *BytesPool {
+	return defaultBytesPool
+}
+
+// GetBytes returns a buffer of the specified length from the default pool
+func GetBytes(size int) []byte {
+	return defaultBytesPool.Get(size)
+}
+
+// PutBytes returns the buffer to the default pool
+func PutBytes(b []byte) {
+	defaultBytesPool.Put(b)
+}
+
+// NewBytesPool creates a pool with size classes from minSize to maxSize (rounded up to powers of two)
+func NewBytesPool(minSize int, maxSize int) *BytesPool {
+	minShift := sizeShift(minSize)
+	maxShift := sizeShift(maxSize)
+
+	if maxShift < minShift {
+		maxShift = minShift
+	}
+
+	return &BytesPool{minShift: minShift, classes: make([]sync.Pool, maxShift-minShift+1)}
+}
+
+// Get returns a buffer of the specified length (the capacity is rounded up to the size class).
+// Buffers larger than the max size class are allocated directly.
+func (p *BytesPool) Get(size int) []byte {
+	atomic.AddUint64(&p.gets, 1)
+
+	idx := p.classIndex(size)
+
+	if idx < 0 {
+		return make([]byte, size)
+	}
+
+	atomic.AddInt64(&p.outstanding, 1)
+
+	if b, ok := p.classes[idx].Get().(*[]byte); ok {
+		atomic.AddUint64(&p.hits, 1)
+		return (*b)[:size]
+	}
+
+	return make([]byte, size, 1<<(p.minShift+idx))
+}
+
+// Put returns the buffer to the pool.
+// Buffers with capacities not matching any size class (e.g., larger than the max one) are ignored.
+func (p *BytesPool) Put(b []byte) {
+	c := cap(b)
+
+	if c == 0 || c&(c-1) != 0 {
+		return
+	}
+
+	idx := sizeShift(c) - p.minShift
+
+	if idx < 0 || idx >= len(p.classes) {
+		return
+	}
+
+	atomic.AddInt64(&p.outstanding, -1)
+
+	b = b[:0]
+	p.classes[idx].Put(&b)
+}
+
+// ReadAll reads from r until EOF into a pooled buffer.
+// The buffer must be returned via Put (it's returned automatically in case of error).
+func (p *BytesPool) ReadAll(r io.Reader) ([]byte, error) {
+	b := p.Get(bytesPoolMinSize)[:0]
+
+	for {
+		if len(b) == cap(b) {
+			grown := p.Get(2 * cap(b))
+			copy(grown, b)
+			p.Put(b)
+			b = grown[:len(b)]
+		}
+
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+
+		if err == io.EOF {
+			return b, nil
+		}
+
+		if err != nil {
+			p.Put(b)
+			return nil, err
+		}
+	}
+}
+
+// Stats returns the pool stats
+func (p *BytesPool) Stats() BytesPoolStats {
+	return BytesPoolStats{
+		Gets:        atomic.LoadUint64(&p.gets),
+		Hits:        atomic.LoadUint64(&p.hits),
+		Outstanding: atomic.LoadInt64(&p.outstanding),
+	}
+}
+
+func (p *BytesPool) classIndex(size int) int {
+	idx := sizeShift(size) - p.minShift
+
+	if idx < 0 {
+		return 0
+	}
+
+	if idx >= len(p.classes) {
+		return -1
+	}
+
+	return idx
+}
+
+// sizeShift returns the exponent of the smallest power of two not less than size
+func sizeShift(size int) int {
+	if size <= 1 {
+		return 0
+	}
+
+	return bits.Len(uint(size - 1))
+}
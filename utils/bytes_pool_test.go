@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBytesPool(t *testing.T) {
+	pool := NewBytesPool(256, 1024)
+
+	t.Run("Size classes", func(t *testing.T) {
+		b := pool.Get(10)
+		assert.Equal(t, 10, len(b))
+		assert.Equal(t, 256, cap(b))
+		pool.Put(b)
+
+		b = pool.Get(300)
+		assert.Equal(t, 512, cap(b))
+		pool.Put(b)
+
+		b = pool.Get(1024)
+		assert.Equal(t, 1024, cap(b))
+		pool.Put(b)
+
+		assert.Equal(t, int64(0), pool.Stats().Outstanding)
+	})
+
+	t.Run("Oversized buffers", func(t *testing.T) {
+		stats := pool.Stats()
+
+		b := pool.Get(2000)
+		assert.Equal(t, 2000, len(b))
+		pool.Put(b)
+
+		assert.Equal(t, stats.Gets+1, pool.Stats().Gets)
+		assert.Equal(t, stats.Outstanding, pool.Stats().Outstanding)
+	})
+
+	t.Run("Foreign buffers", func(t *testing.T) {
+		stats := pool.Stats()
+
+		pool.Put(make([]byte, 300))
+		pool.Put(nil)
+
+		assert.Equal(t, stats, pool.Stats())
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		pool := NewBytesPool(256, 1024)
+
+		b := pool.Get(100)
+		assert.Equal(t, BytesPoolStats{Gets: 1, Hits: 0, Outstanding: 1}, pool.Stats())
+
+		pool.Put(b)
+		assert.Equal(t, int64(0), pool.Stats().Outstanding)
+	})
+}
+
+func TestBytesPoolReadAll(t *testing.T) {
+	pool := NewBytesPool(256, 1024)
+
+	t.Run("Small message", func(t *testing.T) {
+		b, err := pool.ReadAll(strings.NewReader("hello"))
+
+		assert.Nil(t, err)
+		assert.Equal(t, "hello", string(b))
+
+		pool.Put(b)
+		assert.Equal(t, int64(0), pool.Stats().Outstanding)
+	})
+
+	t.Run("Message larger than the max size class", func(t *testing.T) {
+		msg := strings.Repeat("a", 3000)
+
+		b, err := pool.ReadAll(strings.NewReader(msg))
+
+		assert.Nil(t, err)
+		assert.Equal(t, msg, string(b))
+
+		pool.Put(b)
+		assert.Equal(t, int64(0), pool.Stats().Outstanding)
+	})
+
+	t.Run("Read error", func(t *testing.T) {
+		r := io.MultiReader(strings.NewReader(strings.Repeat("a", 500)), &failingReader{})
+
+		_, err := pool.ReadAll(r)
+
+		assert.Error(t, err)
+		assert.Equal(t, int64(0), pool.Stats().Outstanding)
+	})
+}
+
+func TestBytesPoolConcurrentReuse(t *testing.T) {
+	pool := NewBytesPool(256, 1024)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			expected := bytes.Repeat([]byte{byte('a' + i)}, 100+i*50)
+
+			for j := 0; j < 1000; j++ {
+				b := pool.Get(len(expected))
+				copy(b, expected)
+				runtime.Gosched()
+
+				// Buffers must not be handed out to multiple owners
+				if !assert.Equal(t, expected, b) {
+					return
+				}
+
+				pool.Put(b)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int64(0), pool.Stats().Outstanding)
+	assert.Greater(t, pool.Stats().Hits, uint64(0))
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
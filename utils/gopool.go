@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,13 +14,51 @@ var ErrScheduleTimeout = fmt.Errorf("schedule error: timed out")
 // See https://adtac.in/2021/04/23/note-on-worker-pools-in-go.html
 const workerRespawnThreshold = 1 << 16
 
+// Wait and execution times are measured for every N-th task only (to keep clock reads off the tasks path)
+const poolTimingsSampleRate = 64
+
 // GoPool contains logic of goroutine reuse.
 // Copied from https://github.com/gobwas/ws-examples/blob/master/src/gopool/pool.go
 type GoPool struct {
+	// Stats (updated atomically; must be the first fields to be 64-bit aligned)
+	scheduled uint64
+	processed uint64
+	saturated uint64
+	sampled   uint64
+	waitTime  int64
+	execTime  int64
+
 	name string
-	size int
-	sem  chan struct{}
-	work chan func()
+	work chan poolTask
+
+	// The max number of workers (updated atomically, so resizing doesn't add locks to the tasks path)
+	size int32
+	// The number of running workers (updated atomically)
+	workers int32
+	// The number of workers waiting for a task
+	idle int32
+}
+
+// GoPoolStats contains cumulative pool stats
+type GoPoolStats struct {
+	// The number of tasks waiting in the queue
+	Queued int
+	// The total number of executed tasks
+	Processed uint64
+	// The total number of times a task couldn't be scheduled without blocking
+	Saturated uint64
+	// The number of sampled tasks (the timings below are measured for them only)
+	Sampled uint64
+	// The total time sampled tasks spent in the queue
+	WaitTime time.Duration
+	// The total time spent executing sampled tasks
+	ExecTime time.Duration
+}
+
+type poolTask struct {
+	fn func()
+	// Set for sampled tasks only
+	enqueuedAt time.Time
 }
 
 var initializedPools []*GoPool = make([]*GoPool, 0)
@@ -49,15 +88,14 @@ func NewGoPool(name string, size int) *GoPool {
 	}
 
 	p := &GoPool{
-		name: name,
-		size: size,
-		sem:  make(chan struct{}, size),
-		work: make(chan func(), queue),
+		name:    name,
+		size:    int32(size),
+		workers: int32(spawn),
+		work:    make(chan poolTask, queue),
 	}
 
 	for i := 0; i < spawn; i++ {
-		p.sem <- struct{}{}
-		go p.worker(func() {})
+		go p.worker(poolTask{})
 	}
 
 	initializedPools = append(initializedPools, p)
@@ -70,7 +108,31 @@ func (p *GoPool) Name() string {
 }
 
 func (p *GoPool) Size() int {
-	return p.size
+	return int(atomic.LoadInt32(&p.size))
+}
+
+// Stats returns the pool stats
+func (p *GoPool) Stats() GoPoolStats {
+	return GoPoolStats{
+		Queued:    len(p.work),
+		Processed: atomic.LoadUint64(&p.processed),
+		Saturated: atomic.LoadUint64(&p.saturated),
+		Sampled:   atomic.LoadUint64(&p.sampled),
+		WaitTime:  time.Duration(atomic.LoadInt64(&p.waitTime)),
+		ExecTime:  time.Duration(atomic.LoadInt64(&p.execTime)),
+	}
+}
+
+// Resize changes the max number of workers.
+// New workers are spawned on demand; when shrinking, excess workers exit
+// after finishing their current task (queued tasks are never dropped).
+// The queue size stays the same.
+func (p *GoPool) Resize(size int) {
+	if size <= 0 {
+		size = 1
+	}
+
+	atomic.StoreInt32(&p.size, int32(size))
 }
 
 // Schedule schedules task to be executed over pool's workers.
@@ -85,29 +147,104 @@ func (p *GoPool) ScheduleTimeout(timeout time.Duration, task func()) error {
 }
 
 func (p *GoPool) schedule(task func(), timeout <-chan time.Time) error {
+	t := poolTask{fn: task}
+
+	if atomic.AddUint64(&p.scheduled, 1)%poolTimingsSampleRate == 0 {
+		t.enqueuedAt = time.Now()
+	}
+
+	// Spawn a new worker only if idle ones are not enough to handle queued tasks
+	if int(atomic.LoadInt32(&p.idle)) <= len(p.work) && p.acquire() {
+		go p.worker(t)
+		return nil
+	}
+
+	select {
+	case p.work <- t:
+		return nil
+	default:
+		atomic.AddUint64(&p.saturated, 1)
+	}
+
 	select {
 	case <-timeout:
 		return ErrScheduleTimeout
-	case p.work <- task:
-		return nil
-	case p.sem <- struct{}{}:
-		go p.worker(task)
+	case p.work <- t:
 		return nil
 	}
 }
 
-func (p *GoPool) worker(task func()) {
+func (p *GoPool) worker(task poolTask) {
 	counter := 1
-	defer func() { <-p.sem }()
 
-	task()
+	if task.fn != nil {
+		p.execute(task)
+	}
 
-	for task := range p.work {
-		task()
+	for {
+		if p.release() {
+			return
+		}
+
+		atomic.AddInt32(&p.idle, 1)
+		t := <-p.work
+		atomic.AddInt32(&p.idle, -1)
+
+		p.execute(t)
 		counter++
 
 		if counter >= workerRespawnThreshold {
+			// Replace the worker with a fresh goroutine
+			go p.worker(poolTask{})
 			return
 		}
 	}
 }
+
+func (p *GoPool) execute(t poolTask) {
+	if t.enqueuedAt.IsZero() {
+		t.fn()
+		atomic.AddUint64(&p.processed, 1)
+		return
+	}
+
+	start := time.Now()
+
+	t.fn()
+
+	atomic.AddInt64(&p.waitTime, int64(start.Sub(t.enqueuedAt)))
+	atomic.AddInt64(&p.execTime, int64(time.Since(start)))
+	atomic.AddUint64(&p.sampled, 1)
+	atomic.AddUint64(&p.processed, 1)
+}
+
+// acquire reserves a slot for a new worker if the pool is not full
+func (p *GoPool) acquire() bool {
+	for {
+		workers := atomic.LoadInt32(&p.workers)
+
+		if workers >= atomic.LoadInt32(&p.size) {
+			return false
+		}
+
+		if atomic.CompareAndSwapInt32(&p.workers, workers, workers+1) {
+			return true
+		}
+	}
+}
+
+// release makes the calling worker exit if the pool has been shrunk
+// (the CAS is only performed when the pool has been shrunk, i.e., rarely)
+func (p *GoPool) release() bool {
+	for {
+		workers := atomic.LoadInt32(&p.workers)
+
+		if workers <= atomic.LoadInt32(&p.size) {
+			return false
+		}
+
+		if atomic.CompareAndSwapInt32(&p.workers, workers, workers-1) {
+			return true
+		}
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -52,3 +54,178 @@ func getGID() uint64 {
 	n, _ := strconv.ParseUint(string(b), 10, 64)
 	return n
 }
+
+func TestResize(t *testing.T) {
+	pool := NewGoPool("resizable", 2)
+
+	assert.Equal(t, 2, pool.Size())
+
+	pool.Resize(4)
+	assert.Equal(t, 4, pool.Size())
+
+	var running int32
+	var maxRunning int32
+
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+
+	task := func() {
+		n := atomic.AddInt32(&running, 1)
+
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+
+		<-release
+		atomic.AddInt32(&running, -1)
+		wg.Done()
+	}
+
+	wg.Add(4)
+	for i := 0; i < 4; i++ {
+		pool.Schedule(task)
+	}
+
+	for i := 0; i < 50 && atomic.LoadInt32(&running) < 4; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.Equal(t, int32(4), atomic.LoadInt32(&maxRunning))
+
+	// Shrinking must not drop running or queued tasks
+	pool.Resize(1)
+
+	wg.Add(2)
+	go func() {
+		pool.Schedule(task)
+		pool.Schedule(task)
+	}()
+
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, 1, pool.Size())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&running))
+}
+
+func TestStats(t *testing.T) {
+	pool := NewGoPool("stats", 1)
+
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+
+	wg.Add(3)
+
+	for i := 0; i < 3; i++ {
+		go pool.Schedule(func() {
+			<-release
+			time.Sleep(time.Millisecond)
+			wg.Done()
+		})
+	}
+
+	for i := 0; i < 50 && pool.Stats().Saturated == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stats := pool.Stats()
+	assert.Equal(t, 1, stats.Queued)
+	assert.True(t, stats.Saturated >= 1)
+
+	close(release)
+	wg.Wait()
+
+	// Timings are measured for every N-th task only
+	for i := 3; i < poolTimingsSampleRate; i++ {
+		wg.Add(1)
+		pool.Schedule(func() {
+			time.Sleep(time.Millisecond)
+			wg.Done()
+		})
+	}
+
+	wg.Wait()
+
+	// Wait for the last task to be accounted
+	for i := 0; i < 50 && pool.Stats().Processed < poolTimingsSampleRate; i++ {
+		time.Sleep(time.Millisecond)
+	}
+
+	stats = pool.Stats()
+	assert.Equal(t, 0, stats.Queued)
+	assert.Equal(t, uint64(poolTimingsSampleRate), stats.Processed)
+	assert.Equal(t, uint64(1), stats.Sampled)
+	assert.True(t, stats.ExecTime >= time.Millisecond)
+	assert.True(t, stats.WaitTime > 0)
+}
+
+func BenchmarkSchedule(b *testing.B) {
+	pool := NewGoPool("bench", 16)
+
+	var wg sync.WaitGroup
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		wg.Add(1)
+		pool.Schedule(wg.Done)
+	}
+
+	wg.Wait()
+}
+
+// baselinePool is the original pool implementation without resizing and stats
+// (used to measure the instrumentation overhead)
+type baselinePool struct {
+	sem  chan struct{}
+	work chan func()
+}
+
+func newBaselinePool(size int) *baselinePool {
+	p := &baselinePool{sem: make(chan struct{}, size), work: make(chan func(), size/2)}
+
+	for i := 0; i < size/5; i++ {
+		p.sem <- struct{}{}
+		go p.worker(func() {})
+	}
+
+	return p
+}
+
+func (p *baselinePool) Schedule(task func()) {
+	select {
+	case p.work <- task:
+	case p.sem <- struct{}{}:
+		go p.worker(task)
+	}
+}
+
+func (p *baselinePool) worker(task func()) {
+	defer func() { <-p.sem }()
+
+	task()
+
+	for task := range p.work {
+		task()
+	}
+}
+
+func BenchmarkScheduleBaseline(b *testing.B) {
+	pool := newBaselinePool(16)
+
+	var wg sync.WaitGroup
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		wg.Add(1)
+		pool.Schedule(wg.Done)
+	}
+
+	wg.Wait()
+}
@@ -4,13 +4,54 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/json"
 )
 
-// InitLogger sets log level, format and output
-func InitLogger(format string, level string) error {
+// LogContexts contains the values of the "context" log field used by the application
+var LogContexts = []string{
+	"access_log",
+	"disconnector",
+	"events",
+	"gobench",
+	"http",
+	"hub",
+	"main",
+	"metrics",
+	"node",
+	"pubsub",
+	"rpc",
+	"sse",
+	"standalone",
+	"tracing",
+	"webhook",
+	"ws",
+}
+
+// rootHandler is installed as the global logger handler once; the actual handler and level are swapped
+// atomically by InitLogger, so the logger could be re-configured at runtime (e.g., on reload) safely
+var (
+	rootHandler     = &reloadableHandler{}
+	rootHandlerOnce sync.Once
+)
+
+// staticLogFields are added to all JSON log entries (see SetStaticLogFields)
+var staticLogFields log.Fields
+
+// SetStaticLogFields sets the fields to add to all log entries in the JSON format
+// (e.g., the node identifier); must be called before InitLogger
+func SetStaticLogFields(fields log.Fields) {
+	staticLogFields = fields
+}
+
+// InitLogger sets log level, format and output.
+// Levels could be overridden per log context (e.g., "rpc=debug,ws=warn").
+func InitLogger(format string, level string, levels string) error {
 	logLevel, err := log.ParseLevel(level)
 
 	if err != nil {
@@ -18,16 +59,175 @@ func InitLogger(format string, level string) error {
 		return errors.New(msg)
 	}
 
-	log.SetLevel(logLevel)
+	overrides, err := ParseLogLevels(levels)
+
+	if err != nil {
+		return err
+	}
+
+	var handler log.Handler
 
 	if format == "text" {
-		log.SetHandler(&LogHandler{writer: os.Stdout, tty: IsTTY()})
+		handler = &LogHandler{writer: os.Stdout, tty: IsTTY()}
 	} else if format == "json" {
-		log.SetHandler(json.New(os.Stdout))
+		handler = json.New(os.Stdout)
+
+		if len(staticLogFields) > 0 {
+			handler = &StaticFieldsHandler{handler: handler, fields: staticLogFields}
+		}
 	} else {
 		msg := fmt.Sprintf("Unknown log format: %s.\nAvaialable formats are: text, json", format)
 		return errors.New(msg)
 	}
 
+	minLevel := logLevel
+
+	if len(overrides) > 0 {
+		for _, l := range overrides {
+			if l < minLevel {
+				minLevel = l
+			}
+		}
+
+		handler = &ContextLevelHandler{handler: handler, level: logLevel, levels: overrides}
+	}
+
+	// Apply settings only if both level and format are valid
+	rootHandler.current.Store(&leveledHandler{handler: handler, level: minLevel})
+
+	rootHandlerOnce.Do(func() {
+		// Filtering by level is performed by the root handler
+		log.SetLevel(log.DebugLevel)
+		log.SetHandler(rootHandler)
+	})
+
+	if unknown := unknownLogContexts(overrides); len(unknown) > 0 {
+		log.WithField("context", "main").Warnf("Unknown log contexts: %s", strings.Join(unknown, ", "))
+	}
+
 	return nil
 }
+
+// ParseLogLevels parses a comma-separated list of context=level pairs
+func ParseLogLevels(levels string) (map[string]log.Level, error) {
+	overrides := make(map[string]log.Level)
+
+	for _, pair := range strings.Split(levels, ",") {
+		pair = strings.TrimSpace(pair)
+
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("Invalid log level override: %s.\nExpected format is: context=level", pair)
+		}
+
+		level, err := log.ParseLevel(strings.TrimSpace(parts[1]))
+
+		if err != nil {
+			return nil, fmt.Errorf("Unknown log level for %s: %s", parts[0], parts[1])
+		}
+
+		overrides[strings.TrimSpace(parts[0])] = level
+	}
+
+	return overrides, nil
+}
+
+func unknownLogContexts(overrides map[string]log.Level) []string {
+	unknown := []string{}
+
+	for name := range overrides {
+		known := false
+
+		for _, ctx := range LogContexts {
+			if ctx == name {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			unknown = append(unknown, name)
+		}
+	}
+
+	sort.Strings(unknown)
+
+	return unknown
+}
+
+// ContextLevelHandler filters log entries by level depending on the "context" field
+// (entries without overrides are filtered using the default level)
+type ContextLevelHandler struct {
+	handler log.Handler
+	level   log.Level
+	levels  map[string]log.Level
+}
+
+// HandleLog implements log.Handler interface
+func (h *ContextLevelHandler) HandleLog(e *log.Entry) error {
+	level := h.level
+
+	if ctx, ok := e.Fields["context"].(string); ok {
+		if l, ok := h.levels[ctx]; ok {
+			level = l
+		}
+	}
+
+	if e.Level < level {
+		return nil
+	}
+
+	return h.handler.HandleLog(e)
+}
+
+// StaticFieldsHandler adds the static fields to log entries (entry fields take precedence)
+type StaticFieldsHandler struct {
+	handler log.Handler
+	fields  log.Fields
+}
+
+// HandleLog implements log.Handler interface
+func (h *StaticFieldsHandler) HandleLog(e *log.Entry) error {
+	// Entry fields could be shared with the parent entry, so we must not modify them
+	fields := make(log.Fields, len(e.Fields)+len(h.fields))
+
+	for k, v := range h.fields {
+		fields[k] = v
+	}
+
+	for k, v := range e.Fields {
+		fields[k] = v
+	}
+
+	entry := *e
+	entry.Fields = fields
+
+	return h.handler.HandleLog(&entry)
+}
+
+type leveledHandler struct {
+	handler log.Handler
+	level   log.Level
+}
+
+// reloadableHandler passes log entries of the current level (or higher) to the current handler
+type reloadableHandler struct {
+	// *leveledHandler
+	current atomic.Value
+}
+
+// HandleLog implements log.Handler interface
+func (h *reloadableHandler) HandleLog(e *log.Entry) error {
+	current := h.current.Load().(*leveledHandler)
+
+	if e.Level < current.level {
+		return nil
+	}
+
+	return current.handler.HandleLog(e)
+}
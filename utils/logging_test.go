@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryHandler struct {
+	Entries []*log.Entry
+}
+
+func (h *memoryHandler) HandleLog(e *log.Entry) error {
+	h.Entries = append(h.Entries, e)
+	return nil
+}
+
+func TestParseLogLevels(t *testing.T) {
+	levels, err := ParseLogLevels(" rpc=debug, ws=warn,")
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]log.Level{"rpc": log.DebugLevel, "ws": log.WarnLevel}, levels)
+
+	levels, err = ParseLogLevels("")
+
+	assert.Nil(t, err)
+	assert.Empty(t, levels)
+
+	_, err = ParseLogLevels("rpc")
+	assert.NotNil(t, err)
+
+	_, err = ParseLogLevels("rpc=verbose")
+	assert.NotNil(t, err)
+}
+
+func TestContextLevelHandler(t *testing.T) {
+	mem := &memoryHandler{}
+	handler := &ContextLevelHandler{
+		handler: mem,
+		level:   log.InfoLevel,
+		levels:  map[string]log.Level{"rpc": log.DebugLevel, "ws": log.ErrorLevel},
+	}
+
+	logger := &log.Logger{Handler: handler, Level: log.DebugLevel}
+
+	logger.WithField("context", "rpc").Debug("rpc debug")
+	logger.WithField("context", "ws").Warn("ws warn")
+	logger.WithField("context", "ws").Error("ws error")
+	logger.WithField("context", "node").Debug("node debug")
+	logger.WithField("context", "node").Info("node info")
+	logger.Info("no context")
+
+	messages := []string{}
+
+	for _, e := range mem.Entries {
+		messages = append(messages, e.Message)
+	}
+
+	assert.Equal(t, []string{"rpc debug", "ws error", "node info", "no context"}, messages)
+}
+
+func TestUnknownLogContexts(t *testing.T) {
+	overrides := map[string]log.Level{"rpc": log.DebugLevel, "grpc": log.DebugLevel, "apollo": log.InfoLevel}
+
+	assert.Equal(t, []string{"apollo", "grpc"}, unknownLogContexts(overrides))
+}
+
+func TestStaticFieldsHandler(t *testing.T) {
+	mem := &memoryHandler{}
+	handler := &StaticFieldsHandler{handler: mem, fields: log.Fields{"node_id": "node-1", "context": "default"}}
+
+	logger := &log.Logger{Handler: handler, Level: log.DebugLevel}
+	ctx := logger.WithField("context", "rpc")
+
+	ctx.Info("test")
+
+	if assert.Equal(t, 1, len(mem.Entries)) {
+		assert.Equal(t, "node-1", mem.Entries[0].Fields["node_id"])
+		assert.Equal(t, "rpc", mem.Entries[0].Fields["context"])
+	}
+
+	// Parent entry fields are not modified
+	assert.Nil(t, ctx.Fields["node_id"])
+}
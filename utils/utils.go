@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/mattn/go-isatty"
@@ -10,3 +11,16 @@ import (
 func IsTTY() bool {
 	return isatty.IsTerminal(os.Stdout.Fd())
 }
+
+// MaxLoggedPayloadSize is the max number of payload bytes to include into logs
+const MaxLoggedPayloadSize = 256
+
+// TruncateBytes returns the string representation of data limited to max bytes
+// (used to log potentially large payloads)
+func TruncateBytes(data []byte, max int) string {
+	if len(data) <= max {
+		return string(data)
+	}
+
+	return fmt.Sprintf("%s...(%d bytes more)", data[:max], len(data)-max)
+}
@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// Config contains webhook notifications configuration
+type Config struct {
+	// Endpoint URL to post events to (notifications are disabled if empty)
+	URL string
+	// Secret used to sign payloads (HMAC-SHA256); payloads are not signed if empty
+	Secret string
+	// The max number of events waiting for delivery (new events are dropped when the queue is full)
+	QueueSize int
+	// The max number of delivery retries per event
+	MaxRetries int
+}
+
+// NewConfig builds a new config with defaults
+func NewConfig() Config {
+	return Config{QueueSize: 100, MaxRetries: 3}
+}
+
+// Enabled returns true iff URL is not empty
+func (c *Config) Enabled() bool {
+	return c.URL != ""
+}
+
+// Validate checks the configuration values
+func (c *Config) Validate() error {
+	u, err := url.Parse(c.URL)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("URL must be a valid HTTP(S) URL")
+	}
+
+	if c.QueueSize <= 0 {
+		return fmt.Errorf("Queue size must be positive, got: %d", c.QueueSize)
+	}
+
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("Max retries must be non-negative, got: %d", c.MaxRetries)
+	}
+
+	return nil
+}
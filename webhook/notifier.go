@@ -0,0 +1,332 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/version"
+	"github.com/apex/log"
+)
+
+const (
+	metricsWebhooksDelivered = "webhooks_delivered_total"
+	metricsWebhooksFailed    = "webhooks_failed_total"
+	metricsWebhooksDropped   = "webhooks_dropped_total"
+
+	// Headers added to webhook requests
+	SignatureHeader = "X-AnyCable-Signature"
+	EventHeader     = "X-AnyCable-Event"
+
+	requestTimeout    = 5 * time.Second
+	retryInitialDelay = time.Second
+	// How long to wait for the pending events to be delivered on shutdown
+	shutdownTimeout = 2 * time.Second
+	// How often to check the health of the watched components
+	watchInterval = time.Second
+)
+
+// Lifecycle event types
+const (
+	EventNodeStarted           = "node_started"
+	EventShutdownInitiated     = "shutdown_initiated"
+	EventDrainCompleted        = "drain_completed"
+	EventRPCLost               = "rpc_lost"
+	EventRPCRestored           = "rpc_restored"
+	EventSubscriberLost        = "subscriber_disconnected"
+	EventSubscriberReconnected = "subscriber_reconnected"
+)
+
+// Event is a webhook payload
+type Event struct {
+	Type string `json:"event"`
+	// Event time (Unix milliseconds)
+	Timestamp int64  `json:"ts"`
+	NodeID    string `json:"node_id"`
+	Version   string `json:"version"`
+	// Event-specific data (counts, durations, etc.)
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// Notifier posts lifecycle events to the webhook endpoint asynchronously.
+// The queue is bounded: events are dropped when the endpoint can't keep up,
+// so notifying never blocks the caller.
+//
+// A nil notifier is valid and sends nothing.
+type Notifier struct {
+	url        string
+	secret     string
+	nodeID     string
+	maxRetries int
+	interval   time.Duration
+	client     *http.Client
+
+	events  chan *Event
+	metrics *metrics.Metrics
+	log     *log.Entry
+
+	mu      sync.RWMutex
+	stopped bool
+	done    chan struct{}
+	// Closed when shutdown timed out to abort retries
+	abort     chan struct{}
+	abortOnce sync.Once
+	// Closed to stop health watchers
+	unwatch     chan struct{}
+	unwatchOnce sync.Once
+}
+
+// NewNotifier builds a new notifier (nodeID is added to every event)
+func NewNotifier(config *Config, m *metrics.Metrics, nodeID string) (*Notifier, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.RegisterCounter(metricsWebhooksDelivered, "The total number of delivered webhook events")
+	m.RegisterCounter(metricsWebhooksFailed, "The total number of webhook events failed to be delivered")
+	m.RegisterCounter(metricsWebhooksDropped, "The total number of webhook events dropped due to the full queue")
+
+	return &Notifier{
+		url:        config.URL,
+		secret:     config.Secret,
+		nodeID:     nodeID,
+		maxRetries: config.MaxRetries,
+		interval:   watchInterval,
+		client:     &http.Client{Timeout: requestTimeout},
+		events:     make(chan *Event, config.QueueSize),
+		metrics:    m,
+		log:        log.WithField("context", "webhook"),
+		done:       make(chan struct{}),
+		abort:      make(chan struct{}),
+		unwatch:    make(chan struct{}),
+	}, nil
+}
+
+// Run delivers events until the notifier is shut down
+func (n *Notifier) Run() {
+	defer close(n.done)
+
+	for event := range n.events {
+		if err := n.deliver(event); err != nil {
+			n.metrics.Counter(metricsWebhooksFailed).Inc()
+			n.log.Debugf("Failed to deliver %s event: %v", event.Type, err)
+			continue
+		}
+
+		n.metrics.Counter(metricsWebhooksDelivered).Inc()
+		n.log.Debugf("Delivered %s event", event.Type)
+	}
+}
+
+// Notify enqueues the event without blocking (the event is dropped if the queue is full)
+func (n *Notifier) Notify(eventType string, data map[string]interface{}) {
+	if n == nil {
+		return
+	}
+
+	event := &Event{
+		Type:      eventType,
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+		NodeID:    n.nodeID,
+		Version:   version.Version(),
+		Data:      data,
+	}
+
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	if n.stopped {
+		return
+	}
+
+	select {
+	case n.events <- event:
+	default:
+		n.metrics.Counter(metricsWebhooksDropped).Inc()
+		n.log.Debugf("Dropped %s event: queue is full", eventType)
+	}
+}
+
+// Watch checks the component health periodically and notifies about its changes:
+// the lost event is sent when a healthy component becomes unhealthy (with the error in the data),
+// and the restored event is sent when it becomes healthy again
+func (n *Notifier) Watch(check func() error, lostEvent string, restoredEvent string, data map[string]interface{}) {
+	if n == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(n.interval)
+		defer ticker.Stop()
+
+		// Components are not considered lost until they become healthy for the first time
+		healthy := false
+		lost := false
+
+		for {
+			select {
+			case <-n.unwatch:
+				return
+			case <-ticker.C:
+				err := check()
+
+				switch {
+				case err == nil && lost:
+					lost = false
+					healthy = true
+					n.Notify(restoredEvent, copyData(data))
+				case err == nil:
+					healthy = true
+				case healthy:
+					healthy = false
+					lost = true
+
+					payload := copyData(data)
+					payload["error"] = err.Error()
+
+					n.Notify(lostEvent, payload)
+				}
+			}
+		}
+	}()
+}
+
+// StopWatching stops health watchers (e.g., components going down during shutdown must not be reported)
+func (n *Notifier) StopWatching() {
+	if n == nil {
+		return
+	}
+
+	n.unwatchOnce.Do(func() { close(n.unwatch) })
+}
+
+// Shutdown stops accepting new events and waits for the pending ones to be delivered
+func (n *Notifier) Shutdown() error {
+	n.StopWatching()
+
+	n.mu.Lock()
+
+	if n.stopped {
+		n.mu.Unlock()
+		return nil
+	}
+
+	n.stopped = true
+	close(n.events)
+	n.mu.Unlock()
+
+	select {
+	case <-n.done:
+	case <-time.After(shutdownTimeout):
+		n.abortOnce.Do(func() { close(n.abort) })
+		n.log.Warnf("Pending webhook events haven't been delivered in %s", shutdownTimeout)
+	}
+
+	return nil
+}
+
+// deliver posts the event and retries with exponential backoff (unless aborted)
+func (n *Notifier) deliver(event *Event) error {
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	delay := retryInitialDelay
+
+	for attempt := 0; ; attempt++ {
+		err = n.post(event.Type, payload)
+
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+
+		if errors.As(err, &permanent) || attempt >= n.maxRetries {
+			return err
+		}
+
+		n.log.Debugf("Failed to deliver %s event, retrying in %v: %v", event.Type, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-n.abort:
+			return err
+		}
+
+		delay *= 2
+	}
+}
+
+type permanentError struct {
+	status int
+}
+
+func (e *permanentError) Error() string {
+	return fmt.Sprintf("Webhook endpoint responded with %d", e.status)
+}
+
+func (n *Notifier) post(eventType string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(payload))
+
+	if err != nil {
+		return &permanentError{}
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+
+	if n.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(n.secret, payload))
+	}
+
+	res, err := n.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body) // nolint:errcheck
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+
+	// Client errors are not retried (except for timeouts and rate limiting)
+	if res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusRequestTimeout && res.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{status: res.StatusCode}
+	}
+
+	return fmt.Errorf("Webhook endpoint responded with %d", res.StatusCode)
+}
+
+// Sign returns the payload signature ("sha256=" followed by the hex-encoded HMAC-SHA256 digest)
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload) // nolint:errcheck
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func copyData(data map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(data)+1)
+
+	for k, v := range data {
+		res[k] = v
+	}
+
+	return res
+}
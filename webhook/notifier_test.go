@@ -0,0 +1,254 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/metrics"
+	"github.com/anycable/anycable-go/version"
+	"github.com/stretchr/testify/assert"
+)
+
+type testEndpoint struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	// Response statuses by attempt (the last one is used for the rest)
+	statuses []int
+	// Blocks requests until closed (if set)
+	blocker chan struct{}
+}
+
+func (e *testEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if e.blocker != nil {
+		<-e.blocker
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+
+	e.mu.Lock()
+	e.requests = append(e.requests, r)
+	e.bodies = append(e.bodies, body)
+	attempt := len(e.requests) - 1
+	e.mu.Unlock()
+
+	status := http.StatusOK
+
+	if len(e.statuses) > 0 {
+		if attempt >= len(e.statuses) {
+			attempt = len(e.statuses) - 1
+		}
+
+		status = e.statuses[attempt]
+	}
+
+	w.WriteHeader(status)
+}
+
+func (e *testEndpoint) Count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.requests)
+}
+
+func newTestNotifier(t *testing.T, url string) (*Notifier, *metrics.Metrics) {
+	m := metrics.NewMetrics(nil, 10)
+	config := NewConfig()
+	config.URL = url
+	config.Secret = "s3cr3t"
+
+	notifier, err := NewNotifier(&config, m, "node-1")
+	assert.Nil(t, err)
+
+	return notifier, m
+}
+
+func TestNotifier(t *testing.T) {
+	t.Run("Posts signed events", func(t *testing.T) {
+		endpoint := &testEndpoint{}
+		srv := httptest.NewServer(endpoint)
+		defer srv.Close()
+
+		notifier, m := newTestNotifier(t, srv.URL)
+		go notifier.Run()
+
+		notifier.Notify(EventDrainCompleted, map[string]interface{}{"sessions_drained": 42})
+
+		assert.Nil(t, notifier.Shutdown())
+		assert.Equal(t, 1, endpoint.Count())
+		assert.Equal(t, uint64(1), m.Counter(metricsWebhooksDelivered).Value())
+
+		req := endpoint.requests[0]
+		body := endpoint.bodies[0]
+
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, EventDrainCompleted, req.Header.Get(EventHeader))
+		assert.Equal(t, Sign("s3cr3t", body), req.Header.Get(SignatureHeader))
+
+		var event map[string]interface{}
+		assert.Nil(t, json.Unmarshal(body, &event))
+
+		assert.Equal(t, "drain_completed", event["event"])
+		assert.Equal(t, "node-1", event["node_id"])
+		assert.Equal(t, version.Version(), event["version"])
+		assert.NotEmpty(t, event["ts"])
+		assert.Equal(t, map[string]interface{}{"sessions_drained": float64(42)}, event["data"])
+	})
+
+	t.Run("Retries server errors", func(t *testing.T) {
+		endpoint := &testEndpoint{statuses: []int{http.StatusServiceUnavailable, http.StatusOK}}
+		srv := httptest.NewServer(endpoint)
+		defer srv.Close()
+
+		notifier, m := newTestNotifier(t, srv.URL)
+		go notifier.Run()
+
+		notifier.Notify(EventNodeStarted, nil)
+
+		assert.Nil(t, notifier.Shutdown())
+		assert.Equal(t, 2, endpoint.Count())
+		assert.Equal(t, uint64(1), m.Counter(metricsWebhooksDelivered).Value())
+		assert.Equal(t, uint64(0), m.Counter(metricsWebhooksFailed).Value())
+	})
+
+	t.Run("Doesn't retry client errors", func(t *testing.T) {
+		endpoint := &testEndpoint{statuses: []int{http.StatusUnauthorized}}
+		srv := httptest.NewServer(endpoint)
+		defer srv.Close()
+
+		notifier, m := newTestNotifier(t, srv.URL)
+		go notifier.Run()
+
+		notifier.Notify(EventNodeStarted, nil)
+
+		assert.Nil(t, notifier.Shutdown())
+		assert.Equal(t, 1, endpoint.Count())
+		assert.Equal(t, uint64(0), m.Counter(metricsWebhooksDelivered).Value())
+		assert.Equal(t, uint64(1), m.Counter(metricsWebhooksFailed).Value())
+	})
+
+	t.Run("Drops events when the queue is full", func(t *testing.T) {
+		endpoint := &testEndpoint{}
+		srv := httptest.NewServer(endpoint)
+		defer srv.Close()
+
+		m := metrics.NewMetrics(nil, 10)
+		config := NewConfig()
+		config.URL = srv.URL
+		config.QueueSize = 2
+
+		notifier, err := NewNotifier(&config, m, "node-1")
+		assert.Nil(t, err)
+
+		for i := 0; i < 3; i++ {
+			notifier.Notify(EventNodeStarted, nil)
+		}
+
+		assert.Equal(t, uint64(1), m.Counter(metricsWebhooksDropped).Value())
+
+		go notifier.Run()
+
+		assert.Nil(t, notifier.Shutdown())
+		assert.Equal(t, 2, endpoint.Count())
+		assert.Empty(t, endpoint.requests[0].Header.Get(SignatureHeader))
+	})
+
+	t.Run("Doesn't block shutdown when the endpoint hangs", func(t *testing.T) {
+		endpoint := &testEndpoint{blocker: make(chan struct{})}
+		srv := httptest.NewServer(endpoint)
+		defer srv.Close()
+		defer close(endpoint.blocker)
+
+		notifier, _ := newTestNotifier(t, srv.URL)
+		go notifier.Run()
+
+		notifier.Notify(EventShutdownInitiated, nil)
+
+		start := time.Now()
+
+		assert.Nil(t, notifier.Shutdown())
+		assert.Less(t, int64(time.Since(start)), int64(shutdownTimeout+time.Second))
+	})
+
+	t.Run("Nil notifier is no-op", func(t *testing.T) {
+		var notifier *Notifier
+
+		notifier.Notify(EventNodeStarted, nil)
+		notifier.Watch(func() error { return nil }, EventRPCLost, EventRPCRestored, nil)
+		notifier.StopWatching()
+	})
+}
+
+func TestNotifierWatch(t *testing.T) {
+	endpoint := &testEndpoint{}
+	srv := httptest.NewServer(endpoint)
+	defer srv.Close()
+
+	notifier, _ := newTestNotifier(t, srv.URL)
+	notifier.interval = 10 * time.Millisecond
+	go notifier.Run()
+
+	// 0 – unhealthy (never been healthy), 1 – healthy, 2 – unhealthy, 3 – healthy
+	var state int32
+
+	notifier.Watch(func() error {
+		switch atomic.LoadInt32(&state) {
+		case 0, 2:
+			return errors.New("connection refused")
+		}
+
+		return nil
+	}, EventSubscriberLost, EventSubscriberReconnected, map[string]interface{}{"adapter": "redis"})
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, endpoint.Count())
+
+	for _, next := range []int32{1, 2, 3} {
+		atomic.StoreInt32(&state, next)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	notifier.StopWatching()
+	atomic.StoreInt32(&state, 2)
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Nil(t, notifier.Shutdown())
+	assert.Equal(t, 2, endpoint.Count())
+
+	var lost, reconnected Event
+
+	assert.Nil(t, json.Unmarshal(endpoint.bodies[0], &lost))
+	assert.Nil(t, json.Unmarshal(endpoint.bodies[1], &reconnected))
+
+	assert.Equal(t, EventSubscriberLost, lost.Type)
+	assert.Equal(t, map[string]interface{}{"adapter": "redis", "error": "connection refused"}, lost.Data)
+
+	assert.Equal(t, EventSubscriberReconnected, reconnected.Type)
+	assert.Equal(t, map[string]interface{}{"adapter": "redis"}, reconnected.Data)
+}
+
+func TestConfigValidate(t *testing.T) {
+	config := NewConfig()
+	config.URL = "https://example.com/hooks"
+
+	assert.Nil(t, config.Validate())
+
+	config.URL = "example.com"
+	assert.NotNil(t, config.Validate())
+
+	config.URL = "https://example.com/hooks"
+	config.QueueSize = 0
+	assert.NotNil(t, config.Validate())
+
+	config.QueueSize = 1
+	config.MaxRetries = -1
+	assert.NotNil(t, config.Validate())
+}
@@ -0,0 +1,25 @@
+package ws
+
+import "sync"
+
+var (
+	writeBufferPoolsMu sync.Mutex
+	writeBufferPools   = make(map[int]*sync.Pool)
+)
+
+// writeBufferPool returns a shared pool of connections write buffers of the specified size:
+// buffers are only held by connections while writing messages, so idle connections hold no write buffers.
+// NOTE: pools must not be shared between connections with different buffer sizes.
+func writeBufferPool(size int) *sync.Pool {
+	writeBufferPoolsMu.Lock()
+	defer writeBufferPoolsMu.Unlock()
+
+	pool, ok := writeBufferPools[size]
+
+	if !ok {
+		pool = &sync.Pool{}
+		writeBufferPools[size] = pool
+	}
+
+	return pool
+}
@@ -6,10 +6,46 @@ type Config struct {
 	WriteBufferSize   int
 	MaxMessageSize    int64
 	EnableCompression bool
-	AllowedOrigins    string
+	// Compression level (1 – best speed, 9 – best compression)
+	CompressionLevel int
+	// Messages smaller than this size (in bytes) are not compressed
+	CompressionMinSize int
+	AllowedOrigins     string
+	// Whether to accept requests without the Origin header when AllowedOrigins is set
+	AllowMissingOrigin bool
+	// Called when a request is rejected due to the origin check (optional)
+	OnOriginRejected func(origin string)
+	// The max number of connections per IP per RateLimitInterval (0 – no limit)
+	RateLimit int
+	// Rate limit interval in seconds
+	RateLimitInterval int
+	// The max number of connections per IP allowed in a burst
+	RateLimitBurst int
+	// Comma-separated list of CIDRs not affected by the rate limit
+	RateLimitExempt string
+	// The max number of tracked IPs
+	RateLimitCacheSize int
+	// Comma-separated list of cookies to pass to RPC (all cookies are passed if empty)
+	ProxyCookies string
+	// Called when a request is rejected due to the rate limit (optional)
+	OnRateLimited func(ip string)
+	// For how long to limit the connections admission rate after start (seconds, 0 – disabled)
+	SlowStartDuration int
+	// The initial number of connections accepted per second during the slow start
+	SlowStartRate int
+	// How to ramp the admission rate up during the slow start (linear or exponential)
+	SlowStartRamp string
+	// Called when a request is rejected during the slow start (optional)
+	OnSlowStartRejected func()
+	// Called when the WebSocket upgrade fails, e.g., due to invalid handshake headers (optional)
+	OnUpgradeFailed func()
+	// Comma-separated list of synthetic env entries (connection metadata) to pass to RPC
+	EnvMeta string
+	// Node identifier passed to RPC (hostname is used if empty; resolved by the runner at startup)
+	NodeID string
 }
 
 // NewConfig build a new Config struct
 func NewConfig() Config {
-	return Config{}
+	return Config{CompressionLevel: 1, RateLimitInterval: 1, RateLimitCacheSize: 10000, SlowStartRate: 100, SlowStartRamp: SlowStartLinear, EnvMeta: DefaultEnvMeta}
 }
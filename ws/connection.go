@@ -1,18 +1,36 @@
 package ws
 
 import (
+	"io"
 	"time"
 
+	"github.com/anycable/anycable-go/utils"
 	"github.com/gorilla/websocket"
 )
 
 // Connection is a WebSocket implementation of Connection
 type Connection struct {
 	conn *websocket.Conn
+	// Messages smaller than this size are sent uncompressed (if compression is enabled)
+	compressionMinSize int
+	// Incoming messages larger than this size are rejected (no limit if zero)
+	maxMessageSize int64
 }
 
 func NewConnection(conn *websocket.Conn) *Connection {
-	return &Connection{conn}
+	return &Connection{conn: conn}
+}
+
+// NewConnectionWithConfig creates a connection respecting compression settings
+func NewConnectionWithConfig(conn *websocket.Conn, config *Config) *Connection {
+	return &Connection{conn: conn, compressionMinSize: config.CompressionMinSize, maxMessageSize: config.MaxMessageSize}
+}
+
+// ReadLimit returns the read limit of the underlying connection for the specified max message size.
+// Messages exceeding the max size are read up to this limit to report their size;
+// larger ones are rejected by the underlying connection right away (without reading the payload).
+func ReadLimit(maxMessageSize int64) int64 {
+	return maxMessageSize * 2
 }
 
 // Write writes a text message to a WebSocket
@@ -21,6 +39,10 @@ func (ws Connection) Write(msg []byte, deadline time.Time) error {
 		return err
 	}
 
+	if ws.compressionMinSize > 0 {
+		ws.conn.EnableWriteCompression(len(msg) >= ws.compressionMinSize)
+	}
+
 	w, err := ws.conn.NextWriter(websocket.TextMessage)
 
 	if err != nil {
@@ -40,6 +62,10 @@ func (ws Connection) WriteBinary(msg []byte, deadline time.Time) error {
 		return err
 	}
 
+	if ws.compressionMinSize > 0 {
+		ws.conn.EnableWriteCompression(len(msg) >= ws.compressionMinSize)
+	}
+
 	w, err := ws.conn.NextWriter(websocket.BinaryMessage)
 
 	if err != nil {
@@ -53,9 +79,75 @@ func (ws Connection) WriteBinary(msg []byte, deadline time.Time) error {
 	return w.Close()
 }
 
+// WritePing writes a ping control frame to a WebSocket
+func (ws Connection) WritePing(deadline time.Time) error {
+	return ws.conn.WriteControl(websocket.PingMessage, []byte{}, deadline)
+}
+
+// OnPong registers a callback to be called every time a pong frame is received
+func (ws Connection) OnPong(callback func()) {
+	ws.conn.SetPongHandler(func(string) error {
+		callback()
+		return nil
+	})
+}
+
 func (ws Connection) Read() ([]byte, error) {
-	_, message, err := ws.conn.ReadMessage()
-	return message, err
+	return ws.readMessage(io.ReadAll, func([]byte) {})
+}
+
+// ReadPooled reads the next message into a buffer from the default bytes pool.
+// The buffer must be returned via utils.PutBytes once the message has been handled.
+func (ws Connection) ReadPooled() ([]byte, error) {
+	return ws.readMessage(utils.DefaultBytesPool().ReadAll, utils.PutBytes)
+}
+
+// readMessage reads the next message and checks its size (release is called for the messages being rejected)
+func (ws Connection) readMessage(readAll func(io.Reader) ([]byte, error), release func([]byte)) ([]byte, error) {
+	_, r, err := ws.conn.NextReader()
+
+	if err != nil {
+		return nil, ws.readError(err)
+	}
+
+	if ws.maxMessageSize <= 0 {
+		return readAll(r)
+	}
+
+	msg, err := readAll(io.LimitReader(r, ws.maxMessageSize+1))
+
+	if err != nil {
+		return nil, ws.readError(err)
+	}
+
+	if int64(len(msg)) <= ws.maxMessageSize {
+		return msg, nil
+	}
+
+	size := int64(len(msg))
+	release(msg)
+
+	// Discard the rest of the message to find out its size
+	rest, err := io.Copy(io.Discard, r)
+
+	if err != nil {
+		if tooBig := ws.readError(err); tooBig != err {
+			return nil, tooBig
+		}
+
+		return nil, &MessageTooBigError{Size: size + rest, Limit: ws.maxMessageSize}
+	}
+
+	return nil, &MessageTooBigError{Size: size + rest, Limit: ws.maxMessageSize, Exact: true}
+}
+
+// readError converts the read limit error into the message too big error
+func (ws Connection) readError(err error) error {
+	if err == websocket.ErrReadLimit && ws.maxMessageSize > 0 {
+		return &MessageTooBigError{Size: ReadLimit(ws.maxMessageSize), Limit: ws.maxMessageSize}
+	}
+
+	return err
 }
 
 // Close sends close frame with a given code and a reason
@@ -0,0 +1,178 @@
+package ws
+
+import (
+	"bytes"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anycable/anycable-go/utils"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingConn stores all the data read from the underlying connection
+type recordingConn struct {
+	net.Conn
+	mu   sync.Mutex
+	data bytes.Buffer
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+
+	c.mu.Lock()
+	c.data.Write(b[:n])
+	c.mu.Unlock()
+
+	return n, err
+}
+
+func TestConnectionCompressionMinSize(t *testing.T) {
+	config := NewConfig()
+	config.EnableCompression = true
+	config.CompressionLevel = 9
+	config.CompressionMinSize = 64
+
+	small := "ping"
+	large := strings.Repeat("broadcast ", 20)
+
+	handler := WebsocketHandler([]string{}, &config, func(wsc *websocket.Conn, info *RequestInfo, callback func()) error {
+		conn := NewConnectionWithConfig(wsc, &config)
+		deadline := time.Now().Add(time.Second)
+
+		assert.Nil(t, conn.Write([]byte(small), deadline))
+		assert.Nil(t, conn.Write([]byte(large), deadline))
+
+		return nil
+	})
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	var recorder *recordingConn
+
+	dialer := websocket.Dialer{
+		EnableCompression: true,
+		NetDial: func(network, addr string) (net.Conn, error) {
+			conn, err := net.Dial(network, addr)
+			recorder = &recordingConn{Conn: conn}
+			return recorder, err
+		},
+	}
+
+	client, _, err := dialer.Dial("ws"+srv.URL[len("http"):], nil)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	_, msg, err := client.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, small, string(msg))
+
+	_, msg, err = client.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, large, string(msg))
+
+	recorder.mu.Lock()
+	raw := recorder.data.Bytes()
+	recorder.mu.Unlock()
+
+	frames := raw[bytes.Index(raw, []byte("\r\n\r\n"))+4:]
+
+	// Server frames are not masked and both payloads are less than 126 bytes
+	firstLen := int(frames[1])
+	second := frames[2+firstLen:]
+
+	// RSV1 bit indicates a compressed message
+	assert.Equal(t, byte(0), frames[0]&0x40, "small message must not be compressed")
+	assert.Equal(t, byte(0x40), second[0]&0x40, "large message must be compressed")
+}
+
+func TestConnectionReadPooled(t *testing.T) {
+	config := NewConfig()
+	config.MaxMessageSize = 1024
+
+	small := "ping"
+	large := strings.Repeat("command ", 100)
+
+	received := make(chan string, 3)
+
+	handler := WebsocketHandler([]string{}, &config, func(wsc *websocket.Conn, info *RequestInfo, callback func()) error {
+		conn := NewConnectionWithConfig(wsc, &config)
+
+		for {
+			msg, err := conn.ReadPooled()
+
+			if err != nil {
+				if IsMessageTooBigError(err) {
+					received <- "too big"
+				}
+
+				return nil
+			}
+
+			received <- string(msg)
+			utils.PutBytes(msg)
+		}
+	})
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+srv.URL[len("http"):], nil)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	assert.Nil(t, client.WriteMessage(websocket.TextMessage, []byte(small)))
+	assert.Nil(t, client.WriteMessage(websocket.TextMessage, []byte(large)))
+	assert.Nil(t, client.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("a", 2048))))
+
+	assert.Equal(t, small, <-received)
+	assert.Equal(t, large, <-received)
+	assert.Equal(t, "too big", <-received)
+}
+
+func TestConnectionReadMessageTooBig(t *testing.T) {
+	config := NewConfig()
+	config.MaxMessageSize = 1024
+
+	for _, tc := range []struct {
+		name  string
+		size  int
+		err   string
+		exact bool
+	}{
+		{"Within the read limit", 1500, "message size 1500 exceeds the limit of 1024 bytes", true},
+		{"Beyond the read limit", 4096, "message size is more than 2048 bytes (limit: 1024 bytes)", false},
+	} {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			readErr := make(chan error, 1)
+
+			handler := WebsocketHandler([]string{}, &config, func(wsc *websocket.Conn, info *RequestInfo, callback func()) error {
+				_, err := NewConnectionWithConfig(wsc, &config).Read()
+				readErr <- err
+				return nil
+			})
+
+			srv := httptest.NewServer(handler)
+			defer srv.Close()
+
+			client, _, err := websocket.DefaultDialer.Dial("ws"+srv.URL[len("http"):], nil)
+			assert.Nil(t, err)
+			defer client.Close()
+
+			assert.Nil(t, client.WriteMessage(websocket.TextMessage, []byte(strings.Repeat("a", tc.size))))
+
+			err = <-readErr
+
+			assert.True(t, IsMessageTooBigError(err))
+			assert.Equal(t, tc.err, err.Error())
+			assert.Equal(t, tc.exact, err.(*MessageTooBigError).Exact)
+		})
+	}
+}
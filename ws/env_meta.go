@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Synthetic env entries (connection metadata) which could be passed to RPC
+const (
+	// Client IP (REMOTE_ADDR, trusted-proxy aware)
+	EnvMetaRemoteAddr = "remote_addr"
+	// TLS state (ANYCABLE_TLS) and negotiated TLS version (ANYCABLE_TLS_VERSION)
+	EnvMetaTLS = "tls"
+	// Negotiated WebSocket subprotocol (sec-websocket-protocol)
+	EnvMetaProtocol = "protocol"
+	// Server node identifier (ANYCABLE_NODE_ID)
+	EnvMetaNodeID = "node_id"
+
+	// DefaultEnvMeta contains all the supported entries
+	DefaultEnvMeta = "remote_addr,tls,protocol,node_id"
+)
+
+const (
+	tlsHeader        = "ANYCABLE_TLS"
+	tlsVersionHeader = "ANYCABLE_TLS_VERSION"
+	nodeIDHeader     = "ANYCABLE_NODE_ID"
+)
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLSv1.0",
+	tls.VersionTLS11: "TLSv1.1",
+	tls.VersionTLS12: "TLSv1.2",
+	tls.VersionTLS13: "TLSv1.3",
+}
+
+// ParseEnvMeta parses a comma-separated list of synthetic env entries
+func ParseEnvMeta(list string) (map[string]bool, error) {
+	entries := make(map[string]bool)
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+
+		if name == "" {
+			continue
+		}
+
+		switch name {
+		case EnvMetaRemoteAddr, EnvMetaTLS, EnvMetaProtocol, EnvMetaNodeID:
+			entries[name] = true
+		default:
+			return nil, fmt.Errorf("Unknown env meta entry: %s", name)
+		}
+	}
+
+	return entries, nil
+}
+
+// NodeIdentifier returns the configured node identifier or the hostname
+func (c *Config) NodeIdentifier() string {
+	if c.NodeID != "" {
+		return c.NodeID
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+
+	return ""
+}
+
+// setEnvMeta fills the request info with the connection metadata and adds (or removes)
+// the corresponding synthetic entries to the headers passed to RPC
+func setEnvMeta(info *RequestInfo, r *http.Request, subprotocol string, nodeID string, entries map[string]bool) {
+	headers := *info.Headers
+
+	info.TLS = r.TLS != nil
+	info.NodeID = nodeID
+
+	if r.TLS != nil {
+		info.TLSVersion = tlsVersions[r.TLS.Version]
+	}
+
+	if !entries[EnvMetaRemoteAddr] {
+		delete(headers, remoteAddrHeader)
+	}
+
+	if entries[EnvMetaTLS] {
+		if info.TLS {
+			headers[tlsHeader] = "on"
+			headers[tlsVersionHeader] = info.TLSVersion
+		} else {
+			headers[tlsHeader] = "off"
+		}
+	}
+
+	if entries[EnvMetaProtocol] && subprotocol != "" {
+		headers[protocolHeader] = subprotocol
+	}
+
+	if entries[EnvMetaNodeID] && nodeID != "" {
+		headers[nodeIDHeader] = nodeID
+	}
+}
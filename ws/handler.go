@@ -3,23 +3,43 @@ package ws
 import (
 	"errors"
 	"fmt"
-	"net"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/anycable/anycable-go/server"
 	"github.com/anycable/anycable-go/version"
 	"github.com/apex/log"
 	"github.com/gorilla/websocket"
 	nanoid "github.com/matoous/go-nanoid"
 )
 
-const remoteAddrHeader = "REMOTE_ADDR"
+const (
+	remoteAddrHeader = "REMOTE_ADDR"
+	cookieHeader     = "cookie"
+	// Negotiated WebSocket subprotocol (passed to RPC)
+	protocolHeader = "sec-websocket-protocol"
+	// Verified client certificate subject common name
+	clientCertCNHeader = "SSL_CLIENT_CN"
+	// Verified client certificate subject alternative names (comma-separated)
+	clientCertSANHeader = "SSL_CLIENT_SAN"
+)
 
 type RequestInfo struct {
-	UID     string
-	Url     string
+	UID      string
+	Url      string
+	RemoteIP string
+	// Negotiated protocol version
+	Protocol string
+	// Whether the connection has been established over TLS and the negotiated TLS version
+	TLS        bool
+	TLSVersion string
+	// Server node identifier
+	NodeID  string
 	Headers *map[string]string
+	// When the underlying connection has been accepted (zero if unknown)
+	AcceptedAt time.Time
 }
 
 func NewRequestInfo(r *http.Request, headersToFetch []string) (*RequestInfo, error) {
@@ -30,56 +50,179 @@ func NewRequestInfo(r *http.Request, headersToFetch []string) (*RequestInfo, err
 		return nil, errors.New("Failed to retrieve connection uid")
 	}
 
-	return &RequestInfo{UID: uid, Headers: &headers}, nil
+	return &RequestInfo{UID: uid, RemoteIP: headers[remoteAddrHeader], Headers: &headers}, nil
+}
+
+// RequestInfoBuilder builds connection info passed to RPC from HTTP requests
+// respecting the env meta and proxied cookies settings
+type RequestInfoBuilder struct {
+	headersToFetch []string
+	proxyCookies   map[string]bool
+	envMeta        map[string]bool
+	nodeID         string
+}
+
+// NewRequestInfoBuilder creates a builder for the specified configuration
+func NewRequestInfoBuilder(headersToFetch []string, config *Config) *RequestInfoBuilder {
+	// Entries must be validated by the caller
+	envMeta, _ := ParseEnvMeta(config.EnvMeta)
+
+	return &RequestInfoBuilder{
+		headersToFetch: headersToFetch,
+		proxyCookies:   parseCookieNames(config.ProxyCookies),
+		envMeta:        envMeta,
+		nodeID:         config.NodeIdentifier(),
+	}
+}
+
+// Build returns connection info for the request and the negotiated subprotocol (if any)
+func (b *RequestInfoBuilder) Build(r *http.Request, subprotocol string) (*RequestInfo, error) {
+	url := r.URL.String()
+
+	if !r.URL.IsAbs() {
+		// See https://github.com/golang/go/issues/28940#issuecomment-441749380
+		scheme := "http://"
+		if r.TLS != nil {
+			scheme = "https://"
+		}
+		url = fmt.Sprintf("%s%s%s", scheme, r.Host, url)
+	}
+
+	info, err := NewRequestInfo(r, b.headersToFetch)
+	if err != nil {
+		return nil, err
+	}
+	info.Url = url
+	info.Protocol = ProtocolVersion(subprotocol)
+	info.AcceptedAt = server.AcceptedAt(r.Context())
+
+	setEnvMeta(info, r, subprotocol, b.nodeID, b.envMeta)
+
+	if len(b.proxyCookies) > 0 {
+		if cookie, ok := (*info.Headers)[cookieHeader]; ok {
+			(*info.Headers)[cookieHeader] = FilterCookies(cookie, b.proxyCookies)
+		}
+	}
+
+	return info, nil
+}
+
+// OriginChecker returns a function to verify request origins according to the configuration
+func OriginChecker(config *Config) func(r *http.Request) bool {
+	checkOrigin := CheckOrigin(config.AllowedOrigins)
+
+	if config.AllowMissingOrigin {
+		check := checkOrigin
+		checkOrigin = func(r *http.Request) bool {
+			return r.Header.Get("Origin") == "" || check(r)
+		}
+	}
+
+	return checkOrigin
 }
 
 type sessionHandler = func(conn *websocket.Conn, info *RequestInfo, callback func()) error
 
+// NewConnectionsRateLimiter returns the per-IP connections rate limiter according to the configuration (nil if disabled)
+func NewConnectionsRateLimiter(config *Config) *RateLimiter {
+	if config.RateLimit <= 0 {
+		return nil
+	}
+
+	// CIDRs must be validated by the caller
+	exempt, _ := server.ParseCIDRs(config.RateLimitExempt)
+	interval := time.Duration(config.RateLimitInterval) * time.Second
+
+	return NewRateLimiter(config.RateLimit, interval, config.RateLimitBurst, config.RateLimitCacheSize, exempt)
+}
+
+// RateLimitHandler wraps the handler with the per-IP connections rate limiting:
+// excess requests are rejected with 429 (the handler is returned as is if the limiter is nil)
+func RateLimitHandler(handler http.Handler, limiter *RateLimiter, config *Config) http.Handler {
+	if limiter == nil {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := server.ClientIP(r); !limiter.Allow(ip) {
+			log.WithField("context", "ws").Debugf("Connection rejected: rate limit exceeded for %s", ip)
+
+			if config.OnRateLimited != nil {
+				config.OnRateLimited(ip)
+			}
+
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
 // WebsocketHandler generate a new http handler for WebSocket connections
 func WebsocketHandler(headersToFetch []string, config *Config, sessionHandler sessionHandler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	checkOrigin := OriginChecker(config)
+	infoBuilder := NewRequestInfoBuilder(headersToFetch, config)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := log.WithField("context", "ws")
 
+		if !checkOrigin(r) {
+			origin := r.Header.Get("Origin")
+
+			ctx.Warnf("WebSocket connection rejected: origin is not allowed: %q", origin)
+
+			if config.OnOriginRejected != nil {
+				config.OnOriginRejected(origin)
+			}
+
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
 		upgrader := websocket.Upgrader{
-			CheckOrigin:       CheckOrigin(config.AllowedOrigins),
-			Subprotocols:      []string{"actioncable-v1-json"},
+			// Origin has been already checked
+			CheckOrigin:       func(r *http.Request) bool { return true },
+			Subprotocols:      Subprotocols,
 			ReadBufferSize:    config.ReadBufferSize,
 			WriteBufferSize:   config.WriteBufferSize,
+			WriteBufferPool:   writeBufferPool(config.WriteBufferSize),
 			EnableCompression: config.EnableCompression,
+			HandshakeTimeout:  server.HandshakeTimeout,
 		}
 
 		rheader := map[string][]string{"X-AnyCable-Version": {version.Version()}}
 		wsc, err := upgrader.Upgrade(w, r, rheader)
 		if err != nil {
 			ctx.Debugf("Websocket connection upgrade error: %#v", err.Error())
-			return
-		}
 
-		url := r.URL.String()
-
-		if !r.URL.IsAbs() {
-			// See https://github.com/golang/go/issues/28940#issuecomment-441749380
-			scheme := "http://"
-			if r.TLS != nil {
-				scheme = "https://"
+			if config.OnUpgradeFailed != nil {
+				config.OnUpgradeFailed()
 			}
-			url = fmt.Sprintf("%s%s%s", scheme, r.Host, url)
+
+			return
 		}
 
-		info, err := NewRequestInfo(r, headersToFetch)
+		server.SetAccessLogSubprotocol(r, wsc.Subprotocol())
+
+		info, err := infoBuilder.Build(r, wsc.Subprotocol())
 		if err != nil {
 			CloseWithReason(wsc, websocket.CloseAbnormalClosure, err.Error())
 			return
 		}
-		info.Url = url
 
-		wsc.SetReadLimit(config.MaxMessageSize)
+		wsc.SetReadLimit(ReadLimit(config.MaxMessageSize))
 
 		if config.EnableCompression {
 			wsc.EnableWriteCompression(true)
+
+			if config.CompressionLevel != 0 {
+				// Level must be validated by the caller
+				wsc.SetCompressionLevel(config.CompressionLevel) // nolint:errcheck
+			}
 		}
 
-		sessionCtx := log.WithField("sid", info.UID)
+		sessionCtx := log.WithFields(log.Fields{"sid": info.UID, "protocol": info.Protocol})
 
 		// Separate goroutine for better GC of caller's data.
 		go func() {
@@ -94,6 +237,8 @@ func WebsocketHandler(headersToFetch []string, config *Config, sessionHandler se
 			}
 		}()
 	})
+
+	return RateLimitHandler(handler, NewConnectionsRateLimiter(config), config)
 }
 
 // FetchHeaders extracts specified headers from request
@@ -103,7 +248,23 @@ func FetchHeaders(r *http.Request, list []string) map[string]string {
 	for _, header := range list {
 		res[header] = r.Header.Get(header)
 	}
-	res[remoteAddrHeader], _, _ = net.SplitHostPort(r.RemoteAddr)
+	res[remoteAddrHeader] = server.ClientIP(r)
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cert := r.TLS.VerifiedChains[0][0]
+
+		res[clientCertCNHeader] = cert.Subject.CommonName
+
+		sans := append([]string{}, cert.DNSNames...)
+		sans = append(sans, cert.EmailAddresses...)
+
+		for _, uri := range cert.URIs {
+			sans = append(sans, uri.String())
+		}
+
+		res[clientCertSANHeader] = strings.Join(sans, ",")
+	}
+
 	return res
 }
 
@@ -117,28 +278,118 @@ func FetchUID(r *http.Request) (string, error) {
 	return requestID, nil
 }
 
+// originPattern is a parsed allowed origins list entry
+type originPattern struct {
+	host string
+	// Empty port matches any port
+	port string
+	// Wildcard patterns match the host suffix
+	wildcard bool
+}
+
+// parseOrigins parses the comma-separated allowed origins list (empty entries are skipped)
+func parseOrigins(origins string) []originPattern {
+	patterns := []originPattern{}
+
+	for _, entry := range strings.Split(strings.ToLower(origins), ",") {
+		entry = strings.TrimSpace(entry)
+
+		if entry == "" {
+			continue
+		}
+
+		pattern := originPattern{}
+
+		if strings.HasPrefix(entry, "*") {
+			pattern.wildcard = true
+			entry = entry[1:]
+		}
+
+		pattern.host, pattern.port = splitHostPort(entry)
+		patterns = append(patterns, pattern)
+	}
+
+	return patterns
+}
+
+func (p originPattern) match(host string, port string) bool {
+	if p.port != "" && p.port != port {
+		return false
+	}
+
+	if !p.wildcard {
+		return host == p.host
+	}
+
+	// "*.example.com" matches both the subdomains and the apex domain
+	if strings.HasPrefix(p.host, ".") && host == p.host[1:] {
+		return true
+	}
+
+	return strings.HasSuffix(host, p.host)
+}
+
+func splitHostPort(hostport string) (string, string) {
+	u := url.URL{Host: hostport}
+	return u.Hostname(), u.Port()
+}
+
+// CheckOrigin returns a function to check the request Origin header against the comma-separated list of hostnames.
+// Entries without a port match any port; wildcard entries (e.g., "*.example.com") match subdomains
 func CheckOrigin(origins string) func(r *http.Request) bool {
-	if origins == "" {
+	patterns := parseOrigins(origins)
+
+	if len(patterns) == 0 {
 		return func(r *http.Request) bool { return true }
 	}
 
-	hosts := strings.Split(strings.ToLower(origins), ",")
-
 	return func(r *http.Request) bool {
 		origin := strings.ToLower(r.Header.Get("Origin"))
 		u, err := url.Parse(origin)
-		if err != nil {
+		if err != nil || u.Host == "" {
 			return false
 		}
 
-		for _, host := range hosts {
-			if host[0] == '*' && strings.HasSuffix(u.Host, host[1:]) {
-				return true
-			}
-			if u.Host == host {
+		host, port := u.Hostname(), u.Port()
+
+		for _, pattern := range patterns {
+			if pattern.match(host, port) {
 				return true
 			}
 		}
 		return false
 	}
 }
+
+// FilterCookies returns the cookie header value containing only the specified cookies.
+// Malformed entries are skipped
+func FilterCookies(header string, names map[string]bool) string {
+	res := []string{}
+
+	for _, pair := range strings.Split(header, ";") {
+		pair = strings.TrimSpace(pair)
+		parts := strings.SplitN(pair, "=", 2)
+
+		if len(parts) != 2 {
+			continue
+		}
+
+		if names[strings.TrimSpace(parts[0])] {
+			res = append(res, pair)
+		}
+	}
+
+	return strings.Join(res, "; ")
+}
+
+func parseCookieNames(list string) map[string]bool {
+	names := make(map[string]bool)
+
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[name] = true
+		}
+	}
+
+	return names
+}
@@ -1,9 +1,16 @@
 package ws
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -50,6 +57,34 @@ func TestFetchHeaders(t *testing.T) {
 	})
 }
 
+func TestFetchHeadersWithClientCert(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+
+	spiffe, _ := url.Parse("spiffe://example.com/billing")
+
+	cert := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "billing-service"},
+		DNSNames: []string{"billing.internal"},
+		URIs:     []*url.URL{spiffe},
+	}
+
+	req.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+
+	headers := FetchHeaders(req, []string{})
+
+	assert.Equal(t, "billing-service", headers["SSL_CLIENT_CN"])
+	assert.Equal(t, "billing.internal,spiffe://example.com/billing", headers["SSL_CLIENT_SAN"])
+
+	// Unverified certificates are ignored
+	req.TLS.VerifiedChains = nil
+
+	headers = FetchHeaders(req, []string{})
+	assert.NotContains(t, headers, "SSL_CLIENT_CN")
+}
+
 func TestCheckOriginWithoutHeader(t *testing.T) {
 	req := httptest.NewRequest("GET", "/", nil)
 
@@ -88,3 +123,297 @@ func TestCheckOrigin(t *testing.T) {
 	req.Header.Set("Origin", "http://MY.localhost:8080")
 	assert.Equal(t, CheckOrigin(allowedOrigins)(req), true)
 }
+
+func TestCheckOriginPatterns(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		allowed string
+		origin  string
+		result  bool
+	}{
+		{"trailing comma", "secure.origin,", "http://secure.origin", true},
+		{"trailing comma mismatch", "secure.origin,", "http://evil.origin", false},
+		{"spaces", "secure.origin, my.origin", "http://my.origin", true},
+		{"only commas", " , ,", "http://evil.origin", true},
+		{"origin with port", "my.origin", "http://my.origin:3000", true},
+		{"port mismatch", "my.origin:8080", "http://my.origin:3000", false},
+		{"port match", "my.origin:8080", "http://my.origin:8080", true},
+		{"wildcard subdomain", "*.example.com", "https://www.example.com", true},
+		{"wildcard nested subdomain", "*.example.com", "https://a.b.example.com", true},
+		{"wildcard apex", "*.example.com", "https://example.com", true},
+		{"wildcard apex with port", "*.example.com", "https://example.com:8443", true},
+		{"wildcard subdomain with port", "*.example.com", "https://www.example.com:8443", true},
+		{"wildcard lookalike", "*.example.com", "https://evilexample.com", false},
+		{"wildcard suffix", "*example.com", "https://myexample.com", true},
+		{"wildcard with port mismatch", "*.example.com:8080", "https://www.example.com:3000", false},
+		{"origin suffix attack", "example.com", "https://example.com.evil.io", false},
+		{"malformed origin", "example.com", "null", false},
+	} {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Origin", tc.origin)
+
+			assert.Equal(t, tc.result, CheckOrigin(tc.allowed)(req))
+		})
+	}
+}
+
+func TestWebsocketHandlerOriginCheck(t *testing.T) {
+	var rejected []string
+
+	config := NewConfig()
+	config.AllowedOrigins = "secure.origin"
+	config.OnOriginRejected = func(origin string) { rejected = append(rejected, origin) }
+
+	handler := WebsocketHandler([]string{}, &config, func(conn *websocket.Conn, info *RequestInfo, callback func()) error {
+		return nil
+	})
+
+	t.Run("Rejects not allowed origin", func(t *testing.T) {
+		rejected = nil
+
+		req := httptest.NewRequest("GET", "/cable", nil)
+		req.Header.Set("Origin", "http://evil.origin")
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Equal(t, []string{"http://evil.origin"}, rejected)
+	})
+
+	t.Run("Rejects missing origin", func(t *testing.T) {
+		rejected = nil
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/cable", nil))
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Equal(t, []string{""}, rejected)
+	})
+
+	t.Run("Allows missing origin when configured", func(t *testing.T) {
+		rejected = nil
+		config.AllowMissingOrigin = true
+
+		handler := WebsocketHandler([]string{}, &config, func(conn *websocket.Conn, info *RequestInfo, callback func()) error {
+			return nil
+		})
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/cable", nil))
+
+		// Fails at the upgrade stage, since it's not a WebSocket request
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+		assert.Empty(t, rejected)
+	})
+}
+
+func TestWebsocketHandlerMaxMessageSize(t *testing.T) {
+	config := NewConfig()
+	config.MaxMessageSize = 16
+
+	readErr := make(chan error, 1)
+
+	handler := WebsocketHandler([]string{}, &config, func(conn *websocket.Conn, info *RequestInfo, callback func()) error {
+		_, err := NewConnection(conn).Read()
+		readErr <- err
+		return nil
+	})
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+srv.URL[len("http"):], nil)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	assert.Nil(t, client.WriteMessage(websocket.TextMessage, []byte("this message is too long to be accepted")))
+
+	assert.True(t, IsMessageTooBigError(<-readErr))
+
+	_, _, err = client.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig))
+}
+
+func TestWebsocketHandlerAfterHandshakeTimeout(t *testing.T) {
+	config := NewConfig()
+
+	readErr := make(chan error, 1)
+
+	handler := WebsocketHandler([]string{}, &config, func(conn *websocket.Conn, info *RequestInfo, callback func()) error {
+		_, err := NewConnection(conn).Read()
+		readErr <- err
+		return nil
+	})
+
+	// Upgraded connections must not be affected by the server read timeout
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Config.ReadTimeout = 50 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+srv.URL[len("http"):], nil)
+	assert.Nil(t, err)
+	defer client.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Nil(t, client.WriteMessage(websocket.TextMessage, []byte("hello")))
+	assert.Nil(t, <-readErr)
+}
+
+func TestFilterCookies(t *testing.T) {
+	names := parseCookieNames("session, remember_token")
+
+	t.Run("Keeps only allowed cookies", func(t *testing.T) {
+		header := "_ga=GA1.2.3; session=abc==; utm=x; remember_token=42"
+		assert.Equal(t, "session=abc==; remember_token=42", FilterCookies(header, names))
+	})
+
+	t.Run("Skips malformed entries", func(t *testing.T) {
+		header := "garbage;; session=abc; =; remember_token"
+		assert.Equal(t, "session=abc", FilterCookies(header, names))
+	})
+
+	t.Run("Without matching cookies", func(t *testing.T) {
+		assert.Equal(t, "", FilterCookies("_ga=1", names))
+	})
+}
+
+func TestWebsocketHandlerSubprotocols(t *testing.T) {
+	config := NewConfig()
+	infos := make(chan *RequestInfo, 1)
+
+	handler := WebsocketHandler([]string{}, &config, func(conn *websocket.Conn, info *RequestInfo, callback func()) error {
+		infos <- info
+		return nil
+	})
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	tests := []struct {
+		requested  []string
+		negotiated string
+		version    string
+	}{
+		{requested: []string{"actioncable-v1.1-json", "actioncable-v1-json"}, negotiated: "actioncable-v1.1-json", version: "1.1"},
+		{requested: []string{"actioncable-v1-json"}, negotiated: "actioncable-v1-json", version: "1"},
+		{requested: []string{"actioncable-v2-json"}, negotiated: "", version: "1"},
+	}
+
+	for _, tt := range tests {
+		dialer := websocket.Dialer{Subprotocols: tt.requested}
+
+		client, _, err := dialer.Dial("ws"+srv.URL[len("http"):], nil)
+		assert.Nil(t, err)
+
+		assert.Equal(t, tt.negotiated, client.Subprotocol())
+
+		info := <-infos
+		assert.Equal(t, tt.version, info.Protocol)
+
+		if tt.negotiated != "" {
+			assert.Equal(t, tt.negotiated, (*info.Headers)["sec-websocket-protocol"])
+		} else {
+			assert.NotContains(t, *info.Headers, "sec-websocket-protocol")
+		}
+
+		client.Close()
+	}
+}
+
+func TestWebsocketHandlerEnvMeta(t *testing.T) {
+	config := NewConfig()
+	config.NodeID = "node-1"
+	infos := make(chan *RequestInfo, 1)
+
+	handler := func() http.Handler {
+		return WebsocketHandler([]string{}, &config, func(conn *websocket.Conn, info *RequestInfo, callback func()) error {
+			infos <- info
+			return nil
+		})
+	}
+
+	t.Run("With all entries over TLS", func(t *testing.T) {
+		srv := httptest.NewTLSServer(handler())
+		defer srv.Close()
+
+		dialer := websocket.Dialer{
+			Subprotocols:    []string{"actioncable-v1-json"},
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // #nosec
+		}
+
+		client, _, err := dialer.Dial("wss"+srv.URL[len("https"):], nil)
+		assert.Nil(t, err)
+		defer client.Close()
+
+		info := <-infos
+		headers := *info.Headers
+
+		assert.True(t, info.TLS)
+		assert.Equal(t, "node-1", info.NodeID)
+		assert.Equal(t, "127.0.0.1", headers["REMOTE_ADDR"])
+		assert.Equal(t, "on", headers["ANYCABLE_TLS"])
+		assert.Equal(t, info.TLSVersion, headers["ANYCABLE_TLS_VERSION"])
+		assert.Contains(t, info.TLSVersion, "TLSv1.")
+		assert.Equal(t, "actioncable-v1-json", headers["sec-websocket-protocol"])
+		assert.Equal(t, "node-1", headers["ANYCABLE_NODE_ID"])
+	})
+
+	t.Run("Without remote address", func(t *testing.T) {
+		config.EnvMeta = "tls"
+
+		srv := httptest.NewServer(handler())
+		defer srv.Close()
+
+		dialer := websocket.Dialer{Subprotocols: []string{"actioncable-v1-json"}}
+
+		client, _, err := dialer.Dial("ws"+srv.URL[len("http"):], nil)
+		assert.Nil(t, err)
+		defer client.Close()
+
+		info := <-infos
+		headers := *info.Headers
+
+		assert.False(t, info.TLS)
+		assert.Equal(t, "127.0.0.1", info.RemoteIP)
+		assert.Equal(t, map[string]string{"ANYCABLE_TLS": "off"}, headers)
+	})
+}
+
+func TestParseEnvMeta(t *testing.T) {
+	entries, err := ParseEnvMeta(" remote_addr, tls ")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"remote_addr": true, "tls": true}, entries)
+
+	entries, err = ParseEnvMeta("")
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+
+	_, err = ParseEnvMeta("remote_addr,ip")
+	assert.NotNil(t, err)
+}
+
+func TestWebsocketHandlerUpgradeFailed(t *testing.T) {
+	failed := 0
+
+	config := NewConfig()
+	config.OnUpgradeFailed = func() { failed++ }
+
+	handler := WebsocketHandler([]string{}, &config, func(conn *websocket.Conn, info *RequestInfo, callback func()) error {
+		return nil
+	})
+
+	// Not a WebSocket handshake request
+	req := httptest.NewRequest("GET", "/cable", nil)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, 1, failed)
+}
@@ -0,0 +1,27 @@
+package ws
+
+// Supported WebSocket subprotocols
+const (
+	ActionCableV1JSON  = "actioncable-v1-json"
+	ActionCableV11JSON = "actioncable-v1.1-json"
+)
+
+// Protocol versions
+const (
+	ProtocolV1  = "1"
+	ProtocolV11 = "1.1"
+)
+
+// Subprotocols contains all supported subprotocols
+var Subprotocols = []string{ActionCableV11JSON, ActionCableV1JSON}
+
+// ProtocolVersion returns the protocol version for the negotiated subprotocol.
+// The base version is returned for unknown (or missing) subprotocols
+func ProtocolVersion(subprotocol string) string {
+	switch subprotocol {
+	case ActionCableV11JSON:
+		return ProtocolV11
+	default:
+		return ProtocolV1
+	}
+}
@@ -0,0 +1,122 @@
+package ws
+
+import (
+	"container/list"
+	"net"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket rate limiter keyed by IP.
+// The number of tracked IPs is bounded (least recently used buckets are evicted).
+type RateLimiter struct {
+	rate     float64
+	burst    float64
+	capacity int
+	exempt   []*net.IPNet
+
+	buckets map[string]*list.Element
+	lru     *list.List
+	mu      sync.Mutex
+
+	now func() time.Time
+}
+
+type rateBucket struct {
+	ip     string
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter allowing limit requests per interval (with the specified burst) per IP
+func NewRateLimiter(limit int, interval time.Duration, burst int, capacity int, exempt []*net.IPNet) *RateLimiter {
+	if burst < limit {
+		burst = limit
+	}
+
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	return &RateLimiter{
+		rate:     float64(limit) / interval.Seconds(),
+		burst:    float64(burst),
+		capacity: capacity,
+		exempt:   exempt,
+		buckets:  make(map[string]*list.Element),
+		lru:      list.New(),
+		now:      time.Now,
+	}
+}
+
+// Allow returns true if the request from the IP is allowed
+func (l *RateLimiter) Allow(ip string) bool {
+	if l.isExempt(ip) {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	var bucket *rateBucket
+
+	if el, ok := l.buckets[ip]; ok {
+		l.lru.MoveToFront(el)
+		bucket = el.Value.(*rateBucket)
+
+		bucket.tokens += now.Sub(bucket.last).Seconds() * l.rate
+
+		if bucket.tokens > l.burst {
+			bucket.tokens = l.burst
+		}
+
+		bucket.last = now
+	} else {
+		bucket = &rateBucket{ip: ip, tokens: l.burst, last: now}
+		l.buckets[ip] = l.lru.PushFront(bucket)
+
+		if l.lru.Len() > l.capacity {
+			oldest := l.lru.Back()
+			l.lru.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*rateBucket).ip)
+		}
+	}
+
+	if bucket.tokens < 1 {
+		return false
+	}
+
+	bucket.tokens--
+
+	return true
+}
+
+// Size returns the number of tracked IPs
+func (l *RateLimiter) Size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.lru.Len()
+}
+
+func (l *RateLimiter) isExempt(addr string) bool {
+	if len(l.exempt) == 0 {
+		return false
+	}
+
+	ip := net.ParseIP(addr)
+
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range l.exempt {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,48 @@
+package ws
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+
+	_, exempt, _ := net.ParseCIDR("10.0.0.0/8")
+
+	limiter := NewRateLimiter(2, time.Second, 3, 2, []*net.IPNet{exempt})
+	limiter.now = func() time.Time { return now }
+
+	t.Run("Allows burst and refills tokens", func(t *testing.T) {
+		assert.True(t, limiter.Allow("1.1.1.1"))
+		assert.True(t, limiter.Allow("1.1.1.1"))
+		assert.True(t, limiter.Allow("1.1.1.1"))
+		assert.False(t, limiter.Allow("1.1.1.1"))
+
+		now = now.Add(500 * time.Millisecond)
+
+		assert.True(t, limiter.Allow("1.1.1.1"))
+		assert.False(t, limiter.Allow("1.1.1.1"))
+	})
+
+	t.Run("Tracks IPs independently", func(t *testing.T) {
+		assert.True(t, limiter.Allow("2.2.2.2"))
+	})
+
+	t.Run("Ignores exempt IPs", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			assert.True(t, limiter.Allow("10.0.0.1"))
+		}
+	})
+
+	t.Run("Evicts least recently used IPs", func(t *testing.T) {
+		assert.True(t, limiter.Allow("3.3.3.3"))
+		assert.Equal(t, 2, limiter.Size())
+
+		// 1.1.1.1 has been evicted and gets a new bucket
+		assert.True(t, limiter.Allow("1.1.1.1"))
+	})
+}
@@ -0,0 +1,135 @@
+package ws
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+)
+
+const (
+	// SlowStartLinear increases the admission rate by the initial rate every second
+	SlowStartLinear = "linear"
+	// SlowStartExponential doubles the admission rate every tenth of the slow start duration
+	SlowStartExponential = "exponential"
+
+	// The max Retry-After value (seconds) for rejected connections (the actual value is randomized)
+	slowStartMaxRetryAfter = 5
+)
+
+// SlowStart is a boot-time admission controller: it limits the number of accepted connections
+// per second during the slow start period with the rate ramping up to unlimited
+type SlowStart struct {
+	initialRate float64
+	duration    time.Duration
+	ramp        string
+	startedAt   time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	now func() time.Time
+}
+
+// NewSlowStart creates a slow start admission controller starting now
+func NewSlowStart(config *Config) *SlowStart {
+	now := time.Now()
+
+	return &SlowStart{
+		initialRate: float64(config.SlowStartRate),
+		duration:    time.Duration(config.SlowStartDuration) * time.Second,
+		ramp:        config.SlowStartRamp,
+		startedAt:   now,
+		tokens:      float64(config.SlowStartRate),
+		last:        now,
+		now:         time.Now,
+	}
+}
+
+// Rate returns the current admission rate (connections per second); 0 means unlimited
+func (s *SlowStart) Rate() float64 {
+	return s.rateAt(s.now())
+}
+
+// Allow returns true if a new connection could be accepted
+func (s *SlowStart) Allow() bool {
+	now := s.now()
+	rate := s.rateAt(now)
+
+	if rate == 0 {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens += now.Sub(s.last).Seconds() * rate
+	s.last = now
+
+	// Allow bursts up to one second worth of connections
+	if burst := math.Max(rate, 1); s.tokens > burst {
+		s.tokens = burst
+	}
+
+	if s.tokens < 1 {
+		return false
+	}
+
+	s.tokens--
+
+	return true
+}
+
+// RetryAfter returns a randomized number of seconds for rejected clients to wait before reconnecting
+func (s *SlowStart) RetryAfter() int {
+	max := slowStartMaxRetryAfter
+
+	if remaining := int(s.duration.Seconds()-s.now().Sub(s.startedAt).Seconds()) + 1; remaining < max {
+		max = remaining
+	}
+
+	if max < 1 {
+		return 1
+	}
+
+	return 1 + rand.Intn(max) // nolint:gosec
+}
+
+func (s *SlowStart) rateAt(now time.Time) float64 {
+	elapsed := now.Sub(s.startedAt)
+
+	if elapsed >= s.duration {
+		return 0
+	}
+
+	if s.ramp == SlowStartExponential {
+		return s.initialRate * math.Pow(2, 10*elapsed.Seconds()/s.duration.Seconds())
+	}
+
+	return s.initialRate * (1 + elapsed.Seconds())
+}
+
+// SlowStartHandler wraps the handler with the slow start admission control:
+// excess requests are rejected with 503 and the Retry-After header
+func SlowStartHandler(handler http.Handler, s *SlowStart, config *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.Allow() {
+			log.WithField("context", "ws").Debugf("WebSocket connection rejected: slow start rate exceeded")
+
+			if config.OnSlowStartRejected != nil {
+				config.OnSlowStartRejected()
+			}
+
+			w.Header().Set("Retry-After", strconv.Itoa(s.RetryAfter()))
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlowStart(t *testing.T) {
+	config := NewConfig()
+	config.SlowStartDuration = 10
+	config.SlowStartRate = 2
+
+	slowStart := NewSlowStart(&config)
+	now := slowStart.startedAt
+	slowStart.now = func() time.Time { return now }
+
+	t.Run("Limits admission rate", func(t *testing.T) {
+		assert.Equal(t, 2.0, slowStart.Rate())
+
+		assert.True(t, slowStart.Allow())
+		assert.True(t, slowStart.Allow())
+		assert.False(t, slowStart.Allow())
+	})
+
+	t.Run("Ramps up linearly", func(t *testing.T) {
+		now = now.Add(time.Second)
+
+		assert.Equal(t, 4.0, slowStart.Rate())
+
+		for i := 0; i < 4; i++ {
+			assert.True(t, slowStart.Allow())
+		}
+
+		assert.False(t, slowStart.Allow())
+	})
+
+	t.Run("Randomizes retry after", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			retry := slowStart.RetryAfter()
+
+			assert.GreaterOrEqual(t, retry, 1)
+			assert.LessOrEqual(t, retry, slowStartMaxRetryAfter)
+		}
+	})
+
+	t.Run("Becomes unlimited after the duration", func(t *testing.T) {
+		now = now.Add(10 * time.Second)
+
+		assert.Equal(t, 0.0, slowStart.Rate())
+
+		for i := 0; i < 100; i++ {
+			assert.True(t, slowStart.Allow())
+		}
+	})
+}
+
+func TestSlowStartExponential(t *testing.T) {
+	config := NewConfig()
+	config.SlowStartDuration = 10
+	config.SlowStartRate = 2
+	config.SlowStartRamp = SlowStartExponential
+
+	slowStart := NewSlowStart(&config)
+	now := slowStart.startedAt
+	slowStart.now = func() time.Time { return now }
+
+	assert.Equal(t, 2.0, slowStart.Rate())
+
+	now = now.Add(3 * time.Second)
+	assert.Equal(t, 16.0, slowStart.Rate())
+}
+
+func TestSlowStartHandler(t *testing.T) {
+	config := NewConfig()
+	config.SlowStartDuration = 10
+	config.SlowStartRate = 1
+
+	rejected := 0
+	config.OnSlowStartRejected = func() { rejected++ }
+
+	slowStart := NewSlowStart(&config)
+	now := slowStart.startedAt
+	slowStart.now = func() time.Time { return now }
+
+	handler := SlowStartHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}), slowStart, &config)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/cable", nil))
+	assert.Equal(t, http.StatusSwitchingProtocols, w.Code)
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/cable", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, 1, rejected)
+
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, retry, 1)
+}
@@ -1,6 +1,12 @@
 package ws
 
-import "github.com/gorilla/websocket"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/anycable/anycable-go/utils"
+	"github.com/gorilla/websocket"
+)
 
 const (
 	// CloseNormalClosure indicates normal closure
@@ -14,6 +20,27 @@ const (
 
 	// CloseGoingAway indicates closing because of server shuts down or client disconnects
 	CloseGoingAway = websocket.CloseGoingAway
+
+	// CloseMessageTooBig indicates closing because of the incoming message exceeds the size limit
+	CloseMessageTooBig = websocket.CloseMessageTooBig
+
+	// CloseTryAgainLater indicates closing because of temporary unavailability of the server dependencies
+	CloseTryAgainLater = websocket.CloseTryAgainLater
+
+	// CloseUnauthorized indicates closing because of failed authentication
+	CloseUnauthorized = 4001
+
+	// CloseTokenExpired indicates closing because the session credentials couldn't be refreshed
+	CloseTokenExpired = 4002
+
+	// CloseDeliveryFailed indicates closing because the client hasn't acknowledged messages in time
+	CloseDeliveryFailed = 4008
+
+	// ClosePongTimeout indicates closing because the client hasn't responded to pings in time
+	ClosePongTimeout = 4009
+
+	// CloseTooManyRequests indicates closing because of exceeded limits
+	CloseTooManyRequests = 4029
 )
 
 var (
@@ -37,8 +64,53 @@ type SentFrame struct {
 	Payload     []byte
 	CloseCode   int
 	CloseReason string
+	// Whether the payload has been taken from the bytes pool
+	pooled bool
+}
+
+// NewPooledFrame creates a frame with the payload taken from the default bytes pool (see utils.GetBytes).
+// Pooled frames must not be shared between sessions.
+func NewPooledFrame(frameType FrameType, payload []byte) *SentFrame {
+	return &SentFrame{FrameType: frameType, Payload: payload, pooled: true}
+}
+
+// Release returns the payload to the bytes pool (if it has been taken from it).
+// The frame must not be used after that.
+func (f *SentFrame) Release() {
+	if !f.pooled {
+		return
+	}
+
+	utils.PutBytes(f.Payload)
+	f.Payload = nil
+	f.pooled = false
 }
 
 func IsCloseError(err error) bool {
 	return websocket.IsCloseError(err, expectedCloseStatuses...)
 }
+
+// MessageTooBigError is returned when the incoming message exceeds the max message size
+type MessageTooBigError struct {
+	// The size of the message in bytes (or its lower bound if the message hasn't been read till the end)
+	Size int64
+	// The max message size
+	Limit int64
+	// Whether the whole message has been read (and thus Size is exact)
+	Exact bool
+}
+
+func (e *MessageTooBigError) Error() string {
+	if e.Exact {
+		return fmt.Sprintf("message size %d exceeds the limit of %d bytes", e.Size, e.Limit)
+	}
+
+	return fmt.Sprintf("message size is more than %d bytes (limit: %d bytes)", e.Size, e.Limit)
+}
+
+// IsMessageTooBigError returns true if the error is caused by exceeding the read limit
+func IsMessageTooBigError(err error) bool {
+	var tooBig *MessageTooBigError
+
+	return err == websocket.ErrReadLimit || errors.As(err, &tooBig)
+}